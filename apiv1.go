@@ -1,18 +1,26 @@
 package qrcodeapi
 
 import (
-	"image/gif"
-	"image/jpeg"
-	"image/png"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"path/filepath"
 	"strings"
 
 	"github.com/emersion/go-vcard"
 	"github.com/labstack/echo/v4"
+	"github.com/whitekid/goxp/fx"
 	"github.com/whitekid/goxp/request"
 
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+	"qrcodeapi/pkg/links"
 	"qrcodeapi/pkg/qrcode"
 )
 
@@ -20,54 +28,52 @@ type router interface {
 	Route(e *echo.Echo, path string)
 }
 
-type APIv1 struct{}
+type APIv1 struct {
+	cfg   *config.Config
+	codes codes.Store // store for persisted codes
+	links links.Store // store for shortened urls
+}
 
 var _ router = (*APIv1)(nil)
 
-func newAPIv1() router { return &APIv1{} }
+// newAPIv1 create api; persisted codes and shortened urls are disabled if the store is nil
+func newAPIv1(cfg *config.Config, codeStore codes.Store, linkStore links.Store) router {
+	return &APIv1{cfg: cfg, codes: codeStore, links: linkStore}
+}
 
 func (api *APIv1) Route(e *echo.Echo, path string) {
 	v1 := e.Group(path)
 
 	v1.GET("/qrcode", api.handleGenerate)
+	v1.GET("/qrcode.:ext", withPathFormat(api.handleGenerate))
+	v1.GET("/qrcode@:scale", withPathScale(api.handleGenerate))
+	v1.GET("/qrcode/capacities", api.handleCapacities)
+	v1.GET("/qrcode/encoding", api.handleEncoding)
+	v1.GET("/qrcode/*", api.handlePathContent)
+	v1.POST("/qrcode", api.handleDocument)
+	v1.POST("/batch", api.handleBatch)
+	v1.POST("/batch/sequence", api.handleBatchSequence)
+	v1.GET("/batch/:id", api.handleBatchResult)
+	v1.POST("/jobs", api.handleCreateJob)
+	v1.GET("/jobs/:id", api.handleJob)
+	v1.DELETE("/jobs/:id", api.handleCancelJob)
+	v1.GET("/jobs/:id/events", api.handleJobEvents)
+	v1.GET("/auto", api.handleAuto)
+	v1.GET("/wifi", api.handleWifi)
+	v1.GET("/url", api.handleURL)
 	v1.GET("/contact", api.handleContact)
+	v1.POST("/contact", api.handleContactJSON)
 	v1.POST("/vcard", api.handleContactVCard)
 	v1.POST("/vevent", api.handleVEvent)
-}
-
-type RenderRequest struct {
-	W int    `query:"w"`
-	H int    `query:"h"`
-	T string `query:"t"`
-}
-
-func (api *APIv1) renderQRCode(c echo.Context, in *qrcode.QR) error {
-	// NOTE c.Bind()는 Post에서 동작하지 않음
-	req := &RenderRequest{
-		W: parseIntDef(c.QueryParam("w"), 200, 21, 200),
-		H: parseIntDef(c.QueryParam("h"), 200, 21, 200),
-		T: c.QueryParam("t"),
-	}
-
-	img, err := in.Render(req.W, req.H)
-	if err != nil {
-		return err
-	}
-
-	switch strings.ToLower(req.T) {
-	case "jpeg", "jpg":
-		return jpeg.Encode(c.Response().Writer, img, nil)
-	case "gif":
-		return gif.Encode(c.Response().Writer, img, nil)
-	default:
-		return png.Encode(c.Response().Writer, img)
-	}
+	v1.GET("/event", api.handleEvent)
+	v1.POST("/decode", api.handleDecode)
 }
 
 type GenerateRequest struct {
-	Content string `query:"content"`
-	URL     string `query:"url"`
-	SSID    string `query:"ssid"`
+	Content    string `query:"content"`
+	URL        string `query:"url"`
+	UseReferer bool   `query:"useReferer"`
+	SSID       string `query:"ssid"`
 }
 
 func (api *APIv1) handleGenerate(c echo.Context) error {
@@ -76,32 +82,288 @@ func (api *APIv1) handleGenerate(c echo.Context) error {
 		return err
 	}
 
+	if req.SSID != "" {
+		if req.Content != "" || req.URL != "" || req.UseReferer {
+			return echo.NewHTTPError(http.StatusBadRequest, "ssid can not be used with content, url or useReferer")
+		}
+
+		// wifi on /qrcode is kept for compatibility, use /wifi instead
+		c.Response().Header().Set("Deprecation", "true")
+		c.Response().Header().Set("Link", `</v1/wifi>; rel="successor-version"`)
+		return api.handleWifi(c)
+	}
+
 	switch {
 	case req.Content != "":
+		c.Set(ctxKeyPayloadKind, kindText)
 		qr, err := qrcode.Text(req.Content)
 		if err != nil {
 			return err
 		}
-		return api.renderQRCode(c, qr)
+		return api.render(c, qr)
 
 	case req.URL != "":
-		qr, err := qrcode.Text("URLTO:" + req.URL)
+		c.Set(ctxKeyPayloadKind, kindURL)
+		if err := checkURLParam(api.cfg, c, req.URL); err != nil {
+			return err
+		}
+		return api.renderURL(c, req.URL)
+
+	case req.UseReferer:
+		c.Response().Header().Add(echo.HeaderVary, "Referer")
+		referer, err := url.Parse(c.Request().Referer())
+		if err != nil || (referer.Scheme != "http" && referer.Scheme != "https") || referer.Host == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid referer")
+		}
+
+		c.Set(ctxKeyPayloadKind, kindURL)
+		prefix, err := urlPayloadPrefix(c.QueryParam("payload"), api.cfg.URLPayload)
 		if err != nil {
 			return err
 		}
-		return api.renderQRCode(c, qr)
 
-	case req.SSID != "":
-		return api.handleWifi(c)
+		qr, err := qrcode.Text(prefix + referer.String())
+		if err != nil {
+			return err
+		}
+		return api.render(c, qr)
 	}
 
 	return echo.NewHTTPError(http.StatusBadRequest)
 }
 
+// maxURLLength limit of the url target, in bytes
+const maxURLLength = 2048
+
+// url payload formats
+const (
+	urlPayloadRaw   = "raw"   // url as is, scanned as url by most readers
+	urlPayloadURLTO = "urlto" // `URLTO:<url>`, default of `/qrcode?url=` by url_payload config
+)
+
+// urlPayloadPrefix returns prefix of the url payload format; defaultPayload is used if the format is not given
+func urlPayloadPrefix(payload string, defaultPayload string) (string, error) {
+	if payload == "" {
+		payload = defaultPayload
+	}
+
+	switch strings.ToLower(payload) {
+	case urlPayloadRaw:
+		return "", nil
+	case urlPayloadURLTO:
+		return "URLTO:", nil
+	}
+	return "", invalidParam("payload", payload)
+}
+
+type URLRequest struct {
+	Target  string `query:"target" validate:"required"`
+	Payload string `query:"payload"`
+	Preview bool   `query:"preview"`
+	Shorten bool   `query:"shorten"`
+}
+
+// handleURL encode validated url, `GET /url?target=<url>`; `shorten=true` encodes short url of the target.
+// `preview=true` returns the payload as text instead of the image.
+func (api *APIv1) handleURL(c echo.Context) error {
+	c.Set(ctxKeyPayloadKind, kindURL)
+
+	req := &URLRequest{}
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	if len(req.Target) > maxURLLength {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("target is longer than %d bytes", maxURLLength))
+	}
+
+	if err := validateURL(req.Target, api.cfg.URLSchemes); err != nil {
+		return err
+	}
+
+	if err := checkTarget(api.cfg, c, req.Target); err != nil {
+		return err
+	}
+
+	// /url encodes the url as is unless requested, regardless of url_payload config
+	prefix, err := urlPayloadPrefix(req.Payload, urlPayloadRaw)
+	if err != nil {
+		return err
+	}
+
+	target := req.Target
+	if req.Shorten {
+		if target, err = api.shortenURL(c, req.Target, shortenAlways); err != nil {
+			return err
+		}
+	}
+	payload := prefix + target
+
+	if req.Preview {
+		return c.String(http.StatusOK, payload)
+	}
+
+	qr, err := qrcode.Text(payload)
+	if err != nil {
+		return err
+	}
+	return api.render(c, qr)
+}
+
+// capacities reference table of the encoder, it does not change while the server runs
+var capacities = &CapacitiesResponse{Capacities: qrcode.Capacities()}
+
+type CapacitiesResponse struct {
+	Capacities []qrcode.Capacity `json:"capacities"`
+}
+
+// handleCapacities returns the largest number of characters of each mode per version and error correction level
+func (api *APIv1) handleCapacities(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=86400")
+	return c.JSON(http.StatusOK, capacities)
+}
+
+type EncodingResponse struct {
+	Version         int      `json:"version"`
+	ErrorCorrection string   `json:"ec"`
+	Mode            string   `json:"mode"`
+	Mask            int      `json:"mask"`
+	DataCodewords   string   `json:"data_codewords"` // hex
+	Codewords       string   `json:"codewords"`      // hex, interleaved with error correction codewords
+	Matrix          []string `json:"matrix"`         // rows of `1` for dark and `0` for light modules, without quiet zone
+}
+
+// handleEncoding returns codewords and module matrix of the content with render parameters, to debug encoding decisions;
+// enabled by debug_encoding config.
+func (api *APIv1) handleEncoding(c echo.Context) error {
+	if !api.cfg.DebugEncoding {
+		return echo.ErrNotFound
+	}
+
+	content := c.QueryParam("content")
+	if content == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "content is required")
+	}
+
+	params, err := renderParams(api.cfg, c)
+	if err != nil {
+		return err
+	}
+
+	req, err := parseRenderRequest(api.cfg, params)
+	if err != nil {
+		return err
+	}
+
+	if err := checkPayload(api.cfg, c, content); err != nil {
+		return err
+	}
+
+	qr, err := qrcode.Text(content)
+	if err != nil {
+		return err
+	}
+
+	enc, err := qr.Encoding(req.options())
+	if err != nil {
+		return renderError(err)
+	}
+
+	resp := &EncodingResponse{
+		Version:         enc.Version,
+		ErrorCorrection: enc.ErrorCorrection,
+		Mode:            enc.Mode,
+		Mask:            enc.Mask,
+		DataCodewords:   hex.EncodeToString(enc.DataCodewords),
+		Codewords:       hex.EncodeToString(enc.Codewords),
+		Matrix:          make([]string, len(enc.Matrix)),
+	}
+	for y, row := range enc.Matrix {
+		b := make([]byte, len(row))
+		for x, dark := range row {
+			b[x] = '0'
+			if dark {
+				b[x] = '1'
+			}
+		}
+		resp.Matrix[y] = string(b)
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, resp)
+}
+
+// maxPathContentLength limit of the content in the path, in bytes
+const maxPathContentLength = 1024
+
+// handlePathContent encode the path segment as text, `/qrcode/<content>[.<ext>]`;
+// content is always literal text and extension of known format selects output format.
+func (api *APIv1) handlePathContent(c echo.Context) error {
+	// echo unescapes the path unless it has encoded slash, so use the escaped path to decode consistently
+	segment := strings.TrimPrefix(c.Request().URL.EscapedPath(), strings.TrimSuffix(c.Path(), "*"))
+	if segment == "" || strings.Contains(segment, "/") {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+
+	if i := strings.LastIndex(segment, "."); i > 0 {
+		if f := formatByName(segment[i+1:]); f != nil {
+			c.Set(ctxKeyPathFormat, f.name)
+			segment = segment[:i]
+		}
+	}
+
+	content, err := url.PathUnescape(segment)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid path: "+err.Error())
+	}
+
+	if len(content) > maxPathContentLength {
+		return echo.NewHTTPError(http.StatusRequestURITooLong, fmt.Sprintf("content is longer than %d bytes", maxPathContentLength))
+	}
+
+	c.Set(ctxKeyPayloadKind, kindText)
+	qr, err := qrcode.Text(content)
+	if err != nil {
+		return err
+	}
+	return api.render(c, qr)
+}
+
+type AutoRequest struct {
+	Content string `query:"content" validate:"required"`
+	Detect  string `query:"detect"`
+}
+
+// handleAuto detect payload type of the content and encode it formatted for the type;
+// detected type is returned in X-QR-Type header. `detect=false` encodes content as text.
+func (api *APIv1) handleAuto(c echo.Context) error {
+	req := &AutoRequest{}
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	typ, qr := qrcode.Detect(req.Content)
+	if req.Detect == "false" {
+		typ, qr = qrcode.TypeText, &qrcode.QR{Content: req.Content}
+	}
+
+	c.Set(ctxKeyPayloadKind, typ)
+	c.Response().Header().Set("X-QR-Type", typ)
+	return api.render(c, qr)
+}
+
 type WIFIRequest struct {
 	SSID   string `query:"ssid" validate:"required"`
 	Auth   string `query:"auth" validate:"required"`
-	Pass   string `query:"pass"`
+	Pass   string `query:"pass" sensitive:"true"`
 	Hidden string `query:"hidden"`
 	EAP    string `query:"eap"`
 	AnonID string `query:"anon"`
@@ -109,7 +371,34 @@ type WIFIRequest struct {
 	PH2    string `query:"ph2"`
 }
 
+// document returns wifi document of the request; hidden is omitted if it is neither true nor false
+func (req *WIFIRequest) document() *WifiDocument {
+	wifi := &WifiDocument{
+		SSID:   req.SSID,
+		Auth:   req.Auth,
+		Pass:   req.Pass,
+		EAP:    req.EAP,
+		AnonID: req.AnonID,
+		Ident:  req.Ident,
+		PH2:    req.PH2,
+	}
+
+	switch req.Hidden {
+	case "true":
+		v := true
+		wifi.Hidden = &v
+	case "false", "":
+		v := false
+		wifi.Hidden = &v
+	}
+
+	return wifi
+}
+
+// handleWifi encode wifi join payload, `GET /wifi?ssid=&auth=&pass=...`
 func (api *APIv1) handleWifi(c echo.Context) error {
+	c.Set(ctxKeyPayloadKind, kindWifi)
+
 	req := &WIFIRequest{}
 	if err := c.Bind(req); err != nil {
 		return err
@@ -119,28 +408,12 @@ func (api *APIv1) handleWifi(c echo.Context) error {
 		return err
 	}
 
-	hidden := (*bool)(nil)
-	switch req.Hidden {
-	case "true":
-		v := true
-		hidden = &v
-	case "false", "":
-		v := false
-		hidden = &v
-	}
-
-	qr, err := qrcode.WIFI(req.SSID,
-		qrcode.StrToWifiAuth(req.Auth), req.Pass, hidden,
-		qrcode.WPA2Options{
-			EAPMethod:         req.EAP,
-			AnonymousIdentity: req.AnonID,
-			Identity:          req.Ident,
-			Phase2Method:      req.PH2})
+	qr, err := wifiPayload(req.document())
 	if err != nil {
 		return err
 	}
 
-	return api.renderQRCode(c, qr)
+	return api.render(c, qr)
 }
 
 type ContactRequest struct {
@@ -157,6 +430,7 @@ type ContactRequest struct {
 	EmailWork string `query:"email[work]"`
 
 	Tel     string `query:"tel"`
+	TelCell string `query:"tel[cell]"` // mobile if not given
 	TelHome string `query:"tel[home]"`
 	TelWork string `query:"tel[work]"`
 	Mobile  string `query:"mobile"`
@@ -183,9 +457,23 @@ type ContactRequest struct {
 	} `validate:"dive"`
 
 	Note string `query:"note"`
+
+	Version     string `query:"version"`     // vcard version, 3.0 or 4.0
+	Birthday    string `query:"bday"`        // YYYY-MM-DD, or --MM-DD if the year is unknown
+	Anniversary string `query:"anniversary"` // YYYY-MM-DD, or --MM-DD if the year is unknown
+}
+
+// parseContactDate returns nil if the date is not given
+func parseContactDate(s string) (*qrcode.Date, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return qrcode.ParseDate(s)
 }
 
 func (api *APIv1) handleContact(c echo.Context) error {
+	c.Set(ctxKeyPayloadKind, kindContact)
+
 	req := &ContactRequest{}
 	if err := c.Bind(req); err != nil {
 		return err
@@ -195,7 +483,36 @@ func (api *APIv1) handleContact(c echo.Context) error {
 		return err
 	}
 
+	version, err := qrcode.ParseVCardVersion(req.Version)
+	if err != nil {
+		return invalidParam("version", req.Version)
+	}
+
+	if req.TelCell == "" {
+		req.TelCell = req.Mobile
+	}
+	for _, tel := range []struct{ name, number string }{
+		{"tel", req.Tel}, {"tel[cell]", req.TelCell}, {"tel[home]", req.TelHome}, {"tel[work]", req.TelWork},
+		{"fax[home]", req.FaxHome}, {"fax[work]", req.FaxWork}, {"pager", req.Pager},
+	} {
+		if tel.number != "" && !qrcode.ValidPhoneNumber(tel.number) {
+			return invalidParam(tel.name, tel.number)
+		}
+	}
+
+	birthday, err := parseContactDate(req.Birthday)
+	if err != nil {
+		return invalidParam("bday", req.Birthday)
+	}
+
+	anniversary, err := parseContactDate(req.Anniversary)
+	if err != nil {
+		return invalidParam("anniversary", req.Anniversary)
+	}
+
 	qr, err := qrcode.Contact(&qrcode.Card{
+		Version: version,
+
 		FirstName:  req.FirstName,
 		LastName:   req.LastName,
 		MiddleName: req.MiddleName,
@@ -209,7 +526,7 @@ func (api *APIv1) handleContact(c echo.Context) error {
 		WorkEmail: req.EmailWork,
 
 		Tel:     req.Tel,
-		Mobile:  req.Mobile,
+		Mobile:  req.TelCell,
 		HomeTel: req.TelHome,
 		WorkTel: req.TelWork,
 
@@ -235,27 +552,249 @@ func (api *APIv1) handleContact(c echo.Context) error {
 		},
 
 		Note: req.Note,
+
+		Birthday:    birthday,
+		Anniversary: anniversary,
 	})
 	if err != nil {
 		return err
 	}
 
-	return api.renderQRCode(c, qr)
+	if err := setLineEnding(c, qr); err != nil {
+		return err
+	}
+
+	return api.render(c, qr)
+}
+
+// ContactJSONRequest json contact of `POST /contact`
+type ContactJSONRequest struct {
+	Name struct {
+		First  string `json:"first"`
+		Last   string `json:"last"`
+		Middle string `json:"middle"`
+		Prefix string `json:"prefix"`
+		Suffix string `json:"suffix"`
+	} `json:"name"`
+
+	Company    string `json:"company"`
+	Department string `json:"department"`
+	Title      string `json:"title"`
+
+	Phones []struct {
+		Number string `json:"number"`
+		Type   string `json:"type"` // cell, home, work, fax, pager, voice, text, video
+	} `json:"phones"`
+	Emails []struct {
+		Address string `json:"address"`
+		Type    string `json:"type"` // home, work
+	} `json:"emails"`
+	Addresses []struct {
+		Type     string `json:"type"` // home, work
+		Street   string `json:"street"`
+		City     string `json:"city"`
+		Province string `json:"province"`
+		PostCode string `json:"postcode"`
+		Country  string `json:"country"`
+	} `json:"addresses"`
+
+	URL  string `json:"url"`
+	Note string `json:"note"`
+
+	Version     string `json:"version"`     // vcard version, 3.0 or 4.0
+	Birthday    string `json:"bday"`        // YYYY-MM-DD, or --MM-DD if the year is unknown
+	Anniversary string `json:"anniversary"` // YYYY-MM-DD, or --MM-DD if the year is unknown
+}
+
+var (
+	phoneTypes = []string{"cell", "home", "work", "fax", "pager", "voice", "text", "video"}
+	placeTypes = []string{"home", "work"}
+)
+
+// vcard build vcard of the contact; type is validated with json path of the field
+func (req *ContactJSONRequest) vcard() (vcard.Card, error) {
+	version, err := qrcode.ParseVCardVersion(req.Version)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("version: invalid value %q", req.Version))
+	}
+
+	card := vcard.Card{}
+	card.SetValue(vcard.FieldVersion, version)
+
+	name := &vcard.Name{
+		Field:           &vcard.Field{},
+		GivenName:       req.Name.First,
+		FamilyName:      req.Name.Last,
+		AdditionalName:  req.Name.Middle,
+		HonorificPrefix: req.Name.Prefix,
+		HonorificSuffix: req.Name.Suffix,
+	}
+	formattedName := strings.Join(fx.Filter([]string{req.Name.Prefix, req.Name.First, req.Name.Middle, req.Name.Last, req.Name.Suffix},
+		func(s string) bool { return s != "" }), " ")
+	if formattedName == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "name: required")
+	}
+	card.SetName(name)
+	card.SetValue(vcard.FieldFormattedName, formattedName)
+
+	if req.Company != "" || req.Department != "" {
+		card.SetValue(vcard.FieldOrganization, strings.TrimSuffix(req.Company+";"+req.Department, ";"))
+	}
+	if req.Title != "" {
+		card.SetValue(vcard.FieldTitle, req.Title)
+	}
+
+	for i, phone := range req.Phones {
+		if phone.Number == "" {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("phones[%d].number: required", i))
+		}
+		if !qrcode.ValidPhoneNumber(phone.Number) {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("phones[%d].number: invalid value %q", i, phone.Number))
+		}
+
+		var types []string
+		if phone.Type != "" {
+			if !fx.Contains(phoneTypes, strings.ToLower(phone.Type)) {
+				return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("phones[%d].type: invalid value %q", i, phone.Type))
+			}
+			types = []string{phone.Type}
+		}
+		// parameters are in the property as json and query contacts are of the same syntax
+		property, value := qrcode.TelProperty(version, phone.Number, types...)
+		card.Add(property, &vcard.Field{Value: value})
+	}
+
+	for i, email := range req.Emails {
+		if email.Address == "" {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("emails[%d].address: required", i))
+		}
+		field := &vcard.Field{Value: email.Address}
+		if email.Type != "" {
+			if !fx.Contains(placeTypes, strings.ToLower(email.Type)) {
+				return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("emails[%d].type: invalid value %q", i, email.Type))
+			}
+			field.Params = vcard.Params{vcard.ParamType: {strings.ToLower(email.Type)}}
+		}
+		card.Add(vcard.FieldEmail, field)
+	}
+
+	for i, addr := range req.Addresses {
+		address := &vcard.Address{
+			Field:         &vcard.Field{},
+			StreetAddress: addr.Street,
+			Locality:      addr.City,
+			Region:        addr.Province,
+			PostalCode:    addr.PostCode,
+			Country:       addr.Country,
+		}
+		if addr.Type != "" {
+			if !fx.Contains(placeTypes, strings.ToLower(addr.Type)) {
+				return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("addresses[%d].type: invalid value %q", i, addr.Type))
+			}
+			address.Params = vcard.Params{vcard.ParamType: {strings.ToLower(addr.Type)}}
+		}
+		card.AddAddress(address)
+	}
+
+	if req.URL != "" {
+		card.SetValue(vcard.FieldURL, req.URL)
+	}
+	if req.Note != "" {
+		card.SetValue(vcard.FieldNote, req.Note)
+	}
+
+	for _, date := range []struct {
+		name  string
+		field string
+		value string
+	}{
+		{"bday", vcard.FieldBirthday, req.Birthday},
+		{"anniversary", qrcode.AnniversaryField(version), req.Anniversary},
+	} {
+		d, err := parseContactDate(date.value)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%s: invalid value %q", date.name, date.value))
+		}
+		if d != nil {
+			card.SetValue(date.field, d.Format(version))
+		}
+	}
+
+	return card, nil
+}
+
+func (api *APIv1) handleContactJSON(c echo.Context) error {
+	c.Set(ctxKeyPayloadKind, kindContact)
+
+	req := &ContactJSONRequest{}
+	if err := json.NewDecoder(c.Request().Body).Decode(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	card, err := req.vcard()
+	if err != nil {
+		return err
+	}
+
+	qr, err := qrcode.VCard(card)
+	if err != nil {
+		return err
+	}
+
+	if err := setLineEnding(c, qr); err != nil {
+		return err
+	}
+
+	return api.render(c, qr)
 }
 
 const (
 	mimeVCard  = "text/vcard"
 	mimeVEvent = "text/vevent"
+
+	maxVCFSize = 1 << 20 // uploaded vcf file
 )
 
+// vcfTypes media types of uploaded vcf file other than text/vcard
+var vcfTypes = []string{mimeVCard, "text/x-vcard", "text/directory"}
+
+// handleContactVCard encode vcard of text/vcard body or `file` part of multipart/form-data;
+// `index` or `uid` selects a card of multi-card body, and `all=true` returns zip of codes of every card.
 func (api *APIv1) handleContactVCard(c echo.Context) error {
-	if mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(request.HeaderContentType)); mediaType != mimeVCard {
+	c.Set(ctxKeyPayloadKind, kindVCard)
+
+	var body io.Reader
+	switch mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(request.HeaderContentType)); mediaType {
+	case mimeVCard:
+		body = c.Request().Body
+	case echo.MIMEMultipartForm:
+		f, err := vcfFile(c)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		body = f
+	default:
 		return echo.NewHTTPError(http.StatusBadRequest)
 	}
 
-	card, err := vcard.NewDecoder(c.Request().Body).Decode()
+	sel, err := parseVCardSelector(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		return err
+	}
+
+	cards, err := decodeVCards(body)
+	if err != nil {
+		return err
+	}
+
+	if sel.all {
+		return renderVCardArchive(api.cfg, c, cards)
+	}
+
+	card, err := sel.pick(cards)
+	if err != nil {
+		return err
 	}
 
 	qr, err := qrcode.VCard(card)
@@ -263,25 +802,166 @@ func (api *APIv1) handleContactVCard(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	return api.renderQRCode(c, qr)
+	return api.render(c, qr)
 }
 
+// vcfFile returns the uploaded `file` part; it should be .vcf file or of vcard media type
+func vcfFile(c echo.Context) (multipart.File, error) {
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, maxVCFSize)
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxErr):
+			return nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("file: larger than %d bytes", maxVCFSize))
+		case errors.Is(err, http.ErrMissingFile):
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "file: required")
+		}
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(fh.Header.Get(echo.HeaderContentType))
+	if !strings.EqualFold(filepath.Ext(fh.Filename), ".vcf") && !fx.Contains(vcfTypes, mediaType) {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "file: not a vcf file: "+fh.Filename)
+	}
+
+	return fh.Open()
+}
+
+// handleVEvent encode VEVENT of text/vevent body, or VCALENDAR of several VEVENTs of text/calendar body, with CRLF line endings;
+// larger body than the byte capacity of the largest code returns 413.
 func (api *APIv1) handleVEvent(c echo.Context) error {
-	if mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(request.HeaderContentType)); mediaType != mimeVEvent {
+	c.Set(ctxKeyPayloadKind, kindVEvent)
+
+	if mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(request.HeaderContentType)); mediaType != mimeVEvent && mediaType != mimeCalendar {
 		return echo.NewHTTPError(http.StatusBadRequest)
 	}
 
-	body, err := io.ReadAll(c.Request().Body)
+	body, err := io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, maxCalendarSize))
 	defer c.Request().Body.Close()
 	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("larger than %d bytes", maxCalendarSize))
+		}
 		return err
 	}
 
-	content := strings.ReplaceAll(string(body), "\n", "\r\n")
+	if err := validateCalendar(string(body)); err != nil {
+		return err
+	}
+
+	// lines are ended with CRLF by the spec, CRLF of the body is kept
+	content := strings.ReplaceAll(strings.ReplaceAll(string(body), "\r\n", "\n"), "\n", "\r\n")
 	qr, err := qrcode.Text(content)
 	if err != nil {
 		return err
 	}
 
-	return api.renderQRCode(c, qr)
+	if capacity := qrcode.ByteCapacity(api.cfg.MaxVersion, "L"); len(qr.Content) > capacity {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("calendar of %d bytes exceeds %d bytes, the capacity of version %d", len(qr.Content), capacity, api.cfg.MaxVersion))
+	}
+
+	return api.render(c, qr)
+}
+
+type EventRequest struct {
+	Summary     string   `query:"summary" validate:"required"`
+	Start       string   `query:"start" validate:"required"` // RFC 3339 or iCalendar UTC date-time, ex) 2026-07-01T09:00:00+09:00
+	End         string   `query:"end"`
+	AllDay      bool     `query:"allday"` // start and end are dates, implied by date of start
+	Location    string   `query:"location"`
+	Description string   `query:"description"`
+	URL         string   `query:"url"`   // http or https url of the event
+	Geo         string   `query:"geo"`   // lat,lon
+	Alarms      []string `query:"alarm"` // repeated for several alarms
+}
+
+// handleEvent encode VEVENT of the query parameters, `GET /event?summary=&start=&end=`;
+// times are converted to UTC and text fields are escaped. `end` of all-day event is the last day of the event.
+func (api *APIv1) handleEvent(c echo.Context) error {
+	c.Set(ctxKeyPayloadKind, kindVEvent)
+
+	req := &EventRequest{}
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	event := &qrcode.Event{Summary: req.Summary, Location: req.Location, Description: req.Description, URL: req.URL}
+	_, err := parseEventDate(req.Start)
+	event.AllDay = req.AllDay || err == nil
+
+	// dates and date-times are not mixed
+	parse := fx.Ternary(event.AllDay, parseEventDate, parseEventTime)
+	if event.Start, err = parse(req.Start); err != nil {
+		return invalidParam("start", req.Start)
+	}
+
+	if req.End != "" {
+		if event.End, err = parse(req.End); err != nil {
+			return invalidParam("end", req.End)
+		}
+		if event.AllDay {
+			// DTEND of all-day event is exclusive
+			event.End = event.End.AddDate(0, 0, 1)
+		}
+	}
+
+	if req.URL != "" && validateURL(req.URL, []string{"http", "https"}) != nil {
+		return invalidParam("url", req.URL)
+	}
+
+	if req.Geo != "" {
+		if event.Geo, err = parseGeo(req.Geo); err != nil {
+			return invalidParam("geo", req.Geo)
+		}
+	}
+
+	for _, alarm := range req.Alarms {
+		trigger, err := parseAlarm(alarm)
+		if err != nil {
+			return invalidParam("alarm", alarm)
+		}
+		event.Alarms = append(event.Alarms, trigger)
+	}
+
+	qr, err := qrcode.VEvent(event)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := setLineEnding(c, qr); err != nil {
+		return err
+	}
+
+	return api.render(c, qr)
+}
+
+// line endings of `lineEnding` parameter
+var lineEndings = map[string]string{
+	"crlf": "\r\n",
+	"lf":   "\n",
+}
+
+// setLineEnding replace line endings of generated vcard or vevent with `lineEnding` parameter; CRLF by the spec if not given.
+// posted vcard and calendar are always CRLF.
+func setLineEnding(c echo.Context, qr *qrcode.QR) error {
+	v := c.QueryParam("lineEnding")
+	if v == "" {
+		v = "crlf"
+	}
+
+	eol, ok := lineEndings[strings.ToLower(v)]
+	if !ok {
+		return invalidParam("lineEnding", v)
+	}
+
+	qr.Content = strings.ReplaceAll(strings.ReplaceAll(qr.Content, "\r\n", "\n"), "\n", eol)
+	return nil
 }
@@ -1,17 +1,33 @@
 package qrcodeapi
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"image"
+	"image/color"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
+	"github.com/emersion/go-vcard"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
 	"github.com/whitekid/goxp/request"
 
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
 	"qrcodeapi/pkg/qrcode"
 )
 
@@ -19,7 +35,7 @@ func TestText(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 
-	ts := newTestServer(ctx, newAPIv1())
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
 
 	type args struct {
 		width     int
@@ -38,6 +54,7 @@ func TestText(t *testing.T) {
 		{"default", args{0, 0, "png"}, 200, 200, "image/png", "png", false},
 		{"default", args{0, 0, "jpg"}, 200, 200, "image/jpeg", "jpeg", false},
 		{"default", args{0, 0, "gif"}, 200, 200, "image/gif", "gif", false},
+		{"size", args{100, 100, ""}, 100, 100, "image/png", "png", false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -80,7 +97,7 @@ func TestURL(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 
-	ts := newTestServer(ctx, newAPIv1())
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
 
 	resp, err := request.Get("%s/qrcode", ts.URL).
 		Query("url", "google.com").Do(ctx)
@@ -97,11 +114,107 @@ func TestURL(t *testing.T) {
 	require.Equal(t, "URLTO:google.com", got)
 }
 
+func TestURLPayloadDefault(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	viper.Set("url_payload", "raw")
+	defer viper.Set("url_payload", "urlto")
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name        string
+		req         *request.Request
+		wantStatus  int
+		wantContent string
+	}{
+		{"url", request.Get("%s/qrcode", ts.URL).Query("url", "https://github.com"), http.StatusOK, "https://github.com"},
+		{"url urlto", request.Get("%s/qrcode", ts.URL).Query("url", "https://github.com").Query("payload", "urlto"), http.StatusOK, "URLTO:https://github.com"},
+		{"referer", request.Get("%s/qrcode", ts.URL).Query("useReferer", "true").Header("Referer", "https://github.com/whitekid"), http.StatusOK, "https://github.com/whitekid"},
+		{"document", request.Post("%s/qrcode", ts.URL).JSON(map[string]string{"url": "https://github.com"}), http.StatusOK, "https://github.com"},
+		{"url route", request.Get("%s/url", ts.URL).Query("target", "https://github.com"), http.StatusOK, "https://github.com"},
+		{"invalid", request.Get("%s/qrcode", ts.URL).Query("url", "https://github.com").Query("payload", "mecard"), http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.req.Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if !resp.Success() {
+				return
+			}
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantContent, got)
+		})
+	}
+}
+
+func TestURLRoute(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name            string
+		query           map[string]string
+		wantStatus      int
+		wantContentType string
+		wantContent     string
+	}{
+		{"https", map[string]string{"target": "https://github.com/whitekid"}, http.StatusOK, "image/png", "https://github.com/whitekid"},
+		{"urlto", map[string]string{"target": "https://github.com", "payload": "urlto"}, http.StatusOK, "image/png", "URLTO:https://github.com"},
+		{"svg", map[string]string{"target": "https://github.com", "t": "svg"}, http.StatusOK, "image/svg+xml", ""},
+		{"preview", map[string]string{"target": "https://github.com", "preview": "true"}, http.StatusOK, "text/plain; charset=UTF-8", "https://github.com"},
+		{"preview urlto", map[string]string{"target": "https://github.com", "payload": "URLTO", "preview": "true"}, http.StatusOK, "text/plain; charset=UTF-8", "URLTO:https://github.com"},
+		{"javascript", map[string]string{"target": "javascript:alert(1)"}, http.StatusBadRequest, "", ""},
+		{"relative", map[string]string{"target": "github.com"}, http.StatusBadRequest, "", ""},
+		{"too long", map[string]string{"target": "https://github.com/" + strings.Repeat("a", maxURLLength)}, http.StatusBadRequest, "", ""},
+		{"invalid payload", map[string]string{"target": "https://github.com", "payload": "mecard"}, http.StatusBadRequest, "", ""},
+		{"required", map[string]string{}, http.StatusBadRequest, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/url", ts.URL).Queries(tt.query).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if !resp.Success() {
+				return
+			}
+
+			require.Equal(t, tt.wantContentType, resp.Header.Get(request.HeaderContentType))
+			if tt.wantContent == "" {
+				return
+			}
+
+			if strings.HasPrefix(tt.wantContentType, "text/plain") {
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				require.Equal(t, tt.wantContent, string(body))
+				return
+			}
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantContent, got)
+		})
+	}
+}
+
 func TestWifi(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 
-	ts := newTestServer(ctx, newAPIv1())
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
 
 	type args struct {
 		query    map[string]string
@@ -127,56 +240,285 @@ func TestWifi(t *testing.T) {
 			"ph2":    "MSCHAPV2",
 		}, "WIFI:S:myssid;T:WPA;P:mypassword;H:true;E:TTLS;A:anon_id;I:my_ident;PH2:MSCHAPV2;;"}, false, http.StatusOK},
 	}
+	for _, path := range []string{"/wifi", "/qrcode"} {
+		for _, tt := range tests {
+			t.Run(path+" "+tt.name, func(t *testing.T) {
+				resp, err := request.Get("%s%s", ts.URL, path).Queries(tt.arg.query).Do(ctx)
+				if (err != nil) != tt.wantErr {
+					require.Failf(t, `wifi request failed`, `error = %v, wantErr = %v`, err, tt.wantErr)
+				}
+				if tt.wantErr {
+					return
+				}
+
+				require.Equalf(t, tt.wantStatus, resp.StatusCode, "status=%d, wantCode=%d", resp.StatusCode, tt.wantStatus)
+				if path == "/qrcode" {
+					require.Equal(t, "true", resp.Header.Get("Deprecation"))
+				} else {
+					require.Empty(t, resp.Header.Get("Deprecation"))
+				}
+				if !resp.Success() {
+					return
+				}
+
+				require.Truef(t, resp.Success(), "failed with status %s", resp.Status)
+				require.Equal(t, "image/png", resp.Header.Get(request.HeaderContentType))
+
+				defer resp.Body.Close()
+				img, _, err := image.Decode(resp.Body)
+				require.NoError(t, err)
+
+				decoded, err := qrcode.Decode(img)
+				require.NoError(t, err)
+				require.Equal(t, tt.arg.wantCode, decoded)
+			})
+		}
+	}
+}
+
+func TestWifiConflict(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name  string
+		query map[string]string
+	}{
+		{"content", map[string]string{"ssid": "myssid", "auth": "WPA", "content": "hello"}},
+		{"url", map[string]string{"ssid": "myssid", "auth": "WPA", "url": "github.com"}},
+		{"referer", map[string]string{"ssid": "myssid", "auth": "WPA", "useReferer": "true"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/qrcode", ts.URL).Queries(tt.query).Do(ctx)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+	}
+}
+
+func TestContact(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	resp, err := request.Get("%s/contact", ts.URL).
+		Query("name[first]", "firstname").
+		Query("name[last]", "lastname").
+		Do(ctx)
+	require.NoError(t, err)
+	require.True(t, resp.Success())
+
+	require.Equal(t, "image/png", resp.Header.Get(request.HeaderContentType))
+}
+
+func TestContactDates(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name       string
+		params     map[string]string
+		wantStatus int
+		wantLines  []string
+	}{
+		{"4.0", map[string]string{"bday": "1996-04-15", "anniversary": "2020-10-03"}, http.StatusOK,
+			[]string{"VERSION:4.0", "BDAY:19960415", "ANNIVERSARY:20201003"}},
+		{"3.0", map[string]string{"version": "3.0", "bday": "1996-04-15", "anniversary": "2020-10-03"}, http.StatusOK,
+			[]string{"VERSION:3.0", "BDAY:1996-04-15", "X-ANNIVERSARY:2020-10-03"}},
+		{"unknown year", map[string]string{"bday": "--04-15"}, http.StatusOK, []string{"BDAY:--0415"}},
+		{"unknown year 3.0", map[string]string{"version": "3.0", "bday": "--04-15"}, http.StatusOK, []string{"BDAY:--04-15"}},
+		{"invalid date", map[string]string{"bday": "1996-02-30"}, http.StatusBadRequest, nil},
+		{"invalid anniversary", map[string]string{"anniversary": "10/03/2020"}, http.StatusBadRequest, nil},
+		{"invalid version", map[string]string{"version": "5.0"}, http.StatusBadRequest, nil},
+	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			resp, err := request.Get("%s/qrcode", ts.URL).Queries(tt.arg.query).Do(ctx)
-			if (err != nil) != tt.wantErr {
-				require.Failf(t, `wifi request failed`, `error = %v, wantErr = %v`, err, tt.wantErr)
+			req := request.Get("%s/contact", ts.URL).Query("name[first]", "Gildong").Query("name[last]", "Hong")
+			for k, v := range tt.params {
+				req = req.Query(k, v)
 			}
-			if tt.wantErr {
+			resp, err := req.Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != http.StatusOK {
 				return
 			}
 
-			require.Equalf(t, tt.wantStatus, resp.StatusCode, "status=%d, wantCode=%d", resp.StatusCode, tt.wantStatus)
-			if !resp.Success() {
-				return
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			for _, line := range tt.wantLines {
+				require.Contains(t, got, line+"\r\n")
 			}
+		})
+	}
 
-			require.Truef(t, resp.Success(), "failed with status %s", resp.Status)
-			require.Equal(t, "image/png", resp.Header.Get(request.HeaderContentType))
+	// json body
+	resp, err := request.Post("%s/contact", ts.URL).
+		JSON(map[string]interface{}{"name": map[string]string{"first": "Gildong"}, "version": "3.0", "bday": "--04-15", "anniversary": "2020-10-03"}).
+		Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Truef(t, resp.Success(), "failed with %d", resp.StatusCode)
+	img, _, err := image.Decode(resp.Body)
+	require.NoError(t, err)
+	got, err := qrcode.Decode(img)
+	require.NoError(t, err)
+	card, err := vcard.NewDecoder(strings.NewReader(got)).Decode()
+	require.NoError(t, err)
+	require.Equal(t, "3.0", card.Value(vcard.FieldVersion))
+	require.Equal(t, "--04-15", card.Value(vcard.FieldBirthday))
+	require.Equal(t, "2020-10-03", card.Value("X-ANNIVERSARY"))
+
+	resp, err = request.Post("%s/contact", ts.URL).
+		JSON(map[string]interface{}{"name": map[string]string{"first": "Gildong"}, "bday": "2023-02-29"}).
+		Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestContactTel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
 
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name       string
+		params     map[string]string
+		wantStatus int
+		wantLines  []string
+	}{
+		{"4.0", map[string]string{"tel[cell]": "+82-10-1234-5678", "tel[work]": "+82 2 123 4567", "tel": "1588-0000"}, http.StatusOK,
+			[]string{"TEL;VALUE=uri;TYPE=cell:tel:+82-10-1234-5678", "TEL;VALUE=uri;TYPE=work:tel:+82-2-123-4567", "TEL;VALUE=uri:tel:1588-0000"}},
+		{"3.0", map[string]string{"version": "3.0", "tel[cell]": "+82-10-1234-5678", "tel[home]": "02-123-4567", "fax[work]": "02-123-4568"}, http.StatusOK,
+			[]string{"TEL;TYPE=CELL:+82-10-1234-5678", "TEL;TYPE=HOME:02-123-4567", "TEL;TYPE=WORK,FAX:02-123-4568"}},
+		{"mobile", map[string]string{"version": "3.0", "mobile": "010-1234-5678"}, http.StatusOK, []string{"TEL;TYPE=CELL:010-1234-5678"}},
+		{"2.1", map[string]string{"version": "2.1", "tel[cell]": "+82-10-1234-5678", "fax[work]": "02-123-4568"}, http.StatusOK,
+			[]string{"VERSION:2.1", "TEL;CELL:+82-10-1234-5678", "TEL;WORK;FAX:02-123-4568"}},
+		{"invalid", map[string]string{"tel[cell]": "call me"}, http.StatusBadRequest, nil},
+		{"invalid fax", map[string]string{"fax[home]": "+82-2-123-4567;ext=1"}, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request.Get("%s/contact", ts.URL).Query("name[first]", "Gildong").Query("name[last]", "Hong")
+			for k, v := range tt.params {
+				req = req.Query(k, v)
+			}
+			resp, err := req.Do(ctx)
+			require.NoError(t, err)
 			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
 			img, _, err := image.Decode(resp.Body)
 			require.NoError(t, err)
-
-			decoded, err := qrcode.Decode(img)
+			got, err := qrcode.Decode(img)
 			require.NoError(t, err)
-			require.Equal(t, tt.arg.wantCode, decoded)
+			for _, line := range tt.wantLines {
+				require.Contains(t, got, line+"\r\n")
+			}
 		})
 	}
+
+	// json body
+	resp, err := request.Post("%s/contact", ts.URL).
+		JSON(map[string]interface{}{"name": map[string]string{"first": "Gildong"}, "phones": []map[string]string{{"number": "call me"}}}).
+		Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
 }
 
-func TestContact(t *testing.T) {
+func TestContactJSON(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 
-	ts := newTestServer(ctx, newAPIv1())
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
 
-	resp, err := request.Get("%s/contact", ts.URL).
-		Query("name[first]", "firstname").
-		Query("name[last]", "lastname").
+	resp, err := request.Post("%s/contact", ts.URL).
+		JSON(map[string]interface{}{
+			"name":    map[string]string{"first": "Gildong", "last": "Hong"},
+			"company": "Example",
+			"title":   "Engineer",
+			"phones": []map[string]string{
+				{"number": "+82-10-1234-5678", "type": "cell"},
+				{"number": "+82-2-123-4567", "type": "work"},
+			},
+			"emails": []map[string]string{
+				{"address": "gildong@example.com", "type": "work"},
+				{"address": "gildong@home.example"},
+			},
+		}).
 		Do(ctx)
 	require.NoError(t, err)
-	require.True(t, resp.Success())
+	require.Truef(t, resp.Success(), "failed with %d", resp.StatusCode)
 
-	require.Equal(t, "image/png", resp.Header.Get(request.HeaderContentType))
+	defer resp.Body.Close()
+	img, _, err := image.Decode(resp.Body)
+	require.NoError(t, err)
+	got, err := qrcode.Decode(img)
+	require.NoError(t, err)
+
+	card, err := vcard.NewDecoder(strings.NewReader(got)).Decode()
+	require.NoError(t, err)
+	require.Equal(t, "4.0", card.Value(vcard.FieldVersion))
+	require.Equal(t, "Gildong Hong", card.Value(vcard.FieldFormattedName))
+	require.Equal(t, "Gildong", card.Name().GivenName)
+	require.Equal(t, "Hong", card.Name().FamilyName)
+	require.Equal(t, "Example", card.Value(vcard.FieldOrganization))
+	require.Equal(t, "Engineer", card.Value(vcard.FieldTitle))
+
+	phones := card[vcard.FieldTelephone]
+	require.Len(t, phones, 2)
+	require.Equal(t, "tel:+82-10-1234-5678", phones[0].Value)
+	require.Equal(t, []string{"cell"}, phones[0].Params.Types())
+	require.Equal(t, []string{"work"}, phones[1].Params.Types())
+	require.Equal(t, []string{"gildong@example.com", "gildong@home.example"}, card.Values(vcard.FieldEmail))
+
+	// invalid
+	tests := [...]struct {
+		name        string
+		body        string
+		wantMessage string
+	}{
+		{"no name", `{"company": "Example"}`, "name: required"},
+		{"phone type", `{"name": {"first": "a"}, "phones": [{"number": "1", "type": "cell"}, {"number": "2", "type": "car"}]}`, `phones[1].type: invalid value \"car\"`},
+		{"email address", `{"name": {"first": "a"}, "emails": [{"type": "work"}]}`, "emails[0].address: required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Post("%s/contact", ts.URL).
+				Header(request.HeaderContentType, "application/json").
+				Body(strings.NewReader(tt.body)).
+				Do(ctx)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Contains(t, string(body), tt.wantMessage)
+		})
+	}
 }
 
 func TestContactVCF(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 
-	ts := newTestServer(ctx, newAPIv1())
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
 
 	content := `BEGIN:VCARD
 VERSION:4.0
@@ -199,32 +541,1078 @@ END:VCARD`
 	require.Equal(t, strings.ReplaceAll(content, "\n", "\r\n"), got)
 }
 
-// VEvent는 QR 스캐너에서 안되네
-func TestVEvent(t *testing.T) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+func TestContactVCFUpload(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	ts := newTestServer(ctx, newAPIv1())
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
 
-	content := `BEGIN:VEVENT
-SUMMARY:Summer+Vacation!
-DTSTART:20180601T070000Z
-DTEND:20180831T070000Z
-END:VEVENT`
+	// exported address book of two cards
+	const contacts = "BEGIN:VCARD\r\nVERSION:4.0\r\nN:Choe;Cheng Dae;;;\r\nEND:VCARD\r\n" +
+		"BEGIN:VCARD\r\nVERSION:4.0\r\nN:Kim;Minsu;;;\r\nTEL:+82-10-1234-5678\r\nEND:VCARD\r\n"
 
-	resp, err := request.Post("%s/vevent", ts.URL).
-		ContentType(mimeVEvent).
-		Body(strings.NewReader(content)).
-		Do(ctx)
+	upload := func(filename, contentType string, data []byte, index string) *request.Response {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, filename))
+		h.Set(request.HeaderContentType, contentType)
+		part, err := w.CreatePart(h)
+		require.NoError(t, err)
+		_, err = part.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		req := request.Post("%s/vcard", ts.URL).ContentType(w.FormDataContentType()).Body(&buf)
+		if index != "" {
+			req = req.Query("index", index)
+		}
+		resp, err := req.Do(ctx)
+		require.NoError(t, err)
+		return resp
+	}
+
+	tests := [...]struct {
+		name        string
+		filename    string
+		contentType string
+		data        string
+		index       string
+		wantStatus  int
+		wantContent string
+	}{
+		{"first card", "contacts.vcf", "text/vcard", contacts, "0", http.StatusOK, "N:Choe;Cheng Dae;;;"},
+		{"ambiguous", "contacts.vcf", "text/vcard", contacts, "", http.StatusBadRequest, ""},
+		{"index", "contacts.vcf", "application/octet-stream", contacts, "1", http.StatusOK, "TEL:+82-10-1234-5678"},
+		{"vcard type", "contacts", "text/x-vcard", contacts, "0", http.StatusOK, "N:Choe;Cheng Dae;;;"},
+		{"index out of range", "contacts.vcf", "text/vcard", contacts, "2", http.StatusBadRequest, ""},
+		{"invalid index", "contacts.vcf", "text/vcard", contacts, "-1", http.StatusBadRequest, ""},
+		{"not vcf", "photo.png", "image/png", "\x89PNG\r\n", "", http.StatusBadRequest, ""},
+		{"invalid vcf", "contacts.vcf", "text/vcard", "hello world", "", http.StatusBadRequest, ""},
+		{"too large", "contacts.vcf", "text/vcard", strings.Repeat(contacts, maxVCFSize/len(contacts)+1), "", http.StatusRequestEntityTooLarge, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := upload(tt.filename, tt.contentType, []byte(tt.data), tt.index)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.True(t, strings.HasPrefix(got, "BEGIN:VCARD\r\n"), got)
+			require.Contains(t, got, tt.wantContent+"\r\n")
+			require.Equal(t, 1, strings.Count(got, "BEGIN:VCARD"))
+		})
+	}
+
+	// file part is required
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	require.NoError(t, w.WriteField("index", "0"))
+	require.NoError(t, w.Close())
+	resp, err := request.Post("%s/vcard", ts.URL).ContentType(w.FormDataContentType()).Body(&buf).Do(ctx)
 	require.NoError(t, err)
-	require.True(t, resp.Success(), "failed with status %d: %s", resp.StatusCode, resp.Status)
-	require.Equal(t, "image/png", resp.Header.Get(request.HeaderContentType))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestContactVCardSelect(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	const contacts = "BEGIN:VCARD\r\nVERSION:4.0\r\nUID:urn:uuid:0001\r\nFN:Choe Cheng Dae\r\nN:Choe;Cheng Dae;;;\r\nEND:VCARD\r\n" +
+		"BEGIN:VCARD\r\nVERSION:4.0\r\nUID:urn:uuid:0002\r\nFN:Kim/Minsu\r\nTEL:+82-10-1234-5678\r\nEND:VCARD\r\n" +
+		"BEGIN:VCARD\r\nVERSION:4.0\r\nUID:urn:uuid:0003\r\nFN:../Lee Jiwoo\r\nEMAIL:jiwoo@example.com\r\nEND:VCARD\r\n"
+
+	post := func(query map[string]string) *request.Response {
+		req := request.Post("%s/vcard", ts.URL).ContentType("text/vcard").Body(strings.NewReader(contacts))
+		for k, v := range query {
+			req = req.Query(k, v)
+		}
+		resp, err := req.Do(ctx)
+		require.NoError(t, err)
+		return resp
+	}
+
+	tests := [...]struct {
+		name        string
+		query       map[string]string
+		wantStatus  int
+		wantContent string
+		wantError   string
+	}{
+		{"ambiguous", nil, http.StatusBadRequest, "", "3 cards"},
+		{"index", map[string]string{"index": "1"}, http.StatusOK, "FN:Kim/Minsu", ""},
+		{"uid", map[string]string{"uid": "urn:uuid:0003"}, http.StatusOK, "EMAIL:jiwoo@example.com", ""},
+		{"uid not found", map[string]string{"uid": "urn:uuid:0004"}, http.StatusBadRequest, "", "not found"},
+		{"index out of range", map[string]string{"index": "3"}, http.StatusBadRequest, "", "3 cards"},
+		{"index and uid", map[string]string{"index": "0", "uid": "urn:uuid:0001"}, http.StatusBadRequest, "", "only one of"},
+		{"invalid all", map[string]string{"all": "yes"}, http.StatusBadRequest, "", "invalid all"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := post(tt.query)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != http.StatusOK {
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				require.Contains(t, string(body), tt.wantError)
+				return
+			}
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Contains(t, got, tt.wantContent+"\r\n")
+			require.Equal(t, 1, strings.Count(got, "BEGIN:VCARD"))
+		})
+	}
 
+	// zip of every card named from FN
+	resp := post(map[string]string{"all": "true"})
 	defer resp.Body.Close()
-	img, _, err := image.Decode(resp.Body)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/zip", resp.Header.Get(echo.HeaderContentType))
+
+	body, err := io.ReadAll(resp.Body)
 	require.NoError(t, err)
-	got, err := qrcode.Decode(img)
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
 	require.NoError(t, err)
 
-	require.Equal(t, strings.ReplaceAll(content, "\n", "\r\n"), got)
+	wantNames := []string{"Choe Cheng Dae.png", "Kim_Minsu.png", "_Lee Jiwoo.png"}
+	wantContents := []string{"FN:Choe Cheng Dae", "FN:Kim/Minsu", "FN:../Lee Jiwoo"}
+	require.Len(t, zr.File, len(wantNames))
+	for i, f := range zr.File {
+		require.Equal(t, wantNames[i], f.Name)
+
+		r, err := f.Open()
+		require.NoError(t, err)
+		img, _, err := image.Decode(r)
+		r.Close()
+		require.NoError(t, err)
+		got, err := qrcode.Decode(img)
+		require.NoError(t, err)
+		require.Contains(t, got, wantContents[i]+"\r\n")
+	}
+}
+
+func TestContactFileName(t *testing.T) {
+	tests := [...]struct {
+		fn   string
+		want string
+	}{
+		{"Kim Minsu", "Kim Minsu"},
+		{"Kim/Minsu", "Kim_Minsu"},
+		{`a\b:c*d?e"f<g>h|i`, "a_b_c_d_e_f_g_h_i"},
+		{"../../etc/passwd", "_.._etc_passwd"},
+		{"..", "007"},
+		{"", "007"},
+		{strings.Repeat("가", 70), strings.Repeat("가", maxFileNameLength)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fn, func(t *testing.T) {
+			card := vcard.Card{}
+			if tt.fn != "" {
+				card.SetValue(vcard.FieldFormattedName, tt.fn)
+			}
+			require.Equal(t, tt.want, contactFileName(card, 7))
+		})
+	}
+}
+
+// VEvent는 QR 스캐너에서 안되네
+func TestVEvent(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name    string
+		content string
+	}{
+		{"event", `BEGIN:VEVENT
+SUMMARY:Summer+Vacation!
+DTSTART:20180601T070000Z
+DTEND:20180831T070000Z
+END:VEVENT`},
+		// reminder of the event is kept as given
+		{"alarm", `BEGIN:VEVENT
+SUMMARY:Summer+Vacation!
+DTSTART:20180601T070000Z
+DTEND:20180831T070000Z
+BEGIN:VALARM
+ACTION:DISPLAY
+TRIGGER:-PT15M
+END:VALARM
+END:VEVENT`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Post("%s/vevent", ts.URL).
+				ContentType(mimeVEvent).
+				Body(strings.NewReader(tt.content)).
+				Do(ctx)
+			require.NoError(t, err)
+			require.True(t, resp.Success(), "failed with status %d: %s", resp.StatusCode, resp.Status)
+			require.Equal(t, "image/png", resp.Header.Get(request.HeaderContentType))
+
+			defer resp.Body.Close()
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+
+			require.Equal(t, strings.ReplaceAll(tt.content, "\n", "\r\n"), got)
+		})
+	}
+}
+
+func TestEvent(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	viper.Set("rate_limit", 1000)
+	defer viper.Set("rate_limit", 20)
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name       string
+		query      map[string]string
+		wantStatus int
+		want       string
+	}{
+		{"event", map[string]string{
+			"summary": "Summer Vacation", "start": "2018-06-01T16:00:00+09:00", "end": "20180831T070000Z",
+			"location": "Jeju, Korea", "description": "pack; sunscreen\nand hat",
+		}, http.StatusOK, "BEGIN:VEVENT\r\nSUMMARY:Summer Vacation\r\nDTSTART:20180601T070000Z\r\nDTEND:20180831T070000Z\r\n" +
+			"LOCATION:Jeju\\, Korea\r\nDESCRIPTION:pack\\; sunscreen\\nand hat\r\nEND:VEVENT"},
+		{"start only", map[string]string{"summary": "Standup", "start": "2026-07-01T09:00:00Z"}, http.StatusOK,
+			"BEGIN:VEVENT\r\nSUMMARY:Standup\r\nDTSTART:20260701T090000Z\r\nEND:VEVENT"},
+		{"summary required", map[string]string{"start": "2026-07-01T09:00:00Z"}, http.StatusBadRequest, ""},
+		{"start required", map[string]string{"summary": "Standup"}, http.StatusBadRequest, ""},
+		{"invalid start", map[string]string{"summary": "Standup", "start": "2026-07-01 09:00"}, http.StatusBadRequest, ""},
+		{"invalid end", map[string]string{"summary": "Standup", "start": "2026-07-01T09:00:00Z", "end": "tomorrow"}, http.StatusBadRequest, ""},
+		{"end before start", map[string]string{"summary": "Standup", "start": "2026-07-01T09:00:00Z", "end": "2026-07-01T08:00:00Z"}, http.StatusBadRequest, ""},
+		{"all day", map[string]string{"summary": "Vacation", "start": "2024-06-01", "end": "2024-06-03", "allday": "true"}, http.StatusOK,
+			"BEGIN:VEVENT\r\nSUMMARY:Vacation\r\nDTSTART;VALUE=DATE:20240601\r\nDTEND;VALUE=DATE:20240604\r\nEND:VEVENT"},
+		{"all day of date start", map[string]string{"summary": "Holiday", "start": "20240601"}, http.StatusOK,
+			"BEGIN:VEVENT\r\nSUMMARY:Holiday\r\nDTSTART;VALUE=DATE:20240601\r\nEND:VEVENT"},
+		{"all day of one day", map[string]string{"summary": "Holiday", "start": "2024-06-01", "end": "2024-06-01"}, http.StatusOK,
+			"BEGIN:VEVENT\r\nSUMMARY:Holiday\r\nDTSTART;VALUE=DATE:20240601\r\nDTEND;VALUE=DATE:20240602\r\nEND:VEVENT"},
+		{"all day to leap day", map[string]string{"summary": "Trip", "start": "2024-02-28", "end": "2024-02-29"}, http.StatusOK,
+			"BEGIN:VEVENT\r\nSUMMARY:Trip\r\nDTSTART;VALUE=DATE:20240228\r\nDTEND;VALUE=DATE:20240301\r\nEND:VEVENT"},
+		{"all day of date-time start", map[string]string{"summary": "Trip", "start": "2024-06-01T09:00:00Z", "allday": "true"}, http.StatusBadRequest, ""},
+		{"date start and date-time end", map[string]string{"summary": "Trip", "start": "2024-06-01", "end": "2024-06-03T09:00:00Z"}, http.StatusBadRequest, ""},
+		{"all day end before start", map[string]string{"summary": "Trip", "start": "2024-06-03", "end": "2024-06-01"}, http.StatusBadRequest, ""},
+		{"invalid allday", map[string]string{"summary": "Trip", "start": "2024-06-03", "allday": "maybe"}, http.StatusBadRequest, ""},
+		{"url and geo", map[string]string{
+			"summary": "Meetup", "start": "2026-07-01T09:00:00Z", "location": "12, Teheran-ro; Gangnam-gu, Seoul",
+			"url": "https://example.com/meetup", "geo": "37.4979,127.0276",
+		}, http.StatusOK, "BEGIN:VEVENT\r\nSUMMARY:Meetup\r\nDTSTART:20260701T090000Z\r\nLOCATION:12\\, Teheran-ro\\; Gangnam-gu\\, Seoul\r\n" +
+			"URL:https://example.com/meetup\r\nGEO:37.4979;127.0276\r\nEND:VEVENT"},
+		{"geo of bounds", map[string]string{"summary": "Pole", "start": "2026-07-01T09:00:00Z", "geo": "-90,180"}, http.StatusOK,
+			"BEGIN:VEVENT\r\nSUMMARY:Pole\r\nDTSTART:20260701T090000Z\r\nGEO:-90;180\r\nEND:VEVENT"},
+		{"invalid url", map[string]string{"summary": "Meetup", "start": "2026-07-01T09:00:00Z", "url": "ftp://example.com"}, http.StatusBadRequest, ""},
+		{"url without host", map[string]string{"summary": "Meetup", "start": "2026-07-01T09:00:00Z", "url": "example.com/meetup"}, http.StatusBadRequest, ""},
+		{"invalid geo", map[string]string{"summary": "Meetup", "start": "2026-07-01T09:00:00Z", "geo": "37.4979"}, http.StatusBadRequest, ""},
+		{"latitude out of range", map[string]string{"summary": "Meetup", "start": "2026-07-01T09:00:00Z", "geo": "91,127"}, http.StatusBadRequest, ""},
+		{"longitude out of range", map[string]string{"summary": "Meetup", "start": "2026-07-01T09:00:00Z", "geo": "37,-180.5"}, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/event", ts.URL).Queries(tt.query).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if !resp.Success() {
+				return
+			}
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEventAlarm(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	alarm := func(trigger string) string {
+		return "BEGIN:VALARM\r\nACTION:DISPLAY\r\nTRIGGER:" + trigger + "\r\nDESCRIPTION:Standup\r\nEND:VALARM\r\n"
+	}
+	tests := [...]struct {
+		name       string
+		alarms     []string
+		wantStatus int
+		want       string
+	}{
+		{"duration", []string{"-PT15M"}, http.StatusOK, alarm("-PT15M")},
+		{"shorthand", []string{"15m"}, http.StatusOK, alarm("-PT15M")},
+		{"shorthand of days", []string{"1d"}, http.StatusOK, alarm("-P1D")},
+		{"multiple", []string{"1h", "-PT5M", "P1W"}, http.StatusOK, alarm("-PT1H") + alarm("-PT5M") + alarm("P1W")},
+		{"date and time", []string{"-p1dt2h30m"}, http.StatusOK, alarm("-P1DT2H30M")},
+		{"invalid duration", []string{"-PT"}, http.StatusBadRequest, ""},
+		{"invalid shorthand", []string{"15 minutes"}, http.StatusBadRequest, ""},
+		{"one of invalid", []string{"15m", "P1W2D"}, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request.Get("%s/event", ts.URL).Queries(map[string]string{"summary": "Standup", "start": "2026-07-01T09:00:00Z"})
+			for _, alarm := range tt.alarms {
+				req = req.Query("alarm", alarm)
+			}
+			resp, err := req.Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if !resp.Success() {
+				return
+			}
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, "BEGIN:VEVENT\r\nSUMMARY:Standup\r\nDTSTART:20260701T090000Z\r\n"+tt.want+"END:VEVENT", got)
+		})
+	}
+}
+
+func TestEventFolding(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	description := "Agenda: opening, keynote; lunch, and workshops\n" + strings.Repeat("세션, ", 20) + "\nclosing remarks, networking and dinner at the venue"
+	resp, err := request.Get("%s/event", ts.URL).Queries(map[string]string{
+		"summary": "Conference", "start": "2026-07-01T09:00:00Z", "description": description,
+	}).Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	img, _, err := image.Decode(resp.Body)
+	require.NoError(t, err)
+	got, err := qrcode.Decode(img)
+	require.NoError(t, err)
+
+	lines := strings.Split(got, "\r\n")
+	for _, line := range lines {
+		require.LessOrEqual(t, len(line), 75, line)
+		require.True(t, utf8.ValidString(line), line)
+	}
+
+	// unfold and unescape, RFC 5545 3.1 and 3.3.11
+	unfolded := strings.ReplaceAll(got, "\r\n ", "")
+	require.Greater(t, len(lines), len(strings.Split(unfolded, "\r\n")))
+	var value string
+	for _, line := range strings.Split(unfolded, "\r\n") {
+		if strings.HasPrefix(line, "DESCRIPTION:") {
+			value = strings.TrimPrefix(line, "DESCRIPTION:")
+		}
+	}
+	unescaped := strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(value)
+	require.Equal(t, description, unescaped)
+}
+
+func TestLineEnding(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	endpoints := [...]struct {
+		name string
+		req  func() *request.Request
+	}{
+		{"contact", func() *request.Request {
+			return request.Get("%s/contact", ts.URL).Query("name[first]", "firstname").Query("name[last]", "lastname")
+		}},
+		{"contact json", func() *request.Request {
+			return request.Post("%s/contact", ts.URL).JSON(map[string]interface{}{"name": map[string]string{"first": "Gildong"}})
+		}},
+		{"event", func() *request.Request {
+			return request.Get("%s/event", ts.URL).Query("summary", "Summer Vacation!").Query("start", "2018-06-01T16:00:00+09:00")
+		}},
+	}
+
+	tests := [...]struct {
+		name       string
+		lineEnding string
+		wantStatus int
+		wantEOL    string
+	}{
+		{"default", "", http.StatusOK, "\r\n"},
+		{"crlf", "crlf", http.StatusOK, "\r\n"},
+		{"lf", "lf", http.StatusOK, "\n"},
+		{"upper case", "LF", http.StatusOK, "\n"},
+		{"invalid", "cr", http.StatusBadRequest, ""},
+	}
+	for _, ep := range endpoints {
+		for _, tt := range tests {
+			t.Run(ep.name+"/"+tt.name, func(t *testing.T) {
+				req := ep.req()
+				if tt.lineEnding != "" {
+					req = req.Query("lineEnding", tt.lineEnding)
+				}
+				resp, err := req.Do(ctx)
+				require.NoError(t, err)
+				defer resp.Body.Close()
+				require.Equal(t, tt.wantStatus, resp.StatusCode)
+				if !resp.Success() {
+					return
+				}
+
+				img, _, err := image.Decode(resp.Body)
+				require.NoError(t, err)
+				got, err := qrcode.Decode(img)
+				require.NoError(t, err)
+
+				lines := strings.Split(got, tt.wantEOL)
+				require.Greater(t, len(lines), 2)
+				for _, line := range lines {
+					require.NotContains(t, line, "\n")
+					require.NotContains(t, line, "\r")
+				}
+				require.True(t, strings.HasPrefix(got, "BEGIN:"), got)
+			})
+		}
+	}
+}
+
+// posted vcard and calendar are not generated, lineEnding is not applied and lines are ended with CRLF
+func TestLineEndingPosted(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	vevent := "BEGIN:VEVENT\r\nSUMMARY:Summer Vacation!\r\nDTSTART:20180601T070000Z\r\nEND:VEVENT\r\n"
+	tests := [...]struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		wantContent string
+	}{
+		{"vevent", "/vevent", mimeVEvent, vevent, vevent},
+		{"vevent of lf", "/vevent", mimeVEvent, strings.ReplaceAll(vevent, "\r\n", "\n"), vevent},
+		{"calendar", "/vevent", mimeCalendar, "BEGIN:VCALENDAR\r\n" + vevent + "END:VCALENDAR\r\n", "BEGIN:VCALENDAR\r\n" + vevent + "END:VCALENDAR\r\n"},
+		{"vcard", "/vcard", mimeVCard, "BEGIN:VCARD\r\nVERSION:4.0\r\nN:lastname;firstname;;;\r\nEND:VCARD\r\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Post("%s%s", ts.URL, tt.path).Query("lineEnding", "lf").
+				ContentType(tt.contentType).Body(strings.NewReader(tt.body)).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+
+			if tt.wantContent != "" {
+				require.Equal(t, tt.wantContent, got)
+				return
+			}
+			require.Contains(t, got, "\r\n")
+			require.NotContains(t, strings.ReplaceAll(got, "\r\n", ""), "\n")
+		})
+	}
+}
+
+func TestDefaultFormat(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	cfg := config.Current()
+	ts := newTestServer(ctx, newAPIv1(cfg, codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name            string
+		defaultFormat   string
+		wantContentType string
+	}{
+		{"png", "png", "image/png"},
+		{"gif", "gif", "image/gif"},
+		{"invalid", "bmp", "image/png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.DefaultFormat = tt.defaultFormat
+
+			resp, err := request.Get("%s/qrcode", ts.URL).
+				Query("content", "hello world").
+				Header(echo.HeaderAccept, "*/*").
+				Do(ctx)
+			require.NoError(t, err)
+			require.Truef(t, resp.Success(), "failed with %d", resp.StatusCode)
+			require.Equal(t, tt.wantContentType, resp.Header.Get(request.HeaderContentType))
+		})
+	}
+}
+
+func TestCornerRadius(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name       string
+		imageType  string
+		wantCorner func(r, g, b, a uint32) bool
+	}{
+		{"png", "png", func(r, g, b, a uint32) bool { return a == 0 }},
+		{"jpeg", "jpg", func(r, g, b, a uint32) bool { return a == 0xffff && r > 0xf000 && g > 0xf000 && b > 0xf000 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/qrcode", ts.URL).
+				Query("content", "hello world").
+				Query("cornerRadius", "30").
+				Query("t", tt.imageType).
+				Do(ctx)
+			require.NoError(t, err)
+			require.Truef(t, resp.Success(), "failed with %d", resp.StatusCode)
+
+			defer resp.Body.Close()
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+
+			require.True(t, tt.wantCorner(img.At(0, 0).RGBA()), "corner pixel: %v", img.At(0, 0))
+			_, _, _, a := img.At(100, 100).RGBA()
+			require.Equal(t, uint32(0xffff), a, "center should be opaque")
+
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, "hello world", got)
+		})
+	}
+}
+
+func TestReferer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name       string
+		referer    string
+		wantStatus int
+		wantCode   string
+	}{
+		{"valid", "https://blog.example.com/posts/1?lang=ko", http.StatusOK, "URLTO:https://blog.example.com/posts/1?lang=ko"},
+		{"empty", "", http.StatusBadRequest, ""},
+		{"invalid", "not a url", http.StatusBadRequest, ""},
+		{"not http", "ftp://example.com/file", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request.Get("%s/qrcode", ts.URL).Query("useReferer", "true")
+			if tt.referer != "" {
+				req = req.Header("Referer", tt.referer)
+			}
+
+			resp, err := req.Do(ctx)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if !resp.Success() {
+				return
+			}
+
+			defer resp.Body.Close()
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantCode, got)
+		})
+	}
+}
+
+func TestProgressive(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	// sofMarker returns the first start of frame marker of jpeg; 0xc0 baseline, 0xc2 progressive
+	sofMarker := func(data []byte) byte {
+		for i := 2; i+4 <= len(data) && data[i] == 0xff; i += 2 + (int(data[i+2])<<8 | int(data[i+3])) {
+			if m := data[i+1]; m == 0xc0 || m == 0xc2 {
+				return m
+			}
+		}
+		return 0
+	}
+
+	tests := [...]struct {
+		name        string
+		imageType   string
+		progressive string
+		wantStatus  int
+		wantFormat  func(t *testing.T, data []byte)
+	}{
+		{"jpeg baseline", "jpg", "false", http.StatusOK, func(t *testing.T, data []byte) { require.Equal(t, byte(0xc0), sofMarker(data)) }},
+		{"jpeg progressive", "jpg", "true", http.StatusOK, func(t *testing.T, data []byte) { require.Equal(t, byte(0xc2), sofMarker(data)) }},
+		{"png", "png", "false", http.StatusOK, func(t *testing.T, data []byte) { require.Equal(t, byte(0), data[28], "interlace method") }},
+		{"png interlaced", "png", "true", http.StatusOK, func(t *testing.T, data []byte) { require.Equal(t, byte(1), data[28], "interlace method") }},
+		{"gif", "gif", "true", http.StatusBadRequest, nil},
+		{"svg", "svg", "true", http.StatusBadRequest, nil},
+		{"invalid", "jpg", "maybe", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/qrcode", ts.URL).
+				Query("content", "hello world").
+				Query("t", tt.imageType).
+				Query("progressive", tt.progressive).
+				Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if !resp.Success() {
+				return
+			}
+
+			data, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			tt.wantFormat(t, data)
+
+			img, _, err := image.Decode(bytes.NewReader(data))
+			require.NoError(t, err)
+			require.Equal(t, image.Pt(200, 200), img.Bounds().Size())
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, "hello world", got)
+		})
+	}
+}
+
+func TestInterlace(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name          string
+		imageType     string
+		interlace     string
+		wantStatus    int
+		wantInterlace byte // interlace method of IHDR
+	}{
+		{"png", "png", "false", http.StatusOK, 0},
+		{"png interlaced", "png", "true", http.StatusOK, 1},
+		{"jpeg", "jpg", "true", http.StatusBadRequest, 0},
+		{"svg", "svg", "true", http.StatusBadRequest, 0},
+		{"invalid", "png", "adam7", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/qrcode", ts.URL).
+				Query("content", "hello world").
+				Query("t", tt.imageType).
+				Query("interlace", tt.interlace).
+				Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if !resp.Success() {
+				return
+			}
+
+			data, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, "IHDR", string(data[12:16]))
+			require.Equal(t, tt.wantInterlace, data[28], "interlace method")
+
+			img, _, err := image.Decode(bytes.NewReader(data))
+			require.NoError(t, err)
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, "hello world", got)
+		})
+	}
+}
+
+func TestSVG(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name      string
+		alt       string
+		wantTitle string
+	}{
+		{"content", "", "<title id=\"qr-title\">hello &amp; world</title>"},
+		{"alt", "greeting", "<title id=\"qr-title\">greeting</title>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/qrcode", ts.URL).
+				Query("content", "hello & world").
+				Query("t", "svg").
+				Query("alt", tt.alt).
+				Do(ctx)
+			require.NoError(t, err)
+			require.Truef(t, resp.Success(), "failed with %d", resp.StatusCode)
+			require.Equal(t, "image/svg+xml", resp.Header.Get(request.HeaderContentType))
+
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Contains(t, string(body), `role="img"`)
+			require.Contains(t, string(body), tt.wantTitle)
+			require.Contains(t, string(body), "<desc id=\"qr-desc\">hello &amp; world</desc>")
+		})
+	}
+}
+
+func TestAuto(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name       string
+		content    string
+		detect     string
+		wantStatus int
+		wantType   string
+		want       string
+	}{
+		{"url", "https://github.com", "", http.StatusOK, "url", "URLTO:https://github.com"},
+		{"email", "me@example.com", "", http.StatusOK, "email", "mailto:me@example.com"},
+		{"phone", "+1 555-123-4567", "", http.StatusOK, "tel", "tel:+15551234567"},
+		{"text", "hello world", "", http.StatusOK, "text", "hello world"},
+		{"detect disabled", "me@example.com", "false", http.StatusOK, "text", "me@example.com"},
+		{"empty", "", "", http.StatusBadRequest, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/auto", ts.URL).
+				Query("content", tt.content).
+				Query("detect", tt.detect).
+				Do(ctx)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			require.Equal(t, tt.wantType, resp.Header.Get("X-QR-Type"))
+
+			defer resp.Body.Close()
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMaxVersion(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	viper.Set("max_version", 2)
+	defer viper.Set("max_version", 40)
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name       string
+		content    string
+		imageType  string
+		wantStatus int
+	}{
+		{"allowed", "hello world", "png", http.StatusOK},
+		{"too dense", strings.Repeat("hello world ", 10), "png", http.StatusBadRequest},
+		{"too dense svg", strings.Repeat("hello world ", 10), "svg", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/qrcode", ts.URL).
+				Query("content", tt.content).
+				Query("t", tt.imageType).
+				Do(ctx)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			if tt.wantStatus != http.StatusOK {
+				defer resp.Body.Close()
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				require.Contains(t, string(body), "up to 2 is allowed, shorten the content or use a url shortener")
+			}
+		})
+	}
+}
+
+func TestCapacities(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	resp, err := request.Get("%s/qrcode/capacities", ts.URL).Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.True(t, resp.Success())
+	require.Contains(t, resp.Header.Get("Cache-Control"), "max-age")
+
+	got := &CapacitiesResponse{}
+	require.NoError(t, resp.JSON(got))
+	require.Len(t, got.Capacities, 160)
+	require.Equal(t, qrcode.Capacity{Version: 1, ErrorCorrection: "L", Modules: 21, DataCodewords: 19, Numeric: 41, Alphanumeric: 25, Byte: 17, Kanji: 10},
+		got.Capacities[0])
+	require.Equal(t, qrcode.Capacity{Version: 40, ErrorCorrection: "H", Modules: 177, DataCodewords: 1276, Numeric: 3057, Alphanumeric: 1852, Byte: 1273, Kanji: 784},
+		got.Capacities[len(got.Capacities)-1])
+}
+
+func TestEncoding(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	cfg := config.Current()
+	ts := newTestServer(ctx, newAPIv1(cfg, codes.NewMemoryStore(), nil))
+
+	get := func(params map[string]string) *request.Response {
+		req := request.Get("%s/qrcode/encoding", ts.URL)
+		for k, v := range params {
+			req = req.Query(k, v)
+		}
+		resp, err := req.Do(ctx)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := get(map[string]string{"content": "hello world"})
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode, "disabled by default")
+
+	cfg.DebugEncoding = true
+
+	tests := [...]struct {
+		name              string
+		params            map[string]string
+		wantStatus        int
+		wantVersion       int
+		wantEC            string
+		wantDataCodewords string
+	}{
+		{"hello world", map[string]string{"content": "hello world"}, http.StatusOK, 1, "L", "40b68656c6c6f20776f726c640ec11ec11ec11"},
+		{"alphanumeric", map[string]string{"content": "HELLO", "ec": "H"}, http.StatusOK, 1, "H", "202b0b78cc00ec11ec"},
+		{"no content", map[string]string{"ec": "H"}, http.StatusBadRequest, 0, "", ""},
+		{"invalid ec", map[string]string{"content": "hello", "ec": "X"}, http.StatusBadRequest, 0, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := get(tt.params)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			got := &EncodingResponse{}
+			require.NoError(t, resp.JSON(got))
+			require.Equal(t, tt.wantVersion, got.Version)
+			require.Equal(t, tt.wantEC, got.ErrorCorrection)
+			require.Equal(t, tt.wantDataCodewords, got.DataCodewords)
+			require.True(t, strings.HasPrefix(got.Codewords, got.DataCodewords), "single block of version 1")
+			require.Len(t, got.Matrix, 21)
+			for _, row := range got.Matrix {
+				require.Regexp(t, "^[01]{21}$", row)
+			}
+			require.Equal(t, "1111111", got.Matrix[0][:7], "finder pattern")
+		})
+	}
+}
+
+func TestPathContent(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name            string
+		path            string
+		wantStatus      int
+		wantContentType string
+		want            string
+	}{
+		{"text", "/qrcode/hello%20world", http.StatusOK, "image/png", "hello world"},
+		{"extension", "/qrcode/hello%20world.png", http.StatusOK, "image/png", "hello world"},
+		{"unicode", "/qrcode/%ED%95%9C%EA%B8%80%20QR.gif", http.StatusOK, "image/gif", "한글 QR"},
+		{"encoded slash", "/qrcode/a%2Fb%2Fc.png", http.StatusOK, "image/png", "a/b/c"},
+		{"unknown extension", "/qrcode/example.com", http.StatusOK, "image/png", "example.com"},
+		{"literal payload prefix", "/qrcode/WIFI%3AS%3Amyssid%3B%3B", http.StatusOK, "image/png", "WIFI:S:myssid;;"},
+		{"query parameters", "/qrcode/hello.svg?w=100&fg=%23112233", http.StatusOK, "image/svg+xml", ""},
+		{"t precedes", "/qrcode/hello.svg?t=jpg", http.StatusOK, "image/jpeg", "hello"},
+		{"slash", "/qrcode/a/b.png", http.StatusNotFound, "", ""},
+		{"too long", "/qrcode/" + strings.Repeat("a", maxPathContentLength+1) + ".png", http.StatusRequestURITooLong, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + tt.path)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			require.Equal(t, tt.wantContentType, resp.Header.Get(request.HeaderContentType))
+
+			if tt.want != "" {
+				img, _, err := image.Decode(resp.Body)
+				require.NoError(t, err)
+				got, err := qrcode.Decode(img)
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExactSize(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name       string
+		params     map[string]string
+		wantStatus int
+		wantSize   image.Point
+	}{
+		{"exact", map[string]string{"w": "150", "h": "150"}, http.StatusOK, image.Pt(150, 150)},
+		{"dpr", map[string]string{"w": "100", "h": "100", "dpr": "2"}, http.StatusOK, image.Pt(200, 200)},
+		{"label", map[string]string{"w": "150", "h": "150", "label": "asset 1"}, http.StatusOK, image.Pt(150, 150)},
+		{"does not fit", map[string]string{"w": "21", "h": "21"}, http.StatusBadRequest, image.Point{}},
+		{"composite", map[string]string{"composite": "horizontal"}, http.StatusBadRequest, image.Point{}},
+		{"invalid", map[string]string{"exactSize": "yes"}, http.StatusBadRequest, image.Point{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request.Get("%s/qrcode", ts.URL).Query("content", "hello world")
+			if _, ok := tt.params["exactSize"]; !ok {
+				req = req.Query("exactSize", "true")
+			}
+			for k, v := range tt.params {
+				req = req.Query(k, v)
+			}
+			resp, err := req.Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if !resp.Success() {
+				return
+			}
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantSize, img.Bounds().Size())
+
+			// modules are hard edged, no blended pixels in the code
+			for y := 0; y < tt.wantSize.Y*3/4; y++ {
+				for x := 0; x < tt.wantSize.X; x++ {
+					gray := color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+					require.True(t, gray == 0 || gray == 0xff, "pixel %d,%d is %d", x, y, gray)
+				}
+			}
+
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, "hello world", got)
+		})
+	}
+}
+
+// FuzzGenerate the handler never panics and returns an image or an error message for any content and parameters;
+// run with `go test -run '^$' -fuzz FuzzGenerate`.
+func FuzzGenerate(f *testing.F) {
+	viper.Set("rate_limit", 1000000)
+	f.Cleanup(func() { viper.Set("rate_limit", 20) })
+
+	e := newEcho(config.Current(), io.Discard)
+	newAPIv1(config.Current(), codes.NewMemoryStore(), nil).Route(e, "")
+
+	seeds := [...]struct {
+		content, w, h, dpr, fg, bg, ec, format string
+		extra                                  string // other parameters as query string
+	}{
+		{"hello", "", "", "", "", "", "", "", ""},
+		{"https://example.com", "200", "200", "2", "#ff0000", "#ffffff", "H", "svg", "shape=dot&cornerRadius=50"},
+		{strings.Repeat("A", 4296), "21", "21", "1", "#000", "#fff", "L", "png", "margin=0"},
+		{"한글", "-1", "99999999999999999999", "0", "red", "rgb(", "X", "jpeg", "label=x&captionSize=1e308"},
+		{"hello", "100", "100", "3", "#00000000", "transparent", "Q", "txt", "mask=7&marginTop=-5&compositeSize=1"},
+		{"hello", "200", "50", "1", "", "", "M", "gif", "composite=vertical&exactSize=true"},
+		{"\x00\xff", "1", "1", "1.5", "#fffffffff", "#", "h", "pdf", "eyeInnerColor=%23&shape=rounded&logoAuto=true"},
+		{"", "", "", "", "", "", "", "", "url=http://[::1&w=abc"},
+	}
+	for _, s := range seeds {
+		f.Add(s.content, s.w, s.h, s.dpr, s.fg, s.bg, s.ec, s.format, s.extra)
+	}
+
+	f.Fuzz(func(t *testing.T, content, w, h, dpr, fg, bg, ec, format, extra string) {
+		query, err := url.ParseQuery(extra)
+		if err != nil {
+			t.Skip()
+		}
+		// remote images are fetched from the network
+		for _, k := range []string{"logo", "background", "halftone"} {
+			query.Del(k)
+		}
+		for k, v := range map[string]string{"content": content, "w": w, "h": h, "dpr": dpr, "fg": fg, "bg": bg, "ec": ec, "t": format} {
+			if v != "" {
+				query.Set(k, v)
+			}
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/qrcode?"+query.Encode(), nil)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		switch {
+		case rec.Code == http.StatusOK:
+			require.NotEmpty(t, rec.Header().Get(echo.HeaderContentType))
+			require.NotZero(t, rec.Body.Len())
+		case rec.Code >= http.StatusBadRequest && rec.Code < http.StatusInternalServerError:
+			got := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got), rec.Body.String())
+			require.NotEmpty(t, got["message"])
+		default:
+			t.Fatalf("status %d of %s: %s", rec.Code, query.Encode(), rec.Body.String())
+		}
+	})
 }
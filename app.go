@@ -2,30 +2,50 @@ package qrcodeapi
 
 import (
 	"context"
+	"io"
+	stdlog "log"
 	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
 	"strconv"
+	"strings"
+	"syscall"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
-	"github.com/whitekid/goxp/fx"
+	gommonlog "github.com/labstack/gommon/log"
 	"github.com/whitekid/goxp/log"
 	"github.com/whitekid/goxp/service"
-	"golang.org/x/time/rate"
 
 	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+	"qrcodeapi/pkg/links"
+	"qrcodeapi/pkg/token"
 )
 
-func Run(ctx context.Context) error { return New().Serve(ctx) }
+func Run(ctx context.Context, cfg *config.Config) error { return New(cfg).Serve(ctx) }
 
-type qrcodeService struct{}
+type qrcodeService struct {
+	cfg *config.Config
+}
 
 var _ service.Interface = (*qrcodeService)(nil)
 
-func New() service.Interface { return &qrcodeService{} }
+func New(cfg *config.Config) service.Interface { return &qrcodeService{cfg: cfg} }
 
 func (s *qrcodeService) Serve(ctx context.Context) error {
-	e := s.setup()
+	out, err := newLogOutput(s.cfg)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	e, err := s.setup(ctx, out)
+	if err != nil {
+		return err
+	}
 
 	go func() {
 		<-ctx.Done()
@@ -34,7 +54,25 @@ func (s *qrcodeService) Serve(ctx context.Context) error {
 		}
 	}()
 
-	if err := e.Start(config.BindAddr()); err != nil && err != http.ErrServerClosed {
+	watchHangup(ctx, e, out, s.cfg)
+
+	if s.cfg.MTLSBindAddr != "" {
+		ln, err := mtlsListener(s.cfg)
+		if err != nil {
+			return err
+		}
+
+		// shutdown together with the main listener by e.Shutdown()
+		e.TLSServer.Handler = e
+		e.TLSServer.ErrorLog = e.StdLogger
+		go func() {
+			if err := e.TLSServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+				e.Logger.Errorf("mtls listener: %s", err)
+			}
+		}()
+	}
+
+	if err := e.Start(s.cfg.BindAddr); err != nil && err != http.ErrServerClosed {
 		return err
 	}
 
@@ -45,17 +83,67 @@ type Validator struct {
 	validator *validator.Validate
 }
 
+// newValidator returns validator whose errors are of the parameter names, query, form or json name of the fields
+func newValidator() *Validator {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, key := range []string{"query", "form", "json"} {
+			if name, _, _ := strings.Cut(field.Tag.Get(key), ","); name != "" && name != "-" {
+				return name
+			}
+		}
+		return field.Name
+	})
+	return &Validator{validator: v}
+}
+
 func (v *Validator) Validate(i interface{}) error {
 	if err := v.validator.Struct(i); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
 	}
 	return nil
 }
 
-func newEcho() *echo.Echo {
+// logLevels echo log level of log_level config
+var logLevels = map[string]gommonlog.Lvl{
+	"debug": gommonlog.DEBUG,
+	"info":  gommonlog.INFO,
+	"warn":  gommonlog.WARN,
+	"error": gommonlog.ERROR,
+	"off":   gommonlog.OFF,
+}
+
+// watchHangup reopen log file and reload config on SIGHUP; log file is reopened for logrotate
+func watchHangup(ctx context.Context, e *echo.Echo, out logOutput, cfg *config.Config) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hup)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				if err := out.Reopen(); err != nil {
+					e.Logger.Errorf("reopen log file: %s", err)
+				}
+				reloadConfig(e, cfg)
+			}
+		}
+	}()
+}
+
+func newEcho(cfg *config.Config, out io.Writer) *echo.Echo {
 	e := echo.New()
 	e.HideBanner = true
-	e.Validator = &Validator{validator: validator.New()}
+	e.Logger.SetOutput(out)
+	e.Logger.SetLevel(logLevels[cfg.LogLevel])
+	e.StdLogger = stdlog.New(out, e.Logger.Prefix()+": ", 0)
+	e.HTTPErrorHandler = httpErrorHandler(e, cfg)
+	e.IPExtractor = ipExtractor(cfg)
+	e.Validator = newValidator()
 	e.Use(func(logCode int) echo.MiddlewareFunc {
 		return func(next echo.HandlerFunc) echo.HandlerFunc {
 			// log http errors
@@ -82,20 +170,80 @@ func newEcho() *echo.Echo {
 		}
 	}(http.StatusBadRequest))
 
-	e.Use(middleware.Logger())
-	e.Use(middleware.RateLimiter(middleware.NewRateLimiterMemoryStore(rate.Limit(config.RateLimit()))))
+	m := newMetrics()
+	e.Use(m.middleware())
+	e.GET(metricsPath, m.handler())
+
+	e.Use(redactRequestURI())
+	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{Output: out}))
+	e.Use(ipFilter(cfg))
+	e.Use(certPrincipal(cfg))
+	e.Use(middleware.RateLimiterWithConfig(middleware.RateLimiterConfig{
+		Store: &rateLimiterStore{cfg: cfg},
+		// monitoring probes self test and readiness frequently
+		Skipper: func(c echo.Context) bool { return c.Path() == selftestPath || c.Path() == readyzPath },
+		// clients authenticated by certificate are limited by the principal
+		IdentifierExtractor: func(c echo.Context) (string, error) {
+			if certAuthenticated(c) {
+				return principal(c), nil
+			}
+			return c.RealIP(), nil
+		},
+	}))
+	e.Use(withEnabledFormats(cfg))
 
 	return e
 }
 
-func (s *qrcodeService) setup() *echo.Echo {
-	e := newEcho()
+func (s *qrcodeService) setup(ctx context.Context, out io.Writer) (*echo.Echo, error) {
+	if err := validateRenderDefaults(s.cfg); err != nil {
+		return nil, err
+	}
+
+	e := newEcho(s.cfg, out)
 	e.GET("/", func(c echo.Context) error {
 		return c.Redirect(http.StatusFound, "https://github.com/whitekid/qrcodeapi")
 	})
-	newAPIv1().Route(e, "/v1")
 
-	return e
+	ready := newReadinessAPI()
+
+	var codeStore codes.Store
+	if s.cfg.CodesEnabled {
+		var err error
+		if codeStore, err = codes.NewStore(s.cfg.CodeStore, s.cfg.CodeStorePath); err != nil {
+			return nil, err
+		}
+		newCodesAPI(s.cfg, codeStore).Route(e, "")
+		// codes are generated without the store; only persisting and retrieving are degraded
+		ready.register(readinessCheck{name: "code_store", check: codeStore.Ping})
+	}
+
+	var linkStore links.Store
+	if s.cfg.LinksEnabled {
+		var err error
+		if linkStore, err = links.NewStore(s.cfg.LinkStore, s.cfg.LinkStorePath); err != nil {
+			return nil, err
+		}
+		newLinksAPI(ctx, s.cfg, linkStore).Route(e, "")
+		// printed codes redirect through the link store
+		ready.register(readinessCheck{name: "link_store", hard: true, check: linkStore.Ping})
+	}
+	newAPIv1(s.cfg, codeStore, linkStore).Route(e, "/v1")
+
+	if s.cfg.TokenKey != "" {
+		signer, err := token.NewSigner(s.cfg.TokenAlg, s.cfg.TokenKey)
+		if err != nil {
+			return nil, err
+		}
+		newTokenAPI(s.cfg, signer).Route(e, "")
+	}
+
+	newAdminAPI(s.cfg).Route(e, "")
+	newVersionAPI(s.cfg).Route(e, "")
+	newSelftestAPI(s.cfg).Route(e, "")
+	ready.Route(e, "")
+
+	return e, nil
 }
 
 func parseIntDef(s string, defaultValue, minValue, maxValue int) int {
@@ -104,5 +252,12 @@ func parseIntDef(s string, defaultValue, minValue, maxValue int) int {
 		return defaultValue
 	}
 
-	return fx.Min([]int{fx.Max([]int{value, minValue}), maxValue})
+	// NOTE fx.Min() returns the last one for two elements
+	switch {
+	case value < minValue:
+		return minValue
+	case value > maxValue:
+		return maxValue
+	}
+	return value
 }
@@ -3,14 +3,19 @@ package qrcodeapi
 import (
 	"context"
 	"net/http/httptest"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"qrcodeapi/config"
 )
 
-func newTestServer(ctx context.Context, r router) *httptest.Server {
-	e := newEcho()
-	r.Route(e, "")
+func newTestServer(ctx context.Context, routers ...router) *httptest.Server {
+	e := newEcho(config.Current(), os.Stdout)
+	for _, r := range routers {
+		r.Route(e, "")
+	}
 
 	ts := httptest.NewServer(e)
 	go func() {
@@ -35,6 +40,8 @@ func TestParseInt(t *testing.T) {
 	}{
 		{"default value", args{"", 10, 1, 100}, 10},
 		{"cut max", args{"200", 10, 1, 100}, 100},
+		{"cut min", args{"0", 10, 1, 100}, 1},
+		{"in range", args{"50", 10, 1, 100}, 50},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
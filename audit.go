@@ -0,0 +1,17 @@
+package qrcodeapi
+
+import (
+	"fmt"
+
+	"github.com/labstack/echo/v4"
+)
+
+// audit log mutation of the resource with the principal; secrets of string values, such as targets, are redacted
+func audit(c echo.Context, action string, resource string, format string, args ...interface{}) {
+	for i, arg := range args {
+		if s, ok := arg.(string); ok {
+			args[i] = redactPayload(s)
+		}
+	}
+	c.Logger().Infof("audit: principal=%s action=%s resource=%s %s", principal(c), action, resource, fmt.Sprintf(format, args...))
+}
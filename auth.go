@@ -0,0 +1,77 @@
+package qrcodeapi
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"qrcodeapi/config"
+)
+
+const ctxKeyPrincipal = "qrcodeapi.principal"
+
+// keyPrincipal identify api key without exposing the key
+func keyPrincipal(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "apikey:" + hex.EncodeToString(sum[:4])
+}
+
+// principal returns authenticated principal of the request
+func principal(c echo.Context) string {
+	if p, ok := c.Get(ctxKeyPrincipal).(string); ok {
+		return p
+	}
+	return "anonymous"
+}
+
+// requireAPIKey authenticate request with `Authorization: Bearer <api key>` or `X-API-Key` header.
+// all requests are rejected if no api keys are configured. requests authenticated by client certificate are passed.
+func requireAPIKey(cfg *config.Config) echo.MiddlewareFunc {
+	return middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
+		Skipper:   certAuthenticated,
+		KeyLookup: "header:" + echo.HeaderAuthorization + ",header:X-API-Key",
+		Validator: func(key string, c echo.Context) (bool, error) {
+			for _, k := range cfg.Snapshot().APIKeys {
+				if k != "" && subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+					c.Set(ctxKeyPrincipal, keyPrincipal(k))
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+		ErrorHandler: func(err error, c echo.Context) error {
+			return echo.NewHTTPError(http.StatusUnauthorized).SetInternal(err)
+		},
+	})
+}
+
+// requireAPIKeyIfConfigured authenticate request as requireAPIKey() only if api keys are configured
+func requireAPIKeyIfConfigured(cfg *config.Config) echo.MiddlewareFunc {
+	requireKey := requireAPIKey(cfg)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		authenticated := requireKey(next)
+		return func(c echo.Context) error {
+			if len(cfg.Snapshot().APIKeys) == 0 {
+				return next(c)
+			}
+			return authenticated(c)
+		}
+	}
+}
+
+// requireAuthConfigured hide the endpoint with 404 if neither api keys nor mtls listener is configured,
+// so that it is never open by default
+func requireAuthConfigured(cfg *config.Config) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if len(cfg.Snapshot().APIKeys) == 0 && cfg.MTLSBindAddr == "" {
+				return echo.ErrNotFound
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package qrcodeapi
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+
+	"qrcodeapi/pkg/qrcode"
+)
+
+const (
+	maxBackgroundBytes = 4 << 20
+	maxBackgroundSide  = 2048
+
+	backgroundCenter = "center"
+)
+
+// parseBackgroundPosition returns normalized position of the code in the background, `center` or `x,y`
+func parseBackgroundPosition(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || s == backgroundCenter {
+		return backgroundCenter, nil
+	}
+
+	pt, err := backgroundPoint(s)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d,%d", pt.X, pt.Y), nil
+}
+
+func backgroundPoint(s string) (image.Point, error) {
+	xs, ys, ok := strings.Cut(s, ",")
+	if !ok {
+		return image.Point{}, fmt.Errorf("invalid position: %s", s)
+	}
+
+	x, errX := strconv.Atoi(strings.TrimSpace(xs))
+	y, errY := strconv.Atoi(strings.TrimSpace(ys))
+	if errX != nil || errY != nil || x < 0 || y < 0 || x >= maxBackgroundSide || y >= maxBackgroundSide {
+		return image.Point{}, fmt.Errorf("invalid position: %s", s)
+	}
+	return image.Pt(x, y), nil
+}
+
+// backgroundPosition returns top-left of the code in the background image, nil if centered
+func (req *RenderRequest) backgroundPosition() *image.Point {
+	if req.BackgroundPos == "" || req.BackgroundPos == backgroundCenter {
+		return nil
+	}
+	pt, _ := backgroundPoint(req.BackgroundPos)
+	return &pt
+}
+
+// remoteImages urls of images fetched on rendering
+type remoteImages struct {
+	logo       string
+	background string
+	halftone   string
+}
+
+func (req *RenderRequest) remoteImages() remoteImages {
+	return remoteImages{logo: req.Logo, background: req.Background, halftone: req.Halftone}
+}
+
+// fetch fetch the images into the options
+func (r remoteImages) fetch(ctx context.Context, opts *qrcode.RenderOptions) (err error) {
+	if r.logo != "" {
+		if opts.Logo, err = fetchImage(ctx, r.logo, "logo", maxLogoBytes); err != nil {
+			return err
+		}
+	}
+
+	if r.background != "" {
+		if opts.BackgroundImage, err = fetchImage(ctx, r.background, "background", maxBackgroundBytes); err != nil {
+			return err
+		}
+	}
+
+	if r.halftone != "" {
+		if opts.Halftone, err = fetchImage(ctx, r.halftone, "halftone", maxBackgroundBytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
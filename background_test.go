@@ -0,0 +1,150 @@
+package qrcodeapi
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+	"qrcodeapi/pkg/qrcode"
+)
+
+var backgroundColor = color.RGBA{0x20, 0x40, 0x80, 0xff}
+
+func newBackgroundServer(ctx context.Context) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size := map[string]image.Point{"/bg.png": image.Pt(400, 300), "/large.png": image.Pt(maxBackgroundSide+1, 10)}[r.URL.Path]
+		if size == (image.Point{}) {
+			http.NotFound(w, r)
+			return
+		}
+
+		bg := image.NewRGBA(image.Rectangle{Max: size})
+		draw.Draw(bg, bg.Bounds(), image.NewUniform(backgroundColor), image.Point{}, draw.Src)
+		w.Header().Set("Content-Type", "image/png")
+		png.Encode(w, bg)
+	}))
+	go func() {
+		<-ctx.Done()
+		ts.Close()
+	}()
+	return ts
+}
+
+func TestBackground(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	bgServer := newBackgroundServer(ctx)
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	get := func(params map[string]string) *request.Response {
+		req := request.Get("%s/qrcode", ts.URL).Query("content", "https://github.com/whitekid/qrcodeapi")
+		for k, v := range params {
+			req = req.Query(k, v)
+		}
+		resp, err := req.Do(ctx)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// private addresses are refused
+	for _, u := range []string{bgServer.URL + "/bg.png", "http://169.254.169.254/latest/meta-data"} {
+		resp := get(map[string]string{"background": u})
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, u)
+	}
+
+	// test server listens on loopback
+	allowLoopback(t)
+
+	tests := [...]struct {
+		name       string
+		params     map[string]string
+		wantStatus int
+		wantCode   image.Point // top-left of the code
+	}{
+		{"centered", map[string]string{"background": bgServer.URL + "/bg.png"}, http.StatusOK, image.Pt(100, 50)},
+		{"position", map[string]string{"background": bgServer.URL + "/bg.png", "backgroundPos": "10,20", "w": "150", "h": "150"}, http.StatusOK, image.Pt(10, 20)},
+		{"out of background", map[string]string{"background": bgServer.URL + "/bg.png", "backgroundPos": "300,0"}, http.StatusBadRequest, image.Point{}},
+		{"invalid position", map[string]string{"background": bgServer.URL + "/bg.png", "backgroundPos": "left"}, http.StatusBadRequest, image.Point{}},
+		{"invalid url", map[string]string{"background": "file:///etc/passwd"}, http.StatusBadRequest, image.Point{}},
+		{"not found", map[string]string{"background": bgServer.URL + "/none.png"}, http.StatusBadRequest, image.Point{}},
+		{"too large", map[string]string{"background": bgServer.URL + "/large.png"}, http.StatusBadRequest, image.Point{}},
+		{"svg", map[string]string{"background": bgServer.URL + "/bg.png", "t": "svg"}, http.StatusBadRequest, image.Point{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := get(tt.params)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, image.Pt(400, 300), img.Bounds().Size())
+			require.Equal(t, backgroundColor, color.RGBAModel.Convert(img.At(399, 299)))
+			require.Equal(t, color.RGBA{0xff, 0xff, 0xff, 0xff}, color.RGBAModel.Convert(img.At(tt.wantCode.X, tt.wantCode.Y)))
+
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, "https://github.com/whitekid/qrcodeapi", got)
+		})
+	}
+}
+
+func TestHalftone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	bgServer := newBackgroundServer(ctx)
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	allowLoopback(t)
+
+	tests := [...]struct {
+		name       string
+		params     map[string]string
+		wantStatus int
+	}{
+		{"halftone", map[string]string{"halftone": bgServer.URL + "/bg.png"}, http.StatusOK},
+		{"composite", map[string]string{"halftone": bgServer.URL + "/bg.png", "composite": "horizontal", "w": "200", "compositeSize": "100"}, http.StatusOK},
+		{"too small", map[string]string{"halftone": bgServer.URL + "/bg.png", "w": "50", "h": "50"}, http.StatusBadRequest},
+		{"invalid url", map[string]string{"halftone": "file:///etc/passwd"}, http.StatusBadRequest},
+		{"not found", map[string]string{"halftone": bgServer.URL + "/none.png"}, http.StatusBadRequest},
+		{"svg", map[string]string{"halftone": bgServer.URL + "/bg.png", "t": "svg"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request.Get("%s/qrcode", ts.URL).Query("content", "https://github.com/whitekid/qrcodeapi")
+			for k, v := range tt.params {
+				req = req.Query(k, v)
+			}
+			resp, err := req.Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, "https://github.com/whitekid/qrcodeapi", got)
+		})
+	}
+}
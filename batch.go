@@ -0,0 +1,377 @@
+package qrcodeapi
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/whitekid/goxp/fx"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/pdf"
+)
+
+const (
+	mimePDF = "application/pdf"
+
+	layoutGrid    = "grid"
+	maxBatchItems = 500
+	maxSheetCols  = 20
+	maxSheetRows  = 40
+
+	sheetDPI      = 300  // resolution of the rendered cell images
+	maxCellPixels = 1200 // upper bound of rendered cell image size
+)
+
+var pageSizes = map[string]pdf.Size{
+	"a4":     pdf.A4,
+	"a5":     pdf.A5,
+	"letter": pdf.Letter,
+	"legal":  pdf.Legal,
+}
+
+var lengthUnits = map[string]float64{
+	"mm": pdf.Millimeter,
+	"cm": pdf.Centimeter,
+	"in": pdf.Inch,
+	"pt": pdf.Point,
+}
+
+var lengthPattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)(mm|cm|in|pt)$`)
+
+// parseLength parse physical length with unit, ex) `10mm`, `0.5in`; returns points
+func parseLength(s string) (float64, error) {
+	m := lengthPattern.FindStringSubmatch(strings.ToLower(s))
+	if m == nil {
+		return 0, fmt.Errorf("invalid length: %s", s)
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	return v * lengthUnits[m[2]], nil
+}
+
+// sheetLayout grid of equal cells on the page inside of the margin
+type sheetLayout struct {
+	page       pdf.Size
+	margin     float64 // points
+	cols, rows int
+	caption    bool // draw caption under each code
+}
+
+// parseSheetLayout parse layout parameters of batch output
+func parseSheetLayout(param func(name string) string) (*sheetLayout, error) {
+	if v := param("layout"); v != layoutGrid {
+		return nil, invalidParam("layout", v)
+	}
+
+	l := &sheetLayout{page: pdf.A4, margin: 10 * pdf.Millimeter, cols: 3, rows: 8}
+
+	for _, v := range [...]struct {
+		name     string
+		value    *int
+		maxValue int
+	}{{"cols", &l.cols, maxSheetCols}, {"rows", &l.rows, maxSheetRows}} {
+		s := param(v.name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > v.maxValue {
+			return nil, invalidParam(v.name, s)
+		}
+		*v.value = n
+	}
+
+	if v := param("page"); v != "" {
+		size, ok := pageSizes[strings.ToLower(v)]
+		if !ok {
+			return nil, invalidParam("page", v)
+		}
+		l.page = size
+	}
+
+	if v := param("margin"); v != "" {
+		margin, err := parseLength(v)
+		if err != nil || margin*2 >= math.Min(l.page.Width, l.page.Height) {
+			return nil, invalidParam("margin", v)
+		}
+		l.margin = margin
+	}
+
+	if v := param("caption"); v != "" {
+		caption, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, invalidParam("caption", v)
+		}
+		l.caption = caption
+	}
+
+	return l, nil
+}
+
+func (l *sheetLayout) perPage() int { return l.cols * l.rows }
+
+// cell returns page index and rect of i-th item; cells are filled from left to right, top to bottom
+func (l *sheetLayout) cell(i int) (int, pdf.Rect) {
+	width := (l.page.Width - l.margin*2) / float64(l.cols)
+	height := (l.page.Height - l.margin*2) / float64(l.rows)
+
+	n := i % l.perPage()
+	return i / l.perPage(), pdf.Rect{
+		X:      l.margin + float64(n%l.cols)*width,
+		Y:      l.margin + float64(n/l.cols)*height,
+		Width:  width,
+		Height: height,
+	}
+}
+
+// cellPixels returns size of the rendered code to print the cell in sheetDPI
+func (l *sheetLayout) cellPixels() int {
+	_, rect := l.cell(0)
+	side := int(math.Min(rect.Width, rect.Height) / pdf.Inch * sheetDPI)
+	switch {
+	case side < minSize:
+		return minSize
+	case side > maxCellPixels:
+		return maxCellPixels
+	}
+	return side
+}
+
+// fitRect returns the largest rect of the image size in the center of the cell
+func fitRect(cell pdf.Rect, size image.Point) pdf.Rect {
+	scale := math.Min(cell.Width/float64(size.X), cell.Height/float64(size.Y))
+	width, height := float64(size.X)*scale, float64(size.Y)*scale
+	return pdf.Rect{
+		X:      cell.X + (cell.Width-width)/2,
+		Y:      cell.Y + (cell.Height-height)/2,
+		Width:  width,
+		Height: height,
+	}
+}
+
+// caption returns label of the document, or its payload; wifi password is never used
+func (doc *QRCodeDocument) caption() string {
+	caption := doc.Label
+	switch {
+	case caption != "":
+	case doc.Content != "":
+		caption = doc.Content
+	case doc.URL != "":
+		caption = doc.URL
+	case doc.Wifi != nil:
+		caption = doc.Wifi.SSID
+	}
+
+	if r := []rune(caption); len(r) > maxLabelLength {
+		caption = string(r[:maxLabelLength])
+	}
+	return caption
+}
+
+// handleBatch lay out codes of json documents on pages of label sheet as pdf;
+// progress is streamed as server-sent events if `Accept: text/event-stream`, and codes are entries of zip or tar with `archive`.
+func (api *APIv1) handleBatch(c echo.Context) error {
+	if v := c.QueryParam("archive"); v != "" {
+		archive, err := parseArchive(v)
+		if err != nil {
+			return err
+		}
+
+		docs, err := decodeBatch(c)
+		if err != nil {
+			return err
+		}
+		if err := checkPixelBudget(api.cfg, docs, nil); err != nil {
+			return err
+		}
+		return renderBatchArchive(api.cfg, c, docs, archive)
+	}
+
+	accept := parseAccept(c.Request().Header.Get(echo.HeaderAccept))
+	if fx.Contains(accept, echo.MIMEApplicationJSON) {
+		docs, err := decodeBatch(c)
+		if err != nil {
+			return err
+		}
+		if err := checkPixelBudget(api.cfg, docs, nil); err != nil {
+			return err
+		}
+		return renderBatchJSON(api.cfg, c, docs)
+	}
+
+	layout, err := parseSheetLayout(c.QueryParam)
+	if err != nil {
+		return err
+	}
+
+	docs, err := decodeBatch(c)
+	if err != nil {
+		return err
+	}
+	if err := checkPixelBudget(api.cfg, docs, layout); err != nil {
+		return err
+	}
+
+	if fx.Contains(accept, mimeEventStream) {
+		return streamBatch(api.cfg, c, docs, layout)
+	}
+
+	out, err := renderSheet(api.cfg, c, docs, layout, nil)
+	if err != nil {
+		return err
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, mimePDF)
+	c.Response().WriteHeader(http.StatusOK)
+	_, err = out.WriteTo(c.Response())
+	return err
+}
+
+// decodeBatch decode json array of documents of the request
+func decodeBatch(c echo.Context) ([]*QRCodeDocument, error) {
+	var docs []*QRCodeDocument
+	if err := decodeDocument(c.Request().Body, &docs); err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 || len(docs) > maxBatchItems {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("number of items must be between 1 and %d", maxBatchItems))
+	}
+	return docs, nil
+}
+
+// checkPixelBudget returns bad request if total pixels of the items exceed batch_max_pixels config;
+// items are cells of the layout, or rendered in their own size if layout is nil.
+func checkPixelBudget(cfg *config.Config, docs []*QRCodeDocument, layout *sheetLayout) error {
+	total := 0
+	for _, doc := range docs {
+		total += batchItemPixels(cfg, doc, layout)
+	}
+	return checkPixelTotal(cfg, total)
+}
+
+// checkPixelTotal returns bad request if total pixels of rendered items exceed batch_max_pixels config
+func checkPixelTotal(cfg *config.Config, total int) error {
+	if budget := cfg.Snapshot().BatchPixels; budget > 0 && total > budget {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("total pixels of items %d exceeds the budget of %d pixels", total, budget))
+	}
+	return nil
+}
+
+// batchItemPixels returns pixels of the rendered item; invalid item is 0 as it is not rendered
+func batchItemPixels(cfg *config.Config, doc *QRCodeDocument, layout *sheetLayout) int {
+	if layout != nil {
+		side := layout.cellPixels()
+		return side * side
+	}
+
+	if doc == nil {
+		return 0
+	}
+	req, err := doc.renderRequest(cfg)
+	if err != nil {
+		return 0
+	}
+	return req.outputPixels()
+}
+
+// outputPixels returns the largest pixels of the rendered image
+func (req *RenderRequest) outputPixels() int {
+	if req.Background != "" {
+		// output is the size of the background, up to maxBackgroundSide
+		return maxBackgroundSide * maxBackgroundSide
+	}
+	return req.pixels(req.W) * req.pixels(req.H)
+}
+
+// renderSheet render the documents on pages of the layout; progress is called after each item is drawn.
+// rendering is stopped if the request context is done.
+func renderSheet(cfg *config.Config, c echo.Context, docs []*QRCodeDocument, layout *sheetLayout, progress func(i int)) (*pdf.Document, error) {
+	out := pdf.New()
+	var page *pdf.Page
+	for i, doc := range docs {
+		if err := c.Request().Context().Err(); err != nil {
+			return nil, err
+		}
+
+		img, err := renderCell(cfg, c, doc, layout)
+		if err != nil {
+			return nil, batchItemError(i, err)
+		}
+
+		// page is added by its first cell, so the last page may be partial
+		n, rect := layout.cell(i)
+		if n == out.Pages() {
+			page = out.AddPage(layout.page)
+		}
+		if err := page.DrawImage(img, fitRect(rect, img.Bounds().Size())); err != nil {
+			return nil, err
+		}
+
+		if progress != nil {
+			progress(i)
+		}
+	}
+	return out, nil
+}
+
+// renderCell render code of the document in cell size of the layout
+func renderCell(cfg *config.Config, c echo.Context, doc *QRCodeDocument, layout *sheetLayout) (image.Image, error) {
+	if doc == nil {
+		return nil, documentError("", "item is required")
+	}
+
+	req, err := doc.renderRequest(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	_, qr, err := doc.qrcode(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkPayload(cfg, c, qr.Content); err != nil {
+		return nil, err
+	}
+
+	// cells are rendered in sheetDPI
+	req.DPR = 1
+	req.Label = ""
+	if layout.caption {
+		req.Label = doc.caption()
+	}
+
+	opts := req.options()
+	opts.Width = layout.cellPixels()
+	opts.Height = opts.Width
+	if opts.Composite != nil {
+		opts.Composite.Size = opts.Composite.Size * opts.Width / req.W
+	}
+
+	if err := req.remoteImages().fetch(c.Request().Context(), opts); err != nil {
+		return nil, err
+	}
+
+	img, err := qr.RenderWithOptions(opts)
+	if err != nil {
+		return nil, renderError(err)
+	}
+	return img, nil
+}
+
+// batchItemError returns error of the item with its index; internal error is not the item error,
+// or echo responds with the internal error instead
+func batchItemError(i int, err error) error {
+	if he, ok := err.(*echo.HTTPError); ok {
+		return echo.NewHTTPError(he.Code, fmt.Sprintf("item %d: %v", i, he.Message)).SetInternal(he.Internal)
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+package qrcodeapi
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/labstack/echo/v4"
+
+	"qrcodeapi/config"
+)
+
+// batchArchive archive of rendered items, one entry per item
+type batchArchive interface {
+	add(name string, data []byte) error
+	Close() error
+}
+
+var batchArchives = map[string]struct {
+	mimeType string
+	new      func(w io.Writer, modTime time.Time) batchArchive
+}{
+	"zip": {"application/zip", newZipArchive},
+	"tar": {"application/x-tar", newTarArchive},
+}
+
+// archiveExtensions file extension of formats whose name is not the extension
+var archiveExtensions = map[string]string{
+	"markdown": "md",
+	"imgtag":   "html",
+}
+
+const maxFileNameLength = 64
+
+// archiveName returns entry name of the i-th item, ex) `003.png`
+func archiveName(i int, format *imageFormat) string {
+	return fmt.Sprintf("%03d.%s", i, archiveExtension(format))
+}
+
+func archiveExtension(format *imageFormat) string {
+	if ext, ok := archiveExtensions[format.name]; ok {
+		return ext
+	}
+	return format.name
+}
+
+// safeFileName returns the name with characters not allowed in file names replaced with `_`,
+// ex) `Kim_Minsu` of `Kim/Minsu`; i-th index is used if the name is empty.
+func safeFileName(name string, i int) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+
+	if r := []rune(name); len(r) > maxFileNameLength {
+		name = string(r[:maxFileNameLength])
+	}
+
+	// no hidden or relative names
+	if name = strings.Trim(name, " ."); name == "" {
+		return fmt.Sprintf("%03d", i)
+	}
+	return name
+}
+
+// uniqueName returns entry name of the name and extension; the same name gets `-2`, `-3` suffix.
+// names counts the names already used.
+func uniqueName(names map[string]int, name, ext string) string {
+	if names[name]++; names[name] > 1 {
+		name = fmt.Sprintf("%s-%d", name, names[name])
+	}
+	return name + ext
+}
+
+// renderBatchArchive render each document in its own size and format as an entry of the archive;
+// an invalid item fails the whole request as the sheet does.
+func renderBatchArchive(cfg *config.Config, c echo.Context, docs []*QRCodeDocument, archive string) error {
+	a := batchArchives[archive]
+
+	var buf bytes.Buffer
+	w := a.new(&buf, time.Now())
+	for i, doc := range docs {
+		format, data, err := renderBatchItem(cfg, c, doc)
+		if err != nil {
+			return batchItemError(i, err)
+		}
+
+		if err := w.add(archiveName(i, format), data); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Blob(http.StatusOK, a.mimeType, buf.Bytes())
+}
+
+// parseArchive returns normalized archive type of batch output
+func parseArchive(s string) (string, error) {
+	s = strings.ToLower(s)
+	if _, ok := batchArchives[s]; !ok {
+		return "", invalidParam("archive", s)
+	}
+	return s, nil
+}
+
+type zipArchive struct {
+	*zip.Writer
+	modTime time.Time
+}
+
+func newZipArchive(w io.Writer, modTime time.Time) batchArchive {
+	return &zipArchive{Writer: zip.NewWriter(w), modTime: modTime}
+}
+
+func (a *zipArchive) add(name string, data []byte) error {
+	f, err := a.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: a.modTime})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+type tarArchive struct {
+	*tar.Writer
+	modTime time.Time
+}
+
+func newTarArchive(w io.Writer, modTime time.Time) batchArchive {
+	return &tarArchive{Writer: tar.NewWriter(w), modTime: modTime}
+}
+
+func (a *tarArchive) add(name string, data []byte) error {
+	if err := a.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o644,
+		Size:     int64(len(data)),
+		ModTime:  a.modTime,
+	}); err != nil {
+		return err
+	}
+	_, err := a.Write(data)
+	return err
+}
@@ -0,0 +1,110 @@
+package qrcodeapi
+
+import (
+	"encoding/base64"
+	"fmt"
+	"image/color"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/qrcode"
+)
+
+// BatchResponse response of json batch; status is 207 Multi-Status if any item is failed
+type BatchResponse struct {
+	Items []*BatchItemResult `json:"items"`
+}
+
+// BatchItemResult result of an item; data is base64 encoded image if succeeded, or error message
+type BatchItemResult struct {
+	Index       int    `json:"index"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Data        string `json:"data,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// renderBatchJSON render each document in its own size and format; failed items don't fail the others,
+// so that clients can retry only failed ones.
+func renderBatchJSON(cfg *config.Config, c echo.Context, docs []*QRCodeDocument) error {
+	resp := &BatchResponse{Items: make([]*BatchItemResult, len(docs))}
+	status := http.StatusOK
+	for i, doc := range docs {
+		result := &BatchItemResult{Index: i, Status: http.StatusOK}
+		format, data, err := renderBatchItem(cfg, c, doc)
+		if err != nil {
+			result.Status, result.Error = http.StatusInternalServerError, err.Error()
+			if he, ok := err.(*echo.HTTPError); ok {
+				result.Status, result.Error = he.Code, fmt.Sprintf("%v", he.Message)
+			}
+			if result.Status >= http.StatusInternalServerError {
+				c.Logger().Errorf("batch item %d: %s", i, err)
+			}
+			status = http.StatusMultiStatus
+		} else {
+			result.ContentType = format.mimeType
+			result.Data = base64.StdEncoding.EncodeToString(data)
+		}
+		resp.Items[i] = result
+	}
+
+	return c.JSON(status, resp)
+}
+
+// renderBatchItem render the document in its own size and format; returns the format and rendered body
+func renderBatchItem(cfg *config.Config, c echo.Context, doc *QRCodeDocument) (*imageFormat, []byte, error) {
+	if doc == nil {
+		return nil, nil, documentError("", "item is required")
+	}
+
+	req, err := doc.renderRequest(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	kind, qr, err := doc.qrcode(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return renderFile(cfg, c, qr, req, kind)
+}
+
+// renderFile render the code as a file of the format of the request, such as an archive entry; Accept is not negotiated
+func renderFile(cfg *config.Config, c echo.Context, qr *qrcode.QR, req *RenderRequest, kind string) (*imageFormat, []byte, error) {
+	if err := checkPayload(cfg, c, qr.Content); err != nil {
+		return nil, nil, err
+	}
+
+	format := negotiateFormat(cfg, req.T, "", "")
+	if err := checkFormat(cfg, format, req); err != nil {
+		return nil, nil, err
+	}
+
+	opts := req.options()
+	if !format.transparent {
+		opts.CornerBackground = color.White
+	}
+
+	out, err := renderImage(c.Request().Context(), cfg, qr, format.withEncoding(req), opts, req.remoteImages(), false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data := out.data
+	if format.embedsPNG() {
+		alt := req.Alt
+		if alt == "" {
+			alt = embedAlt(kind)
+		}
+		body, err := embedImage(format, out.data, &embedOptions{alt: alt, dpr: req.scale()})
+		if err != nil {
+			return nil, nil, err
+		}
+		data = []byte(body)
+	}
+
+	return format, data, nil
+}
@@ -0,0 +1,201 @@
+package qrcodeapi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/qrcode"
+)
+
+// maxSequenceContent upper bound of expanded template; longer than the capacity of the largest code
+const maxSequenceContent = 4096
+
+// SequenceRequest batch of codes whose contents differ by a counter, such as asset tags
+type SequenceRequest struct {
+	Template         string `json:"template"` // content of the item, ex) `https://a.example/t/{{printf "%06d" .N}}`
+	Start            int    `json:"start"`
+	Count            int    `json:"count"`
+	FilenameTemplate string `json:"filenameTemplate,omitempty"` // entry name, `{{.N}}` if empty
+}
+
+// sequenceItem template data of an item
+type sequenceItem struct {
+	N int // start + index of the item
+}
+
+// sequence parsed templates of the request
+type sequence struct {
+	start, count int
+	content      *template.Template
+	filename     *template.Template
+}
+
+// parseSequence parse templates of the request and check the count; templates are executed for the first item
+// so that errors such as unknown fields are rejected before rendering.
+func parseSequence(cfg *config.Config, req *SequenceRequest) (*sequence, error) {
+	if req.Template == "" {
+		return nil, documentError("template", "required")
+	}
+
+	if maxCount := cfg.Snapshot().BatchSequence; req.Count < 1 || req.Count > maxCount {
+		return nil, documentError("count", fmt.Sprintf("must be between 1 and %d", maxCount))
+	}
+
+	seq := &sequence{start: req.Start, count: req.Count}
+	for _, t := range []struct {
+		path  string
+		text  string
+		value **template.Template
+	}{
+		{"template", req.Template, &seq.content},
+		{"filenameTemplate", req.FilenameTemplate, &seq.filename},
+	} {
+		if t.text == "" {
+			t.text = "{{.N}}"
+		}
+
+		tmpl, err := template.New(t.path).Parse(t.text)
+		if err != nil {
+			return nil, documentError(t.path, err.Error())
+		}
+		*t.value = tmpl
+
+		if _, err := expand(tmpl, seq.start); err != nil {
+			return nil, documentError(t.path, err.Error())
+		}
+	}
+
+	return seq, nil
+}
+
+// contentOf returns content of the i-th item
+func (seq *sequence) contentOf(i int) (string, error) { return expand(seq.content, seq.start+i) }
+
+// filenameOf returns entry name of the i-th item without extension, and the extension if the name has
+func (seq *sequence) filenameOf(i int) (name string, ext string, err error) {
+	name, err = expand(seq.filename, seq.start+i)
+	if err != nil {
+		return "", "", err
+	}
+
+	name = safeFileName(name, i)
+	ext = path.Ext(name)
+	return strings.TrimSuffix(name, ext), ext, nil
+}
+
+var errSequenceContent = fmt.Errorf("expanded template is longer than %d bytes", maxSequenceContent)
+
+// expand execute the template of n; output is limited to maxSequenceContent
+func expand(tmpl *template.Template, n int) (string, error) {
+	w := &limitedBuffer{limit: maxSequenceContent}
+	if err := tmpl.Execute(w, &sequenceItem{N: n}); err != nil {
+		if errors.Is(err, errSequenceContent) {
+			return "", errSequenceContent
+		}
+		return "", err
+	}
+	return w.String(), nil
+}
+
+// limitedBuffer buffer which fails writes over the limit
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, errSequenceContent
+	}
+	return b.Buffer.Write(p)
+}
+
+// handleBatchSequence render codes of the content template for start..start+count-1 as zip or tar;
+// render options are query parameters of GET /qrcode.
+func (api *APIv1) handleBatchSequence(c echo.Context) error {
+	archive := "zip"
+	if v := c.QueryParam("archive"); v != "" {
+		var err error
+		if archive, err = parseArchive(v); err != nil {
+			return err
+		}
+	}
+
+	body := &SequenceRequest{}
+	if err := decodeDocument(c.Request().Body, body); err != nil {
+		return err
+	}
+
+	seq, err := parseSequence(api.cfg, body)
+	if err != nil {
+		return err
+	}
+
+	params, err := renderParams(api.cfg, c)
+	if err != nil {
+		return err
+	}
+
+	req, err := parseRenderRequest(api.cfg, params)
+	if err != nil {
+		return err
+	}
+
+	if err := checkPixelTotal(api.cfg, seq.count*req.outputPixels()); err != nil {
+		return err
+	}
+
+	a := batchArchives[archive]
+	var buf bytes.Buffer
+	w := a.new(&buf, time.Now())
+	names := map[string]int{}
+	for i := 0; i < seq.count; i++ {
+		if err := c.Request().Context().Err(); err != nil {
+			return err
+		}
+
+		content, err := seq.contentOf(i)
+		if err != nil {
+			return batchItemError(i, documentError("template", err.Error()))
+		}
+		if content == "" {
+			return batchItemError(i, documentError("template", "expanded to empty content"))
+		}
+
+		qr, err := qrcode.Text(content)
+		if err != nil {
+			return batchItemError(i, echo.NewHTTPError(http.StatusBadRequest, err.Error()))
+		}
+
+		format, data, err := renderFile(api.cfg, c, qr, req, kindText)
+		if err != nil {
+			return batchItemError(i, err)
+		}
+
+		name, ext, err := seq.filenameOf(i)
+		if err != nil {
+			return batchItemError(i, documentError("filenameTemplate", err.Error()))
+		}
+		if ext == "" {
+			ext = "." + archiveExtension(format)
+		}
+
+		if err := w.add(uniqueName(names, name, ext), data); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Blob(http.StatusOK, a.mimeType, buf.Bytes())
+}
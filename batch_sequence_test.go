@@ -0,0 +1,101 @@
+package qrcodeapi
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"image"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/qrcode"
+)
+
+func TestBatchSequence(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cfg := config.Current()
+	cfg.BatchSequence = 20
+	ts := newTestServer(ctx, newAPIv1(cfg, nil, nil))
+
+	tests := [...]struct {
+		name         string
+		query        map[string]string
+		body         map[string]interface{}
+		wantStatus   int
+		wantNames    []string
+		wantContents map[string]string // decoded contents of some entries
+	}{
+		{"zero padding", nil, map[string]interface{}{"template": `https://a.example/t/{{printf "%06d" .N}}`, "start": 9, "count": 3, "filenameTemplate": "tag-{{.N}}.png"},
+			http.StatusOK, []string{"tag-9.png", "tag-10.png", "tag-11.png"},
+			map[string]string{"tag-9.png": "https://a.example/t/000009", "tag-11.png": "https://a.example/t/000011"}},
+		{"default name", map[string]string{"t": "jpeg"}, map[string]interface{}{"template": "asset-{{.N}}", "count": 2},
+			http.StatusOK, []string{"0.jpeg", "1.jpeg"}, map[string]string{"1.jpeg": "asset-1"}},
+		{"same name", nil, map[string]interface{}{"template": "asset-{{.N}}", "count": 2, "filenameTemplate": "tag"},
+			http.StatusOK, []string{"tag.png", "tag-2.png"}, nil},
+		{"cap", nil, map[string]interface{}{"template": "asset-{{.N}}", "count": 21}, http.StatusBadRequest, nil, nil},
+		{"zero count", nil, map[string]interface{}{"template": "asset-{{.N}}"}, http.StatusBadRequest, nil, nil},
+		{"no template", nil, map[string]interface{}{"count": 1}, http.StatusBadRequest, nil, nil},
+		{"parse error", nil, map[string]interface{}{"template": "asset-{{.N", "count": 2}, http.StatusBadRequest, nil, nil},
+		{"unknown field", nil, map[string]interface{}{"template": "asset-{{.Serial}}", "count": 2}, http.StatusBadRequest, nil, nil},
+		{"filename error", nil, map[string]interface{}{"template": "asset-{{.N}}", "count": 2, "filenameTemplate": "{{.N"}, http.StatusBadRequest, nil, nil},
+		{"too long", nil, map[string]interface{}{"template": `{{range 5000}}a{{end}}`, "count": 1}, http.StatusBadRequest, nil, nil},
+		{"unknown body field", nil, map[string]interface{}{"template": "asset-{{.N}}", "count": 1, "size": 1}, http.StatusBadRequest, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request.Post("%s/batch/sequence", ts.URL)
+			for k, v := range tt.query {
+				req = req.Query(k, v)
+			}
+			resp, err := req.JSON(tt.body).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			require.Equal(t, "application/zip", resp.Header.Get("Content-Type"))
+
+			data, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+			require.NoError(t, err)
+
+			var names []string
+			for _, f := range r.File {
+				names = append(names, f.Name)
+				want, ok := tt.wantContents[f.Name]
+				if !ok {
+					continue
+				}
+
+				rc, err := f.Open()
+				require.NoError(t, err)
+				img, _, err := image.Decode(rc)
+				rc.Close()
+				require.NoError(t, err)
+				got, err := qrcode.Decode(img)
+				require.NoError(t, err)
+				require.Equal(t, want, got)
+			}
+			require.Equal(t, tt.wantNames, names)
+		})
+	}
+
+	// budget is checked before rendering
+	cfg.BatchPixels = 100
+	resp, err := request.Post("%s/batch/sequence", ts.URL).JSON(map[string]interface{}{"template": "asset-{{.N}}", "count": 2}).Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	b, _ := io.ReadAll(resp.Body)
+	require.Contains(t, string(b), "exceeds the budget")
+}
@@ -0,0 +1,91 @@
+package qrcodeapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"qrcodeapi/config"
+)
+
+const (
+	mimeEventStream = "text/event-stream"
+
+	maxBatchResults = 16 // rendered pdf of streamed batches kept for download
+)
+
+// batchResults rendered pdf of streamed batches by id; the least recently used ones are evicted
+var batchResults lruCache[[]byte]
+
+// BatchItemEvent `item` event, the item is drawn
+type BatchItemEvent struct {
+	Index int `json:"index"`
+	Total int `json:"total"`
+}
+
+// BatchDoneEvent `done` event, the pdf is ready to download
+type BatchDoneEvent struct {
+	Pages int    `json:"pages"`
+	URL   string `json:"url"`
+}
+
+// BatchErrorEvent `error` event, the batch is failed
+type BatchErrorEvent struct {
+	Message string `json:"message"`
+}
+
+// streamBatch render the batch and stream progress as server-sent events;
+// `item` for each drawn item, then `done` with download url or `error`.
+func streamBatch(cfg *config.Config, c echo.Context, docs []*QRCodeDocument, layout *sheetLayout) error {
+	resp := c.Response()
+	resp.Header().Set(echo.HeaderContentType, mimeEventStream)
+	resp.Header().Set("Cache-Control", "no-cache")
+	resp.WriteHeader(http.StatusOK)
+
+	send := func(event string, data interface{}) {
+		b, _ := json.Marshal(data)
+		fmt.Fprintf(resp, "event: %s\ndata: %s\n\n", event, b)
+		resp.Flush()
+	}
+
+	out, err := renderSheet(cfg, c, docs, layout, func(i int) {
+		send("item", &BatchItemEvent{Index: i, Total: len(docs)})
+	})
+	if err != nil {
+		// status is already sent, error is reported as event
+		message := err.Error()
+		if he, ok := err.(*echo.HTTPError); ok {
+			message = fmt.Sprintf("%v", he.Message)
+		}
+		c.Logger().Errorf("batch: %s", err)
+		send("error", &BatchErrorEvent{Message: message})
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := out.WriteTo(&buf); err != nil {
+		send("error", &BatchErrorEvent{Message: err.Error()})
+		return nil
+	}
+
+	id, err := resultID()
+	if err != nil {
+		send("error", &BatchErrorEvent{Message: err.Error()})
+		return nil
+	}
+	batchResults.Add(id, buf.Bytes(), maxBatchResults)
+	send("done", &BatchDoneEvent{Pages: out.Pages(), URL: publicURL(cfg, c, c.Path()+"/"+id)})
+	return nil
+}
+
+// handleBatchResult download pdf of streamed batch
+func (api *APIv1) handleBatchResult(c echo.Context) error {
+	data, ok := batchResults.Get(c.Param("id"))
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "batch not found: "+c.Param("id"))
+	}
+	return c.Blob(http.StatusOK, mimePDF, data)
+}
@@ -0,0 +1,500 @@
+package qrcodeapi
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/fx"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/pdf"
+	"qrcodeapi/pkg/qrcode"
+)
+
+func TestSheetLayout(t *testing.T) {
+	layout := &sheetLayout{page: pdf.Size{Width: 100, Height: 200}, margin: 10, cols: 2, rows: 3}
+
+	tests := [...]struct {
+		index    int
+		wantPage int
+		wantRect pdf.Rect
+	}{
+		{0, 0, pdf.Rect{X: 10, Y: 10, Width: 40, Height: 60}},
+		{1, 0, pdf.Rect{X: 50, Y: 10, Width: 40, Height: 60}},
+		{2, 0, pdf.Rect{X: 10, Y: 70, Width: 40, Height: 60}},
+		{5, 0, pdf.Rect{X: 50, Y: 130, Width: 40, Height: 60}},
+		{6, 1, pdf.Rect{X: 10, Y: 10, Width: 40, Height: 60}},
+		{9, 1, pdf.Rect{X: 50, Y: 70, Width: 40, Height: 60}},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.index), func(t *testing.T) {
+			page, rect := layout.cell(tt.index)
+			require.Equal(t, tt.wantPage, page)
+			require.Equal(t, tt.wantRect, rect)
+		})
+	}
+
+	// image is fitted in the center of the cell
+	require.Equal(t, pdf.Rect{X: 10, Y: 20, Width: 40, Height: 40}, fitRect(pdf.Rect{X: 10, Y: 10, Width: 40, Height: 60}, image.Pt(200, 200)))
+	require.Equal(t, pdf.Rect{X: 15, Y: 10, Width: 30, Height: 60}, fitRect(pdf.Rect{X: 10, Y: 10, Width: 40, Height: 60}, image.Pt(100, 200)))
+}
+
+func TestParseSheetLayout(t *testing.T) {
+	tests := [...]struct {
+		name    string
+		params  map[string]string
+		want    *sheetLayout
+		wantErr bool
+	}{
+		{"default", map[string]string{"layout": "grid"}, &sheetLayout{page: pdf.A4, margin: 10 * pdf.Millimeter, cols: 3, rows: 8}, false},
+		{"letter", map[string]string{"layout": "grid", "cols": "3", "rows": "10", "page": "Letter", "margin": "0.5in", "caption": "true"},
+			&sheetLayout{page: pdf.Letter, margin: 36, cols: 3, rows: 10, caption: true}, false},
+		{"no layout", map[string]string{}, nil, true},
+		{"unknown layout", map[string]string{"layout": "list"}, nil, true},
+		{"cols", map[string]string{"layout": "grid", "cols": "0"}, nil, true},
+		{"rows", map[string]string{"layout": "grid", "rows": "41"}, nil, true},
+		{"page", map[string]string{"layout": "grid", "page": "B5"}, nil, true},
+		{"margin unit", map[string]string{"layout": "grid", "margin": "10"}, nil, true},
+		{"margin too large", map[string]string{"layout": "grid", "margin": "11cm"}, nil, true},
+		{"caption", map[string]string{"layout": "grid", "caption": "yes"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSheetLayout(func(name string) string { return tt.params[name] })
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBatch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), nil, nil))
+
+	items := func(n int) []map[string]interface{} {
+		items := make([]map[string]interface{}, n)
+		for i := range items {
+			items[i] = map[string]interface{}{"content": fmt.Sprintf("asset-%03d", i)}
+		}
+		return items
+	}
+
+	tests := [...]struct {
+		name       string
+		query      string
+		items      interface{}
+		wantStatus int
+		wantPages  int
+	}{
+		{"one page", "layout=grid&cols=3&rows=8", items(24), http.StatusOK, 1},
+		{"partial last page", "layout=grid&cols=3&rows=8", items(25), http.StatusOK, 2},
+		{"letter 30 up", "layout=grid&cols=3&rows=10&page=letter&margin=0.5in&caption=true", items(61), http.StatusOK, 3},
+		{"no layout", "", items(1), http.StatusBadRequest, 0},
+		{"empty", "layout=grid", items(0), http.StatusBadRequest, 0},
+		{"too many", "layout=grid", items(maxBatchItems + 1), http.StatusBadRequest, 0},
+		{"invalid item", "layout=grid", []map[string]interface{}{{"content": "a"}, {"width": 10}}, http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Post("%s/batch?%s", ts.URL, tt.query).JSON(tt.items).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if !resp.Success() {
+				return
+			}
+			require.Equal(t, "application/pdf", resp.Header.Get(request.HeaderContentType))
+
+			data, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Contains(t, string(data), fmt.Sprintf("/Count %d", tt.wantPages))
+		})
+	}
+}
+
+func TestBatchCell(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), nil, nil))
+
+	resp, err := request.Post("%s/batch?layout=grid&cols=2&rows=2&caption=true", ts.URL).
+		JSON([]map[string]interface{}{
+			{"content": "asset-001"},
+			{"url": "https://example.com/asset/002", "label": "asset 2"},
+			{"wifi": map[string]string{"ssid": "office", "auth": "WPA", "pass": "secret"}},
+		}).
+		Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.True(t, resp.Success())
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "/Count 1")
+
+	// second cell of 2x2 grid on A4 inside 10mm margin
+	layout := &sheetLayout{page: pdf.A4, margin: 10 * pdf.Millimeter, cols: 2, rows: 2}
+	_, cell := layout.cell(1)
+	require.InDelta(t, 297.64, cell.X, 0.01)
+
+	images := pdfImages(t, data)
+	require.Len(t, images, 3)
+
+	got, err := qrcode.Decode(images[1])
+	require.NoError(t, err)
+	require.Equal(t, "URLTO:https://example.com/asset/002", got)
+}
+
+var pdfImagePattern = regexp.MustCompile(`/Width (\d+) /Height (\d+) .*?/Length (\d+) >>\nstream\n`)
+
+// pdfImages rasterize images of the pdf written by pkg/pdf
+func pdfImages(t *testing.T, data []byte) []image.Image {
+	var images []image.Image
+	for _, m := range pdfImagePattern.FindAllSubmatchIndex(data, -1) {
+		width, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		height, _ := strconv.Atoi(string(data[m[4]:m[5]]))
+		length, _ := strconv.Atoi(string(data[m[6]:m[7]]))
+
+		zr, err := zlib.NewReader(bytes.NewReader(data[m[1] : m[1]+length]))
+		require.NoError(t, err)
+		pix, err := io.ReadAll(zr)
+		require.NoError(t, err)
+
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		for i := 0; i < width*height; i++ {
+			copy(img.Pix[i*4:], []byte{pix[i*3], pix[i*3+1], pix[i*3+2], 0xff})
+		}
+		images = append(images, img)
+	}
+	return images
+}
+
+func TestBatchStream(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), nil, nil))
+
+	stream := func(items interface{}) []sseEvent {
+		resp, err := request.Post("%s/batch?layout=grid&cols=2&rows=1", ts.URL).
+			Header(echo.HeaderAccept, "text/event-stream").
+			JSON(items).
+			Do(ctx)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "text/event-stream", resp.Header.Get(request.HeaderContentType))
+
+		return readEvents(t, resp.Body)
+	}
+
+	events := stream([]map[string]interface{}{{"content": "a"}, {"content": "b"}, {"content": "c"}})
+	require.Equal(t, []string{"item", "item", "item", "done"}, fx.Map(events, func(e sseEvent) string { return e.event }))
+	for i, e := range events[:3] {
+		item := &BatchItemEvent{}
+		require.NoError(t, json.Unmarshal([]byte(e.data), item))
+		require.Equal(t, &BatchItemEvent{Index: i, Total: 3}, item)
+	}
+
+	done := &BatchDoneEvent{}
+	require.NoError(t, json.Unmarshal([]byte(events[3].data), done))
+	require.Equal(t, 2, done.Pages)
+	require.Regexp(t, "^"+ts.URL+"/batch/[A-Za-z0-9]+$", done.URL)
+
+	resp, err := request.Get(done.URL).Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.True(t, resp.Success())
+	require.Equal(t, "application/pdf", resp.Header.Get(request.HeaderContentType))
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "/Count 2")
+
+	// failed item
+	events = stream([]map[string]interface{}{{"content": "a"}, {"content": "b", "ec": "X"}})
+	require.Equal(t, []string{"item", "error"}, fx.Map(events, func(e sseEvent) string { return e.event }))
+	require.Contains(t, events[1].data, "item 1: ec: invalid value")
+
+	resp, err = request.Get("%s/batch/unknown", ts.URL).Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+type sseEvent struct {
+	event, data string
+}
+
+// readEvents read server-sent events until the stream is closed
+func readEvents(t *testing.T, r io.Reader) []sseEvent {
+	var events []sseEvent
+	current := sseEvent{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			events = append(events, current)
+			current = sseEvent{}
+		case strings.HasPrefix(line, "event: "):
+			current.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func TestBatchJSON(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), nil, nil))
+
+	tests := [...]struct {
+		name       string
+		items      interface{}
+		wantStatus int
+		wantItems  []int
+	}{
+		{"all succeeded", []map[string]interface{}{{"content": "asset-001"}, {"content": "asset-002", "type": "svg"}},
+			http.StatusOK, []int{http.StatusOK, http.StatusOK}},
+		{"partial", []map[string]interface{}{{"content": "asset-001"}, {"content": "asset-002", "width": 10}, {"url": "https://example.com"}},
+			http.StatusMultiStatus, []int{http.StatusOK, http.StatusBadRequest, http.StatusOK}},
+		{"all failed", []interface{}{nil, map[string]interface{}{"content": "a", "composite": map[string]string{"layout": "vertical"}, "type": "svg"}},
+			http.StatusMultiStatus, []int{http.StatusBadRequest, http.StatusBadRequest}},
+		{"empty", []interface{}{}, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Post("%s/batch", ts.URL).
+				Header(echo.HeaderAccept, echo.MIMEApplicationJSON).
+				JSON(tt.items).
+				Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantItems == nil {
+				return
+			}
+
+			var got BatchResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+			require.Len(t, got.Items, len(tt.wantItems))
+			for i, item := range got.Items {
+				require.Equal(t, i, item.Index)
+				require.Equal(t, tt.wantItems[i], item.Status)
+				if item.Status != http.StatusOK {
+					require.NotEmpty(t, item.Error)
+					require.Empty(t, item.Data)
+					continue
+				}
+
+				require.Empty(t, item.Error)
+				data, err := base64.StdEncoding.DecodeString(item.Data)
+				require.NoError(t, err)
+				if item.ContentType != "image/png" {
+					require.Equal(t, "image/svg+xml", item.ContentType)
+					require.Contains(t, string(data), "<svg")
+					continue
+				}
+
+				img, _, err := image.Decode(bytes.NewReader(data))
+				require.NoError(t, err)
+				_, err = qrcode.Decode(img)
+				require.NoError(t, err)
+			}
+		})
+	}
+
+	// error message of the item is the same as the single document
+	resp, err := request.Post("%s/batch", ts.URL).
+		Header(echo.HeaderAccept, echo.MIMEApplicationJSON).
+		JSON([]map[string]interface{}{{"content": "a", "width": 10}}).
+		Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var got BatchResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	require.Equal(t, "width: must be between 21 and 200", got.Items[0].Error)
+}
+
+func TestBatchArchive(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), nil, nil))
+
+	items := []map[string]interface{}{{"content": "asset-001"}, {"content": "asset-002", "type": "jpeg"}, {"url": "https://example.com", "type": "svg"}}
+	wantNames := []string{"000.png", "001.jpeg", "002.svg"}
+	wantContents := []string{"asset-001", "asset-002", "URLTO:https://example.com"}
+
+	// entries returns name and content of archive entries
+	entries := map[string]func(t *testing.T, data []byte) ([]string, [][]byte){
+		"tar": func(t *testing.T, data []byte) ([]string, [][]byte) {
+			var names []string
+			var contents [][]byte
+			r := tar.NewReader(bytes.NewReader(data))
+			for {
+				h, err := r.Next()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				b, err := io.ReadAll(r)
+				require.NoError(t, err)
+				names, contents = append(names, h.Name), append(contents, b)
+			}
+			return names, contents
+		},
+		"zip": func(t *testing.T, data []byte) ([]string, [][]byte) {
+			var names []string
+			var contents [][]byte
+			r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+			require.NoError(t, err)
+			for _, f := range r.File {
+				rc, err := f.Open()
+				require.NoError(t, err)
+				b, err := io.ReadAll(rc)
+				rc.Close()
+				require.NoError(t, err)
+				names, contents = append(names, f.Name), append(contents, b)
+			}
+			return names, contents
+		},
+	}
+
+	tests := [...]struct {
+		archive         string
+		wantContentType string
+	}{
+		{"tar", "application/x-tar"},
+		{"zip", "application/zip"},
+		{"TAR", "application/x-tar"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.archive, func(t *testing.T) {
+			resp, err := request.Post("%s/batch", ts.URL).Query("archive", tt.archive).JSON(items).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, tt.wantContentType, resp.Header.Get(echo.HeaderContentType))
+
+			data, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			names, contents := entries[strings.ToLower(tt.archive)](t, data)
+			require.Equal(t, wantNames, names)
+
+			for i, b := range contents {
+				if strings.HasSuffix(names[i], ".svg") {
+					require.Contains(t, string(b), "<svg")
+					continue
+				}
+
+				img, _, err := image.Decode(bytes.NewReader(b))
+				require.NoError(t, err)
+				got, err := qrcode.Decode(img)
+				require.NoError(t, err)
+				require.Equal(t, wantContents[i], got)
+			}
+		})
+	}
+
+	// an invalid item or archive fails the request
+	for _, tt := range [...]struct {
+		archive string
+		items   interface{}
+		want    string
+	}{
+		{"tar", []map[string]interface{}{{"content": "a"}, {"content": "b", "width": 10}}, "item 1: width: must be between 21 and 200"},
+		{"rar", items, "invalid archive: rar"},
+	} {
+		resp, err := request.Post("%s/batch", ts.URL).Query("archive", tt.archive).JSON(tt.items).Do(ctx)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Contains(t, string(b), tt.want)
+	}
+}
+
+func TestBatchPixelBudget(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cfg := config.Current()
+	ts := newTestServer(ctx, newAPIv1(cfg, nil, nil))
+
+	items := func(n int, item map[string]interface{}) []map[string]interface{} {
+		l := make([]map[string]interface{}, n)
+		for i := range l {
+			l[i] = item
+		}
+		return l
+	}
+	item := map[string]interface{}{"content": "asset", "width": 100, "height": 100, "dpr": 2} // 200x200 pixels
+
+	tests := [...]struct {
+		name       string
+		budget     int
+		query      string
+		accept     string
+		items      []map[string]interface{}
+		wantStatus int
+		wantError  string
+	}{
+		{"json", 1_000_000, "", echo.MIMEApplicationJSON, items(30, item), http.StatusBadRequest,
+			"total pixels of items 1200000 exceeds the budget of 1000000 pixels"},
+		{"archive", 1_000_000, "archive=tar", "", items(30, item), http.StatusBadRequest,
+			"total pixels of items 1200000 exceeds the budget of 1000000 pixels"},
+		// a cell of a page is 1200x1200 pixels
+		{"sheet", 100_000_000, "layout=grid&cols=1&rows=1", "", items(maxBatchItems, item), http.StatusBadRequest,
+			"total pixels of items 720000000 exceeds the budget of 100000000 pixels"},
+		{"in budget", 1_000_000, "", echo.MIMEApplicationJSON, items(25, item), http.StatusOK, ""},
+		{"no limit", 0, "archive=zip", "", items(30, item), http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.BatchPixels = tt.budget
+
+			req := request.Post("%s/batch?%s", ts.URL, tt.query).JSON(tt.items)
+			if tt.accept != "" {
+				req = req.Header(echo.HeaderAccept, tt.accept)
+			}
+			resp, err := req.Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Contains(t, string(body), tt.wantError)
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package qrcodeapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+)
+
+// payloadURLPattern http(s) urls in the payload, such as `URLTO:`, vcard `URL:` or plain text
+var payloadURLPattern = regexp.MustCompile(`(?i)https?://[^\s"'<>\\]+`)
+
+// payloadURLs returns http(s) urls of the payload; trailing separators of payload formats, such as MECARD `;`, are trimmed
+func payloadURLs(content string) []*url.URL {
+	var urls []*url.URL
+	for _, s := range payloadURLPattern.FindAllString(content, -1) {
+		if u, err := url.Parse(strings.TrimRight(s, ";,.)")); err == nil && u.Host != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// checkPayload returns error if an url of the payload is blocked or flagged, see checkURLs
+func checkPayload(cfg *config.Config, c echo.Context, content string) error {
+	return checkURLs(cfg, c, payloadURLs(content))
+}
+
+// checkTarget returns error if the target of /url or links is blocked or flagged; the target is validated already
+func checkTarget(cfg *config.Config, c echo.Context, target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil
+	}
+	return checkURLs(cfg, c, []*url.URL{u})
+}
+
+// checkURLParam returns error if the url parameter of /qrcode is blocked or flagged; the parameter is encoded as is,
+// so an url without scheme, such as `phish.example`, is checked as http url as it is not found by payloadURLPattern
+func checkURLParam(cfg *config.Config, c echo.Context, target string) error {
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	return checkTarget(cfg, c, target)
+}
+
+// checkURLs returns 451 if an url is in url_blocklist and 403 if flagged by safe browsing.
+// lookup errors of safe browsing are logged and the urls are allowed, so that the generator keeps working.
+func checkURLs(cfg *config.Config, c echo.Context, urls []*url.URL) error {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	snapshot := cfg.Snapshot()
+	blocklist := snapshot.URLBlocklist
+	for _, u := range urls {
+		if blocked(blocklist, u) {
+			return echo.NewHTTPError(http.StatusUnavailableForLegalReasons, "target is blocked: "+u.Hostname())
+		}
+	}
+
+	key := snapshot.SafeBrowsingKey
+	if key == "" {
+		return nil
+	}
+
+	threat, err := safeBrowsing.lookup(c.Request().Context(), key, urls)
+	if err != nil {
+		c.Logger().Warnf("safe browsing lookup failed, urls are allowed: %s", err)
+		return nil
+	}
+	if threat != "" {
+		return echo.NewHTTPError(http.StatusForbidden, "target is flagged as "+strings.ToLower(threat))
+	}
+	return nil
+}
+
+// blocked returns true if an entry of the blocklist matches the url;
+// `example.com` matches the host, `.example.com` the domain and its subdomains,
+// and `https://example.com/path` the url of the scheme, host and path regardless of query and fragment.
+func blocked(blocklist []string, u *url.URL) bool {
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	for _, entry := range blocklist {
+		entry = strings.ToLower(entry)
+		switch {
+		case strings.Contains(entry, "://"):
+			e, err := url.Parse(entry)
+			if err != nil {
+				continue
+			}
+			if strings.ToLower(u.Scheme) == e.Scheme && host == strings.TrimSuffix(e.Hostname(), ".") && u.Port() == e.Port() &&
+				strings.TrimSuffix(u.EscapedPath(), "/") == strings.TrimSuffix(e.EscapedPath(), "/") {
+				return true
+			}
+
+		case strings.HasPrefix(entry, "."):
+			if host == entry[1:] || strings.HasSuffix(host, entry) {
+				return true
+			}
+
+		case host == entry:
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	safeBrowsingTimeout   = 2 * time.Second
+	safeBrowsingCacheSize = 10_000
+	safeBrowsingSafeTTL   = 5 * time.Minute // verdict of urls without match; matches have cache duration of the api
+)
+
+// safeBrowsingURL threatMatches:find endpoint of google safe browsing v4; tests replace it with a stub
+var safeBrowsingURL = "https://safebrowsing.googleapis.com/v4/threatMatches:find"
+
+var safeBrowsing = &safeBrowsingClient{
+	// the endpoint is fixed, not given by users, so remoteClient is not required
+	client: &http.Client{Timeout: safeBrowsingTimeout},
+}
+
+// safeBrowsingClient lookup urls with verdicts cached to respect the quota of the api
+type safeBrowsingClient struct {
+	client *http.Client
+	cache  lruCache[*safeBrowsingVerdict]
+}
+
+type safeBrowsingVerdict struct {
+	threat  string // threat type, empty if safe
+	expires time.Time
+}
+
+type safeBrowsingRequest struct {
+	Client struct {
+		ClientID      string `json:"clientId"`
+		ClientVersion string `json:"clientVersion,omitempty"`
+	} `json:"client"`
+	ThreatInfo struct {
+		ThreatTypes      []string             `json:"threatTypes"`
+		PlatformTypes    []string             `json:"platformTypes"`
+		ThreatEntryTypes []string             `json:"threatEntryTypes"`
+		ThreatEntries    []safeBrowsingThreat `json:"threatEntries"`
+	} `json:"threatInfo"`
+}
+
+type safeBrowsingThreat struct {
+	URL string `json:"url"`
+}
+
+type safeBrowsingResponse struct {
+	Matches []struct {
+		ThreatType    string             `json:"threatType"`
+		Threat        safeBrowsingThreat `json:"threat"`
+		CacheDuration string             `json:"cacheDuration"` // ex) `300s`
+	} `json:"matches"`
+}
+
+// lookup returns threat type of the first flagged url; urls of cached verdicts are not looked up again
+func (sb *safeBrowsingClient) lookup(ctx context.Context, key string, urls []*url.URL) (string, error) {
+	now := time.Now()
+	body := &safeBrowsingRequest{}
+	for _, u := range urls {
+		s := u.String()
+		if v, ok := sb.cache.Get(s); ok && now.Before(v.expires) {
+			if v.threat != "" {
+				return v.threat, nil
+			}
+			continue
+		}
+		body.ThreatInfo.ThreatEntries = append(body.ThreatInfo.ThreatEntries, safeBrowsingThreat{URL: s})
+	}
+	if len(body.ThreatInfo.ThreatEntries) == 0 {
+		return "", nil
+	}
+
+	body.Client.ClientID, body.Client.ClientVersion = "qrcodeapi", version
+	body.ThreatInfo.ThreatTypes = []string{"MALWARE", "SOCIAL_ENGINEERING", "UNWANTED_SOFTWARE", "POTENTIALLY_HARMFUL_APPLICATION"}
+	body.ThreatInfo.PlatformTypes = []string{"ANY_PLATFORM"}
+	body.ThreatInfo.ThreatEntryTypes = []string{"URL"}
+
+	resp, err := request.Post(safeBrowsingURL).Query("key", key).JSON(body).WithClient(sb.client).Do(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if !resp.Success() {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	result := &safeBrowsingResponse{}
+	if err := resp.JSON(result); err != nil {
+		return "", err
+	}
+
+	threats := map[string]*safeBrowsingVerdict{}
+	threat := ""
+	for _, m := range result.Matches {
+		ttl, err := time.ParseDuration(m.CacheDuration)
+		if err != nil || ttl <= 0 {
+			ttl = safeBrowsingSafeTTL
+		}
+		threats[m.Threat.URL] = &safeBrowsingVerdict{threat: m.ThreatType, expires: now.Add(ttl)}
+		if threat == "" {
+			threat = m.ThreatType
+		}
+	}
+
+	for _, entry := range body.ThreatInfo.ThreatEntries {
+		v, ok := threats[entry.URL]
+		if !ok {
+			v = &safeBrowsingVerdict{expires: now.Add(safeBrowsingSafeTTL)}
+		}
+		sb.cache.Add(entry.URL, v, safeBrowsingCacheSize)
+	}
+
+	return threat, nil
+}
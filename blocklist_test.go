@@ -0,0 +1,212 @@
+package qrcodeapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+)
+
+func TestBlocked(t *testing.T) {
+	blocklist := []string{"evil.example", ".phish.example", "https://a.example/login", "http://b.example:8080/"}
+
+	tests := [...]struct {
+		url  string
+		want bool
+	}{
+		{"https://evil.example/", true},
+		{"http://EVIL.example./path?q=1", true},
+		{"https://www.evil.example/", false},
+		{"https://notevil.example/", false},
+		{"https://phish.example/", true},
+		{"https://login.phish.example/", true},
+		{"https://a.b.phish.example:8443/x", true},
+		{"https://notphish.example/", false},
+		{"https://phish.example.com/", false},
+		{"https://a.example/login", true},
+		{"https://a.example/login/?next=/", true},
+		{"https://a.example/login#top", true},
+		{"http://a.example/login", false},
+		{"https://a.example/login/more", false},
+		{"https://a.example/", false},
+		{"http://b.example:8080", true},
+		{"http://b.example/", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, blocked(blocklist, u))
+		})
+	}
+}
+
+func TestPayloadURLs(t *testing.T) {
+	tests := [...]struct {
+		content string
+		want    []string
+	}{
+		{"hello world", nil},
+		{"https://example.com/a?b=c", []string{"https://example.com/a?b=c"}},
+		{"URLTO:http://example.com", []string{"http://example.com"}},
+		{"MECARD:N:Kim;URL:https://example.com/me;;", []string{"https://example.com/me"}},
+		{"BEGIN:VCARD\r\nURL:HTTPS://a.example\r\nNOTE:see http://b.example/x.\r\nEND:VCARD", []string{"https://a.example", "http://b.example/x"}},
+		{"ftp://example.com", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			var got []string
+			for _, u := range payloadURLs(tt.content) {
+				got = append(got, u.String())
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	viper.Set("url_blocklist", []string{".phish.example"})
+	defer viper.Set("url_blocklist", []string{})
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name       string
+		path       string
+		params     map[string]string
+		wantStatus int
+	}{
+		{"url", "/url", map[string]string{"target": "https://login.phish.example/"}, http.StatusUnavailableForLegalReasons},
+		{"url preview", "/url", map[string]string{"target": "https://phish.example/", "preview": "true"}, http.StatusUnavailableForLegalReasons},
+		{"url allowed", "/url", map[string]string{"target": "https://example.com/"}, http.StatusOK},
+		{"content", "/qrcode", map[string]string{"content": "scan https://www.phish.example/pay now"}, http.StatusUnavailableForLegalReasons},
+		{"content url", "/qrcode", map[string]string{"url": "https://phish.example"}, http.StatusUnavailableForLegalReasons},
+		{"url without scheme", "/qrcode", map[string]string{"url": "login.phish.example"}, http.StatusUnavailableForLegalReasons},
+		{"url without scheme allowed", "/qrcode", map[string]string{"url": "example.com"}, http.StatusOK},
+		{"content allowed", "/qrcode", map[string]string{"content": "phish.example"}, http.StatusOK},
+		{"auto", "/auto", map[string]string{"content": "https://phish.example/"}, http.StatusUnavailableForLegalReasons},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request.Get("%s%s", ts.URL, tt.path)
+			for k, v := range tt.params {
+				req = req.Query(k, v)
+			}
+			resp, err := req.Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestBlocklistBatch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	viper.Set("url_blocklist", []string{".phish.example"})
+	defer viper.Set("url_blocklist", []string{})
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), nil, nil))
+	items := []map[string]interface{}{{"content": "a"}, {"content": "https://phish.example/pay"}}
+
+	// pdf sheet
+	resp, err := request.Post("%s/batch?layout=grid&cols=2&rows=1", ts.URL).JSON(items).Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusUnavailableForLegalReasons, resp.StatusCode)
+
+	// progress stream
+	resp, err = request.Post("%s/batch?layout=grid&cols=2&rows=1", ts.URL).
+		Header(echo.HeaderAccept, "text/event-stream").
+		JSON(items).
+		Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	events := readEvents(t, resp.Body)
+	require.Len(t, events, 2)
+	require.Equal(t, "error", events[1].event)
+	require.Contains(t, events[1].data, "item 1: target is blocked: phish.example")
+}
+
+func TestSafeBrowsing(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var lookups int32
+	failing := int32(0)
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&lookups, 1)
+		if atomic.LoadInt32(&failing) == 1 {
+			http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+			return
+		}
+		require.Equal(t, "test-key", r.URL.Query().Get("key"))
+
+		body := &safeBrowsingRequest{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(body))
+
+		resp := map[string]interface{}{}
+		for _, entry := range body.ThreatInfo.ThreatEntries {
+			if entry.URL == "https://malware.example/" {
+				resp["matches"] = []map[string]interface{}{{
+					"threatType": "SOCIAL_ENGINEERING", "threat": map[string]string{"url": entry.URL}, "cacheDuration": "300s",
+				}}
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer stub.Close()
+
+	defer func(u string) { safeBrowsingURL = u }(safeBrowsingURL)
+	safeBrowsingURL = stub.URL
+	defer func(sb *safeBrowsingClient) { safeBrowsing = sb }(safeBrowsing)
+	safeBrowsing = &safeBrowsingClient{client: http.DefaultClient}
+
+	viper.Set("safe_browsing_key", "test-key")
+	defer viper.Set("safe_browsing_key", "")
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	get := func(target string) int {
+		resp, err := request.Get("%s/url", ts.URL).Query("target", target).Do(ctx)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusForbidden, get("https://malware.example/"))
+	require.Equal(t, http.StatusOK, get("https://safe.example/"))
+	require.Equal(t, int32(2), atomic.LoadInt32(&lookups))
+
+	// cached verdicts are not looked up again, even while the api is failing
+	atomic.StoreInt32(&failing, 1)
+	require.Equal(t, http.StatusForbidden, get("https://malware.example/"))
+	require.Equal(t, http.StatusOK, get("https://safe.example/"))
+	require.Equal(t, int32(2), atomic.LoadInt32(&lookups))
+
+	// fail open for urls not cached
+	require.Equal(t, http.StatusOK, get("https://unknown.example/"))
+	failed := atomic.LoadInt32(&lookups)
+	require.Greater(t, failed, int32(2))
+
+	// lookup errors are not cached
+	atomic.StoreInt32(&failing, 0)
+	require.Equal(t, http.StatusOK, get("https://unknown.example/"))
+	require.Equal(t, failed+1, atomic.LoadInt32(&lookups))
+}
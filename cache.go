@@ -0,0 +1,85 @@
+package qrcodeapi
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache least recently used cache; capacity is given on each add so that it can follow the config
+type lruCache[T any] struct {
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type lruEntry[T any] struct {
+	key string
+	val T
+}
+
+// Get returns cached value of the key
+func (c *lruCache[T]) Get(key string) (val T, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		return val, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*lruEntry[T]).val, true
+}
+
+// Add add the value and evict least recently used ones over the capacity; all entries are removed if capacity <= 0
+func (c *lruCache[T]) Add(key string, val T, capacity int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.items == nil {
+		c.ll = list.New()
+		c.items = map[string]*list.Element{}
+	}
+
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*lruEntry[T]).val = val
+	} else {
+		c.items[key] = c.ll.PushFront(&lruEntry[T]{key: key, val: val})
+	}
+
+	for c.ll.Len() > 0 && c.ll.Len() > capacity {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.items, e.Value.(*lruEntry[T]).key)
+	}
+}
+
+// Len returns number of cached entries
+func (c *lruCache[T]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// Remove remove entries of the matched keys; returns number of removed entries
+func (c *lruCache[T]) Remove(match func(key string) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key, e := range c.items {
+		if match(key) {
+			c.ll.Remove(e)
+			delete(c.items, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Purge remove all entries
+func (c *lruCache[T]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll, c.items = nil, nil
+}
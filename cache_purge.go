@@ -0,0 +1,81 @@
+package qrcodeapi
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// cacheBackend cache of rendered images which can be purged
+type cacheBackend interface {
+	// Remove remove entries of the matched keys; returns number of removed entries
+	Remove(match func(key string) bool) int
+}
+
+// cacheBackends caches of rendered images by name
+var cacheBackends = map[string]cacheBackend{
+	"memory": &renderCache,
+}
+
+// PurgeRequest one of all, keys or prefix is required
+type PurgeRequest struct {
+	All    bool     `json:"all,omitempty"`
+	Keys   []string `json:"keys,omitempty"`   // canonical forms of the requests, entries of every preset and format are removed
+	Prefix string   `json:"prefix,omitempty"` // prefix of cache keys, ex) `brand-dark:` for a preset
+}
+
+type PurgeResponse struct {
+	Removed map[string]int `json:"removed"` // removed entries by backend
+}
+
+// match returns matcher of cache keys of the request
+func (req *PurgeRequest) match() (func(key string) bool, error) {
+	given := 0
+	for _, ok := range []bool{req.All, len(req.Keys) > 0, req.Prefix != ""} {
+		if ok {
+			given++
+		}
+	}
+	if given != 1 {
+		return nil, documentError("", "one of all, keys or prefix is required")
+	}
+
+	switch {
+	case req.All:
+		return func(string) bool { return true }, nil
+
+	case req.Prefix != "":
+		return func(key string) bool { return strings.HasPrefix(key, req.Prefix) }, nil
+	}
+
+	keys := map[string]bool{}
+	for _, key := range req.Keys {
+		if key == "" {
+			return nil, documentError("keys", "empty key")
+		}
+		keys[key] = true
+	}
+	return func(key string) bool { return keys[key[strings.LastIndex(key, ":")+1:]] }, nil
+}
+
+// handlePurgeCache remove rendered images from every cache backend, such as after a rendering fix
+func (api *adminAPI) handlePurgeCache(c echo.Context) error {
+	req := &PurgeRequest{}
+	if err := decodeDocument(c.Request().Body, req); err != nil {
+		return err
+	}
+
+	match, err := req.match()
+	if err != nil {
+		return err
+	}
+
+	resp := &PurgeResponse{Removed: map[string]int{}}
+	for name, backend := range cacheBackends {
+		resp.Removed[name] = backend.Remove(match)
+	}
+	c.Logger().Infof("cache purged by %s: %v", principal(c), resp.Removed)
+
+	return c.JSON(http.StatusOK, resp)
+}
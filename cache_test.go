@@ -0,0 +1,195 @@
+package qrcodeapi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+)
+
+func TestLRUCache(t *testing.T) {
+	cache := &lruCache[int]{}
+	cache.Add("a", 1, 2)
+	cache.Add("b", 2, 2)
+	_, _ = cache.Get("a")
+	cache.Add("c", 3, 2) // evicts b, least recently used
+
+	_, ok := cache.Get("b")
+	require.False(t, ok)
+	got, ok := cache.Get("a")
+	require.True(t, ok)
+	require.Equal(t, 1, got)
+	require.Equal(t, 2, cache.Len())
+
+	cache.Add("d", 4, 0)
+	require.Equal(t, 0, cache.Len())
+}
+
+func TestRenderCache(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	viper.Set("render_cache_size", 10)
+	defer viper.Set("render_cache_size", 0)
+	renderCache.Purge()
+	defer renderCache.Purge()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	get := func(accept string) (*http.Response, []byte) {
+		resp, err := request.Get("%s/qrcode", ts.URL).
+			Query("content", "hello").
+			Header(echo.HeaderAccept, accept).
+			Do(ctx)
+		require.NoError(t, err)
+		require.Truef(t, resp.Success(), "failed with %d", resp.StatusCode)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.Response, body
+	}
+
+	tests := [...]struct {
+		name            string
+		accept          string
+		wantContentType string
+		wantEntries     int
+	}{
+		{"svg", "image/svg+xml", "image/svg+xml", 1},
+		{"png", "image/png", "image/png", 2},
+		{"svg again", "image/svg+xml", "image/svg+xml", 2},
+		{"png again", "image/png", "image/png", 2},
+	}
+	bodies := map[string][]byte{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := get(tt.accept)
+			require.Equal(t, tt.wantContentType, resp.Header.Get(echo.HeaderContentType))
+			require.Equal(t, "Accept, Accept-Encoding, Cookie", resp.Header.Get(echo.HeaderVary))
+			require.Equal(t, tt.wantEntries, renderCache.Len())
+
+			if want, ok := bodies[tt.wantContentType]; ok {
+				require.Equal(t, want, body)
+			}
+			bodies[tt.wantContentType] = body
+		})
+	}
+	require.NotEqual(t, bodies["image/svg+xml"], bodies["image/png"])
+}
+
+func TestPurgeCache(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	viper.Set("render_cache_size", 10)
+	viper.Set("rate_limit", 1000)
+	viper.Set("presets", map[string]interface{}{"brand": map[string]interface{}{"fg": "#ff0000"}})
+	defer func() {
+		viper.Set("render_cache_size", 0)
+		viper.Set("rate_limit", 20)
+		viper.Set("presets", map[string]interface{}{})
+	}()
+
+	cfg := config.Current()
+	ts := newTestServer(ctx, newAPIv1(cfg, codes.NewMemoryStore(), nil), newAdminAPI(cfg))
+
+	get := func(content, preset string) {
+		req := request.Get("%s/qrcode", ts.URL).Query("content", content)
+		if preset != "" {
+			req = req.Query("preset", preset)
+		}
+		resp, err := req.Do(ctx)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	purge := func(key string, body interface{}) (int, *PurgeResponse) {
+		req := request.Post("%s/admin/cache/purge", ts.URL).JSON(body)
+		if key != "" {
+			req = req.Header(echo.HeaderAuthorization, "Bearer "+key)
+		}
+		resp, err := req.Do(ctx)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		got := &PurgeResponse{}
+		if resp.Success() {
+			require.NoError(t, resp.JSON(got))
+		}
+		return resp.StatusCode, got
+	}
+
+	canonical := func(content string) string {
+		req, err := parseRenderRequest(config.Current(), func(string) string { return "" })
+		require.NoError(t, err)
+		return codes.Canonical(content, req.params())
+	}
+
+	// cached returns true if any entry has the prefix and the canonical form of the content
+	cached := func(prefix, content string) bool {
+		for key := range renderCache.items {
+			if strings.HasPrefix(key, prefix) && strings.Contains(key, "content="+content+"&") {
+				return true
+			}
+		}
+		return false
+	}
+
+	// unavailable without auth
+	status, _ := purge("", map[string]interface{}{"all": true})
+	require.Equal(t, http.StatusNotFound, status)
+
+	cfg.APIKeys = []string{"secret"}
+	status, _ = purge("", map[string]interface{}{"all": true})
+	require.Equal(t, http.StatusUnauthorized, status)
+
+	tests := [...]struct {
+		name        string
+		body        interface{}
+		wantStatus  int
+		wantRemoved int
+		wantCached  []bool // cached after purge: a, b, brand
+	}{
+		{"all", map[string]interface{}{"all": true}, http.StatusOK, 3, []bool{false, false, false}},
+		{"prefix", map[string]interface{}{"prefix": "brand:"}, http.StatusOK, 1, []bool{true, true, false}},
+		{"keys", map[string]interface{}{"keys": []string{canonical("b")}}, http.StatusOK, 1, []bool{true, false, true}},
+		{"no match", map[string]interface{}{"prefix": "other:"}, http.StatusOK, 0, []bool{true, true, true}},
+		{"none", map[string]interface{}{}, http.StatusBadRequest, 0, []bool{true, true, true}},
+		{"both", map[string]interface{}{"all": true, "prefix": "brand:"}, http.StatusBadRequest, 0, []bool{true, true, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			renderCache.Purge()
+			get("a", "")
+			get("b", "")
+			get("c", "brand")
+			require.Equal(t, 3, renderCache.Len())
+
+			status, got := purge("secret", tt.body)
+			require.Equal(t, tt.wantStatus, status)
+			if status == http.StatusOK {
+				require.Equal(t, map[string]int{"memory": tt.wantRemoved}, got.Removed)
+			}
+			require.Equal(t, tt.wantCached, []bool{cached(":", "a"), cached(":", "b"), cached("brand:", "c")})
+
+			// purged ones are rendered again, the others are hits
+			before := renderCache.Len()
+			get("a", "")
+			get("b", "")
+			get("c", "brand")
+			require.Equal(t, 3, renderCache.Len())
+			require.Equal(t, tt.wantRemoved, 3-before)
+		})
+	}
+}
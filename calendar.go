@@ -0,0 +1,136 @@
+package qrcodeapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"qrcodeapi/pkg/qrcode"
+)
+
+const (
+	mimeCalendar = "text/calendar"
+
+	maxCalendarSize = 64 << 10 // read limit of the body, larger than any code can encode
+)
+
+// validateCalendar check that components of the body are paired and nested;
+// body is a VEVENT, or a VCALENDAR of one or more VEVENTs.
+func validateCalendar(body string) error {
+	var stack []string
+	top, events := "", 0
+	for i, line := range strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n") {
+		// folded line continues the previous one
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+
+		invalid := func(format string, args ...interface{}) error {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid calendar: line %d: ", i+1)+fmt.Sprintf(format, args...))
+		}
+
+		name, value, _ := strings.Cut(line, ":")
+		switch strings.ToUpper(name) {
+		case "BEGIN":
+			component := strings.ToUpper(value)
+			switch {
+			case len(stack) == 0 && top != "":
+				return invalid("second top-level component %s", component)
+			case len(stack) == 0 && component != "VEVENT" && component != "VCALENDAR":
+				return invalid("VEVENT or VCALENDAR is expected, got %s", component)
+			case component == "VEVENT" && len(stack) > 0 && stack[len(stack)-1] != "VCALENDAR":
+				return invalid("VEVENT in %s", stack[len(stack)-1])
+			}
+
+			if len(stack) == 0 {
+				top = component
+			}
+			if component == "VEVENT" {
+				events++
+			}
+			stack = append(stack, component)
+
+		case "END":
+			component := strings.ToUpper(value)
+			if len(stack) == 0 || stack[len(stack)-1] != component {
+				return invalid("unexpected END:%s", component)
+			}
+			stack = stack[:len(stack)-1]
+
+		default:
+			if len(stack) == 0 {
+				return invalid("property outside of component")
+			}
+		}
+	}
+
+	switch {
+	case top == "":
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid calendar: no VEVENT")
+	case len(stack) > 0:
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid calendar: END:"+stack[len(stack)-1]+" is missing")
+	case events == 0:
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid calendar: VCALENDAR has no VEVENT")
+	}
+	return nil
+}
+
+// parseEventTime parse date-time of RFC 3339, or of iCalendar in UTC, `YYYYMMDDTHHMMSSZ`
+func parseEventTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(qrcode.ICalTime, s)
+}
+
+// parseGeo parse `lat,lon` of GEO in degrees; ranges are validated by qrcode.VEvent
+func parseGeo(s string) (*qrcode.Geo, error) {
+	lat, lon, ok := strings.Cut(s, ",")
+	if !ok {
+		return nil, errors.New("geo must be lat,lon")
+	}
+
+	var geo qrcode.Geo
+	var err error
+	if geo.Lat, err = strconv.ParseFloat(strings.TrimSpace(lat), 64); err != nil {
+		return nil, err
+	}
+	if geo.Lon, err = strconv.ParseFloat(strings.TrimSpace(lon), 64); err != nil {
+		return nil, err
+	}
+	return &geo, nil
+}
+
+// alarmShorthand duration before start of the event, ex) 15m, 1h, 2d
+var alarmShorthand = regexp.MustCompile(`^(\d+)([smhdw])$`)
+
+// parseAlarm parse TRIGGER duration of the alarm, of iCalendar, `-PT15M`, or of alarmShorthand
+func parseAlarm(s string) (string, error) {
+	if m := alarmShorthand.FindStringSubmatch(s); m != nil {
+		unit := strings.ToUpper(m[2])
+		if unit == "D" || unit == "W" {
+			return "-P" + m[1] + unit, nil
+		}
+		return "-PT" + m[1] + unit, nil
+	}
+
+	trigger := strings.ToUpper(s)
+	if !qrcode.ValidDuration(trigger) {
+		return "", errors.New("invalid duration")
+	}
+	return trigger, nil
+}
+
+// parseEventDate parse date of all-day event, `YYYY-MM-DD` or of iCalendar, `YYYYMMDD`
+func parseEventDate(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(qrcode.ICalDate, s)
+}
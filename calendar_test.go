@@ -0,0 +1,94 @@
+package qrcodeapi
+
+import (
+	"context"
+	"image"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+	"qrcodeapi/pkg/qrcode"
+)
+
+func TestValidateCalendar(t *testing.T) {
+	tests := [...]struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{"event", "BEGIN:VEVENT\nSUMMARY:a\nEND:VEVENT", ""},
+		{"alarm", "BEGIN:VEVENT\nBEGIN:VALARM\nTRIGGER:-PT15M\nEND:VALARM\nEND:VEVENT\n", ""},
+		{"calendar", "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VEVENT\r\nSUMMARY:a\r\nEND:VEVENT\r\nBEGIN:VEVENT\r\nSUMMARY:b\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", ""},
+		{"folded", "BEGIN:VEVENT\nDESCRIPTION:long\n  description\nEND:VEVENT", ""},
+		{"lower case", "begin:vevent\nend:vevent", ""},
+		{"empty", "", "no VEVENT"},
+		{"no event", "BEGIN:VCALENDAR\nVERSION:2.0\nEND:VCALENDAR", "VCALENDAR has no VEVENT"},
+		{"not closed", "BEGIN:VCALENDAR\nBEGIN:VEVENT\nEND:VEVENT", "END:VCALENDAR is missing"},
+		{"mismatched", "BEGIN:VEVENT\nBEGIN:VALARM\nEND:VEVENT\nEND:VALARM", "line 3: unexpected END:VEVENT"},
+		{"two top-level", "BEGIN:VEVENT\nEND:VEVENT\nBEGIN:VEVENT\nEND:VEVENT", "line 3: second top-level component"},
+		{"other component", "BEGIN:VCARD\nEND:VCARD", "VEVENT or VCALENDAR is expected"},
+		{"nested event", "BEGIN:VEVENT\nBEGIN:VEVENT\nEND:VEVENT\nEND:VEVENT", "line 2: VEVENT in VEVENT"},
+		{"outside", "SUMMARY:a\nBEGIN:VEVENT\nEND:VEVENT", "line 1: property outside of component"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCalendar(tt.body)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	event := func(summary string) string {
+		return "BEGIN:VEVENT\r\nSUMMARY:" + summary + "\r\nDTSTART:20240601T090000Z\r\nDTEND:20240601T100000Z\r\nEND:VEVENT\r\n"
+	}
+	calendar := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//qrcodeapi//EN\r\n" + event("Keynote") + event("Lunch") + "END:VCALENDAR\r\n"
+
+	tests := [...]struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{"two events", mimeCalendar, calendar, http.StatusOK},
+		{"as vevent", mimeVEvent, calendar, http.StatusOK},
+		{"invalid", mimeCalendar, "BEGIN:VCALENDAR\r\n" + event("Keynote"), http.StatusBadRequest},
+		{"over capacity", mimeCalendar, "BEGIN:VCALENDAR\r\n" + strings.Repeat(event("Talk"), 40) + "END:VCALENDAR\r\n", http.StatusRequestEntityTooLarge},
+		{"over read limit", mimeCalendar, strings.Repeat("X", maxCalendarSize+1), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Post("%s/vevent", ts.URL).ContentType(tt.contentType).Body(strings.NewReader(tt.body)).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, tt.body, got)
+			require.Contains(t, got, "SUMMARY:Keynote\r\n")
+			require.Contains(t, got, "SUMMARY:Lunch\r\n")
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
+	"time"
+
+	"qrcodeapi/pkg/qrcode"
+)
+
+// function exported function; options are json of the js object, result is []byte or string
+type function func(options []byte) (interface{}, error)
+
+// functions exported functions by name
+var functions = map[string]function{
+	"encode":  encode,
+	"text":    payload(text),
+	"wifi":    payload(wifi),
+	"contact": payload(contact),
+	"vevent":  payload(vevent),
+}
+
+// sizes of the image as the api
+const (
+	defaultSize = 200
+	minSize     = 21
+	maxSize     = 200
+)
+
+// EncodeOptions options of encode, of the same names as the api parameters
+type EncodeOptions struct {
+	Content string `json:"content"`
+	W       int    `json:"w"`
+	H       int    `json:"h"`
+	EC      string `json:"ec"`
+	Shape   string `json:"shape"`
+	Margin  *int   `json:"margin"`
+	Charset string `json:"charset"`
+	ECI     bool   `json:"eci"`
+}
+
+// encode render the content as png
+func encode(options []byte) (interface{}, error) {
+	var req EncodeOptions
+	if err := json.Unmarshal(options, &req); err != nil {
+		return nil, err
+	}
+	if req.Content == "" {
+		return nil, errors.New("content: required")
+	}
+
+	opts := &qrcode.RenderOptions{Width: defaultSize, Height: defaultSize, Margin: req.Margin, ECI: req.ECI}
+	for _, size := range []struct {
+		name  string
+		value int
+		dst   *int
+	}{{"w", req.W, &opts.Width}, {"h", req.H, &opts.Height}} {
+		if size.value == 0 {
+			continue
+		}
+		if size.value < minSize || size.value > maxSize {
+			return nil, fmt.Errorf("%s: must be between %d and %d", size.name, minSize, maxSize)
+		}
+		*size.dst = size.value
+	}
+
+	var err error
+	if opts.ErrorCorrection, err = qrcode.ParseErrorCorrection(req.EC); err != nil {
+		return nil, err
+	}
+	if opts.Shape, err = qrcode.ParseShape(req.Shape); err != nil {
+		return nil, err
+	}
+	if opts.Charset, err = qrcode.ParseCharset(req.Charset); err != nil {
+		return nil, err
+	}
+
+	qr, _ := qrcode.Text(req.Content)
+	img, err := qr.RenderWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// payload returns function which returns content of the payload builder
+func payload[T any](build func(req *T) (*qrcode.QR, error)) function {
+	return func(options []byte) (interface{}, error) {
+		req := new(T)
+		if err := json.Unmarshal(options, req); err != nil {
+			return nil, err
+		}
+
+		qr, err := build(req)
+		if err != nil {
+			return nil, err
+		}
+		return qr.Content, nil
+	}
+}
+
+type TextOptions struct {
+	Content string `json:"content"`
+}
+
+func text(req *TextOptions) (*qrcode.QR, error) { return qrcode.Text(req.Content) }
+
+type WiFiOptions struct {
+	SSID              string `json:"ssid"`
+	Auth              string `json:"auth"` // WEP, WPA, WPA2 or empty
+	Pass              string `json:"pass"`
+	Hidden            *bool  `json:"hidden"`
+	EAPMethod         string `json:"eap"`
+	AnonymousIdentity string `json:"anon"`
+	Identity          string `json:"ident"`
+	Phase2Method      string `json:"ph2"`
+}
+
+func wifi(req *WiFiOptions) (*qrcode.QR, error) {
+	if req.SSID == "" {
+		return nil, errors.New("ssid: required")
+	}
+	return qrcode.WIFI(req.SSID, qrcode.StrToWifiAuth(req.Auth), req.Pass, req.Hidden, qrcode.WPA2Options{
+		EAPMethod:         req.EAPMethod,
+		AnonymousIdentity: req.AnonymousIdentity,
+		Identity:          req.Identity,
+		Phase2Method:      req.Phase2Method,
+	})
+}
+
+// ContactOptions fields of the card as qrcode.Card, with dates of `YYYY-MM-DD` or `--MM-DD`
+type ContactOptions struct {
+	qrcode.Card
+	Birthday    string `json:"bday"`
+	Anniversary string `json:"anniversary"`
+}
+
+func contact(req *ContactOptions) (*qrcode.QR, error) {
+	var err error
+	if req.Card.Version, err = qrcode.ParseVCardVersion(req.Card.Version); err != nil {
+		return nil, err
+	}
+
+	for _, date := range []struct {
+		name  string
+		value string
+		dst   **qrcode.Date
+	}{{"bday", req.Birthday, &req.Card.Birthday}, {"anniversary", req.Anniversary, &req.Card.Anniversary}} {
+		if date.value == "" {
+			continue
+		}
+		if *date.dst, err = qrcode.ParseDate(date.value); err != nil {
+			return nil, fmt.Errorf("%s: %w", date.name, err)
+		}
+	}
+	return qrcode.Contact(&req.Card)
+}
+
+// EventOptions event with times of RFC 3339
+type EventOptions struct {
+	Summary     string    `json:"summary"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Location    string    `json:"location"`
+	Description string    `json:"description"`
+}
+
+func vevent(req *EventOptions) (*qrcode.QR, error) {
+	return qrcode.VEvent(&qrcode.Event{Summary: req.Summary, Start: req.Start, End: req.End, Location: req.Location, Description: req.Description})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"qrcodeapi/pkg/qrcode"
+)
+
+func TestEncode(t *testing.T) {
+	tests := [...]struct {
+		name    string
+		options string
+		wantErr bool
+	}{
+		{"default", `{"content": "hello"}`, false},
+		{"options", `{"content": "hello", "w": 100, "h": 120, "ec": "H", "shape": "dot", "margin": 2}`, false},
+		{"charset", `{"content": "안녕", "charset": "euc-kr"}`, false},
+		{"content required", `{}`, true},
+		{"invalid size", `{"content": "hello", "w": 10}`, true},
+		{"invalid ec", `{"content": "hello", "ec": "X"}`, true},
+		{"invalid json", `{"content": 1}`, true},
+		{"unrepresentable", `{"content": "한글", "charset": "shift_jis"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := functions["encode"]([]byte(tt.options))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			img, err := png.Decode(bytes.NewReader(got.([]byte)))
+			require.NoError(t, err)
+			content, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.NotEmpty(t, content)
+		})
+	}
+}
+
+func TestPayload(t *testing.T) {
+	tests := [...]struct {
+		name    string
+		fn      string
+		options string
+		want    string
+		wantErr bool
+	}{
+		{"text", "text", `{"content": "hello"}`, "hello", false},
+		{"wifi", "wifi", `{"ssid": "home", "auth": "WPA", "pass": "secret;1"}`, `WIFI:S:home;T:WPA;P:secret\;1;;`, false},
+		{"wifi ssid required", "wifi", `{"auth": "WPA"}`, "", true},
+		{"contact", "contact", `{"version": "3.0", "FirstName": "Gildong", "LastName": "Hong", "Mobile": "+82-10-1234-5678", "bday": "1996-04-15"}`,
+			"BEGIN:VCARD\r\nVERSION:3.0\r\nBDAY:1996-04-15\r\nN:Hong;Gildong;;;\r\nTEL;TYPE=CELL:+82-10-1234-5678\r\nEND:VCARD\r\n", false},
+		{"contact invalid date", "contact", `{"FirstName": "Gildong", "bday": "1996-02-30"}`, "", true},
+		{"vevent", "vevent", `{"summary": "Meeting", "start": "2024-05-01T09:00:00+09:00"}`,
+			"BEGIN:VEVENT\r\nSUMMARY:Meeting\r\nDTSTART:20240501T000000Z\r\nEND:VEVENT", false},
+		{"vevent start required", "vevent", `{"summary": "Meeting"}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := functions[tt.fn]([]byte(tt.options))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
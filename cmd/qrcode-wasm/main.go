@@ -0,0 +1,5 @@
+// qrcode-wasm exports encoder and payload builders of pkg/qrcode to browsers as `qrcode` global,
+// so that client side previews are of the same logic as the api; build with `make wasm`.
+package main
+
+func main() { serve(functions) }
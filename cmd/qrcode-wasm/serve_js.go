@@ -0,0 +1,40 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"syscall/js"
+)
+
+// serve set the functions to `qrcode` global and wait for calls; options are passed as js object,
+// bytes are returned as Uint8Array and errors as Error
+func serve(functions map[string]function) {
+	exports := js.Global().Get("Object").New()
+	for name, fn := range functions {
+		exports.Set(name, js.FuncOf(call(fn)))
+	}
+	js.Global().Set("qrcode", exports)
+
+	select {}
+}
+
+func call(fn function) func(this js.Value, args []js.Value) interface{} {
+	return func(this js.Value, args []js.Value) interface{} {
+		options := "{}"
+		if len(args) > 0 && args[0].Type() == js.TypeObject {
+			options = js.Global().Get("JSON").Call("stringify", args[0]).String()
+		}
+
+		result, err := fn([]byte(options))
+		if err != nil {
+			return js.Global().Get("Error").New(err.Error())
+		}
+
+		if b, ok := result.([]byte); ok {
+			array := js.Global().Get("Uint8Array").New(len(b))
+			js.CopyBytesToJS(array, b)
+			return array
+		}
+		return result
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !(js && wasm)
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// serve functions are served on js/wasm only
+func serve(map[string]function) {
+	fmt.Fprintln(os.Stderr, "qrcode-wasm runs in browsers, build with GOOS=js GOARCH=wasm")
+	os.Exit(1)
+}
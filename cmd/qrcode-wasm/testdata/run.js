@@ -0,0 +1,20 @@
+// run.js <wasm_exec.js> <qrcode.wasm> <function> <options json>
+// runs the wasm binary and writes result of the exported function to stdout, or the error to stderr
+"use strict";
+
+const fs = require("fs");
+
+const [wasmExec, wasmFile, fn, options] = process.argv.slice(2);
+require(wasmExec);
+
+const go = new Go();
+WebAssembly.instantiate(fs.readFileSync(wasmFile), go.importObject).then(({ instance }) => {
+	go.run(instance);
+
+	const result = globalThis.qrcode[fn](JSON.parse(options));
+	if (result instanceof Error) {
+		process.stderr.write(result.message);
+		process.exit(1);
+	}
+	process.stdout.write(result instanceof Uint8Array ? Buffer.from(result) : result, () => process.exit(0));
+});
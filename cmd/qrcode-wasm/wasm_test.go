@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"qrcodeapi/pkg/qrcode"
+)
+
+// TestWasm build the binary for js/wasm and call the exported functions with node, if it is installed
+func TestWasm(t *testing.T) {
+	if testing.Short() {
+		t.Skip("wasm build is skipped in short mode")
+	}
+
+	wasm := filepath.Join(t.TempDir(), "qrcode.wasm")
+	cmd := exec.Command("go", "build", "-o", wasm, ".")
+	cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed, build only")
+	}
+	wasmExec := filepath.Join(runtime.GOROOT(), "lib", "wasm", "wasm_exec.js")
+	if _, err := os.Stat(wasmExec); err != nil {
+		// before go 1.24
+		wasmExec = filepath.Join(runtime.GOROOT(), "misc", "wasm", "wasm_exec.js")
+	}
+
+	call := func(fn string, options string) ([]byte, error) {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(node, filepath.Join("testdata", "run.js"), wasmExec, wasm, fn, options)
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, stderr.String())
+		}
+		return stdout.Bytes(), nil
+	}
+
+	got, err := call("encode", `{"content": "hello wasm", "ec": "Q"}`)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(got))
+	require.NoError(t, err)
+	content, err := qrcode.Decode(img)
+	require.NoError(t, err)
+	require.Equal(t, "hello wasm", content)
+
+	// same as the native build
+	want, err := functions["encode"]([]byte(`{"content": "hello wasm", "ec": "Q"}`))
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	got, err = call("wifi", `{"ssid": "home", "auth": "WPA", "pass": "secret"}`)
+	require.NoError(t, err)
+	require.Equal(t, "WIFI:S:home;T:WPA;P:secret;;", string(got))
+
+	_, err = call("encode", `{}`)
+	require.ErrorContains(t, err, "content: required")
+}
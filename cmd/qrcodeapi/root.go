@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+
 	"github.com/spf13/cobra"
 	"github.com/whitekid/goxp/log"
 
@@ -8,10 +10,28 @@ import (
 	"qrcodeapi/config"
 )
 
+var printConfig bool
+
 var rootCmd = &cobra.Command{
-	Use: "qrcodeapi",
+	Use:          "qrcodeapi",
+	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := qrcodeapi.Run(cmd.Context()); err != nil {
+		cfg, err := config.Load()
+		if err != nil {
+			log.Errorf("%+v", err)
+			return err
+		}
+
+		if printConfig {
+			out, err := cfg.YAML()
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(cmd.OutOrStdout(), out)
+			return nil
+		}
+
+		if err := qrcodeapi.Run(cmd.Context(), cfg); err != nil {
 			log.Errorf("%+v", err)
 			return err
 		}
@@ -21,4 +41,5 @@ var rootCmd = &cobra.Command{
 
 func init() {
 	config.InitFlagSet(rootCmd.Use, rootCmd.Flags())
+	rootCmd.Flags().BoolVar(&printConfig, "print-config", false, "print effective config with secrets masked and exit")
 }
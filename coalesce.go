@@ -0,0 +1,43 @@
+package qrcodeapi
+
+import "sync"
+
+// flightGroup coalesce concurrent calls of the same key into one call, as golang.org/x/sync/singleflight does
+type flightGroup[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall[T]
+}
+
+type flightCall[T any] struct {
+	wg  sync.WaitGroup
+	val T
+	err error
+}
+
+// Do call fn once for concurrent calls of the key; shared is true if the result is from other call
+func (g *flightGroup[T]) Do(key string, fn func() (T, error)) (val T, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall[T]{}
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		call.wg.Wait()
+		return call.val, call.err, true
+	}
+
+	call := &flightCall[T]{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		call.wg.Done()
+	}()
+
+	call.val, call.err = fn()
+	return call.val, call.err, false
+}
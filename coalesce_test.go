@@ -0,0 +1,81 @@
+package qrcodeapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+)
+
+func TestCoalesce(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	allowLoopback(t)
+
+	// logo is fetched in the shared rendering; slow logo keeps the rendering in flight until all requests arrive
+	var fetched int32
+	logoServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetched, 1)
+		time.Sleep(300 * time.Millisecond)
+		png.Encode(w, image.NewGray(image.Rect(0, 0, 16, 16)))
+	}))
+	defer logoServer.Close()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	const n = 10
+	bodies := make([][]byte, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			resp, err := request.Get("%s/qrcode", ts.URL).
+				Query("content", "hello world").
+				Query("logo", logoServer.URL+"/logo.png").
+				Do(ctx)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer resp.Body.Close()
+			if !resp.Success() {
+				errs[i] = fmt.Errorf("failed with %d", resp.StatusCode)
+				return
+			}
+			bodies[i], errs[i] = io.ReadAll(resp.Body)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range errs {
+		require.NoError(t, errs[i])
+		require.True(t, bytes.Equal(bodies[0], bodies[i]))
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&fetched))
+
+	// next request renders again
+	resp, err := request.Get("%s/qrcode", ts.URL).
+		Query("content", "hello world").
+		Query("logo", logoServer.URL+"/logo.png").
+		Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, int32(2), atomic.LoadInt32(&fetched))
+}
@@ -0,0 +1,156 @@
+package qrcodeapi
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+	"qrcodeapi/pkg/qrcode"
+)
+
+// render render qrcode; if `persist=true` is given, code is stored and can be rendered again by `GET /q/<id>`
+func (api *APIv1) render(c echo.Context, in *qrcode.QR) error {
+	params, err := renderParams(api.cfg, c)
+	if err != nil {
+		return err
+	}
+
+	req, err := parseRenderRequest(api.cfg, params)
+	if err != nil {
+		return err
+	}
+
+	return api.renderRequest(c, in, req)
+}
+
+// renderRequest render qrcode with parsed render request, persisted if requested
+func (api *APIv1) renderRequest(c echo.Context, in *qrcode.QR, req *RenderRequest) error {
+	// dry run does not persist the code
+	dry, err := dryRun(c)
+	if err != nil {
+		return err
+	}
+
+	if v := c.QueryParam("persist"); v != "" && !dry {
+		persist, err := strconv.ParseBool(v)
+		if err != nil {
+			return invalidParam("persist", v)
+		}
+
+		if persist {
+			if api.codes == nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "persisted codes are disabled")
+			}
+
+			code, err := api.persist(c, in, req)
+			if err != nil {
+				return err
+			}
+
+			c.Response().Header().Set("X-QR-ID", code.ID)
+			if c.QueryParam("response") == "json" {
+				return c.JSON(http.StatusOK, &PersistResponse{
+					ID:        code.ID,
+					URL:       publicURL(api.cfg, c, "/q/"+code.ID),
+					ExpiresAt: code.ExpiresAt,
+				})
+			}
+		}
+	}
+
+	return writeQRCode(api.cfg, c, in, req)
+}
+
+type PersistResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// persist store the code; code id is content-addressed so that the same request returns the existing code
+func (api *APIv1) persist(c echo.Context, in *qrcode.QR, req *RenderRequest) (*codes.Code, error) {
+	kind, _ := c.Get(ctxKeyPayloadKind).(string)
+	code := codes.New(kind, in.Content, req.params(), api.cfg.CodeTTL)
+
+	existing, err := api.codes.Get(c.Request().Context(), code.ID)
+	switch {
+	case err == nil && !existing.Expired():
+		return existing, nil
+	case err != nil && !errors.Is(err, codes.ErrNotFound):
+		return nil, err
+	}
+
+	if err := api.codes.Put(c.Request().Context(), code); err != nil {
+		return nil, err
+	}
+
+	return code, nil
+}
+
+// codesAPI serves persisted codes
+type codesAPI struct {
+	cfg   *config.Config
+	store codes.Store
+}
+
+var _ router = (*codesAPI)(nil)
+
+func newCodesAPI(cfg *config.Config, store codes.Store) router {
+	return &codesAPI{cfg: cfg, store: store}
+}
+
+func (api *codesAPI) Route(e *echo.Echo, path string) {
+	e.GET(path+"/q/:id", api.handleGet)
+}
+
+// handleGet render persisted code again; image size can be overridden with `w`, `h`
+func (api *codesAPI) handleGet(c echo.Context) error {
+	code, err := api.store.Get(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, codes.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		return err
+	}
+
+	if code.Expired() {
+		return echo.NewHTTPError(http.StatusGone)
+	}
+
+	req, err := parseRenderRequest(api.cfg, func(name string) string {
+		switch name {
+		case "w", "h":
+			if v := c.QueryParam(name); v != "" {
+				return v
+			}
+		}
+		return code.Params[name]
+	})
+	if err != nil {
+		return err
+	}
+
+	qr, err := qrcode.Text(code.Content)
+	if err != nil {
+		return err
+	}
+
+	c.Set(ctxKeyPayloadKind, code.Kind)
+	return writeQRCode(api.cfg, c, qr, req)
+}
+
+// publicURL returns public url of the path
+func publicURL(cfg *config.Config, c echo.Context, path string) string {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = c.Scheme() + "://" + c.Request().Host
+	}
+
+	return strings.TrimSuffix(baseURL, "/") + path
+}
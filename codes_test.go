@@ -0,0 +1,146 @@
+package qrcodeapi
+
+import (
+	"context"
+	"image"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+	"qrcodeapi/pkg/qrcode"
+)
+
+func TestPersist(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	store := codes.NewMemoryStore()
+	ts := newTestServer(ctx, newAPIv1(config.Current(), store, nil), newCodesAPI(config.Current(), store))
+
+	resp, err := request.Get("%s/qrcode?content=hello&w=100&h=100&t=gif&persist=true", ts.URL).Do(ctx)
+	require.NoError(t, err)
+	require.Truef(t, resp.Success(), "failed with %d", resp.StatusCode)
+	id := resp.Header.Get("X-QR-ID")
+	require.NotEmpty(t, id)
+
+	var persisted PersistResponse
+	resp, err = request.Get("%s/qrcode?content=hello&persist=true&response=json", ts.URL).Do(ctx)
+	require.NoError(t, err)
+	require.Truef(t, resp.Success(), "failed with %d", resp.StatusCode)
+	defer resp.Body.Close()
+	require.NoError(t, resp.JSON(&persisted))
+	require.Equal(t, resp.Header.Get("X-QR-ID"), persisted.ID)
+	require.Equal(t, ts.URL+"/q/"+persisted.ID, persisted.URL)
+
+	tests := [...]struct {
+		name       string
+		query      string
+		wantFormat string
+		wantSize   int
+	}{
+		{"stored", "", "gif", 100}, //
+		{"resize", "?w=50&h=50", "gif", 50},
+		{"size only", "?w=150&h=150&t=png", "gif", 150},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/q/%s%s", ts.URL, id, tt.query).Do(ctx)
+			require.NoError(t, err)
+			require.Truef(t, resp.Success(), "failed with %d", resp.StatusCode)
+
+			defer resp.Body.Close()
+			img, format, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantFormat, format)
+			require.Equal(t, tt.wantSize, img.Bounds().Dx())
+
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, "hello", got)
+		})
+	}
+
+	// unknown code
+	resp, err = request.Get("%s/q/unknown", ts.URL).Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestPersistExpired(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	viper.Set("code_ttl", time.Millisecond)
+	defer viper.Set("code_ttl", 30*24*time.Hour)
+
+	store := codes.NewMemoryStore()
+	ts := newTestServer(ctx, newAPIv1(config.Current(), store, nil), newCodesAPI(config.Current(), store))
+
+	resp, err := request.Get("%s/qrcode?content=hello&persist=true", ts.URL).Do(ctx)
+	require.NoError(t, err)
+	require.Truef(t, resp.Success(), "failed with %d", resp.StatusCode)
+	id := resp.Header.Get("X-QR-ID")
+
+	time.Sleep(10 * time.Millisecond)
+
+	resp, err = request.Get("%s/q/%s", ts.URL, id).Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusGone, resp.StatusCode)
+}
+
+func TestPersistDedup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	store := codes.NewMemoryStore()
+	ts := newTestServer(ctx, newAPIv1(config.Current(), store, nil), newCodesAPI(config.Current(), store))
+
+	persist := func(query string) string {
+		resp, err := request.Get("%s/qrcode?persist=true&%s", ts.URL, query).Do(ctx)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return resp.Header.Get("X-QR-ID")
+	}
+
+	id := persist("content=hello&w=100&t=jpg")
+	require.Len(t, id, 16)
+
+	tests := [...]struct {
+		name  string
+		query string
+		same  bool
+	}{
+		{"reordered", "t=jpg&w=100&content=hello", true},
+		{"defaults", "content=hello&w=100&h=200&t=jpeg&ec=L&progressive=false&cornerRadius=0", true},
+		{"lower case ec", "content=hello&w=100&t=jpg&ec=l", true},
+		{"ec level", "content=hello&w=100&t=jpg&ec=H", false},
+		{"size", "content=hello&w=150&t=jpg", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := persist(tt.query)
+			require.Equal(t, tt.same, got == id, "id = %s, want %s", got, id)
+		})
+	}
+}
+
+func TestPersistDisabled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), nil, nil))
+
+	resp, err := request.Get("%s/qrcode?content=hello&persist=true", ts.URL).Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = request.Get("%s/qrcode?content=hello", ts.URL).Do(ctx)
+	require.NoError(t, err)
+	require.Truef(t, resp.Success(), "failed with %d", resp.StatusCode)
+}
@@ -0,0 +1,101 @@
+package qrcodeapi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"image/color"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+// parseColor parse css color; hex `#rgb`, `#rgba`, `#rrggbb` or `#rrggbbaa` and `#` is optional,
+// named color such as `navy` and functional notation `rgb(10, 20, 30)`, `rgba(10, 20, 30, 0.5)`
+func parseColor(s string) (color.Color, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if name == "transparent" {
+		return color.NRGBA{}, nil
+	}
+	if c, ok := colornames.Map[name]; ok {
+		return color.NRGBA{c.R, c.G, c.B, c.A}, nil
+	}
+
+	if m := colorFuncPattern.FindStringSubmatch(name); m != nil {
+		return parseColorFunc(s, m[1], strings.Split(m[2], ","))
+	}
+
+	digits := strings.TrimPrefix(s, "#")
+	switch len(digits) {
+	case 3, 4:
+		// shorthand, each digit is doubled
+		var b strings.Builder
+		for _, d := range digits {
+			b.WriteRune(d)
+			b.WriteRune(d)
+		}
+		digits = b.String()
+	case 6, 8:
+	default:
+		return nil, fmt.Errorf("invalid color: %s, must be color name, rgb(), rgba() or hex #rgb, #rgba, #rrggbb, #rrggbbaa", s)
+	}
+
+	b, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, fmt.Errorf("invalid color: %s, must be hex digits", s)
+	}
+
+	c := color.NRGBA{b[0], b[1], b[2], 0xff}
+	if len(b) == 4 {
+		c.A = b[3]
+	}
+	return c, nil
+}
+
+var colorFuncPattern = regexp.MustCompile(`^(rgba?)\((.*)\)$`)
+
+// parseColorFunc parse arguments of rgb() or rgba(); channels are 0~255 and alpha is 0~1 or percentage
+func parseColorFunc(s string, fn string, args []string) (color.Color, error) {
+	want := 3
+	if fn == "rgba" {
+		want = 4
+	}
+	if len(args) != want {
+		return nil, fmt.Errorf("invalid color: %s, %s() requires %d arguments", s, fn, want)
+	}
+
+	c := color.NRGBA{A: 0xff}
+	for i, channel := range []*uint8{&c.R, &c.G, &c.B} {
+		v, err := strconv.Atoi(strings.TrimSpace(args[i]))
+		if err != nil || v < 0 || v > 0xff {
+			return nil, fmt.Errorf("invalid color: %s, channel must be 0~255", s)
+		}
+		*channel = uint8(v)
+	}
+
+	if fn == "rgba" {
+		arg := strings.TrimSpace(args[3])
+		scale := 1.0
+		if strings.HasSuffix(arg, "%") {
+			arg, scale = strings.TrimSuffix(arg, "%"), 100
+		}
+		alpha, err := strconv.ParseFloat(arg, 64)
+		if err != nil || alpha < 0 || alpha > scale {
+			return nil, fmt.Errorf("invalid color: %s, alpha must be 0~1 or 0%%~100%%", s)
+		}
+		c.A = uint8(math.Round(alpha / scale * 0xff))
+	}
+
+	return c, nil
+}
+
+// formatColor returns hex color of c, alpha is omitted if opaque
+func formatColor(c color.Color) string {
+	nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if nrgba.A == 0xff {
+		return fmt.Sprintf("#%02x%02x%02x", nrgba.R, nrgba.G, nrgba.B)
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", nrgba.R, nrgba.G, nrgba.B, nrgba.A)
+}
@@ -0,0 +1,107 @@
+package qrcodeapi
+
+import (
+	"context"
+	"image/color"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := [...]struct {
+		value   string
+		want    color.Color
+		wantErr bool
+	}{
+		{"fff", color.NRGBA{0xff, 0xff, 0xff, 0xff}, false},
+		{"#fff", color.NRGBA{0xff, 0xff, 0xff, 0xff}, false},
+		{"#1a3", color.NRGBA{0x11, 0xaa, 0x33, 0xff}, false},
+		{"#1a38", color.NRGBA{0x11, 0xaa, 0x33, 0x88}, false},
+		{"#ffffff", color.NRGBA{0xff, 0xff, 0xff, 0xff}, false},
+		{"112233", color.NRGBA{0x11, 0x22, 0x33, 0xff}, false},
+		{"ffffffff", color.NRGBA{0xff, 0xff, 0xff, 0xff}, false},
+		{"#11223344", color.NRGBA{0x11, 0x22, 0x33, 0x44}, false},
+		{"#AABBCC", color.NRGBA{0xaa, 0xbb, 0xcc, 0xff}, false},
+		{"red", color.NRGBA{0xff, 0x00, 0x00, 0xff}, false},
+		{"Navy", color.NRGBA{0x00, 0x00, 0x80, 0xff}, false},
+		{" darkslateblue ", color.NRGBA{0x48, 0x3d, 0x8b, 0xff}, false},
+		{"transparent", color.NRGBA{}, false},
+		{"rgb(10,20,30)", color.NRGBA{10, 20, 30, 0xff}, false},
+		{"RGB( 10, 20, 30 )", color.NRGBA{10, 20, 30, 0xff}, false},
+		{"rgba(10,20,30,0.5)", color.NRGBA{10, 20, 30, 0x80}, false},
+		{"rgba(10, 20, 30, 0)", color.NRGBA{10, 20, 30, 0}, false},
+		{"rgba(10, 20, 30, 1)", color.NRGBA{10, 20, 30, 0xff}, false},
+		{"rgba(10, 20, 30, 25%)", color.NRGBA{10, 20, 30, 0x40}, false},
+		{"", nil, true},
+		{"#", nil, true},
+		{"ff", nil, true},
+		{"fffff", nil, true},
+		{"fffffff", nil, true},
+		{"ggg", nil, true},
+		{"#12345g", nil, true},
+		{"##fff", nil, true},
+		{"reddish", nil, true},
+		{"rgb(10,20)", nil, true},
+		{"rgb(10,20,30,0.5)", nil, true},
+		{"rgba(10,20,30)", nil, true},
+		{"rgb(256,0,0)", nil, true},
+		{"rgb(-1,0,0)", nil, true},
+		{"rgb(a,b,c)", nil, true},
+		{"rgba(0,0,0,1.5)", nil, true},
+		{"rgba(0,0,0,120%)", nil, true},
+		{"rgb(0,0,0", nil, true},
+		{"hsl(0,0%,0%)", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseColor(tt.value)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestColorParams(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), nil, nil))
+
+	tests := [...]struct {
+		query       string
+		wantStatus  int
+		wantMessage string
+	}{
+		{"fg=fff&bg=000", http.StatusOK, ""},
+		{"fg=ggg", http.StatusBadRequest, "fg: invalid color: ggg, must be hex digits"},
+		{"bg=%23ffff", http.StatusOK, ""},
+		{"bg=fffff", http.StatusBadRequest, "bg: invalid color: fffff, must be color name, rgb(), rgba() or hex"},
+		{"fg=navy&bg=rgb(250,250,250)", http.StatusOK, ""},
+		{"fg=rgb(300,0,0)", http.StatusBadRequest, "fg: invalid color: rgb(300,0,0), channel must be 0~255"},
+		{"eyeOuterColor=12", http.StatusBadRequest, "eyeOuterColor: invalid color: 12"},
+		{"eyeInnerColor=xyz", http.StatusBadRequest, "eyeInnerColor: invalid color: xyz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp, err := request.Get("%s/qrcode?content=hello&%s", ts.URL, tt.query).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Contains(t, string(body), tt.wantMessage)
+		})
+	}
+}
@@ -1,31 +1,161 @@
 package config
 
 import (
+	"sync"
+	"time"
+
+	"github.com/spf13/cast"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"github.com/whitekid/goxp/flags"
 )
 
 const (
-	keyBind      = "bind_addr"
-	keyRateLimit = "rate_limit"
+	keyBind          = "bind_addr"
+	keyRateLimit     = "rate_limit"
+	keyDefaultFormat = "default_format"
+	keyFormats       = "formats"
+	keyBaseURL       = "base_url"
+	keyAPIKeys       = "api_keys"
+	keyLinkStore     = "link_store"
+	keyLinkStorePath = "link_store_path"
+	keyLinkSchemes   = "link_schemes"
+	keyStatsFlush    = "stats_flush_interval"
+	keyGeoHeader     = "geo_header"
+	keyLinkGoneURL   = "link_gone_url"
+	keyCodeStore     = "code_store"
+	keyCodeStorePath = "code_store_path"
+	keyCodeTTL       = "code_ttl"
+	keyConfigFile    = "config_file"
+	keyPresets       = "presets"
+	keyRenderDefault = "render_defaults"
+	keyMaxVersion    = "max_version"
+	keyMaxSize       = "max_size"
+	keyVerifyRetries = "verify_retries"
+	keyURLSchemes    = "url_schemes"
+	keyURLPayload    = "url_payload"
+	keyURLBlocklist  = "url_blocklist"
+	keySafeBrowsing  = "safe_browsing_key"
+	keyDebugEncoding = "debug_encoding"
+	keyShortener     = "shortener"
+	keyShortenerURL  = "shortener_url"
+	keyShortenerKey  = "shortener_token"
+	keyShortenerReq  = "shortener_request_field"
+	keyShortenerResp = "shortener_response_field"
+	keyTokenAlg      = "token_alg"
+	keyTokenKey      = "token_key"
+	keyLogLevel      = "log_level"
+	keyLinksEnabled  = "links_enabled"
+	keyCodesEnabled  = "codes_enabled"
+	keyRenderCache   = "render_cache_size"
+	keyBatchPixels   = "batch_max_pixels"
+	keyBatchSequence = "batch_max_sequence"
+	keyLogFile       = "log_file"
+	keyMTLSBind      = "mtls_bind_addr"
+	keyMTLSCert      = "mtls_cert_file"
+	keyMTLSKey       = "mtls_key_file"
+	keyMTLSClientCA  = "mtls_client_ca"
+	keyMTLSPrincipal = "mtls_principals"
+	keyIPAllowlist   = "ip_allowlist"
+	keyIPDenylist    = "ip_denylist"
+	keyIPExempt      = "ip_exempt_paths"
+	keyTrustProxies  = "trusted_proxies"
+	keyLogMaxSize    = "log_max_size"
+	keyLogMaxBackups = "log_max_backups"
+	keyLogMaxAge     = "log_max_age"
+	keyLogCompress   = "log_compress"
 )
 
 var configs = map[string][]flags.Flag{
 	"qrcodeapi": {
-		{keyBind, "B", "127.0.0.1:8000", "bind address"},
-		{keyRateLimit, "", "20", "rate limit"},
+		{Name: keyConfigFile, Shorthand: "c", DefaultValue: "", Usage: "config file, yaml or json"},
+		{Name: keyBind, Shorthand: "B", DefaultValue: "127.0.0.1:8000", Usage: "bind address"},
+		{Name: keyRateLimit, DefaultValue: 20, Usage: "rate limit"},
+		{Name: keyLogLevel, DefaultValue: "info", Usage: "log level: debug, info, warn, error, off"},
+		{Name: keyMTLSBind, DefaultValue: "", Usage: "bind address of tls listener requiring client certificate; disabled if empty"},
+		{Name: keyMTLSCert, DefaultValue: "", Usage: "server certificate file of mtls listener"},
+		{Name: keyMTLSKey, DefaultValue: "", Usage: "server key file of mtls listener"},
+		{Name: keyMTLSClientCA, DefaultValue: "", Usage: "ca bundle file to verify client certificates"},
+		{Name: keyTrustProxies, DefaultValue: []string{}, Usage: "cidrs of proxies trusted for X-Forwarded-For; client address is used if empty"},
+		{Name: keyIPAllowlist, DefaultValue: []string{}, Usage: "cidrs of clients allowed; all if empty"},
+		{Name: keyIPDenylist, DefaultValue: []string{}, Usage: "cidrs of clients denied"},
+		{Name: keyIPExempt, DefaultValue: []string{}, Usage: "paths not restricted by ip allowlist and denylist, ex) /metrics"},
+		{Name: keyLogFile, DefaultValue: "", Usage: "access and application log file; standard output if empty"},
+		{Name: keyLogMaxSize, DefaultValue: 100, Usage: "rotate log file when it exceeds the size in megabytes; no rotation if 0"},
+		{Name: keyLogMaxBackups, DefaultValue: 7, Usage: "number of rotated log files to keep; all if 0"},
+		{Name: keyLogMaxAge, DefaultValue: time.Duration(0), Usage: "remove rotated log files older than the age; kept if 0"},
+		{Name: keyLogCompress, DefaultValue: false, Usage: "gzip rotated log files"},
+		{Name: keyDefaultFormat, DefaultValue: "png", Usage: "default image format when not negotiated"},
+		{Name: keyFormats, DefaultValue: []string{}, Usage: "enabled output formats, ex) png,svg; all if empty"},
+		{Name: keyBaseURL, DefaultValue: "", Usage: "public base url for redirect links; request host if empty"},
+		{Name: keyAPIKeys, DefaultValue: []string{}, Usage: "api keys for management api"},
+		{Name: keyLinksEnabled, DefaultValue: true, Usage: "enable dynamic qrcode links"},
+		{Name: keyLinkStore, DefaultValue: "memory", Usage: "link store: memory, file"},
+		{Name: keyLinkStorePath, DefaultValue: "links", Usage: "link store directory for file store"},
+		{Name: keyLinkSchemes, DefaultValue: []string{"http", "https"}, Usage: "allowed link target url schemes"},
+		{Name: keyStatsFlush, DefaultValue: 5 * time.Second, Usage: "interval to write link scan stats"},
+		{Name: keyGeoHeader, DefaultValue: "", Usage: "request header holding client country code, ex) CF-IPCountry"},
+		{Name: keyLinkGoneURL, DefaultValue: "", Usage: "fallback url for deactivated links; 410 Gone if empty"},
+		{Name: keyCodesEnabled, DefaultValue: true, Usage: "enable persisted codes"},
+		{Name: keyCodeStore, DefaultValue: "memory", Usage: "persisted code store: memory, file"},
+		{Name: keyCodeStorePath, DefaultValue: "codes", Usage: "code store directory for file store"},
+		{Name: keyCodeTTL, DefaultValue: 30 * 24 * time.Hour, Usage: "retention of persisted codes"},
+		{Name: keyMaxVersion, DefaultValue: 40, Usage: "max qrcode symbol version, 1~40; denser code is rejected"},
+		{Name: keyMaxSize, DefaultValue: 200, Usage: "max width and height of images in pixels, 21~4096; larger ones are clamped"},
+		{Name: keyVerifyRetries, DefaultValue: 3, Usage: "renders with reduced styling when the verified code can not be decoded; 0 disables retries"},
+		{Name: keyRenderCache, DefaultValue: 0, Usage: "number of rendered images of GET requests to cache; disabled if 0"},
+		{Name: keyBatchPixels, DefaultValue: 100_000_000, Usage: "total pixels of batch items, sum of width * height; no limit if 0"},
+		{Name: keyBatchSequence, DefaultValue: 10_000, Usage: "max count of sequence batch items"},
+		{Name: keyURLSchemes, DefaultValue: []string{"http", "https"}, Usage: "allowed url schemes of /url"},
+		{Name: keyURLPayload, DefaultValue: "urlto", Usage: "default payload of url parameter: urlto for URLTO:<url>, raw for the url as is"},
+		{Name: keyShortener, DefaultValue: "links", Usage: "url shortener of shorten parameter: links, http"},
+		{Name: keyShortenerURL, DefaultValue: "", Usage: "endpoint of http shortener, the url is posted as json"},
+		{Name: keyShortenerKey, DefaultValue: "", Usage: "bearer token of http shortener"},
+		{Name: keyShortenerReq, DefaultValue: "url", Usage: "json field of the url to http shortener"},
+		{Name: keyShortenerResp, DefaultValue: "short_url", Usage: "json field of the short url in response of http shortener"},
+		{Name: keyURLBlocklist, DefaultValue: []string{}, Usage: "blocked domains and urls of payloads, ex) evil.example for the host, .evil.example with subdomains, https://a.example/phish for the url"},
+		{Name: keySafeBrowsing, DefaultValue: "", Usage: "google safe browsing v4 api key to check urls of payloads; disabled if empty"},
+		{Name: keyDebugEncoding, DefaultValue: false, Usage: "enable /qrcode/encoding returning codewords and module matrix for debugging"},
+		{Name: keyTokenAlg, DefaultValue: "HS256", Usage: "token signing algorithm: HS256, EdDSA"},
+		{Name: keyTokenKey, DefaultValue: "", Usage: "token signing key; secret for HS256, base64 ed25519 seed for EdDSA. /token is disabled if empty"},
 	},
 }
 
-func init() {
+func init() { initViper() }
+
+func initViper() {
 	viper.SetEnvPrefix("qr")
 	viper.AutomaticEnv()
 
 	flags.InitDefaults(nil, configs)
 }
 
-func InitFlagSet(use string, fs *pflag.FlagSet) { flags.InitFlagSet(nil, configs, use, fs) }
+// flagSet flags bound to configs, kept to read configs again on reload
+var flagSet *pflag.FlagSet
+
+func InitFlagSet(use string, fs *pflag.FlagSet) {
+	flags.InitFlagSet(nil, configs, use, fs)
+	flagSet = fs
+}
+
+// mu guards settings changed by Reload at runtime
+var mu sync.RWMutex
 
-func BindAddr() string { return viper.GetString(keyBind) }
-func RateLimit() int   { return viper.GetInt(keyRateLimit) }
+// ReadInConfig read config file if it is given
+func ReadInConfig() error {
+	file := viper.GetString(keyConfigFile)
+	if file == "" {
+		return nil
+	}
+
+	viper.SetConfigFile(file)
+	return viper.ReadInConfig()
+}
+
+func presetsOf(values map[string]interface{}) map[string]map[string]string {
+	presets := map[string]map[string]string{}
+	for name, options := range values {
+		presets[name] = cast.ToStringMapString(options)
+	}
+	return presets
+}
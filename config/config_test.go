@@ -0,0 +1,182 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+// resetConfig clear values of config file and restore defaults after the test
+func resetConfig(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Reset()
+		initViper()
+	})
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+	return file
+}
+
+func TestLoad(t *testing.T) {
+	tests := [...]struct {
+		name            string
+		env             map[string]string
+		file            string
+		wantMaxVersion  int
+		wantLogLevel    string
+		wantStatsFlush  time.Duration
+		wantCodeEnabled bool
+	}{
+		{"defaults", nil, "", 40, "info", 5 * time.Second, true},
+		{"env only", map[string]string{"QR_MAX_VERSION": "10", "QR_LOG_LEVEL": "debug", "QR_CODES_ENABLED": "false"}, "", 10, "debug", 5 * time.Second, false},
+		{"file only", nil, "max_version: 20\nlog_level: warn\nstats_flush_interval: 1m\n", 20, "warn", time.Minute, true},
+		{"env over file", map[string]string{"QR_MAX_VERSION": "10"}, "max_version: 20\nlog_level: warn\n", 10, "warn", 5 * time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetConfig(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if tt.file != "" {
+				t.Setenv("QR_CONFIG_FILE", writeConfigFile(t, tt.file))
+			}
+
+			cfg, err := Load()
+			require.NoError(t, err)
+			require.Equal(t, tt.wantMaxVersion, cfg.MaxVersion)
+			require.Equal(t, tt.wantLogLevel, cfg.LogLevel)
+			require.Equal(t, tt.wantStatsFlush, cfg.StatsFlushInterval)
+			require.Equal(t, tt.wantCodeEnabled, cfg.CodesEnabled)
+		})
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := [...]struct {
+		name    string
+		env     map[string]string
+		file    string
+		wantErr string
+	}{
+		{"bind", map[string]string{"QR_BIND_ADDR": "localhost"}, "", "invalid bind_addr: localhost"},
+		{"rate limit", map[string]string{"QR_RATE_LIMIT": "0"}, "", "invalid rate_limit: 0"},
+		{"log level", map[string]string{"QR_LOG_LEVEL": "verbose"}, "", "invalid log_level: verbose"},
+		{"log max size", nil, "log_max_size: -1\n", "invalid log_max_size: -1"},
+		{"ip allowlist", nil, "ip_allowlist: [10.0.0.0/8, 10.0.0.256]\n", "invalid ip_allowlist: 10.0.0.256"},
+		{"mtls", nil, "mtls_bind_addr: 127.0.0.1:8443\nmtls_cert_file: server.pem\n", "invalid mtls_key_file: , required for mtls_bind_addr"},
+		{"base url", map[string]string{"QR_BASE_URL": "example.com"}, "", "invalid base_url: example.com"},
+		{"format", map[string]string{"QR_DEFAULT_FORMAT": "bmp"}, "", "invalid default_format: bmp"},
+		{"formats", nil, "formats: [png, bmp]\n", "invalid formats: bmp"},
+		{"default format disabled", nil, "formats: [svg, txt]\n", "invalid default_format: png, must be one of formats"},
+		{"max version", nil, "max_version: 41\n", "invalid max_version: 41"},
+		{"max size", nil, "max_size: 10\n", "invalid max_size: 10, must be between 21 and 4096"},
+		{"verify retries", nil, "verify_retries: -1\n", "invalid verify_retries: -1"},
+		{"store", nil, "code_store: redis\n", "invalid code_store: redis"},
+		{"store path", nil, "link_store: file\nlink_store_path: \"\"\n", "invalid link_store_path: "},
+		{"code ttl", map[string]string{"QR_CODE_TTL": "-1h"}, "", "invalid code_ttl: -1h0m0s"},
+		{"token alg", map[string]string{"QR_TOKEN_ALG": "none"}, "", "invalid token_alg: none"},
+		{"url payload", map[string]string{"QR_URL_PAYLOAD": "plain"}, "", "invalid url_payload: plain, must be one of urlto, raw"},
+		{"render defaults", nil, "render_defaults:\n  w: 100\n  t: svg\n", "invalid render_defaults: t, must be one of w, h"},
+		{"shortener", map[string]string{"QR_SHORTENER": "bitly"}, "", "invalid shortener: bitly"},
+		{"shortener url", map[string]string{"QR_SHORTENER": "http"}, "", "invalid shortener_url: , must be http or https url"},
+		{"url blocklist", nil, "url_blocklist: [evil.example, ftp://evil.example/]\n", "invalid url_blocklist: ftp://evil.example/"},
+		{"url blocklist domain", nil, "url_blocklist: [\".\"]\n", "invalid url_blocklist: ., must be domain"},
+		{"file not found", map[string]string{"QR_CONFIG_FILE": "/not/found.yaml"}, "", "found.yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetConfig(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if tt.file != "" {
+				t.Setenv("QR_CONFIG_FILE", writeConfigFile(t, tt.file))
+			}
+
+			_, err := Load()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	resetConfig(t)
+	t.Setenv("QR_API_KEYS", "key1 key2")
+	t.Setenv("QR_TOKEN_KEY", "signing-secret")
+	t.Setenv("QR_SAFE_BROWSING_KEY", "browsing-secret")
+	t.Setenv("QR_SHORTENER_TOKEN", "shortener-secret")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	require.Equal(t, []string{"key1", "key2"}, cfg.APIKeys)
+
+	out, err := cfg.YAML()
+	require.NoError(t, err)
+	require.Contains(t, out, "max_version: 40\n")
+	require.Contains(t, out, "token_key: '******'\n")
+	require.Contains(t, out, "safe_browsing_key: '******'\n")
+	for _, secret := range []string{"key1", "key2", "signing-secret", "browsing-secret", "shortener-secret"} {
+		require.False(t, strings.Contains(out, secret), "secret %s is exposed", secret)
+	}
+
+	// config is not modified
+	require.Equal(t, "signing-secret", cfg.TokenKey)
+}
+
+func TestReload(t *testing.T) {
+	tests := [...]struct {
+		name          string
+		file          string
+		wantErr       bool
+		wantWarnings  []string
+		wantRateLimit int
+		wantPresets   []string
+		wantBindAddr  string
+	}{
+		{"unchanged", "rate_limit: 10\npresets: {dark: {fg: \"#ffffff\"}}\n", false, nil, 10, []string{"dark"}, "127.0.0.1:8000"},
+		{"reloadable", "rate_limit: 5\nlog_level: debug\npresets: {light: {fg: \"#000000\"}}\n", false, nil, 5, []string{"light"}, "127.0.0.1:8000"},
+		{"restart required", "rate_limit: 5\nbind_addr: 127.0.0.1:9000\nmax_version: 10\n", false, []string{"bind_addr is changed, restart required", "max_version is changed, restart required"}, 5, []string{}, "127.0.0.1:8000"},
+		{"invalid", "rate_limit: 0\npresets: {light: {fg: \"#000000\"}}\n", true, nil, 10, []string{"dark"}, "127.0.0.1:8000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetConfig(t)
+			file := writeConfigFile(t, "rate_limit: 10\npresets: {dark: {fg: \"#ffffff\"}}\n")
+			t.Setenv("QR_CONFIG_FILE", file)
+			cfg, err := Load()
+			require.NoError(t, err)
+
+			require.NoError(t, os.WriteFile(file, []byte(tt.file), 0o644))
+			warnings, err := Reload(cfg)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantWarnings, warnings)
+			require.Equal(t, tt.wantRateLimit, Current().RateLimit)
+			require.Equal(t, tt.wantRateLimit, cfg.RateLimit)
+			require.ElementsMatch(t, tt.wantPresets, keysOf(cfg.RenderPresets()))
+			require.Equal(t, tt.wantBindAddr, Current().BindAddr)
+			require.Equal(t, tt.wantBindAddr, cfg.BindAddr)
+		})
+	}
+}
+
+func keysOf(m map[string]map[string]string) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
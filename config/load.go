@@ -0,0 +1,435 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/whitekid/goxp/fx"
+	"gopkg.in/yaml.v3"
+
+	"qrcodeapi/pkg/formats"
+)
+
+// Config effective configuration of the server.
+// values are taken from flags, environment variables(`QR_<KEY>`), config file and defaults in the order of precedence.
+type Config struct {
+	ConfigFile string `yaml:"config_file"`
+
+	// server
+	BindAddr  string   `yaml:"bind_addr"`
+	RateLimit int      `yaml:"rate_limit"`
+	LogLevel  string   `yaml:"log_level"`
+	BaseURL   string   `yaml:"base_url"`
+	APIKeys   []string `yaml:"api_keys"` // secret
+
+	// mutual tls listener
+	MTLSBindAddr   string            `yaml:"mtls_bind_addr"`
+	MTLSCertFile   string            `yaml:"mtls_cert_file"`
+	MTLSKeyFile    string            `yaml:"mtls_key_file"`
+	MTLSClientCA   string            `yaml:"mtls_client_ca"`
+	MTLSPrincipals map[string]string `yaml:"mtls_principals"` // certificate CN or SAN to principal
+
+	// client address
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	IPAllowlist    []string `yaml:"ip_allowlist"`
+	IPDenylist     []string `yaml:"ip_denylist"`
+	IPExemptPaths  []string `yaml:"ip_exempt_paths"`
+
+	// log file
+	LogFile       string        `yaml:"log_file"`
+	LogMaxSize    int           `yaml:"log_max_size"` // megabytes
+	LogMaxBackups int           `yaml:"log_max_backups"`
+	LogMaxAge     time.Duration `yaml:"log_max_age"`
+	LogCompress   bool          `yaml:"log_compress"`
+
+	// render
+	DefaultFormat          string                       `yaml:"default_format"`
+	Formats                []string                     `yaml:"formats"` // enabled output formats, all if empty
+	MaxVersion             int                          `yaml:"max_version"`
+	MaxSize                int                          `yaml:"max_size"`       // of w and h, also of render_defaults
+	VerifyRetries          int                          `yaml:"verify_retries"` // re-renders of unscannable codes with reduced styling
+	URLSchemes             []string                     `yaml:"url_schemes"`
+	URLPayload             string                       `yaml:"url_payload"`
+	Shortener              string                       `yaml:"shortener"`
+	ShortenerURL           string                       `yaml:"shortener_url"`
+	ShortenerToken         string                       `yaml:"shortener_token"` // secret
+	ShortenerRequestField  string                       `yaml:"shortener_request_field"`
+	ShortenerResponseField string                       `yaml:"shortener_response_field"`
+	URLBlocklist           []string                     `yaml:"url_blocklist"`
+	SafeBrowsingKey        string                       `yaml:"safe_browsing_key"` // secret
+	DebugEncoding          bool                         `yaml:"debug_encoding"`
+	Presets                map[string]map[string]string `yaml:"presets"`
+	RenderDefaults         map[string]string            `yaml:"render_defaults"` // values are validated as request parameters on startup
+	RenderCache            int                          `yaml:"render_cache_size"`
+	BatchPixels            int                          `yaml:"batch_max_pixels"`
+	BatchSequence          int                          `yaml:"batch_max_sequence"`
+
+	// dynamic links
+	LinksEnabled       bool          `yaml:"links_enabled"`
+	LinkStore          string        `yaml:"link_store"`
+	LinkStorePath      string        `yaml:"link_store_path"`
+	LinkSchemes        []string      `yaml:"link_schemes"`
+	LinkGoneURL        string        `yaml:"link_gone_url"`
+	StatsFlushInterval time.Duration `yaml:"stats_flush_interval"`
+	GeoHeader          string        `yaml:"geo_header"`
+
+	// persisted codes
+	CodesEnabled  bool          `yaml:"codes_enabled"`
+	CodeStore     string        `yaml:"code_store"`
+	CodeStorePath string        `yaml:"code_store_path"`
+	CodeTTL       time.Duration `yaml:"code_ttl"`
+
+	// signed token
+	TokenAlg string `yaml:"token_alg"`
+	TokenKey string `yaml:"token_key"` // secret
+}
+
+// Load read config file and returns validated configuration
+func Load() (*Config, error) {
+	if err := ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	cfg := Current()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Current returns configuration of current settings
+func Current() *Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	return configOf(viper.GetViper())
+}
+
+func configOf(v *viper.Viper) *Config {
+	return &Config{
+		ConfigFile:             v.GetString(keyConfigFile),
+		BindAddr:               v.GetString(keyBind),
+		RateLimit:              v.GetInt(keyRateLimit),
+		LogLevel:               v.GetString(keyLogLevel),
+		BaseURL:                v.GetString(keyBaseURL),
+		APIKeys:                v.GetStringSlice(keyAPIKeys),
+		MTLSBindAddr:           v.GetString(keyMTLSBind),
+		MTLSCertFile:           v.GetString(keyMTLSCert),
+		MTLSKeyFile:            v.GetString(keyMTLSKey),
+		MTLSClientCA:           v.GetString(keyMTLSClientCA),
+		MTLSPrincipals:         v.GetStringMapString(keyMTLSPrincipal),
+		TrustedProxies:         v.GetStringSlice(keyTrustProxies),
+		IPAllowlist:            v.GetStringSlice(keyIPAllowlist),
+		IPDenylist:             v.GetStringSlice(keyIPDenylist),
+		IPExemptPaths:          v.GetStringSlice(keyIPExempt),
+		LogFile:                v.GetString(keyLogFile),
+		LogMaxSize:             v.GetInt(keyLogMaxSize),
+		LogMaxBackups:          v.GetInt(keyLogMaxBackups),
+		LogMaxAge:              v.GetDuration(keyLogMaxAge),
+		LogCompress:            v.GetBool(keyLogCompress),
+		DefaultFormat:          v.GetString(keyDefaultFormat),
+		Formats:                v.GetStringSlice(keyFormats),
+		MaxVersion:             v.GetInt(keyMaxVersion),
+		MaxSize:                v.GetInt(keyMaxSize),
+		VerifyRetries:          v.GetInt(keyVerifyRetries),
+		URLSchemes:             v.GetStringSlice(keyURLSchemes),
+		URLPayload:             v.GetString(keyURLPayload),
+		Shortener:              v.GetString(keyShortener),
+		ShortenerURL:           v.GetString(keyShortenerURL),
+		ShortenerToken:         v.GetString(keyShortenerKey),
+		ShortenerRequestField:  v.GetString(keyShortenerReq),
+		ShortenerResponseField: v.GetString(keyShortenerResp),
+		URLBlocklist:           v.GetStringSlice(keyURLBlocklist),
+		SafeBrowsingKey:        v.GetString(keySafeBrowsing),
+		DebugEncoding:          v.GetBool(keyDebugEncoding),
+		Presets:                presetsOf(v.GetStringMap(keyPresets)),
+		RenderDefaults:         v.GetStringMapString(keyRenderDefault),
+		RenderCache:            v.GetInt(keyRenderCache),
+		BatchPixels:            v.GetInt(keyBatchPixels),
+		BatchSequence:          v.GetInt(keyBatchSequence),
+		LinksEnabled:           v.GetBool(keyLinksEnabled),
+		LinkStore:              v.GetString(keyLinkStore),
+		LinkStorePath:          v.GetString(keyLinkStorePath),
+		LinkSchemes:            v.GetStringSlice(keyLinkSchemes),
+		LinkGoneURL:            v.GetString(keyLinkGoneURL),
+		StatsFlushInterval:     v.GetDuration(keyStatsFlush),
+		GeoHeader:              v.GetString(keyGeoHeader),
+		CodesEnabled:           v.GetBool(keyCodesEnabled),
+		CodeStore:              v.GetString(keyCodeStore),
+		CodeStorePath:          v.GetString(keyCodeStorePath),
+		CodeTTL:                v.GetDuration(keyCodeTTL),
+		TokenAlg:               v.GetString(keyTokenAlg),
+		TokenKey:               v.GetString(keyTokenKey),
+	}
+}
+
+// Snapshot returns copy of the config; configs which are changed by Reload while serving, see reloadableKeys,
+// are read from the snapshot
+func (cfg *Config) Snapshot() Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	return *cfg
+}
+
+// RenderPresets returns named render presets; option names are lower-cased. presets are changed by Reload while serving
+func (cfg *Config) RenderPresets() map[string]map[string]string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.Presets
+}
+
+// ParseCIDR parse cidr; ip address is parsed as single address network, ex) `10.0.0.1` as `10.0.0.1/32`
+func ParseCIDR(s string) (*net.IPNet, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, ipNet, err := net.ParseCIDR(s)
+	return ipNet, err
+}
+
+// invalid returns validation error of the key
+func invalid(key string, value interface{}, reason string) error {
+	return fmt.Errorf("invalid %s: %v, %s", key, value, reason)
+}
+
+var (
+	logLevels   = []string{"debug", "info", "warn", "error", "off"}
+	storeKinds  = []string{"memory", "file"}
+	shorteners  = []string{"links", "http"}
+	urlPayloads = []string{"urlto", "raw"}
+	tokenAlgs   = []string{"HS256", "EdDSA"}
+
+	// renderDefaultParams parameters of render_defaults, lower-cased; image type is default_format
+	renderDefaultParams = []string{"w", "h", "ec", "margin", "fg", "bg", "padcolor", "eyeoutercolor", "eyeinnercolor"}
+)
+
+// maxVerifyRetries number of styling reductions of unscannable codes: error correction, halftone, shape, colors and logo
+const maxVerifyRetries = 5
+
+// range of max_size; the smallest image of version 1 code, and 4096 for printing at 300dpi up to about 34cm
+const (
+	minMaxSize = 21
+	maxMaxSize = 4096
+)
+
+// Validate returns error naming the first invalid key and its value
+func (cfg *Config) Validate() error {
+	if _, _, err := net.SplitHostPort(cfg.BindAddr); err != nil {
+		return invalid(keyBind, cfg.BindAddr, "must be host:port")
+	}
+
+	if cfg.MTLSBindAddr != "" {
+		if _, _, err := net.SplitHostPort(cfg.MTLSBindAddr); err != nil {
+			return invalid(keyMTLSBind, cfg.MTLSBindAddr, "must be host:port")
+		}
+
+		for _, v := range [...]struct{ key, value string }{{keyMTLSCert, cfg.MTLSCertFile}, {keyMTLSKey, cfg.MTLSKeyFile}, {keyMTLSClientCA, cfg.MTLSClientCA}} {
+			if v.value == "" {
+				return invalid(v.key, v.value, "required for "+keyMTLSBind)
+			}
+		}
+	}
+
+	if cfg.RateLimit <= 0 {
+		return invalid(keyRateLimit, cfg.RateLimit, "must be positive")
+	}
+
+	for _, v := range [...]struct {
+		key   string
+		value []string
+	}{{keyTrustProxies, cfg.TrustedProxies}, {keyIPAllowlist, cfg.IPAllowlist}, {keyIPDenylist, cfg.IPDenylist}} {
+		for _, s := range v.value {
+			if _, err := ParseCIDR(s); err != nil {
+				return invalid(v.key, s, "must be cidr or ip address")
+			}
+		}
+	}
+
+	if !fx.Contains(logLevels, cfg.LogLevel) {
+		return invalid(keyLogLevel, cfg.LogLevel, "must be one of "+strings.Join(logLevels, ", "))
+	}
+
+	for _, v := range [...]struct {
+		key   string
+		value int
+	}{{keyLogMaxSize, cfg.LogMaxSize}, {keyLogMaxBackups, cfg.LogMaxBackups}} {
+		if v.value < 0 {
+			return invalid(v.key, v.value, "must not be negative")
+		}
+	}
+
+	if cfg.LogMaxAge < 0 {
+		return invalid(keyLogMaxAge, cfg.LogMaxAge, "must not be negative")
+	}
+
+	for _, v := range [...]struct{ key, value string }{{keyBaseURL, cfg.BaseURL}, {keyLinkGoneURL, cfg.LinkGoneURL}} {
+		if v.value == "" {
+			continue
+		}
+		if u, err := url.Parse(v.value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return invalid(v.key, v.value, "must be http or https url")
+		}
+	}
+
+	if !formats.Valid(cfg.DefaultFormat) {
+		return invalid(keyDefaultFormat, cfg.DefaultFormat, "must be one of "+strings.Join(formats.Names, ", "))
+	}
+
+	for _, format := range cfg.Formats {
+		if !formats.Valid(format) {
+			return invalid(keyFormats, format, "must be one of "+strings.Join(formats.Names, ", "))
+		}
+	}
+	if len(cfg.Formats) > 0 && !fx.Contains(fx.Map(cfg.Formats, formats.Normalize), formats.Normalize(cfg.DefaultFormat)) {
+		return invalid(keyDefaultFormat, cfg.DefaultFormat, "must be one of formats")
+	}
+
+	for name := range cfg.RenderDefaults {
+		if !fx.Contains(renderDefaultParams, name) {
+			return invalid(keyRenderDefault, name, "must be one of "+strings.Join(renderDefaultParams, ", "))
+		}
+	}
+
+	if cfg.MaxVersion < 1 || cfg.MaxVersion > 40 {
+		return invalid(keyMaxVersion, cfg.MaxVersion, "must be between 1 and 40")
+	}
+
+	if cfg.MaxSize < minMaxSize || cfg.MaxSize > maxMaxSize {
+		return invalid(keyMaxSize, cfg.MaxSize, fmt.Sprintf("must be between %d and %d", minMaxSize, maxMaxSize))
+	}
+
+	if cfg.VerifyRetries < 0 || cfg.VerifyRetries > maxVerifyRetries {
+		return invalid(keyVerifyRetries, cfg.VerifyRetries, fmt.Sprintf("must be between 0 and %d", maxVerifyRetries))
+	}
+
+	if cfg.RenderCache < 0 {
+		return invalid(keyRenderCache, cfg.RenderCache, "must not be negative")
+	}
+
+	if cfg.BatchPixels < 0 {
+		return invalid(keyBatchPixels, cfg.BatchPixels, "must not be negative")
+	}
+
+	if cfg.BatchSequence < 1 {
+		return invalid(keyBatchSequence, cfg.BatchSequence, "must be positive")
+	}
+
+	for _, v := range [...]struct {
+		key   string
+		value []string
+	}{{keyURLSchemes, cfg.URLSchemes}, {keyLinkSchemes, cfg.LinkSchemes}} {
+		if len(v.value) == 0 {
+			return invalid(v.key, v.value, "at least one scheme is required")
+		}
+	}
+
+	if !fx.Contains(urlPayloads, cfg.URLPayload) {
+		return invalid(keyURLPayload, cfg.URLPayload, "must be one of "+strings.Join(urlPayloads, ", "))
+	}
+
+	if !fx.Contains(shorteners, cfg.Shortener) {
+		return invalid(keyShortener, cfg.Shortener, "must be one of "+strings.Join(shorteners, ", "))
+	}
+
+	if cfg.Shortener == "http" {
+		if u, err := url.Parse(cfg.ShortenerURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return invalid(keyShortenerURL, cfg.ShortenerURL, "must be http or https url for http shortener")
+		}
+		for _, v := range [...]struct{ key, value string }{{keyShortenerReq, cfg.ShortenerRequestField}, {keyShortenerResp, cfg.ShortenerResponseField}} {
+			if v.value == "" {
+				return invalid(v.key, v.value, "required for http shortener")
+			}
+		}
+	}
+
+	for _, entry := range cfg.URLBlocklist {
+		if err := validateBlockEntry(entry); err != nil {
+			return invalid(keyURLBlocklist, entry, err.Error())
+		}
+	}
+
+	stores := [...]struct {
+		key, pathKey string
+		kind, path   string
+	}{
+		{keyLinkStore, keyLinkStorePath, cfg.LinkStore, cfg.LinkStorePath},
+		{keyCodeStore, keyCodeStorePath, cfg.CodeStore, cfg.CodeStorePath},
+	}
+	for _, store := range stores {
+		if !fx.Contains(storeKinds, store.kind) {
+			return invalid(store.key, store.kind, "must be one of "+strings.Join(storeKinds, ", "))
+		}
+		if store.kind == "file" && store.path == "" {
+			return invalid(store.pathKey, store.path, "required for file store")
+		}
+	}
+
+	if cfg.StatsFlushInterval <= 0 {
+		return invalid(keyStatsFlush, cfg.StatsFlushInterval, "must be positive")
+	}
+
+	if cfg.CodeTTL <= 0 {
+		return invalid(keyCodeTTL, cfg.CodeTTL, "must be positive")
+	}
+
+	if !fx.Contains(tokenAlgs, cfg.TokenAlg) {
+		return invalid(keyTokenAlg, cfg.TokenAlg, "must be one of "+strings.Join(tokenAlgs, ", "))
+	}
+
+	return nil
+}
+
+// validateBlockEntry check the entry is a domain, `.` prefixed domain or http(s) url
+func validateBlockEntry(entry string) error {
+	if strings.Contains(entry, "://") {
+		if u, err := url.Parse(entry); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("url must be http or https with host")
+		}
+		return nil
+	}
+
+	if domain := strings.TrimPrefix(entry, "."); domain == "" || strings.ContainsAny(domain, " /:") {
+		return errors.New("must be domain, .domain or url")
+	}
+	return nil
+}
+
+const masked = "******"
+
+// Masked returns copy of the config with secrets masked
+func (cfg *Config) Masked() *Config {
+	c := *cfg
+	c.APIKeys = make([]string, len(cfg.APIKeys))
+	for i := range c.APIKeys {
+		c.APIKeys[i] = masked
+	}
+	if c.TokenKey != "" {
+		c.TokenKey = masked
+	}
+	if c.SafeBrowsingKey != "" {
+		c.SafeBrowsingKey = masked
+	}
+	if c.ShortenerToken != "" {
+		c.ShortenerToken = masked
+	}
+	return &c
+}
+
+// YAML returns yaml of the config with secrets masked
+func (cfg *Config) YAML() (string, error) {
+	b, err := yaml.Marshal(cfg.Masked())
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/spf13/viper"
+	"github.com/whitekid/goxp/flags"
+	"github.com/whitekid/goxp/fx"
+)
+
+// reloadableKeys configs which are safe to change while serving
+var reloadableKeys = []string{keyRateLimit, keyPresets, keyAPIKeys, keyLogLevel, keyRenderCache, keyBatchPixels, keyBatchSequence,
+	keyURLBlocklist, keySafeBrowsing, keyVerifyRetries}
+
+// Reload read config file again and apply configs which are safe to change while serving to the settings and cfg,
+// the config of the server, see reloadableKeys. changes of other configs, such as bind_addr, are not applied
+// but returned as warnings. current config is kept if the new config is not valid.
+func Reload(cfg *Config) (warnings []string, err error) {
+	v, err := readConfig(Current().ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
+	next := configOf(v)
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	current := reflect.ValueOf(configOf(viper.GetViper())).Elem()
+	changed := reflect.ValueOf(next).Elem()
+	served := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < current.NumField(); i++ {
+		if reflect.DeepEqual(current.Field(i).Interface(), changed.Field(i).Interface()) {
+			continue
+		}
+
+		key := current.Type().Field(i).Tag.Get("yaml")
+		if !fx.Contains(reloadableKeys, key) {
+			warnings = append(warnings, key+" is changed, restart required")
+			continue
+		}
+
+		value := v.Get(key)
+		if value == nil {
+			// removed from config file; set empty value so that the value of previous file is not used
+			value = reflect.Zero(changed.Field(i).Type()).Interface()
+		}
+		viper.Set(key, value)
+		served.Field(i).Set(changed.Field(i))
+	}
+
+	return warnings, nil
+}
+
+// readConfig read configs with new viper instance as startup does
+func readConfig(file string) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetEnvPrefix("qr")
+	v.AutomaticEnv()
+	flags.InitDefaults(v, configs)
+	if flagSet != nil {
+		if err := v.BindPFlags(flagSet); err != nil {
+			return nil, err
+		}
+	}
+
+	if file != "" {
+		v.Set(keyConfigFile, file)
+		v.SetConfigFile(file)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("read config: %w", err)
+		}
+	}
+
+	return v, nil
+}
@@ -0,0 +1,139 @@
+package qrcodeapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/whitekid/goxp/fx"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+
+	"qrcodeapi/pkg/qrcode"
+)
+
+const (
+	maxDecodeBytes  = maxBackgroundBytes // read limit of the posted image
+	annotateOutline = 2                  // width of the boxes in pixels
+)
+
+// annotateColor color of boxes and captions, rarely a color of codes or photos
+var annotateColor = color.RGBA{0xff, 0x00, 0xff, 0xff}
+
+// DecodeResponse codes found in the posted image, `POST /decode`
+type DecodeResponse struct {
+	Codes     []*DecodeResult `json:"codes"`
+	Annotated string          `json:"annotated,omitempty"` // base64 png of the image with boxes of the codes
+}
+
+// DecodeResult code of the image; points and box are in pixels of the image
+type DecodeResult struct {
+	Content string        `json:"content"`
+	Points  []DecodePoint `json:"points"` // finder patterns, bottom-left, top-left and top-right, and alignment pattern
+	Box     DecodeBox     `json:"box"`
+}
+
+// DecodePoint center of the pattern
+type DecodePoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// DecodeBox bounding box of the code
+type DecodeBox struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// handleDecode decode every code of the posted png, jpeg or gif image; with `annotate=true`, the image is returned
+// with the box and the content of each code drawn, so that it shows where the codes are found, or none is found.
+func (api *APIv1) handleDecode(c echo.Context) error {
+	annotate := c.QueryParam("annotate")
+	if annotate != "" && annotate != "true" && annotate != "false" {
+		return invalidParam("annotate", annotate)
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, maxDecodeBytes))
+	defer c.Request().Body.Close()
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("larger than %d bytes", maxDecodeBytes))
+		}
+		return err
+	}
+
+	img, err := decodeImage(data, "posted")
+	if err != nil {
+		return err
+	}
+
+	codes, err := qrcode.DecodeAll(img)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "failed to decode: "+err.Error())
+	}
+
+	resp := &DecodeResponse{Codes: make([]*DecodeResult, len(codes))}
+	for i, code := range codes {
+		r := code.Bounds
+		resp.Codes[i] = &DecodeResult{
+			Content: code.Content,
+			Points:  fx.Map(code.Points, func(p image.Point) DecodePoint { return DecodePoint{p.X, p.Y} }),
+			Box:     DecodeBox{r.Min.X, r.Min.Y, r.Dx(), r.Dy()},
+		}
+	}
+
+	if annotate == "true" {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, annotateImage(img, codes)); err != nil {
+			return err
+		}
+		resp.Annotated = base64.StdEncoding.EncodeToString(buf.Bytes())
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// annotateImage draw outline of the bounds of each code, and its content under the box, or above if no room
+func annotateImage(img image.Image, codes []*qrcode.DecodedCode) image.Image {
+	bounds := img.Bounds()
+	out := image.NewRGBA(bounds)
+	draw.Draw(out, bounds, img, bounds.Min, draw.Src)
+
+	src := image.NewUniform(annotateColor)
+	face := basicfont.Face7x13
+	d := &font.Drawer{Dst: out, Src: src, Face: face}
+	lineHeight := face.Metrics().Height.Ceil()
+
+	for _, code := range codes {
+		r := code.Bounds
+		for _, edge := range []image.Rectangle{
+			image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+annotateOutline),
+			image.Rect(r.Min.X, r.Max.Y-annotateOutline, r.Max.X, r.Max.Y),
+			image.Rect(r.Min.X, r.Min.Y, r.Min.X+annotateOutline, r.Max.Y),
+			image.Rect(r.Max.X-annotateOutline, r.Min.Y, r.Max.X, r.Max.Y),
+		} {
+			draw.Draw(out, edge.Intersect(bounds), src, image.Point{}, draw.Src)
+		}
+
+		caption := wrapText(code.Content, (bounds.Max.X-r.Min.X)/face.Advance)[0]
+		y := r.Max.Y + face.Metrics().Ascent.Ceil()
+		if y+lineHeight-face.Metrics().Ascent.Ceil() > bounds.Max.Y {
+			y = r.Min.Y - lineHeight + face.Metrics().Ascent.Ceil()
+		}
+		d.Dot = fixed.P(r.Min.X, y)
+		d.DrawString(caption)
+	}
+	return out
+}
@@ -0,0 +1,132 @@
+package qrcodeapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/qrcode"
+)
+
+func TestDecode(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), nil, nil))
+
+	// two codes side by side on white, and the bounds of their dark modules
+	composite := image.NewRGBA(image.Rect(0, 0, 480, 260))
+	draw.Draw(composite, composite.Bounds(), image.White, image.Point{}, draw.Src)
+	var symbols []image.Rectangle
+	for i, content := range []string{"first code", "https://example.com/second"} {
+		qr, err := qrcode.Text(content)
+		require.NoError(t, err)
+		img, err := qr.Render(200, 200)
+		require.NoError(t, err)
+		offset := image.Pt(20+i*240, 20)
+		draw.Draw(composite, img.Bounds().Add(offset), img, image.Point{}, draw.Src)
+
+		symbol := image.Rectangle{}
+		for y := 0; y < 200; y++ {
+			for x := 0; x < 200; x++ {
+				if r, _, _, _ := img.At(x, y).RGBA(); r < 0x8000 {
+					symbol = symbol.Union(image.Rect(x, y, x+1, y+1))
+				}
+			}
+		}
+		symbols = append(symbols, symbol.Add(offset))
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, composite))
+
+	decode := func(query string, data []byte) (*request.Response, *DecodeResponse) {
+		resp, err := request.Post("%s/decode%s", ts.URL, query).Header(request.HeaderContentType, "image/png").Body(bytes.NewReader(data)).Do(ctx)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		if !resp.Success() {
+			return resp, nil
+		}
+
+		got := &DecodeResponse{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(got))
+		return resp, got
+	}
+
+	resp, got := decode("?annotate=true", buf.Bytes())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, got.Codes, 2)
+	sort.Slice(got.Codes, func(i, j int) bool { return got.Codes[i].Box.X < got.Codes[j].Box.X })
+	require.Equal(t, "first code", got.Codes[0].Content)
+	require.Equal(t, "https://example.com/second", got.Codes[1].Content)
+
+	data, err := base64.StdEncoding.DecodeString(got.Annotated)
+	require.NoError(t, err)
+	annotated, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, composite.Bounds(), annotated.Bounds())
+
+	isAnnotation := func(x, y int) bool { return sameColor(annotated.At(x, y), annotateColor) }
+	for i, code := range got.Codes {
+		box := image.Rect(code.Box.X, code.Box.Y, code.Box.X+code.Box.W, code.Box.Y+code.Box.H)
+		// box is around the symbol within a pixel of rounding
+		require.True(t, symbols[i].In(box), "%v of %v", box, symbols[i])
+		require.True(t, box.In(symbols[i].Inset(-1)), "%v of %v", box, symbols[i])
+		for _, p := range code.Points {
+			require.True(t, image.Pt(p.X, p.Y).In(box), p)
+		}
+
+		// edges of the box are drawn, and the inside is not
+		for _, p := range []image.Point{
+			{box.Min.X, (box.Min.Y + box.Max.Y) / 2}, {box.Max.X - 1, (box.Min.Y + box.Max.Y) / 2},
+			{(box.Min.X + box.Max.X) / 2, box.Min.Y}, {(box.Min.X + box.Max.X) / 2, box.Max.Y - 1},
+			box.Min, box.Max.Sub(image.Pt(1, 1)),
+		} {
+			require.True(t, isAnnotation(p.X, p.Y), "%v of %v", p, box)
+		}
+		require.False(t, isAnnotation(box.Min.X+annotateOutline, box.Min.Y+annotateOutline))
+
+		// caption under the box
+		captioned := false
+		for y := box.Max.Y; y < annotated.Bounds().Max.Y && !captioned; y++ {
+			for x := box.Min.X; x < box.Max.X; x++ {
+				if isAnnotation(x, y) {
+					captioned = true
+					break
+				}
+			}
+		}
+		require.True(t, captioned, "caption of %s", code.Content)
+	}
+
+	// without annotate
+	_, got = decode("", buf.Bytes())
+	require.Len(t, got.Codes, 2)
+	require.Empty(t, got.Annotated)
+
+	// no code is found, the image is returned as it is
+	blank := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	draw.Draw(blank, blank.Bounds(), image.NewUniform(color.Gray{0xe0}), image.Point{}, draw.Src)
+	buf.Reset()
+	require.NoError(t, png.Encode(&buf, blank))
+	_, got = decode("?annotate=true", buf.Bytes())
+	require.Empty(t, got.Codes)
+	require.NotEmpty(t, got.Annotated)
+
+	resp, _ = decode("", []byte("not an image"))
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = decode("?annotate=yes", buf.Bytes())
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,327 @@
+package qrcodeapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/qrcode"
+)
+
+// QRCodeDocument complete options document of `POST /qrcode`; one of content, url or wifi is required
+type QRCodeDocument struct {
+	Content string        `json:"content,omitempty"`
+	URL     string        `json:"url,omitempty"`
+	Wifi    *WifiDocument `json:"wifi,omitempty"`
+
+	Width       int     `json:"width,omitempty"`
+	Height      int     `json:"height,omitempty"`
+	Type        string  `json:"type,omitempty"`
+	DPR         float64 `json:"dpr,omitempty"` // device pixel ratio
+	EC          string  `json:"ec,omitempty"`
+	Charset     string  `json:"charset,omitempty"` // charset of byte mode content, utf-8 if empty
+	ECI         *bool   `json:"eci,omitempty"`
+	Margin      *int    `json:"margin,omitempty"`
+	Progressive bool    `json:"progressive,omitempty"`
+	ExactSize   bool    `json:"exactSize,omitempty"` // output is exactly width x height, including the label
+	Label       string  `json:"label,omitempty"`
+	CaptionSize float64 `json:"captionSize,omitempty"` // pixels, or ratio of the width if less than 1
+	Compression string  `json:"compression,omitempty"` // png compression level
+	Alt         string  `json:"alt,omitempty"`
+
+	// quiet zone of the side, margin if not given
+	Margins struct {
+		Top    *int `json:"top,omitempty"`
+		Right  *int `json:"right,omitempty"`
+		Bottom *int `json:"bottom,omitempty"`
+		Left   *int `json:"left,omitempty"`
+	} `json:"margins"`
+
+	Colors struct {
+		FG       string `json:"fg,omitempty"`
+		BG       string `json:"bg,omitempty"`
+		Pad      string `json:"pad,omitempty"` // letterbox outside of the quiet zone
+		EyeOuter string `json:"eyeOuter,omitempty"`
+		EyeInner string `json:"eyeInner,omitempty"`
+	} `json:"colors"`
+
+	Style struct {
+		Shape        string `json:"shape,omitempty"`
+		Mask         string `json:"mask,omitempty"` // mask strategy or pattern 0..7
+		CornerRadius int    `json:"cornerRadius,omitempty"`
+		Logo         string `json:"logo,omitempty"` // logo image url
+		LogoAuto     bool   `json:"logoAuto,omitempty"`
+		Halftone     string `json:"halftone,omitempty"` // url of image shown through the modules
+	} `json:"style"`
+
+	Composite struct {
+		Layout string `json:"layout,omitempty"` // horizontal or vertical, no composite if empty
+		EC     string `json:"ec,omitempty"`
+		Size   int    `json:"size,omitempty"`
+	} `json:"composite"`
+
+	Background struct {
+		URL      string `json:"url,omitempty"`      // background image url, no background if empty
+		Position string `json:"position,omitempty"` // center or x,y pixels of the image
+	} `json:"background"`
+}
+
+type WifiDocument struct {
+	SSID   string `json:"ssid"`
+	Auth   string `json:"auth"`
+	Pass   string `json:"pass,omitempty" sensitive:"true"`
+	Hidden *bool  `json:"hidden,omitempty"`
+	EAP    string `json:"eap,omitempty"`
+	AnonID string `json:"anon,omitempty"`
+	Ident  string `json:"ident,omitempty"`
+	PH2    string `json:"ph2,omitempty"`
+}
+
+// documentPaths json path of render parameters
+var documentPaths = map[string]string{
+	"w":             "width",
+	"h":             "height",
+	"t":             "type",
+	"dpr":           "dpr",
+	"ec":            "ec",
+	"charset":       "charset",
+	"eci":           "eci",
+	"margin":        "margin",
+	"marginTop":     "margins.top",
+	"marginRight":   "margins.right",
+	"marginBottom":  "margins.bottom",
+	"marginLeft":    "margins.left",
+	"progressive":   "progressive",
+	"exactSize":     "exactSize",
+	"label":         "label",
+	"captionSize":   "captionSize",
+	"compression":   "compression",
+	"alt":           "alt",
+	"fg":            "colors.fg",
+	"bg":            "colors.bg",
+	"padColor":      "colors.pad",
+	"eyeOuterColor": "colors.eyeOuter",
+	"eyeInnerColor": "colors.eyeInner",
+	"shape":         "style.shape",
+	"mask":          "style.mask",
+	"cornerRadius":  "style.cornerRadius",
+	"logo":          "style.logo",
+	"logoAuto":      "style.logoAuto",
+	"halftone":      "style.halftone",
+	"composite":     "composite.layout",
+	"compositeEc":   "composite.ec",
+	"compositeSize": "composite.size",
+	"background":    "background.url",
+	"backgroundPos": "background.position",
+}
+
+// params returns render parameters of the document as query parameters
+func (doc *QRCodeDocument) params() map[string]string {
+	params := map[string]string{
+		"t":             doc.Type,
+		"ec":            doc.EC,
+		"charset":       doc.Charset,
+		"label":         doc.Label,
+		"compression":   doc.Compression,
+		"alt":           doc.Alt,
+		"fg":            doc.Colors.FG,
+		"bg":            doc.Colors.BG,
+		"padColor":      doc.Colors.Pad,
+		"eyeOuterColor": doc.Colors.EyeOuter,
+		"eyeInnerColor": doc.Colors.EyeInner,
+		"shape":         doc.Style.Shape,
+		"mask":          doc.Style.Mask,
+		"logo":          doc.Style.Logo,
+		"halftone":      doc.Style.Halftone,
+		"composite":     doc.Composite.Layout,
+		"compositeEc":   doc.Composite.EC,
+		"background":    doc.Background.URL,
+		"backgroundPos": doc.Background.Position,
+	}
+
+	for name, value := range map[string]int{"w": doc.Width, "h": doc.Height, "cornerRadius": doc.Style.CornerRadius, "compositeSize": doc.Composite.Size} {
+		if value != 0 {
+			params[name] = strconv.Itoa(value)
+		}
+	}
+
+	for name, value := range map[string]*int{
+		"margin":       doc.Margin,
+		"marginTop":    doc.Margins.Top,
+		"marginRight":  doc.Margins.Right,
+		"marginBottom": doc.Margins.Bottom,
+		"marginLeft":   doc.Margins.Left,
+	} {
+		if value != nil {
+			params[name] = strconv.Itoa(*value)
+		}
+	}
+
+	if doc.DPR != 0 {
+		params["dpr"] = strconv.FormatFloat(doc.DPR, 'g', -1, 64)
+	}
+
+	if doc.CaptionSize != 0 {
+		params["captionSize"] = strconv.FormatFloat(doc.CaptionSize, 'g', -1, 64)
+	}
+
+	if doc.ECI != nil {
+		params["eci"] = strconv.FormatBool(*doc.ECI)
+	}
+
+	if doc.Progressive {
+		params["progressive"] = "true"
+	}
+
+	if doc.ExactSize {
+		params["exactSize"] = "true"
+	}
+
+	if doc.Style.LogoAuto {
+		params["logoAuto"] = "true"
+	}
+
+	return params
+}
+
+// validate validates fields which are not render parameters; sizes are of max_size config
+func (doc *QRCodeDocument) validate(cfg *config.Config) error {
+	given := 0
+	for _, ok := range []bool{doc.Content != "", doc.URL != "", doc.Wifi != nil} {
+		if ok {
+			given++
+		}
+	}
+	if given != 1 {
+		return documentError("", "one of content, url or wifi is required")
+	}
+
+	for name, value := range map[string]int{"width": doc.Width, "height": doc.Height, "composite.size": doc.Composite.Size} {
+		if value != 0 && (value < minSize || value > cfg.MaxSize) {
+			return documentError(name, fmt.Sprintf("must be between %d and %d", minSize, cfg.MaxSize))
+		}
+	}
+
+	for _, margin := range []struct {
+		name  string
+		value *int
+	}{
+		{"margin", doc.Margin},
+		{"margins.top", doc.Margins.Top},
+		{"margins.right", doc.Margins.Right},
+		{"margins.bottom", doc.Margins.Bottom},
+		{"margins.left", doc.Margins.Left},
+	} {
+		if margin.value != nil && (*margin.value < 0 || *margin.value > maxMargin) {
+			return documentError(margin.name, fmt.Sprintf("must be between 0 and %d", maxMargin))
+		}
+	}
+
+	if doc.Wifi != nil {
+		switch {
+		case doc.Wifi.SSID == "":
+			return documentError("wifi.ssid", "required")
+		case doc.Wifi.Auth == "":
+			return documentError("wifi.auth", "required")
+		}
+	}
+
+	return nil
+}
+
+func (doc *QRCodeDocument) qrcode(cfg *config.Config) (string, *qrcode.QR, error) {
+	switch {
+	case doc.Content != "":
+		qr, err := qrcode.Text(doc.Content)
+		return kindText, qr, err
+
+	case doc.URL != "":
+		prefix, err := urlPayloadPrefix("", cfg.URLPayload)
+		if err != nil {
+			return kindURL, nil, err
+		}
+		qr, err := qrcode.Text(prefix + doc.URL)
+		return kindURL, qr, err
+	}
+
+	wifi := *doc.Wifi
+	if wifi.Hidden == nil {
+		v := false
+		wifi.Hidden = &v
+	}
+
+	qr, err := wifiPayload(&wifi)
+	return kindWifi, qr, err
+}
+
+// documentError returns bad request error of the json path
+func documentError(path string, message string) error {
+	if path == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, message)
+	}
+	return echo.NewHTTPError(http.StatusBadRequest, path+": "+message)
+}
+
+// handleDocument generate qrcode from json options document; same as query parameters of GET /qrcode
+func (api *APIv1) handleDocument(c echo.Context) error {
+	doc := &QRCodeDocument{}
+	if err := decodeDocument(c.Request().Body, doc); err != nil {
+		return err
+	}
+
+	req, err := doc.renderRequest(api.cfg)
+	if err != nil {
+		return err
+	}
+
+	kind, qr, err := doc.qrcode(api.cfg)
+	if err != nil {
+		return err
+	}
+
+	c.Set(ctxKeyPayloadKind, kind)
+	return api.renderRequest(c, qr, req)
+}
+
+// decodeDocument decode json document; unknown fields are not allowed
+func decodeDocument(r io.Reader, v interface{}) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &typeErr):
+			return documentError(typeErr.Field, "must be "+typeErr.Type.String())
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return documentError("", strings.TrimPrefix(err.Error(), "json: "))
+		}
+		return documentError("", err.Error())
+	}
+	return nil
+}
+
+// renderRequest validate the document and returns its render parameters
+func (doc *QRCodeDocument) renderRequest(cfg *config.Config) (*RenderRequest, error) {
+	if err := doc.validate(cfg); err != nil {
+		return nil, err
+	}
+
+	params := doc.params()
+	req, err := parseRenderRequest(cfg, func(name string) string { return params[name] })
+	if err != nil {
+		var pe *paramError
+		if errors.As(err, &pe) {
+			return nil, documentError(documentPaths[pe.name], "invalid value "+strconv.Quote(pe.value))
+		}
+		return nil, err
+	}
+
+	return req, nil
+}
@@ -0,0 +1,109 @@
+package qrcodeapi
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+	"qrcodeapi/pkg/qrcode"
+)
+
+func TestDocument(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	styled := `{
+		"content": "hello world",
+		"width": 150, "height": 150, "type": "png", "ec": "M", "margin": 2,
+		"colors": {"fg": "#112233", "bg": "#eeeeee"},
+		"style": {"shape": "dot", "cornerRadius": 10},
+		"label": "scan me"
+	}`
+
+	resp, err := request.Post("%s/qrcode", ts.URL).
+		Header(request.HeaderContentType, "application/json").
+		Body(bytes.NewBufferString(styled)).
+		Do(ctx)
+	require.NoError(t, err)
+	require.Truef(t, resp.Success(), "failed with %d", resp.StatusCode)
+	require.Equal(t, "image/png", resp.Header.Get(request.HeaderContentType))
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	img, _, err := image.Decode(bytes.NewReader(body))
+	require.NoError(t, err)
+	require.Equal(t, 150, img.Bounds().Dx())
+	require.Greater(t, img.Bounds().Dy(), 150, "label strip is added")
+	require.Equal(t, color.RGBA{}, color.RGBAModel.Convert(img.At(0, 0)))
+	require.Equal(t, color.RGBA{0xee, 0xee, 0xee, 0xff}, color.RGBAModel.Convert(img.At(10, 10)))
+	got, err := qrcode.Decode(img)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", got)
+
+	// same request as query parameters
+	resp, err = request.Get("%s/qrcode", ts.URL).
+		Query("content", "hello world").
+		Query("w", "150").Query("h", "150").Query("t", "png").Query("ec", "M").Query("margin", "2").
+		Query("fg", "#112233").Query("bg", "#eeeeee").
+		Query("shape", "dot").Query("cornerRadius", "10").
+		Query("label", "scan me").
+		Do(ctx)
+	require.NoError(t, err)
+	require.Truef(t, resp.Success(), "failed with %d", resp.StatusCode)
+	defer resp.Body.Close()
+	queryBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, body, queryBody)
+}
+
+func TestDocumentInvalid(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name        string
+		body        string
+		wantMessage string
+	}{
+		{"unknown field", `{"content": "hello", "colour": "red"}`, `unknown field \"colour\"`},
+		{"no content", `{"width": 100}`, "one of content, url or wifi is required"},
+		{"multiple content", `{"content": "hello", "url": "https://example.com"}`, "one of content, url or wifi is required"},
+		{"type mismatch", `{"content": "hello", "width": "100"}`, "width: must be int"},
+		{"nested type mismatch", `{"content": "hello", "style": {"cornerRadius": "10"}}`, "style.cornerRadius: must be int"},
+		{"out of range", `{"content": "hello", "width": 1000}`, "width: must be between 21 and 200"},
+		{"invalid color", `{"content": "hello", "colors": {"fg": "#zzzzzz"}}`, `colors.fg: invalid value \"#zzzzzz\"`},
+		{"invalid shape", `{"content": "hello", "style": {"shape": "star"}}`, `style.shape: invalid value \"star\"`},
+		{"wifi ssid", `{"wifi": {"auth": "WPA"}}`, "wifi.ssid: required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Post("%s/qrcode", ts.URL).
+				Header(request.HeaderContentType, "application/json").
+				Body(bytes.NewBufferString(tt.body)).
+				Do(ctx)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Contains(t, string(body), tt.wantMessage)
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package qrcodeapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"image/png"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// embedAlts default alt text of embedded image by payload kind; payload itself is never used, it may be secret
+var embedAlts = map[string]string{
+	kindText:    "QR code",
+	kindURL:     "QR code of a link",
+	kindWifi:    "QR code to join a wifi network",
+	kindContact: "QR code of a contact",
+	kindVCard:   "QR code of a contact",
+	kindVEvent:  "QR code of an event",
+	kindEmail:   "QR code of an email address",
+	kindTel:     "QR code of a phone number",
+	kindToken:   "QR code of a signed token",
+}
+
+func embedAlt(kind string) string {
+	if alt, ok := embedAlts[kind]; ok {
+		return alt
+	}
+	return embedAlts[kindText]
+}
+
+// embedOptions options of the formats embedding png
+type embedOptions struct {
+	alt  string
+	link *url.URL // markdown only
+	lazy bool     // imgtag only
+	dpr  float64  // img size is css pixels of the image
+}
+
+// parseEmbedOptions parse parameters of embedding formats; they are rejected for other formats
+func parseEmbedOptions(c echo.Context, format *imageFormat, req *RenderRequest) (*embedOptions, error) {
+	opts := &embedOptions{alt: req.Alt, dpr: req.scale()}
+	if opts.alt == "" {
+		kind, _ := c.Get(ctxKeyPayloadKind).(string)
+		opts.alt = embedAlt(kind)
+	}
+
+	if v := c.QueryParam("link"); v != "" {
+		if format != formatMarkdown || validateURL(v, []string{"http", "https"}) != nil {
+			return nil, invalidParam("link", v)
+		}
+		opts.link, _ = url.Parse(v)
+	}
+
+	if v := c.QueryParam("lazy"); v != "" {
+		lazy, err := strconv.ParseBool(v)
+		if err != nil || format != formatImgTag {
+			return nil, invalidParam("lazy", v)
+		}
+		opts.lazy = lazy
+	}
+
+	return opts, nil
+}
+
+// parseEmbedText parse `embed-text` parameter, which embeds the payload in text chunk of png for asset management systems;
+// payloads of secrets are never embedded so that the flag is refused.
+func parseEmbedText(c echo.Context, format *imageFormat, content string) (bool, error) {
+	v := c.QueryParam("embed-text")
+	if v == "" {
+		return false, nil
+	}
+
+	embed, err := strconv.ParseBool(v)
+	if err != nil || (embed && format != formatPNG) {
+		return false, invalidParam("embed-text", v)
+	}
+
+	kind, _ := c.Get(ctxKeyPayloadKind).(string)
+	if embed && sensitivePayload(kind, content) {
+		return false, echo.NewHTTPError(http.StatusBadRequest, "embed-text can not be used with payloads of secrets, such as wifi and otp")
+	}
+	return embed, nil
+}
+
+// embedImage returns text of the format embedding png
+func embedImage(format *imageFormat, png []byte, opts *embedOptions) (string, error) {
+	if format == formatImgTag {
+		return imgTag(png, opts.alt, opts.lazy, opts.dpr)
+	}
+	return markdownImage(png, opts.alt, opts.link), nil
+}
+
+var (
+	markdownAltEscaper  = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, "\r", " ", "\n", " ")
+	markdownLinkEscaper = strings.NewReplacer("(", "%28", ")", "%29", " ", "%20")
+)
+
+// markdownImage returns markdown image of png as data uri; image is wrapped with the link if given
+func markdownImage(png []byte, alt string, link *url.URL) string {
+	image := "![" + markdownAltEscaper.Replace(alt) + "](" + dataURI(png) + ")"
+	if link == nil {
+		return image
+	}
+	return "[" + image + "](" + markdownLinkEscaper.Replace(link.String()) + ")"
+}
+
+// imgTag returns html img element of png as data uri with its size in css pixels
+func imgTag(data []byte, alt string, lazy bool, dpr float64) (string, error) {
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	tag := fmt.Sprintf(`<img src="%s" width="%d" height="%d" alt="%s"`, dataURI(data),
+		int(math.Round(float64(cfg.Width)/dpr)), int(math.Round(float64(cfg.Height)/dpr)), html.EscapeString(alt))
+	if lazy {
+		tag += ` loading="lazy"`
+	}
+	return tag + ">", nil
+}
+
+func dataURI(png []byte) string {
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+}
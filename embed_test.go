@@ -0,0 +1,239 @@
+package qrcodeapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+	"qrcodeapi/pkg/qrcode"
+)
+
+var markdownPattern = regexp.MustCompile(`^(\[)?!\[((?:\\.|[^\\\]])*)\]\(data:image/png;base64,([A-Za-z0-9+/=]+)\)(?:\]\(([^)]+)\))?$`)
+
+func TestMarkdown(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), nil, nil))
+
+	tests := [...]struct {
+		name       string
+		path       string
+		query      map[string]string
+		wantStatus int
+		wantAlt    string
+		wantLink   string
+		wantData   string
+	}{
+		{"text", "/qrcode", map[string]string{"content": "hello"}, http.StatusOK, "QR code", "", "hello"},
+		{"url", "/qrcode", map[string]string{"url": "https://example.com"}, http.StatusOK, "QR code of a link", "", "URLTO:https://example.com"},
+		{"alt", "/qrcode", map[string]string{"content": "hello", "alt": "menu [today]"}, http.StatusOK, `menu \[today\]`, "", "hello"},
+		{"link", "/qrcode", map[string]string{"content": "hello", "link": "https://example.com/menu (1)"}, http.StatusOK,
+			"QR code", "https://example.com/menu%20%281%29", "hello"},
+		{"wifi", "/wifi", map[string]string{"ssid": "office", "auth": "WPA", "pass": "s3cret-pass"}, http.StatusOK,
+			"QR code to join a wifi network", "", "WIFI:S:office;T:WPA;P:s3cret-pass;H:false;;"},
+		{"invalid link", "/qrcode", map[string]string{"content": "hello", "link": "javascript:alert(1)"}, http.StatusBadRequest, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request.Get("%s%s", ts.URL, tt.path).Query("t", "markdown")
+			for k, v := range tt.query {
+				req = req.Query(k, v)
+			}
+			resp, err := req.Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if !resp.Success() {
+				return
+			}
+			require.Equal(t, "text/plain; charset=utf-8", resp.Header.Get(request.HeaderContentType))
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.NotContains(t, string(body), "s3cret-pass")
+
+			m := markdownPattern.FindStringSubmatch(string(body))
+			require.NotNilf(t, m, "invalid markdown: %s", body)
+			require.Equal(t, tt.wantAlt, m[2])
+			require.Equal(t, tt.wantLink != "", m[1] == "[")
+			require.Equal(t, tt.wantLink, m[4])
+
+			data, err := base64.StdEncoding.DecodeString(m[3])
+			require.NoError(t, err)
+			img, format, err := image.Decode(bytes.NewReader(data))
+			require.NoError(t, err)
+			require.Equal(t, "png", format)
+
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantData, got)
+		})
+	}
+
+	// link is only for markdown
+	resp, err := request.Get("%s/qrcode", ts.URL).Query("content", "hello").Query("link", "https://example.com").Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+var imgTagPattern = regexp.MustCompile(`^<img src="data:image/png;base64,([A-Za-z0-9+/=]+)" width="(\d+)" height="(\d+)" alt="([^"]*)"( loading="lazy")?>$`)
+
+func TestImgTag(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), nil, nil))
+
+	tests := [...]struct {
+		name       string
+		path       string
+		query      map[string]string
+		wantStatus int
+		wantWidth  string
+		wantHeight string
+		wantAlt    string
+		wantLazy   bool
+		wantData   string
+	}{
+		{"default", "/qrcode", map[string]string{"content": "hello"}, http.StatusOK, "200", "200", "QR code", false, "hello"},
+		{"size", "/qrcode", map[string]string{"content": "hello", "w": "120", "h": "100"}, http.StatusOK, "120", "100", "QR code", false, "hello"},
+		{"escape", "/qrcode", map[string]string{"content": "hello", "alt": `say "hi" <b>&</b>`}, http.StatusOK,
+			"200", "200", "say &#34;hi&#34; &lt;b&gt;&amp;&lt;/b&gt;", false, "hello"},
+		{"lazy", "/qrcode", map[string]string{"content": "hello", "lazy": "true"}, http.StatusOK, "200", "200", "QR code", true, "hello"},
+		{"wifi", "/wifi", map[string]string{"ssid": "office", "auth": "WPA", "pass": "s3cret-pass"}, http.StatusOK,
+			"200", "200", "QR code to join a wifi network", false, "WIFI:S:office;T:WPA;P:s3cret-pass;H:false;;"},
+		{"invalid lazy", "/qrcode", map[string]string{"content": "hello", "lazy": "sometimes"}, http.StatusBadRequest, "", "", "", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request.Get("%s%s", ts.URL, tt.path).Query("t", "imgtag")
+			for k, v := range tt.query {
+				req = req.Query(k, v)
+			}
+			resp, err := req.Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if !resp.Success() {
+				return
+			}
+			require.Equal(t, "text/html; charset=utf-8", resp.Header.Get(request.HeaderContentType))
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.NotContains(t, string(body), "s3cret-pass")
+
+			m := imgTagPattern.FindStringSubmatch(string(body))
+			require.NotNilf(t, m, "invalid img tag: %s", body)
+			require.Equal(t, tt.wantWidth, m[2])
+			require.Equal(t, tt.wantHeight, m[3])
+			require.Equal(t, tt.wantAlt, m[4])
+			require.Equal(t, tt.wantLazy, m[5] != "")
+
+			data, err := base64.StdEncoding.DecodeString(m[1])
+			require.NoError(t, err)
+			img, _, err := image.Decode(bytes.NewReader(data))
+			require.NoError(t, err)
+			require.Equal(t, tt.wantWidth, strconv.Itoa(img.Bounds().Dx()))
+
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantData, got)
+		})
+	}
+
+	// lazy is only for imgtag
+	resp, err := request.Get("%s/qrcode", ts.URL).Query("content", "hello").Query("lazy", "true").Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// size of high dpr image is css pixels
+	resp, err = request.Get("%s/qrcode", ts.URL).Query("content", "hello").Query("t", "imgtag").
+		Query("w", "80").Query("h", "80").Query("dpr", "2").Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.True(t, resp.Success())
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	m := imgTagPattern.FindStringSubmatch(string(body))
+	require.NotNilf(t, m, "invalid img tag: %s", body)
+	require.Equal(t, []string{"80", "80"}, m[2:4])
+	data, err := base64.StdEncoding.DecodeString(m[1])
+	require.NoError(t, err)
+	img, _, err := image.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, image.Pt(160, 160), img.Bounds().Size())
+}
+
+func TestEmbedText(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name       string
+		path       string
+		params     map[string]string
+		wantStatus int
+		want       string
+	}{
+		{"text", "/qrcode", map[string]string{"content": "hello 한글", "embed-text": "true"}, http.StatusOK, "hello 한글"},
+		{"url", "/url", map[string]string{"target": "https://example.com/a", "embed-text": "true"}, http.StatusOK, "https://example.com/a"},
+		{"not embedded", "/qrcode", map[string]string{"content": "hello"}, http.StatusOK, ""},
+		{"false", "/qrcode", map[string]string{"content": "hello", "embed-text": "false"}, http.StatusOK, ""},
+		{"invalid", "/qrcode", map[string]string{"content": "hello", "embed-text": "yes please"}, http.StatusBadRequest, ""},
+		{"svg", "/qrcode", map[string]string{"content": "hello", "embed-text": "true", "t": "svg"}, http.StatusBadRequest, ""},
+		{"wifi", "/wifi", map[string]string{"ssid": "home", "auth": "WPA", "pass": "secret", "embed-text": "true"}, http.StatusBadRequest, ""},
+		{"wifi content", "/qrcode", map[string]string{"content": "WIFI:S:home;T:WPA;P:secret;;", "embed-text": "true"}, http.StatusBadRequest, ""},
+		{"otp", "/qrcode", map[string]string{"content": "otpauth://totp/ex:kim?secret=JBSWY3DP&issuer=ex", "embed-text": "true"}, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request.Get("%s%s", ts.URL, tt.path)
+			for k, v := range tt.params {
+				req = req.Query(k, v)
+			}
+			resp, err := req.Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			if tt.wantStatus != http.StatusOK {
+				require.NotContains(t, string(body), "secret=JBSWY3DP")
+				return
+			}
+
+			got, ok, err := qrcode.EmbeddedContent(body)
+			require.NoError(t, err)
+			require.Equal(t, tt.want != "", ok)
+			require.Equal(t, tt.want, got)
+
+			// the image is not changed by the chunk
+			img, err := png.Decode(bytes.NewReader(body))
+			require.NoError(t, err)
+			decoded, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			if ok {
+				require.Equal(t, got, decoded)
+			}
+		})
+	}
+}
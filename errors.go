@@ -0,0 +1,190 @@
+package qrcodeapi
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+
+	"qrcodeapi/config"
+)
+
+const errorModeImage = "image"
+
+// ErrorResponse json body of errors; code is stable for clients and message is of Accept-Language
+type ErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// httpErrorHandler returns error with its code and message of Accept-Language, or as image if `errorMode=image` requested.
+// It is useful for <img> embeds which can't show error message.
+// status code is preserved unless `errorStatus=200` is given.
+func httpErrorHandler(e *echo.Echo, cfg *config.Config) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		if c.Response().Committed {
+			return
+		}
+
+		he, ok := err.(*echo.HTTPError)
+		if !ok {
+			he = echo.NewHTTPError(http.StatusInternalServerError).SetInternal(err)
+		}
+		if internal, ok := he.Internal.(*echo.HTTPError); ok {
+			he = internal
+		}
+		if _, ok := he.Message.(string); !ok {
+			e.DefaultHTTPErrorHandler(err, c)
+			return
+		}
+
+		body := errorResponse(he, c.Request().Header.Get("Accept-Language"))
+		if c.QueryParam("errorMode") != errorModeImage {
+			if c.Request().Method == http.MethodHead {
+				err = c.NoContent(he.Code)
+			} else {
+				err = c.JSON(he.Code, body)
+			}
+			if err != nil {
+				c.Logger().Error(err)
+			}
+			return
+		}
+
+		code := he.Code
+		message := body.Message
+		if c.QueryParam("errorStatus") == "200" {
+			code = http.StatusOK
+		}
+
+		format := negotiateFormat(cfg, formatParam(c), c.Request().Header.Get(echo.HeaderAccept), cookieFormat(c))
+		// error image of embedding and disabled formats is png as it is
+		if format.encode == nil || format.embedsPNG() || !formatEnabled(cfg, format) {
+			format = formatPNG
+		}
+		img := renderErrorImage(parseIntDef(c.QueryParam("w"), defaultSize, minSize, cfg.MaxSize),
+			parseIntDef(c.QueryParam("h"), defaultSize, minSize, cfg.MaxSize), message)
+
+		var buf bytes.Buffer
+		if err := format.encode(&buf, img); err != nil {
+			e.DefaultHTTPErrorHandler(err, c)
+			return
+		}
+
+		if err := c.Blob(code, format.mimeType, buf.Bytes()); err != nil {
+			c.Logger().Error(err)
+		}
+	}
+}
+
+// errorResponse returns code and message of the error; message is of the catalog if the code is in it, as is otherwise.
+// messages of status codes are from the catalog only if they are the status text, so that details are not lost.
+func errorResponse(he *echo.HTTPError, acceptLanguage string) *ErrorResponse {
+	message := he.Message.(string)
+	code, args := errorCode(he)
+	if args == nil && message != http.StatusText(he.Code) {
+		return &ErrorResponse{Code: code, Message: message}
+	}
+
+	if localized, ok := messages.message(acceptLanguage, code, args); ok {
+		message = localized
+	}
+	return &ErrorResponse{Code: code, Message: message}
+}
+
+// errorCode returns error code and arguments of the error; code of the status, ex) not_found, if not structured.
+func errorCode(he *echo.HTTPError) (string, map[string]string) {
+	var pe *paramError
+	if errors.As(he.Internal, &pe) {
+		args := map[string]string{"name": pe.name, "value": redactParam(pe.name, pe.value)}
+		if pe.reason != "" {
+			args["reason"] = pe.reason
+			return "invalid_color", args
+		}
+		return "invalid_param", args
+	}
+
+	var ve validator.ValidationErrors
+	if errors.As(he.Internal, &ve) && len(ve) > 0 && ve[0].Tag() == "required" {
+		return "required_param", map[string]string{"name": ve[0].Field()}
+	}
+
+	text := http.StatusText(he.Code)
+	if text == "" {
+		return "error", nil
+	}
+	return strings.ToLower(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text)), nil
+}
+
+var (
+	errorBackground = color.RGBA{0xff, 0xf0, 0xf0, 0xff}
+	errorForeground = color.RGBA{0xc0, 0x00, 0x00, 0xff}
+)
+
+// renderErrorImage draw error message wrapped to the image width
+func renderErrorImage(width, height int, message string) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(errorBackground), image.Point{}, draw.Src)
+
+	face := basicfont.Face7x13
+	const padding = 4
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(errorForeground), Face: face}
+
+	lineHeight := face.Metrics().Height.Ceil()
+	maxChars := (width - padding*2) / face.Advance
+	for i, line := range wrapText(message, maxChars) {
+		y := padding + face.Metrics().Ascent.Ceil() + i*lineHeight
+		if y > height-padding {
+			break
+		}
+
+		d.Dot = fixed.P(padding, y)
+		d.DrawString(line)
+	}
+
+	return img
+}
+
+// wrapText split text into lines not longer than width characters
+func wrapText(text string, width int) []string {
+	if width < 1 {
+		width = 1
+	}
+
+	lines := []string{}
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for len([]rune(word)) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				lines = append(lines, string([]rune(word)[:width]))
+				word = string([]rune(word)[width:])
+			}
+
+			switch {
+			case line == "":
+				line = word
+			case len([]rune(line))+1+len([]rune(word)) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}
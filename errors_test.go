@@ -0,0 +1,147 @@
+package qrcodeapi
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+	"golang.org/x/text/language"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+)
+
+func TestErrorImage(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name            string
+		query           map[string]string
+		wantStatus      int
+		wantContentType string
+	}{
+		{"default", map[string]string{}, http.StatusBadRequest, echo.MIMEApplicationJSONCharsetUTF8},
+		{"image", map[string]string{"errorMode": "image"}, http.StatusBadRequest, "image/png"},
+		{"image with status 200", map[string]string{"errorMode": "image", "errorStatus": "200"}, http.StatusOK, "image/png"},
+		{"gif", map[string]string{"errorMode": "image", "t": "gif"}, http.StatusBadRequest, "image/gif"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/qrcode", ts.URL).
+				Query("ssid", "myssid"). // missing auth
+				Queries(tt.query).
+				Do(ctx)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			require.Equal(t, tt.wantContentType, resp.Header.Get(request.HeaderContentType))
+
+			if tt.query["errorMode"] != errorModeImage {
+				return
+			}
+
+			defer resp.Body.Close()
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, image.Pt(defaultSize, defaultSize), img.Bounds().Size())
+
+			// error message should be drawn
+			drawn := false
+			for y := 0; y < img.Bounds().Dy() && !drawn; y++ {
+				for x := 0; x < img.Bounds().Dx(); x++ {
+					if r, g, _, _ := img.At(x, y).RGBA(); r > 0x8000 && g < 0x8000 {
+						drawn = true
+						break
+					}
+				}
+			}
+			require.True(t, drawn, "error text not drawn")
+		})
+	}
+}
+
+func TestLocalizedError(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name           string
+		path           string
+		acceptLanguage string
+		wantStatus     int
+		want           ErrorResponse
+	}{
+		{"default", "/qrcode?content=hello&ec=Z", "", http.StatusBadRequest, ErrorResponse{"invalid_param", "invalid ec: Z"}},
+		{"en", "/qrcode?content=hello&ec=Z", "en-US", http.StatusBadRequest, ErrorResponse{"invalid_param", "invalid ec: Z"}},
+		{"ko", "/qrcode?content=hello&ec=Z", "ko", http.StatusBadRequest, ErrorResponse{"invalid_param", "ec 값이 올바르지 않습니다: Z"}},
+		{"ko of region", "/qrcode?content=hello&ec=Z", "ko-KR,ko;q=0.9,en-US;q=0.8,en;q=0.7", http.StatusBadRequest,
+			ErrorResponse{"invalid_param", "ec 값이 올바르지 않습니다: Z"}},
+		{"quality", "/qrcode?content=hello&ec=Z", "en;q=0.5, ko;q=0.8", http.StatusBadRequest, ErrorResponse{"invalid_param", "ec 값이 올바르지 않습니다: Z"}},
+		{"fallback", "/qrcode?content=hello&ec=Z", "fr-FR,fr;q=0.9", http.StatusBadRequest, ErrorResponse{"invalid_param", "invalid ec: Z"}},
+		{"invalid header", "/qrcode?content=hello&ec=Z", ";;q=x", http.StatusBadRequest, ErrorResponse{"invalid_param", "invalid ec: Z"}},
+		{"color", "/qrcode?content=hello&fg=nocolor", "ko", http.StatusBadRequest, ErrorResponse{"invalid_color", "fg 색상이 올바르지 않습니다: nocolor"}},
+		{"required", "/event?start=2026-07-01T09:00:00Z", "ko", http.StatusBadRequest, ErrorResponse{"required_param", "summary 값이 필요합니다"}},
+		{"required en", "/event?start=2026-07-01T09:00:00Z", "en", http.StatusBadRequest, ErrorResponse{"required_param", "summary is required"}},
+		{"status", "/unknown", "ko", http.StatusNotFound, ErrorResponse{"not_found", "찾을 수 없습니다"}},
+		{"status en", "/unknown", "", http.StatusNotFound, ErrorResponse{"not_found", "Not Found"}},
+		{"status of bad request", "/qrcode", "ko", http.StatusBadRequest, ErrorResponse{"bad_request", "잘못된 요청입니다"}},
+		{"details of status", "/event?summary=Standup&start=2026-07-01T09:00:00Z&end=2026-06-01T09:00:00Z", "ko", http.StatusBadRequest,
+			ErrorResponse{"bad_request", "end is before start"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s%s", ts.URL, tt.path).Header("Accept-Language", tt.acceptLanguage).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			got := ErrorResponse{}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestCatalog codes of every language are of the fallback language
+func TestCatalog(t *testing.T) {
+	require.Equal(t, language.Make(fallbackLanguage), messages.tags[0])
+	require.Contains(t, messages.tags, language.Korean)
+	for i, tag := range messages.tags[1:] {
+		for code, message := range messages.messages[i+1] {
+			require.NotEmpty(t, message, "%s: %s", tag, code)
+			require.Contains(t, messages.messages[0], code, "%s: %s is not in %s", tag, code, fallbackLanguage)
+		}
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	type args struct {
+		text  string
+		width int
+	}
+	tests := [...]struct {
+		name string
+		args args
+		want []string
+	}{
+		{"short", args{"hello world", 20}, []string{"hello world"}},
+		{"wrap", args{"hello world", 5}, []string{"hello", "world"}},
+		{"long word", args{"helloworld", 4}, []string{"hell", "owor", "ld"}},
+		{"newline", args{"hello\nworld", 20}, []string{"hello", "world"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, wrapText(tt.args.text, tt.args.width))
+		})
+	}
+}
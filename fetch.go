@@ -0,0 +1,149 @@
+package qrcodeapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/whitekid/goxp/fx"
+	"github.com/whitekid/goxp/request"
+)
+
+// every fetch of user given urls goes through fetchRemote, see remoteClient
+const (
+	remoteTimeout      = 5 * time.Second
+	maxRemoteRedirects = 3
+)
+
+// remoteSchemes allowed schemes of remote urls, also of redirects
+var remoteSchemes = []string{"http", "https"}
+
+// addressAllowed returns true if connection to the address is allowed; tests replace it to reach servers on loopback
+var addressAllowed = isPublicIP
+
+// remoteClient client for user given urls; connections to loopback, link-local and private addresses are refused.
+// addresses are checked on the dial, after dns resolution, so that redirects and dns rebinding are also covered.
+// proxy of the environment is not used as its address would be checked instead of the target.
+var remoteClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: remoteTimeout,
+			Control: refusePrivate,
+		}).DialContext,
+		TLSHandshakeTimeout:   remoteTimeout,
+		ResponseHeaderTimeout: remoteTimeout,
+	},
+	CheckRedirect: checkRedirect,
+	Timeout:       remoteTimeout,
+}
+
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !addressAllowed(ip) {
+		return fmt.Errorf("address is not allowed: %s", host)
+	}
+	return nil
+}
+
+// isPublicIP returns false for loopback, link-local, private, unique local, multicast and unspecified addresses
+func isPublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// checkRedirect follow up to maxRemoteRedirects redirects of allowed schemes; the address is checked on the dial
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > maxRemoteRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRemoteRedirects)
+	}
+	return validateRemoteURL(req.URL)
+}
+
+// validateRemoteURL returns error if the url is not of remoteSchemes or has no host
+func validateRemoteURL(u *url.URL) error {
+	if !fx.Contains(remoteSchemes, u.Scheme) || u.Host == "" {
+		return fmt.Errorf("url is not allowed: %s", u.Redacted())
+	}
+	return nil
+}
+
+var errRemoteTooLarge = errors.New("too large")
+
+// fetchRemote fetch body of the url with remoteClient up to maxBytes; errors are bad request with the name of the resource
+func fetchRemote(ctx context.Context, rawURL string, name string, maxBytes int) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "failed to fetch "+name+": "+err.Error())
+	}
+	if err := validateRemoteURL(u); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "failed to fetch "+name+": "+err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, remoteTimeout)
+	defer cancel()
+
+	// without FollowRedirect, request replaces CheckRedirect of the client
+	resp, err := request.Get(rawURL).WithClient(remoteClient).FollowRedirect(true).Do(ctx)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "failed to fetch "+name+": "+err.Error())
+	}
+	defer resp.Body.Close()
+
+	if !resp.Success() {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("failed to fetch %s: status %d", name, resp.StatusCode))
+	}
+
+	if resp.ContentLength > int64(maxBytes) {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%s is larger than %d bytes", name, maxBytes))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "failed to fetch "+name+": "+err.Error())
+	}
+	if len(data) > maxBytes {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%s is larger than %d bytes", name, maxBytes))
+	}
+	return data, nil
+}
+
+// fetchImage fetch image of the url up to maxBytes and maxBackgroundSide, the largest output;
+// png, jpeg and gif are supported. name is the parameter for error messages.
+func fetchImage(ctx context.Context, imageURL string, name string, maxBytes int) (image.Image, error) {
+	data, err := fetchRemote(ctx, imageURL, name, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	return decodeImage(data, name)
+}
+
+// decodeImage decode png, jpeg or gif image up to maxBackgroundSide; name is the parameter for error messages
+func decodeImage(data []byte, name string) (image.Image, error) {
+	// size is checked before decoding pixels
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid "+name+" image: "+err.Error())
+	}
+	if config.Width > maxBackgroundSide || config.Height > maxBackgroundSide {
+		return nil, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("%s image of %dx%d is larger than %dx%d", name, config.Width, config.Height, maxBackgroundSide, maxBackgroundSide))
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid "+name+" image: "+err.Error())
+	}
+	return img, nil
+}
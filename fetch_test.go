@@ -0,0 +1,118 @@
+package qrcodeapi
+
+import (
+	"context"
+	"image"
+	"image/png"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// allowLoopback allow fetches of servers on loopback, such as httptest servers, as public addresses during the test
+func allowLoopback(t *testing.T) {
+	allowed := addressAllowed
+	addressAllowed = func(ip net.IP) bool { return ip.IsLoopback() || isPublicIP(ip) }
+	t.Cleanup(func() { addressAllowed = allowed })
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := [...]struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"169.254.169.254", false},
+		{"10.0.0.1", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			require.Equal(t, tt.want, isPublicIP(net.ParseIP(tt.ip)))
+		})
+	}
+}
+
+func TestFetchRemote(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/logo.png":
+			png.Encode(w, image.NewGray(image.Rect(0, 0, 10, 10)))
+		case "/large":
+			w.Write(make([]byte, 2048))
+		case "/chunked":
+			// no content length
+			for i := 0; i < 4; i++ {
+				w.Write(make([]byte, 512))
+				w.(http.Flusher).Flush()
+			}
+		case "/metadata":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+		case "/private":
+			http.Redirect(w, r, "http://10.0.0.1/", http.StatusFound)
+		case "/file":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, ts.URL+"/loop", http.StatusFound)
+		case "/hops":
+			http.Redirect(w, r, ts.URL+"/logo.png", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	tests := [...]struct {
+		name          string
+		url           string
+		allowLoopback bool
+		wantErr       string
+	}{
+		{"loopback", ts.URL + "/logo.png", false, "address is not allowed: 127.0.0.1"},
+		{"metadata", "http://169.254.169.254/latest/meta-data/", false, "address is not allowed: 169.254.169.254"},
+		{"private", "http://10.0.0.1/", false, "address is not allowed: 10.0.0.1"},
+		{"unique local", "http://[fd00::1]/", false, "address is not allowed: fd00::1"},
+		{"scheme", "file:///etc/passwd", false, "url is not allowed"},
+		{"ftp", "ftp://example.com/logo.png", false, "url is not allowed"},
+		{"ok", ts.URL + "/logo.png", true, ""},
+		{"redirect", ts.URL + "/hops", true, ""},
+		{"redirect to metadata", ts.URL + "/metadata", true, "address is not allowed: 169.254.169.254"},
+		{"redirect to private", ts.URL + "/private", true, "address is not allowed: 10.0.0.1"},
+		{"redirect to file", ts.URL + "/file", true, "url is not allowed"},
+		{"redirect loop", ts.URL + "/loop", true, "stopped after 3 redirects"},
+		{"too large", ts.URL + "/large", true, "logo is larger than 1024 bytes"},
+		{"too large chunked", ts.URL + "/chunked", true, "logo is larger than 1024 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.allowLoopback {
+				allowLoopback(t)
+			}
+
+			data, err := fetchRemote(ctx, tt.url, "logo", 1024)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.True(t, strings.Contains(err.Error(), tt.wantErr), err.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.NotEmpty(t, data)
+		})
+	}
+}
@@ -0,0 +1,384 @@
+package qrcodeapi
+
+import (
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/whitekid/goxp/fx"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/formats"
+	"qrcodeapi/pkg/pdf"
+	"qrcodeapi/pkg/progressive"
+	"qrcodeapi/pkg/qrcode"
+)
+
+// imageFormat output image format
+type imageFormat struct {
+	name        string
+	mimeType    string
+	transparent bool // support transparency
+
+	// raster formats encode rendered image, others render qrcode directly
+	encode func(w io.Writer, img image.Image) error
+	render func(qr *qrcode.QR, w io.Writer, opts *qrcode.RenderOptions) error
+}
+
+var (
+	formatPNG  = &imageFormat{formats.PNG, "image/png", true, pngEncoders[compressionDefault].Encode, nil}
+	formatJPEG = &imageFormat{formats.JPEG, "image/jpeg", false, func(w io.Writer, img image.Image) error { return jpeg.Encode(w, img, nil) }, nil}
+	formatGIF  = &imageFormat{formats.GIF, "image/gif", false, func(w io.Writer, img image.Image) error { return gif.Encode(w, img, nil) }, nil}
+	formatSVG  = &imageFormat{formats.SVG, "image/svg+xml", true, nil, (*qrcode.QR).RenderSVG}
+	formatTXT  = &imageFormat{formats.TXT, "text/plain; charset=utf-8", true, nil, (*qrcode.QR).RenderText}
+	formatPDF  = &imageFormat{formats.PDF, mimePDF, false, encodePDF, nil}
+	formatICO  = &imageFormat{formats.ICO, "image/x-icon", true, nil, (*qrcode.QR).RenderICO}
+
+	// markdown image and html img tag embed png as data uri; encoded png is wrapped when it is written
+	formatMarkdown = &imageFormat{formats.Markdown, "text/plain; charset=utf-8", true, pngEncoders[compressionDefault].Encode, nil}
+	formatImgTag   = &imageFormat{formats.ImgTag, "text/html; charset=utf-8", true, pngEncoders[compressionDefault].Encode, nil}
+
+	// imageFormats formats in the order of formats.Names, which are also validated by config
+	imageFormats = fx.Map(formats.Names, func(name string) *imageFormat {
+		for _, f := range []*imageFormat{formatPNG, formatJPEG, formatGIF, formatSVG, formatTXT, formatMarkdown, formatImgTag, formatPDF, formatICO} {
+			if f.name == name {
+				return f
+			}
+		}
+		panic("imageFormat not found: " + name)
+	})
+)
+
+// encodePDF write single page pdf of the image; one pixel is one point
+func encodePDF(w io.Writer, img image.Image) error {
+	size := img.Bounds().Size()
+	doc := pdf.New()
+	page := doc.AddPage(pdf.Size{Width: float64(size.X), Height: float64(size.Y)})
+	if err := page.DrawImage(img, pdf.Rect{Width: float64(size.X), Height: float64(size.Y)}); err != nil {
+		return err
+	}
+	_, err := doc.WriteTo(w)
+	return err
+}
+
+// png compression levels of `compression` parameter
+const (
+	compressionFastest = "fastest"
+	compressionDefault = "default"
+	compressionBest    = "best"
+)
+
+// pngEncoders encoders by compression level; encoders and their buffers are shared by requests
+var pngEncoders = map[string]*png.Encoder{
+	compressionFastest: {CompressionLevel: png.BestSpeed, BufferPool: &pngBufferPool{}},
+	compressionDefault: {CompressionLevel: png.DefaultCompression, BufferPool: &pngBufferPool{}},
+	compressionBest:    {CompressionLevel: png.BestCompression, BufferPool: &pngBufferPool{}},
+}
+
+type pngBufferPool struct {
+	pool sync.Pool
+}
+
+func (p *pngBufferPool) Get() *png.EncoderBuffer {
+	b, _ := p.pool.Get().(*png.EncoderBuffer)
+	return b
+}
+
+func (p *pngBufferPool) Put(b *png.EncoderBuffer) { p.pool.Put(b) }
+
+// parseCompression returns normalized png compression level, default if empty
+func parseCompression(s string) (string, error) {
+	s = strings.ToLower(s)
+	if s == "" {
+		return compressionDefault, nil
+	}
+	if _, ok := pngEncoders[s]; !ok {
+		return "", fmt.Errorf("invalid compression: %s", s)
+	}
+	return s, nil
+}
+
+// withEncoding returns the format which encodes in compression level and progressive of the request;
+// png is interlaced and jpeg is progressive scan. other formats are returned as it is
+func (f *imageFormat) withEncoding(req *RenderRequest) *imageFormat {
+	encoder := *f
+	switch {
+	case f == formatJPEG && req.Progressive:
+		encoder.encode = func(w io.Writer, img image.Image) error { return progressive.EncodeJPEG(w, img, nil) }
+
+	case f == formatPNG || f.embedsPNG():
+		pngEncoder := pngEncoders[req.Compression]
+		encoder.encode = pngEncoder.Encode
+		if req.Progressive {
+			encoder.encode = func(w io.Writer, img image.Image) error {
+				return progressive.EncodePNG(w, img, pngEncoder.CompressionLevel)
+			}
+		}
+
+	default:
+		return f
+	}
+	return &encoder
+}
+
+// progressive returns true if the format can be encoded progressively
+func (f *imageFormat) progressive() bool { return f == formatPNG || f == formatJPEG || f.embedsPNG() }
+
+// embedsPNG returns true if the format embeds png in text
+func (f *imageFormat) embedsPNG() bool { return f == formatMarkdown || f == formatImgTag }
+
+func formatByName(name string) *imageFormat {
+	name = formats.Normalize(name)
+	for _, f := range imageFormats {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// formatEnabled returns true if the format is enabled by `formats` config; all formats are enabled if it is empty
+func formatEnabled(cfg *config.Config, f *imageFormat) bool {
+	enabled := cfg.Formats
+	return len(enabled) == 0 || fx.Contains(fx.Map(enabled, func(name string) *imageFormat { return formatByName(name) }), f)
+}
+
+// enabledFormatNames returns names of enabled formats, in the order of imageFormats
+func enabledFormatNames(cfg *config.Config) []string {
+	enabled := fx.Filter(imageFormats, func(f *imageFormat) bool { return formatEnabled(cfg, f) })
+	return fx.Map(enabled, func(f *imageFormat) string { return f.name })
+}
+
+// formatNotEnabled returns 400 error of the disabled format with the enabled formats
+func formatNotEnabled(cfg *config.Config, f *imageFormat) error {
+	return echo.NewHTTPError(http.StatusBadRequest,
+		fmt.Sprintf("format %s is not enabled, allowed: %s", f.name, strings.Join(enabledFormatNames(cfg), ", ")))
+}
+
+// withEnabledFormats reject requests of formats disabled by `formats` config of `t` parameter or path extension
+// before the request is bound; formats of request body are checked with the render request.
+func withEnabledFormats(cfg *config.Config) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			for _, name := range []string{c.QueryParam("t"), c.Param("ext")} {
+				if f := formatByName(name); f != nil && !formatEnabled(cfg, f) {
+					return formatNotEnabled(cfg, f)
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// formatByMimeType returns format of the media type; embedding formats are selected by `t` or extension only,
+// as text/html of browser navigation would select imgtag
+func formatByMimeType(mimeType string) *imageFormat {
+	for _, f := range imageFormats {
+		if f.embedsPNG() {
+			continue
+		}
+		if mediaType, _, _ := mime.ParseMediaType(f.mimeType); mediaType == mimeType {
+			return f
+		}
+	}
+	return nil
+}
+
+// svgContentTypes content types of svg by media type; xml types are for legacy tools which handle svg inline.
+// text/xml is us-ascii without charset, so utf-8 is given.
+var svgContentTypes = map[string]string{
+	"image/svg+xml":   "image/svg+xml",
+	"text/xml":        "text/xml; charset=utf-8",
+	"application/xml": "application/xml",
+}
+
+// contentType returns content type of the format; svg is of `svgContentType` parameter,
+// or of the xml media type of Accept header, image/svg+xml if neither is given
+func contentType(c echo.Context, format *imageFormat) (string, error) {
+	if format != formatSVG {
+		return format.mimeType, nil
+	}
+
+	if v := c.QueryParam("svgContentType"); v != "" {
+		ct, ok := svgContentTypes[strings.ToLower(v)]
+		if !ok {
+			return "", invalidParam("svgContentType", v)
+		}
+		return ct, nil
+	}
+
+	accept := parseAccept(c.Request().Header.Get(echo.HeaderAccept))
+	if fx.Contains(accept, echo.MIMETextHTML) {
+		// xml types of browser navigation are for documents, not for svg
+		return format.mimeType, nil
+	}
+
+	for _, mediaType := range accept {
+		if ct, ok := svgContentTypes[mediaType]; ok {
+			return ct, nil
+		}
+	}
+	return format.mimeType, nil
+}
+
+// ctxKeyPathFormat format name of the path extension
+const ctxKeyPathFormat = "qrcodeapi.pathFormat"
+
+// withPathFormat handle `/path.<ext>` routes; extension selects output format unless `t` parameter is given
+func withPathFormat(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		f := formatByName(c.Param("ext"))
+		if f == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "unknown extension: "+c.Param("ext"))
+		}
+
+		c.Set(ctxKeyPathFormat, f.name)
+		return next(c)
+	}
+}
+
+// formatParam returns requested format name; `t` parameter, then path extension
+func formatParam(c echo.Context) string {
+	if t := c.QueryParam("t"); formatByName(t) != nil {
+		return t
+	}
+
+	if ext, ok := c.Get(ctxKeyPathFormat).(string); ok {
+		return ext
+	}
+	return c.QueryParam("t")
+}
+
+// defaultFormat returns configured default format, png if not configured or unknown
+func defaultFormat(cfg *config.Config) *imageFormat {
+	if f := formatByName(cfg.DefaultFormat); f != nil {
+		return f
+	}
+	return formatPNG
+}
+
+// formatCookie cookie of the preferred format of browser sessions, ex) `qr_format=svg`
+const formatCookie = "qr_format"
+
+// cookieFormat returns requested format name of the format cookie, empty if not given
+func cookieFormat(c echo.Context) string {
+	cookie, err := c.Cookie(formatCookie)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// negotiateFormat select output format; Accept of browsers, of navigation or with wildcards, selects the cookie format
+// precedence: t parameter > Accept header > format cookie > default format
+func negotiateFormat(cfg *config.Config, t string, accept string, cookie string) *imageFormat {
+	if f := formatByName(t); f != nil {
+		return f
+	}
+
+	fallback := defaultFormat(cfg)
+	hasCookie := false
+	if f := formatByName(cookie); f != nil && formatEnabled(cfg, f) {
+		fallback, hasCookie = f, true
+	}
+
+	ranges := parseAcceptRanges(accept)
+	for _, r := range ranges {
+		// browsers list their own image formats before the wildcard, which are not the choice of the user
+		if r.mediaType == echo.MIMETextHTML || (hasCookie && (r.mediaType == "*/*" || r.mediaType == "image/*")) {
+			return fallback
+		}
+	}
+
+	for _, r := range ranges {
+		switch r.mediaType {
+		case "*/*", "image/*":
+			return fallback
+		}
+
+		f := formatByMimeType(r.mediaType)
+		if _, ok := svgContentTypes[r.mediaType]; ok {
+			f = formatSVG
+		}
+
+		// disabled formats are not negotiated
+		if f == nil || !formatEnabled(cfg, f) {
+			continue
+		}
+
+		// browsers list image/svg+xml along with image/* for <img>, which are of the same quality
+		if f == formatSVG && r.q <= pngQuality(cfg, ranges) {
+			continue
+		}
+		return f
+	}
+
+	return fallback
+}
+
+// pngQuality returns quality of png by the accept ranges ordered by quality, 0 if png is not acceptable
+func pngQuality(cfg *config.Config, ranges []mediaRange) float64 {
+	if !formatEnabled(cfg, formatPNG) {
+		return 0
+	}
+
+	for _, r := range ranges {
+		switch r.mediaType {
+		case "image/png", "image/*", "*/*":
+			return r.q
+		}
+	}
+	return 0
+}
+
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept returns media types of accept header ordered by quality
+func parseAccept(accept string) []string {
+	ranges := parseAcceptRanges(accept)
+	mediaTypes := make([]string, len(ranges))
+	for i, r := range ranges {
+		mediaTypes[i] = r.mediaType
+	}
+	return mediaTypes
+}
+
+// parseAcceptRanges returns media ranges of accept header ordered by quality
+func parseAcceptRanges(accept string) []mediaRange {
+	ranges := []mediaRange{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		if q <= 0 {
+			continue
+		}
+
+		ranges = append(ranges, mediaRange{mediaType, q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	return ranges
+}
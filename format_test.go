@@ -0,0 +1,352 @@
+package qrcodeapi
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+)
+
+// Accept headers of browsers
+const (
+	acceptChromeNavigation  = "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7"
+	acceptChromeImage       = "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8"
+	acceptFirefoxNavigation = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+	acceptFirefoxImage      = "image/avif,image/webp,image/png,image/svg+xml,image/*;q=0.8,*/*;q=0.5"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	type args struct {
+		t      string
+		accept string
+		cookie string
+	}
+	tests := [...]struct {
+		name string
+		args args
+		want *imageFormat
+	}{
+		{"default", args{"", "", ""}, formatPNG},
+		{"t", args{"jpg", "", ""}, formatJPEG},
+		{"t precedence", args{"gif", "image/jpeg", ""}, formatGIF},
+		{"unknown t", args{"bmp", "", ""}, formatPNG},
+		{"accept", args{"", "image/gif", ""}, formatGIF},
+		{"accept any", args{"", "*/*", ""}, formatPNG},
+		{"accept quality", args{"", "image/gif;q=0.5, image/jpeg", ""}, formatJPEG},
+		{"accept browser", args{"", "image/avif,image/webp,*/*;q=0.8", ""}, formatPNG},
+		{"accept unsupported", args{"", "image/webp", ""}, formatPNG},
+		{"accept pdf", args{"", "application/pdf", ""}, formatPDF},
+		{"t precedes pdf", args{"svg", "application/pdf", ""}, formatSVG},
+		{"accept xml", args{"", "text/xml", ""}, formatSVG},
+		{"accept svg", args{"", "image/svg+xml", ""}, formatSVG},
+		{"svg preferred", args{"", "image/svg+xml, image/*;q=0.8", ""}, formatSVG},
+		{"svg of png quality", args{"", "image/svg+xml, image/png", ""}, formatPNG},
+		{"chrome img", args{"", acceptChromeImage, ""}, formatPNG},
+		{"firefox img", args{"", acceptFirefoxImage, ""}, formatPNG},
+		{"accept html", args{"", "text/html", ""}, formatPNG},
+		{"browser navigation", args{"", "text/html,application/xhtml+xml,image/avif,image/webp,*/*;q=0.8", ""}, formatPNG},
+		{"imgtag by t", args{"imgtag", "text/html", ""}, formatImgTag},
+		{"cookie", args{"", "", "svg"}, formatSVG},
+		{"cookie of browser", args{"", "image/avif,image/webp,*/*;q=0.8", "svg"}, formatSVG},
+		{"chrome navigation", args{"", acceptChromeNavigation, ""}, formatPNG},
+		{"firefox navigation", args{"", acceptFirefoxNavigation, ""}, formatPNG},
+		{"cookie of chrome navigation", args{"", acceptChromeNavigation, "svg"}, formatSVG},
+		{"cookie of chrome img", args{"", acceptChromeImage, "jpg"}, formatJPEG},
+		{"cookie of firefox navigation", args{"", acceptFirefoxNavigation, "svg"}, formatSVG},
+		{"cookie of firefox img", args{"", acceptFirefoxImage, "gif"}, formatGIF},
+		{"accept precedes cookie", args{"", "image/gif", "svg"}, formatGIF},
+		{"accept with wildcard", args{"", "image/gif, */*;q=0.1", ""}, formatGIF},
+		{"t precedes cookie", args{"jpg", "", "svg"}, formatJPEG},
+		{"unknown cookie", args{"", "", "bmp"}, formatPNG},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, negotiateFormat(config.Current(), tt.args.t, tt.args.accept, tt.args.cookie))
+		})
+	}
+}
+
+func TestPathFormat(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name            string
+		path            string
+		query           string
+		wantStatus      int
+		wantContentType string
+	}{
+		{"no extension", "/qrcode", "", http.StatusOK, "image/png"},
+		{"png", "/qrcode.png", "", http.StatusOK, "image/png"},
+		{"jpg", "/qrcode.jpg", "", http.StatusOK, "image/jpeg"},
+		{"jpeg", "/qrcode.jpeg", "", http.StatusOK, "image/jpeg"},
+		{"gif", "/qrcode.gif", "", http.StatusOK, "image/gif"},
+		{"svg", "/qrcode.svg", "", http.StatusOK, "image/svg+xml"},
+		{"txt", "/qrcode.txt", "", http.StatusOK, "text/plain; charset=utf-8"},
+		{"pdf", "/qrcode.pdf", "", http.StatusOK, "application/pdf"},
+		{"ico", "/qrcode.ico", "", http.StatusOK, "image/x-icon"},
+		{"t precedes", "/qrcode.svg", "&t=gif", http.StatusOK, "image/gif"},
+		{"invalid t", "/qrcode.svg", "&t=bmp", http.StatusOK, "image/svg+xml"},
+		{"unknown", "/qrcode.bmp", "", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s%s?content=hello%s", ts.URL, tt.path, tt.query).Do(ctx)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus == http.StatusOK {
+				require.Equal(t, tt.wantContentType, resp.Header.Get(request.HeaderContentType))
+			}
+		})
+	}
+
+	// without extension, Accept header is used as before
+	resp, err := request.Get("%s/qrcode?content=hello", ts.URL).Header(echo.HeaderAccept, "text/plain").Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "text/plain; charset=utf-8", resp.Header.Get(request.HeaderContentType))
+}
+
+func TestAcceptHTML(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	// navigation of browser sends text/html first, which is of imgtag and markdown
+	resp, err := request.Get("%s/qrcode?content=hello", ts.URL).
+		Header(echo.HeaderAccept, "text/html,application/xhtml+xml,image/avif,image/webp,*/*;q=0.8").Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "image/png", resp.Header.Get(echo.HeaderContentType))
+}
+
+func TestFormatCookie(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name            string
+		path            string
+		accept          string
+		cookie          string
+		wantContentType string
+	}{
+		{"cookie", "/qrcode?content=hello", "", "svg", "image/svg+xml"},
+		{"browser", "/qrcode?content=hello", "image/avif,image/webp,*/*;q=0.8", "svg", "image/svg+xml"},
+		{"chrome navigation", "/qrcode?content=hello", acceptChromeNavigation, "svg", "image/svg+xml"},
+		{"chrome img", "/qrcode?content=hello", acceptChromeImage, "jpg", "image/jpeg"},
+		{"firefox navigation", "/qrcode?content=hello", acceptFirefoxNavigation, "svg", "image/svg+xml"},
+		{"firefox img", "/qrcode?content=hello", acceptFirefoxImage, "svg", "image/svg+xml"},
+		{"navigation without cookie", "/qrcode?content=hello", acceptFirefoxNavigation, "", "image/png"},
+		{"t", "/qrcode?content=hello&t=png", "", "svg", "image/png"},
+		{"extension", "/qrcode.gif?content=hello", "", "svg", "image/gif"},
+		{"accept", "/qrcode?content=hello", "image/jpeg", "svg", "image/jpeg"},
+		{"unknown", "/qrcode?content=hello", "", "bmp", "image/png"},
+		{"no cookie", "/qrcode?content=hello", "", "", "image/png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request.Get("%s%s", ts.URL, tt.path).Header(echo.HeaderAccept, tt.accept)
+			if tt.cookie != "" {
+				req = req.Header(echo.HeaderCookie, formatCookie+"="+tt.cookie)
+			}
+			resp, err := req.Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, tt.wantContentType, resp.Header.Get(request.HeaderContentType))
+			require.Contains(t, resp.Header.Get(echo.HeaderVary), "Cookie")
+		})
+	}
+}
+
+func TestEnabledFormats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	viper.Set("formats", []string{"png", "jpg", "svg"})
+	defer viper.Set("formats", []string{})
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil), newVersionAPI(config.Current()))
+
+	tests := [...]struct {
+		name            string
+		path            string
+		accept          string
+		wantStatus      int
+		wantContentType string
+	}{
+		{"png", "/qrcode?content=hello&t=png", "", http.StatusOK, "image/png"},
+		{"alias", "/qrcode?content=hello&t=jpg", "", http.StatusOK, "image/jpeg"},
+		{"pdf", "/qrcode?content=hello&t=pdf", "", http.StatusBadRequest, ""},
+		{"pdf extension", "/qrcode.pdf?content=hello", "", http.StatusBadRequest, ""},
+		{"pdf path content", "/qrcode/hello.pdf", "", http.StatusBadRequest, ""},
+		{"accept pdf", "/qrcode?content=hello", "application/pdf", http.StatusOK, "image/png"},
+		{"accept", "/qrcode?content=hello", "application/pdf, image/svg+xml;q=0.5", http.StatusOK, "image/svg+xml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s%s", ts.URL, tt.path).Header(echo.HeaderAccept, tt.accept).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus == http.StatusOK {
+				require.Equal(t, tt.wantContentType, resp.Header.Get(request.HeaderContentType))
+				return
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Contains(t, string(body), "format pdf is not enabled, allowed: png, jpeg, svg")
+		})
+	}
+
+	resp, err := request.Get("%s/version", ts.URL).Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	got := &VersionResponse{}
+	require.NoError(t, resp.JSON(got))
+	require.Equal(t, []string{"png", "jpeg", "svg"}, got.Features.Formats)
+}
+
+func TestSVGContentType(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name            string
+		query           string
+		accept          string
+		wantStatus      int
+		wantContentType string
+	}{
+		{"default", "&t=svg", "", http.StatusOK, "image/svg+xml"},
+		{"text/xml", "&t=svg&svgContentType=text/xml", "", http.StatusOK, "text/xml; charset=utf-8"},
+		{"application/xml", "&t=svg&svgContentType=Application/XML", "", http.StatusOK, "application/xml"},
+		{"svg", "&t=svg&svgContentType=image/svg%2Bxml", "application/xml", http.StatusOK, "image/svg+xml"},
+		{"accept", "", "application/xml", http.StatusOK, "application/xml"},
+		{"accept svg", "&t=svg", "image/svg+xml, text/xml;q=0.5", http.StatusOK, "image/svg+xml"},
+		{"accept quality", "&t=svg", "image/svg+xml;q=0.5, text/xml", http.StatusOK, "text/xml; charset=utf-8"},
+		{"not svg", "&t=png&svgContentType=text/xml", "", http.StatusOK, "image/png"},
+		{"invalid", "&t=svg&svgContentType=text/html", "", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/qrcode?content=hello%s", ts.URL, tt.query).Header(echo.HeaderAccept, tt.accept).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			require.Equal(t, tt.wantContentType, resp.Header.Get(echo.HeaderContentType))
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			if strings.Contains(tt.wantContentType, "xml") {
+				require.Contains(t, string(body), "<svg")
+			}
+		})
+	}
+}
+
+func TestAcceptPDF(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), nil, nil))
+
+	tests := [...]struct {
+		name            string
+		query           string
+		wantContentType string
+	}{
+		{"accept", "", mimePDF},
+		{"t precedes", "&t=png", "image/png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/qrcode?content=hello%s", ts.URL, tt.query).Header(echo.HeaderAccept, "application/pdf").Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, tt.wantContentType, resp.Header.Get(request.HeaderContentType))
+
+			data, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			if tt.wantContentType == mimePDF {
+				require.True(t, bytes.HasPrefix(data, []byte("%PDF-")))
+				require.Contains(t, string(data), "/MediaBox [0 0 200 200]")
+			}
+		})
+	}
+}
+
+func TestCompression(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), nil, nil))
+
+	get := func(compression string) *request.Response {
+		resp, err := request.Get("%s/qrcode", ts.URL).
+			Query("content", strings.Repeat("compression level of png ", 8)).
+			Query("label", "archive").
+			Query("compression", compression).
+			Do(ctx)
+		require.NoError(t, err)
+		return resp
+	}
+
+	sizes := map[string]int{}
+	images := map[string]image.Image{}
+	for _, compression := range []string{"", compressionFastest, compressionDefault, compressionBest} {
+		resp := get(compression)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		sizes[compression] = len(data)
+
+		img, err := png.Decode(bytes.NewReader(data))
+		require.NoError(t, err)
+		images[compression] = img
+	}
+
+	require.Equal(t, sizes[""], sizes[compressionDefault])
+	require.Less(t, sizes[compressionBest], sizes[compressionFastest])
+
+	// same pixels in any level
+	for _, compression := range []string{compressionFastest, compressionBest} {
+		require.Equal(t, images[compressionDefault].Bounds(), images[compression].Bounds())
+		for y := 0; y < images[compression].Bounds().Dy(); y++ {
+			for x := 0; x < images[compression].Bounds().Dx(); x++ {
+				require.Equal(t, images[compressionDefault].At(x, y), images[compression].At(x, y))
+			}
+		}
+	}
+
+	require.Equal(t, http.StatusBadRequest, get("maximum").StatusCode)
+}
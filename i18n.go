@@ -0,0 +1,80 @@
+package qrcodeapi
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"path"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+const fallbackLanguage = "en"
+
+// localeFiles message catalogs of error codes, `<language>.json`; a language is added by adding its file
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+var messages = mustLoadCatalog(localeFiles, "locales")
+
+// catalog messages of error codes by language; arguments of messages are `{name}` placeholders
+type catalog struct {
+	tags     []language.Tag // fallbackLanguage is the first
+	messages []map[string]string
+	matcher  language.Matcher
+}
+
+func mustLoadCatalog(fsys fs.FS, dir string) *catalog {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+
+	cat := &catalog{}
+	for _, entry := range entries {
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+
+		var m map[string]string
+		if err := json.Unmarshal(data, &m); err != nil {
+			panic(entry.Name() + ": " + err.Error())
+		}
+
+		tag := language.MustParse(strings.TrimSuffix(entry.Name(), ".json"))
+		if tag == language.Make(fallbackLanguage) {
+			cat.tags = append([]language.Tag{tag}, cat.tags...)
+			cat.messages = append([]map[string]string{m}, cat.messages...)
+		} else {
+			cat.tags = append(cat.tags, tag)
+			cat.messages = append(cat.messages, m)
+		}
+	}
+	if len(cat.tags) == 0 || cat.tags[0] != language.Make(fallbackLanguage) {
+		panic("catalog of " + fallbackLanguage + " is required")
+	}
+
+	cat.matcher = language.NewMatcher(cat.tags)
+	return cat
+}
+
+// message returns message of the code in the language of Accept-Language, or of fallbackLanguage
+// if the language or its message is not in the catalog; false if the code is not in the catalog
+func (cat *catalog) message(acceptLanguage string, code string, args map[string]string) (string, bool) {
+	_, i := language.MatchStrings(cat.matcher, acceptLanguage)
+	message, ok := cat.messages[i][code]
+	if !ok {
+		if message, ok = cat.messages[0][code]; !ok {
+			return "", false
+		}
+	}
+
+	replacements := make([]string, 0, len(args)*2)
+	for name, value := range args {
+		replacements = append(replacements, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(replacements...).Replace(message), true
+}
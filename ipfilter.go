@@ -0,0 +1,69 @@
+package qrcodeapi
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/whitekid/goxp/fx"
+
+	"qrcodeapi/config"
+)
+
+// ipExtractor returns client ip extractor; X-Forwarded-For is trusted only from trusted proxies
+func ipExtractor(cfg *config.Config) echo.IPExtractor {
+	if len(cfg.TrustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	// private networks are trusted by default of echo, only configured proxies are trusted
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range mustParseCIDRs(cfg.TrustedProxies) {
+		options = append(options, echo.TrustIPRange(proxy))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+// ipFilter reject clients of denylist or not in allowlist with 403, before any handler work.
+// allowlist is not applied if it is empty; requests of exempt paths are not restricted.
+func ipFilter(cfg *config.Config) echo.MiddlewareFunc {
+	allowlist, denylist := mustParseCIDRs(cfg.IPAllowlist), mustParseCIDRs(cfg.IPDenylist)
+	exempt := cfg.IPExemptPaths
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if fx.Contains(exempt, c.Request().URL.Path) {
+				return next(c)
+			}
+
+			ip := net.ParseIP(c.RealIP())
+			if ip == nil || containsIP(denylist, ip) || (len(allowlist) > 0 && !containsIP(allowlist, ip)) {
+				return echo.NewHTTPError(http.StatusForbidden)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// mustParseCIDRs parse cidrs validated by config
+func mustParseCIDRs(values []string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, s := range values {
+		network, err := config.ParseCIDR(s)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
@@ -0,0 +1,73 @@
+package qrcodeapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+)
+
+func TestIPFilter(t *testing.T) {
+	allowlist := []string{"10.1.0.0/16", "2001:db8::/32"}
+	denylist := []string{"192.0.2.0/24", "2001:db8:bad::/48", "198.51.100.7"}
+	trusted := []string{"127.0.0.1/8", "::1"}
+
+	tests := [...]struct {
+		name       string
+		allowlist  []string
+		denylist   []string
+		trusted    []string
+		path       string
+		xff        string
+		wantStatus int
+	}{
+		{"allowlisted", allowlist, nil, trusted, "/qrcode", "10.1.2.3", http.StatusOK},
+		{"allowlisted ipv6", allowlist, nil, trusted, "/qrcode", "2001:db8::1", http.StatusOK},
+		{"not allowlisted", allowlist, nil, trusted, "/qrcode", "203.0.113.5", http.StatusForbidden},
+		{"not allowlisted ipv6", allowlist, nil, trusted, "/qrcode", "2001:db9::1", http.StatusForbidden},
+		{"exempt path", allowlist, nil, trusted, "/metrics", "203.0.113.5", http.StatusOK},
+		{"denylisted", nil, denylist, trusted, "/qrcode", "192.0.2.7", http.StatusForbidden},
+		{"denylisted ip", nil, denylist, trusted, "/qrcode", "198.51.100.7", http.StatusForbidden},
+		{"denylisted ipv6", nil, denylist, trusted, "/qrcode", "2001:db8:bad::1", http.StatusForbidden},
+		{"not denylisted", nil, denylist, trusted, "/qrcode", "203.0.113.5", http.StatusOK},
+		{"deny over allow", allowlist, []string{"10.1.2.0/24"}, trusted, "/qrcode", "10.1.2.3", http.StatusForbidden},
+		// forwarded address by untrusted client is ignored; 127.0.0.1 is the client
+		{"untrusted allowlisted", allowlist, nil, nil, "/qrcode", "10.1.2.3", http.StatusForbidden},
+		{"untrusted denylisted", nil, denylist, nil, "/qrcode", "192.0.2.7", http.StatusOK},
+		// leftmost address is given by the client, the address appended by trusted proxy is used
+		{"spoofed", allowlist, nil, trusted, "/qrcode", "10.1.2.3, 203.0.113.5", http.StatusForbidden},
+		{"spoofed denylisted", nil, denylist, trusted, "/qrcode", "203.0.113.5, 192.0.2.7", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			viper.Set("ip_allowlist", tt.allowlist)
+			viper.Set("ip_denylist", tt.denylist)
+			viper.Set("trusted_proxies", tt.trusted)
+			viper.Set("ip_exempt_paths", []string{"/metrics"})
+			defer func() {
+				for _, key := range []string{"ip_allowlist", "ip_denylist", "trusted_proxies", "ip_exempt_paths"} {
+					viper.Set(key, []string{})
+				}
+			}()
+
+			ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+			resp, err := request.Get("%s%s?content=hello", ts.URL, tt.path).
+				Header(echo.HeaderXForwardedFor, tt.xff).
+				Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+		})
+	}
+}
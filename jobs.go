@@ -0,0 +1,243 @@
+package qrcodeapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	jobRunning  = "running"
+	jobDone     = "done"
+	jobFailed   = "failed"
+	jobCanceled = "canceled"
+
+	maxBatchJobs = 16 // jobs kept for status and events, as batchResults
+)
+
+var (
+	// batchJobs jobs by id; the least recently used ones are evicted, running ones keep running
+	batchJobs lruCache[*batchJob]
+
+	// jobHeartbeat interval of comments of the events stream, so that proxies don't close idle connections
+	jobHeartbeat = 15 * time.Second
+)
+
+// JobStatus status of the batch job, `GET /jobs/:id`
+type JobStatus struct {
+	ID        string `json:"id"`
+	Status    string `json:"status"` // running, done, failed or canceled
+	Completed int    `json:"completed"`
+	Failed    int    `json:"failed"`
+	Total     int    `json:"total"`
+	Percent   int    `json:"percent"`
+	Pages     int    `json:"pages,omitempty"`
+	URL       string `json:"url,omitempty"`     // download url of the pdf when done
+	Message   string `json:"message,omitempty"` // error when failed
+}
+
+// JobProgressEvent `progress` event of the job events, for each completed or failed item
+type JobProgressEvent struct {
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+	Total     int `json:"total"`
+	Percent   int `json:"percent"`
+}
+
+// batchJob label sheet of the batch rendered in background; changed is closed and renewed on each change
+type batchJob struct {
+	mu      sync.Mutex
+	status  JobStatus
+	changed chan struct{}
+	cancel  context.CancelFunc
+}
+
+// update change the status and wake up the watchers
+func (job *batchJob) update(fn func(status *JobStatus)) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	fn(&job.status)
+	job.status.Percent = 100 * (job.status.Completed + job.status.Failed) / job.status.Total
+	close(job.changed)
+	job.changed = make(chan struct{})
+}
+
+// snapshot returns the status and the channel closed on the next change
+func (job *batchJob) snapshot() (JobStatus, <-chan struct{}) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return job.status, job.changed
+}
+
+// resultID returns random id of the batch result or the job; the id is the only credential of the result
+func resultID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// handleCreateJob render label sheet of the batch in background, `POST /jobs` with the body and parameters of `POST /batch`;
+// the job is not canceled by disconnect of the client but by `DELETE /jobs/:id`.
+func (api *APIv1) handleCreateJob(c echo.Context) error {
+	layout, err := parseSheetLayout(c.QueryParam)
+	if err != nil {
+		return err
+	}
+
+	docs, err := decodeBatch(c)
+	if err != nil {
+		return err
+	}
+	if err := checkPixelBudget(api.cfg, docs, layout); err != nil {
+		return err
+	}
+
+	id, err := resultID()
+	if err != nil {
+		return err
+	}
+
+	// the request context is canceled when the handler returns
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &batchJob{
+		status:  JobStatus{ID: id, Status: jobRunning, Total: len(docs)},
+		changed: make(chan struct{}),
+		cancel:  cancel,
+	}
+	batchJobs.Add(id, job, maxBatchJobs)
+
+	jc := c.Echo().NewContext(c.Request().Clone(ctx), nil)
+	resultURL := publicURL(api.cfg, c, strings.TrimSuffix(c.Path(), "/jobs")+"/batch/"+id)
+	go api.runJob(jc, id, job, docs, layout, resultURL)
+
+	status, _ := job.snapshot()
+	c.Response().Header().Set(echo.HeaderLocation, publicURL(api.cfg, c, c.Path()+"/"+id))
+	return c.JSON(http.StatusAccepted, &status)
+}
+
+// runJob render the sheet of the job, and keep the pdf as streamed batch
+func (api *APIv1) runJob(c echo.Context, id string, job *batchJob, docs []*QRCodeDocument, layout *sheetLayout, resultURL string) {
+	defer job.cancel()
+
+	out, err := renderSheet(api.cfg, c, docs, layout, func(i int) {
+		job.update(func(status *JobStatus) { status.Completed++ })
+	})
+	if err == nil {
+		var buf bytes.Buffer
+		if _, err = out.WriteTo(&buf); err == nil {
+			batchResults.Add(id, buf.Bytes(), maxBatchResults)
+			job.update(func(status *JobStatus) {
+				status.Status, status.Pages, status.URL = jobDone, out.Pages(), resultURL
+			})
+			return
+		}
+	}
+
+	if c.Request().Context().Err() != nil {
+		job.update(func(status *JobStatus) { status.Status = jobCanceled })
+		return
+	}
+
+	message := err.Error()
+	if he, ok := err.(*echo.HTTPError); ok {
+		message = fmt.Sprintf("%v", he.Message)
+	}
+	c.Logger().Errorf("job %s: %s", id, err)
+	job.update(func(status *JobStatus) {
+		status.Status, status.Failed, status.Message = jobFailed, status.Failed+1, message
+	})
+}
+
+func (api *APIv1) job(c echo.Context) (*batchJob, error) {
+	job, ok := batchJobs.Get(c.Param("id"))
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "job not found: "+c.Param("id"))
+	}
+	return job, nil
+}
+
+// handleJob returns status of the job
+func (api *APIv1) handleJob(c echo.Context) error {
+	job, err := api.job(c)
+	if err != nil {
+		return err
+	}
+
+	status, _ := job.snapshot()
+	return c.JSON(http.StatusOK, &status)
+}
+
+// handleCancelJob cancel the running job; the job is ended with `canceled` after the item in progress
+func (api *APIv1) handleCancelJob(c echo.Context) error {
+	job, err := api.job(c)
+	if err != nil {
+		return err
+	}
+
+	job.cancel()
+	return c.NoContent(http.StatusAccepted)
+}
+
+// handleJobEvents stream progress of the job as server-sent events; `progress` for each item in order,
+// then `done`, `failed` or `canceled` with the status. Progress before the subscription is sent first,
+// and disconnect of the client ends the stream only.
+func (api *APIv1) handleJobEvents(c echo.Context) error {
+	job, err := api.job(c)
+	if err != nil {
+		return err
+	}
+
+	resp := c.Response()
+	resp.Header().Set(echo.HeaderContentType, mimeEventStream)
+	resp.Header().Set("Cache-Control", "no-cache")
+	resp.WriteHeader(http.StatusOK)
+	resp.Flush()
+
+	send := func(event string, data interface{}) {
+		b, _ := json.Marshal(data)
+		fmt.Fprintf(resp, "event: %s\ndata: %s\n\n", event, b)
+		resp.Flush()
+	}
+
+	heartbeat := time.NewTicker(jobHeartbeat)
+	defer heartbeat.Stop()
+
+	sent := 0 // items of sent progress events
+	for {
+		status, changed := job.snapshot()
+		for ; sent < status.Completed+status.Failed; sent++ {
+			// items are completed in order before the failed one
+			event := &JobProgressEvent{Completed: sent + 1, Total: status.Total, Percent: 100 * (sent + 1) / status.Total}
+			if sent >= status.Completed {
+				event.Completed, event.Failed = status.Completed, sent+1-status.Completed
+			}
+			send("progress", event)
+		}
+
+		if status.Status != jobRunning {
+			send(status.Status, &status)
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-heartbeat.C:
+			fmt.Fprint(resp, ": heartbeat\n\n")
+			resp.Flush()
+		case <-c.Request().Context().Done():
+			return nil
+		}
+	}
+}
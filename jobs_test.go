@@ -0,0 +1,168 @@
+package qrcodeapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/fx"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+)
+
+func TestJob(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), nil, nil))
+
+	create := func(items interface{}) *JobStatus {
+		resp, err := request.Post("%s/jobs?layout=grid&cols=2&rows=1", ts.URL).JSON(items).Do(ctx)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+		status := &JobStatus{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(status))
+		require.Equal(t, ts.URL+"/jobs/"+status.ID, resp.Header.Get("Location"))
+		return status
+	}
+
+	events := func(id string) []sseEvent {
+		resp, err := request.Get("%s/jobs/%s/events", ts.URL, id).Do(ctx)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "text/event-stream", resp.Header.Get(request.HeaderContentType))
+		return readEvents(t, resp.Body)
+	}
+
+	progress := func(e sseEvent) *JobProgressEvent {
+		event := &JobProgressEvent{}
+		require.NoError(t, json.Unmarshal([]byte(e.data), event))
+		return event
+	}
+
+	status := create([]map[string]interface{}{{"content": "a"}, {"content": "b"}, {"content": "c"}, {"content": "d"}})
+	require.Equal(t, jobRunning, status.Status)
+	require.Equal(t, 4, status.Total)
+
+	got := events(status.ID)
+	require.Equal(t, []string{"progress", "progress", "progress", "progress", "done"}, fx.Map(got, func(e sseEvent) string { return e.event }))
+	for i, e := range got[:4] {
+		require.Equal(t, &JobProgressEvent{Completed: i + 1, Total: 4, Percent: 25 * (i + 1)}, progress(e))
+	}
+
+	done := &JobStatus{}
+	require.NoError(t, json.Unmarshal([]byte(got[4].data), done))
+	require.Equal(t, jobDone, done.Status)
+	require.Equal(t, 2, done.Pages)
+	require.Equal(t, 100, done.Percent)
+	require.Equal(t, ts.URL+"/batch/"+status.ID, done.URL)
+
+	resp, err := request.Get(done.URL).Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "application/pdf", resp.Header.Get(request.HeaderContentType))
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "/Count 2")
+
+	// status and events of finished job are kept
+	resp, err = request.Get("%s/jobs/%s", ts.URL, status.ID).Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(status))
+	require.Equal(t, done, status)
+	require.Equal(t, got, events(status.ID))
+
+	// failed item
+	status = create([]map[string]interface{}{{"content": "a"}, {"content": "b", "ec": "X"}, {"content": "c"}})
+	got = events(status.ID)
+	require.Equal(t, []string{"progress", "progress", "failed"}, fx.Map(got, func(e sseEvent) string { return e.event }))
+	require.Equal(t, &JobProgressEvent{Completed: 1, Total: 3, Percent: 33}, progress(got[0]))
+	require.Equal(t, &JobProgressEvent{Completed: 1, Failed: 1, Total: 3, Percent: 66}, progress(got[1]))
+	require.Contains(t, got[2].data, "item 1: ec: invalid value")
+
+	// invalid batch is not started
+	resp, err = request.Post("%s/jobs", ts.URL).JSON([]map[string]interface{}{{"content": "a"}}).Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	for _, path := range []string{"/jobs/unknown", "/jobs/unknown/events"} {
+		resp, err = request.Get("%s%s", ts.URL, path).Do(ctx)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+	}
+}
+
+func TestJobCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	allowLoopback(t)
+	heartbeat := jobHeartbeat
+	jobHeartbeat = 10 * time.Millisecond
+	t.Cleanup(func() { jobHeartbeat = heartbeat })
+
+	// logo of the second item is not responded until the job is canceled
+	fetched := make(chan struct{})
+	logo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(fetched)
+		<-r.Context().Done()
+	}))
+	defer logo.Close()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), nil, nil))
+
+	resp, err := request.Post("%s/jobs?layout=grid", ts.URL).
+		JSON([]map[string]interface{}{{"content": "a"}, {"content": "b", "style": map[string]string{"logo": logo.URL + "/logo.png"}}, {"content": "c"}}).
+		Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	status := &JobStatus{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(status))
+
+	<-fetched
+
+	// disconnect of a client doesn't affect the job, and idle stream is kept with heartbeats
+	streamCtx, streamCancel := context.WithCancel(ctx)
+	resp, err = request.Get("%s/jobs/%s/events", ts.URL, status.ID).Do(streamCtx)
+	require.NoError(t, err)
+	scanner := bufio.NewScanner(resp.Body)
+	var lines []string
+	for scanner.Scan() && !fx.Contains(lines, ": heartbeat") {
+		lines = append(lines, scanner.Text())
+	}
+	require.Equal(t, []string{"event: progress", `data: {"completed":1,"failed":0,"total":3,"percent":33}`, "", ": heartbeat"}, lines)
+	streamCancel()
+	resp.Body.Close()
+
+	resp, err = request.Get("%s/jobs/%s", ts.URL, status.ID).Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(status))
+	require.Equal(t, jobRunning, status.Status)
+
+	resp, err = request.Delete("%s/jobs/%s", ts.URL, status.ID).Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	resp, err = request.Get("%s/jobs/%s/events", ts.URL, status.ID).Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	got := readEvents(t, resp.Body)
+	// heartbeats are comments, not events
+	events := fx.Filter(got, func(e sseEvent) bool { return e.event != "" })
+	require.Equal(t, []string{"progress", "canceled"}, fx.Map(events, func(e sseEvent) string { return e.event }))
+	require.Contains(t, events[1].data, `"status":"canceled"`)
+}
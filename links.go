@@ -0,0 +1,299 @@
+package qrcodeapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/whitekid/goxp/fx"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/links"
+	"qrcodeapi/pkg/qrcode"
+)
+
+// linksAPI dynamic qrcode links
+type linksAPI struct {
+	cfg      *config.Config
+	store    links.Store
+	recorder *links.Recorder
+}
+
+var _ router = (*linksAPI)(nil)
+
+// newLinksAPI create links api; scan stats are recorded in background until ctx is done
+func newLinksAPI(ctx context.Context, cfg *config.Config, store links.Store) router {
+	recorder := links.NewRecorder(store, cfg.StatsFlushInterval)
+	go recorder.Run(ctx)
+
+	return &linksAPI{cfg: cfg, store: store, recorder: recorder}
+}
+
+func (api *linksAPI) Route(e *echo.Echo, path string) {
+	e.GET(path+"/r/:id", api.handleRedirect)
+
+	g := e.Group(path + "/links")
+	g.POST("", api.handleCreate, requireAPIKey(api.cfg))
+	g.GET("", api.handleList, requireAPIKey(api.cfg))
+	g.GET("/:id", api.handleGet, requireAPIKey(api.cfg))
+	g.PUT("/:id", api.handleUpdate, requireAPIKey(api.cfg))
+	g.DELETE("/:id", api.handleDelete, requireAPIKey(api.cfg))
+	g.GET("/:id/stats", api.handleStats, requireAPIKey(api.cfg))
+}
+
+type CreateLinkRequest struct {
+	Target string `json:"target" validate:"required"`
+}
+
+// handleCreate create redirect link and returns qrcode of the redirect url
+func (api *linksAPI) handleCreate(c echo.Context) error {
+	req := &CreateLinkRequest{}
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	if err := validateLinkTarget(api.cfg, req.Target); err != nil {
+		return err
+	}
+
+	if err := checkTarget(api.cfg, c, req.Target); err != nil {
+		return err
+	}
+
+	link := links.New(req.Target)
+	if err := api.store.Create(c.Request().Context(), link); err != nil {
+		return err
+	}
+
+	qr, err := qrcode.Text(redirectURL(api.cfg, c, link.ID))
+	if err != nil {
+		return err
+	}
+
+	audit(c, "create", "link/"+link.ID, "target=%s", link.Target)
+
+	c.Response().Header().Set("X-Link-ID", link.ID)
+	c.Set(ctxKeyPayloadKind, kindURL)
+	return renderQRCode(api.cfg, c, qr)
+}
+
+func (api *linksAPI) handleList(c echo.Context) error {
+	links, err := api.store.List(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, links)
+}
+
+func (api *linksAPI) handleRedirect(c echo.Context) error {
+	link, err := api.getLink(c)
+	if err != nil {
+		return err
+	}
+
+	api.recorder.Record(&links.Scan{
+		LinkID:  link.ID,
+		Time:    time.Now(),
+		Device:  links.DeviceClass(c.Request().UserAgent()),
+		Country: country(api.cfg, c),
+	})
+
+	if !link.Active() {
+		if gone := api.cfg.LinkGoneURL; gone != "" {
+			return c.Redirect(http.StatusFound, gone)
+		}
+		return echo.NewHTTPError(http.StatusGone)
+	}
+
+	return c.Redirect(http.StatusFound, link.Target)
+}
+
+func (api *linksAPI) getLink(c echo.Context) (*links.Link, error) {
+	link, err := api.store.Get(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, links.ErrNotFound) {
+			return nil, echo.NewHTTPError(http.StatusNotFound)
+		}
+		return nil, err
+	}
+
+	return link, nil
+}
+
+func linkETag(link *links.Link) string { return fmt.Sprintf(`"%d"`, link.Version) }
+
+// checkIfMatch check If-Match header for optimistic concurrency; If-Match is required for mutations
+func checkIfMatch(c echo.Context, link *links.Link) error {
+	ifMatch := c.Request().Header.Get("If-Match")
+	if ifMatch == "" {
+		return echo.NewHTTPError(http.StatusPreconditionRequired, "If-Match header required")
+	}
+
+	etag := linkETag(link)
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == etag {
+			return nil
+		}
+	}
+
+	return echo.NewHTTPError(http.StatusPreconditionFailed, "link was modified")
+}
+
+func (api *linksAPI) linkResponse(c echo.Context, link *links.Link) error {
+	c.Response().Header().Set("ETag", linkETag(link))
+	return c.JSON(http.StatusOK, link)
+}
+
+func (api *linksAPI) handleGet(c echo.Context) error {
+	link, err := api.getLink(c)
+	if err != nil {
+		return err
+	}
+
+	return api.linkResponse(c, link)
+}
+
+type UpdateLinkRequest struct {
+	Target string `json:"target" validate:"required"`
+}
+
+func (api *linksAPI) handleUpdate(c echo.Context) error {
+	req := &UpdateLinkRequest{}
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	if err := validateLinkTarget(api.cfg, req.Target); err != nil {
+		return err
+	}
+
+	if err := checkTarget(api.cfg, c, req.Target); err != nil {
+		return err
+	}
+
+	link, err := api.getLink(c)
+	if err != nil {
+		return err
+	}
+
+	if err := checkIfMatch(c, link); err != nil {
+		return err
+	}
+
+	if !link.Active() {
+		return echo.NewHTTPError(http.StatusGone)
+	}
+
+	old := link.Target
+	link.Target = req.Target
+	if err := api.updateLink(c, link); err != nil {
+		return err
+	}
+
+	audit(c, "update", "link/"+link.ID, "target=%s old_target=%s version=%d", link.Target, old, link.Version)
+	return api.linkResponse(c, link)
+}
+
+// handleDelete deactivate the link; link is kept for stats
+func (api *linksAPI) handleDelete(c echo.Context) error {
+	link, err := api.getLink(c)
+	if err != nil {
+		return err
+	}
+
+	if err := checkIfMatch(c, link); err != nil {
+		return err
+	}
+
+	if !link.Active() {
+		return echo.NewHTTPError(http.StatusGone)
+	}
+
+	link.Deactivate()
+	if err := api.updateLink(c, link); err != nil {
+		return err
+	}
+
+	audit(c, "deactivate", "link/"+link.ID, "version=%d", link.Version)
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (api *linksAPI) updateLink(c echo.Context, link *links.Link) error {
+	if err := api.store.Update(c.Request().Context(), link); err != nil {
+		if errors.Is(err, links.ErrConflict) {
+			return echo.NewHTTPError(http.StatusPreconditionFailed, "link was modified")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// country returns ISO 3166 country code from configured geo header
+func country(cfg *config.Config, c echo.Context) string {
+	header := cfg.GeoHeader
+	if header == "" {
+		return ""
+	}
+
+	code := strings.ToUpper(c.Request().Header.Get(header))
+	if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+		return ""
+	}
+
+	return code
+}
+
+func (api *linksAPI) handleStats(c echo.Context) error {
+	stats, err := api.store.GetStats(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, links.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, stats)
+}
+
+// validateLinkTarget check target is absolute url with allowed scheme
+func validateLinkTarget(cfg *config.Config, target string) error {
+	return validateURL(target, cfg.LinkSchemes)
+}
+
+// validateURL check target is absolute url with one of the schemes
+func validateURL(target string, schemes []string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if !fx.Contains(schemes, strings.ToLower(u.Scheme)) {
+		return echo.NewHTTPError(http.StatusBadRequest, "scheme not allowed: "+u.Scheme)
+	}
+
+	if u.Host == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "host required")
+	}
+
+	return nil
+}
+
+// redirectURL returns public url of the redirect link
+func redirectURL(cfg *config.Config, c echo.Context, id string) string {
+	return publicURL(cfg, c, "/r/"+id)
+}
@@ -0,0 +1,267 @@
+package qrcodeapi
+
+import (
+	"context"
+	"image"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/links"
+	"qrcodeapi/pkg/qrcode"
+)
+
+func TestLinks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	viper.Set("api_keys", []string{"secret"})
+	defer viper.Set("api_keys", []string{})
+
+	ts := newTestServer(ctx, newLinksAPI(ctx, config.Current(), links.NewMemoryStore()))
+
+	// create without api key
+	resp, err := request.Post("%s/links", ts.URL).
+		JSON(map[string]string{"target": "https://example.com/menu"}).
+		Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, err = request.Post("%s/links", ts.URL).
+		Header(echo.HeaderAuthorization, "Bearer secret").
+		JSON(map[string]string{"target": "https://example.com/menu"}).
+		Do(ctx)
+	require.NoError(t, err)
+	require.Truef(t, resp.Success(), "failed with %d", resp.StatusCode)
+	require.Equal(t, "image/png", resp.Header.Get(request.HeaderContentType))
+
+	id := resp.Header.Get("X-Link-ID")
+	require.NotEmpty(t, id)
+
+	defer resp.Body.Close()
+	img, _, err := image.Decode(resp.Body)
+	require.NoError(t, err)
+	got, err := qrcode.Decode(img)
+	require.NoError(t, err)
+	require.Equal(t, ts.URL+"/r/"+id, got)
+
+	// follow redirect
+	resp, err = request.Get(got).FollowRedirect(false).Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	require.Equal(t, "https://example.com/menu", resp.Header.Get("Location"))
+
+	// unknown
+	resp, err = request.Get("%s/r/unknown", ts.URL).FollowRedirect(false).Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// list
+	resp, err = request.Get("%s/links", ts.URL).Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, err = request.Get("%s/links", ts.URL).Header(echo.HeaderAuthorization, "Bearer invalid").Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, err = request.Get("%s/links", ts.URL).Header(echo.HeaderAuthorization, "Bearer secret").Do(ctx)
+	require.NoError(t, err)
+	require.True(t, resp.Success())
+
+	var list []links.Link
+	defer resp.Body.Close()
+	require.NoError(t, resp.JSON(&list))
+	require.Len(t, list, 1)
+	require.Equal(t, id, list[0].ID)
+	require.Equal(t, "https://example.com/menu", list[0].Target)
+}
+
+func TestLinkTarget(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	viper.Set("api_keys", []string{"secret"})
+	defer viper.Set("api_keys", []string{})
+
+	ts := newTestServer(ctx, newLinksAPI(ctx, config.Current(), links.NewMemoryStore()))
+
+	tests := [...]struct {
+		name       string
+		target     string
+		wantStatus int
+	}{
+		{"valid", "https://example.com", http.StatusOK},
+		{"empty", "", http.StatusBadRequest},
+		{"javascript", "javascript:alert(1)", http.StatusBadRequest},
+		{"relative", "/menu", http.StatusBadRequest},
+		{"ftp", "ftp://example.com/file", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Post("%s/links", ts.URL).
+				Header(echo.HeaderAuthorization, "Bearer secret").
+				JSON(map[string]string{"target": tt.target}).
+				Do(ctx)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestLinkStats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	viper.Set("api_keys", []string{"secret"})
+	viper.Set("stats_flush_interval", 10*time.Millisecond)
+	viper.Set("geo_header", "CF-IPCountry")
+	defer func() {
+		viper.Set("api_keys", []string{})
+		viper.Set("stats_flush_interval", 5*time.Second)
+		viper.Set("geo_header", "")
+	}()
+
+	store := links.NewMemoryStore()
+	ts := newTestServer(ctx, newLinksAPI(ctx, config.Current(), store))
+
+	link := links.New("https://example.com")
+	require.NoError(t, store.Create(ctx, link))
+
+	scans := []struct {
+		userAgent string
+		country   string
+	}{
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 16_0 like Mac OS X) Mobile/15E148", "KR"},
+		{"Mozilla/5.0 (Linux; Android 13; Pixel 7) Mobile Safari/537.36", "kr"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/107.0", "US"},
+	}
+	for _, scan := range scans {
+		resp, err := request.Get("%s/r/%s", ts.URL, link.ID).
+			Header("User-Agent", scan.userAgent).
+			Header("CF-IPCountry", scan.country).
+			FollowRedirect(false).
+			Do(ctx)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+	}
+
+	getStats := func() *links.Stats {
+		resp, err := request.Get("%s/links/%s/stats", ts.URL, link.ID).
+			Header(echo.HeaderAuthorization, "Bearer secret").
+			Do(ctx)
+		require.NoError(t, err)
+		require.True(t, resp.Success())
+		defer resp.Body.Close()
+
+		stats := &links.Stats{}
+		require.NoError(t, resp.JSON(stats))
+		return stats
+	}
+
+	require.Eventually(t, func() bool { return getStats().Scans == 3 }, 2*time.Second, 20*time.Millisecond)
+
+	stats := getStats()
+	require.Equal(t, map[string]int64{links.DeviceMobile: 2, links.DeviceDesktop: 1}, stats.Devices)
+	require.Equal(t, map[string]int64{"KR": 2, "US": 1}, stats.Countries)
+	require.Equal(t, map[string]int64{time.Now().UTC().Format("2006-01-02"): 3}, stats.Daily)
+
+	// auth required
+	resp, err := request.Get("%s/links/%s/stats", ts.URL, link.ID).Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, err = request.Get("%s/links/unknown/stats", ts.URL).
+		Header(echo.HeaderAuthorization, "Bearer secret").
+		Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestLinkUpdate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	viper.Set("api_keys", []string{"secret"})
+	defer viper.Set("api_keys", []string{})
+
+	store := links.NewMemoryStore()
+	cfg := config.Current()
+	ts := newTestServer(ctx, newLinksAPI(ctx, cfg, store))
+
+	link := links.New("https://example.com/menu")
+	require.NoError(t, store.Create(ctx, link))
+
+	redirect := func() *request.Response {
+		resp, err := request.Get("%s/r/%s", ts.URL, link.ID).FollowRedirect(false).Do(ctx)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp, err := request.Get("%s/links/%s", ts.URL, link.ID).Header(echo.HeaderAuthorization, "Bearer secret").Do(ctx)
+	require.NoError(t, err)
+	require.True(t, resp.Success())
+	etag := resp.Header.Get("ETag")
+	require.Equal(t, `"1"`, etag)
+
+	update := func(target, ifMatch string) *request.Response {
+		req := request.Put("%s/links/%s", ts.URL, link.ID).
+			Header(echo.HeaderAuthorization, "Bearer secret").
+			JSON(map[string]string{"target": target})
+		if ifMatch != "" {
+			req = req.Header("If-Match", ifMatch)
+		}
+		resp, err := req.Do(ctx)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// auth required
+	resp, err = request.Put("%s/links/%s", ts.URL, link.ID).
+		Header("If-Match", etag).
+		JSON(map[string]string{"target": "https://example.com/menu2"}).Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	require.Equal(t, http.StatusPreconditionRequired, update("https://example.com/menu2", "").StatusCode)
+	require.Equal(t, http.StatusBadRequest, update("javascript:alert(1)", etag).StatusCode)
+
+	resp = update("https://example.com/menu2", etag)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	newETag := resp.Header.Get("ETag")
+	require.Equal(t, `"2"`, newETag)
+
+	resp = redirect()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	require.Equal(t, "https://example.com/menu2", resp.Header.Get("Location"))
+
+	// another editor with stale etag
+	require.Equal(t, http.StatusPreconditionFailed, update("https://example.com/menu3", etag).StatusCode)
+
+	// deactivate
+	deleteLink := func(ifMatch string) *request.Response {
+		resp, err := request.Delete("%s/links/%s", ts.URL, link.ID).
+			Header(echo.HeaderAuthorization, "Bearer secret").
+			Header("If-Match", ifMatch).
+			Do(ctx)
+		require.NoError(t, err)
+		return resp
+	}
+	require.Equal(t, http.StatusPreconditionFailed, deleteLink(etag).StatusCode)
+	require.Equal(t, http.StatusNoContent, deleteLink(newETag).StatusCode)
+
+	require.Equal(t, http.StatusGone, redirect().StatusCode)
+	require.Equal(t, http.StatusGone, update("https://example.com/menu4", `"3"`).StatusCode)
+
+	cfg.LinkGoneURL = "https://example.com/gone"
+
+	resp = redirect()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	require.Equal(t, "https://example.com/gone", resp.Header.Get("Location"))
+}
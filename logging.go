@@ -0,0 +1,35 @@
+package qrcodeapi
+
+import (
+	"io"
+	"os"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/logfile"
+)
+
+// logOutput destination of access and application logs
+type logOutput interface {
+	io.WriteCloser
+	Reopen() error
+}
+
+// stdoutOutput standard output; reopen and close do nothing
+type stdoutOutput struct{ io.Writer }
+
+func (stdoutOutput) Reopen() error { return nil }
+func (stdoutOutput) Close() error  { return nil }
+
+// newLogOutput returns log file rotated by size if log_file is given, standard output otherwise
+func newLogOutput(cfg *config.Config) (logOutput, error) {
+	if cfg.LogFile == "" {
+		return stdoutOutput{os.Stdout}, nil
+	}
+
+	return logfile.New(cfg.LogFile, logfile.Options{
+		MaxSize:    int64(cfg.LogMaxSize) * 1024 * 1024,
+		MaxBackups: cfg.LogMaxBackups,
+		MaxAge:     cfg.LogMaxAge,
+		Compress:   cfg.LogCompress,
+	})
+}
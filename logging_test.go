@@ -0,0 +1,76 @@
+package qrcodeapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+	"qrcodeapi/pkg/logfile"
+)
+
+func TestLogFile(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	cfg := config.Current()
+	cfg.LogFile = filepath.Join(dir, "qrcodeapi.log")
+	cfg.LogMaxSize = 1
+	cfg.LogMaxBackups = 2
+	cfg.LogCompress = true
+
+	out, err := newLogOutput(cfg)
+	require.NoError(t, err)
+	defer out.Close()
+
+	e := newEcho(cfg, out)
+	newAPIv1(cfg, codes.NewMemoryStore(), nil).Route(e, "")
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+	watchHangup(ctx, e, out, cfg)
+
+	// access log
+	resp, err := request.Get("%s/qrcode?content=hello", ts.URL).Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	data, err := os.ReadFile(cfg.LogFile)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"uri":"/qrcode?content=hello"`)
+
+	// about 3MB of application log rotates 3 times and 2 backups are kept
+	line := strings.Repeat("x", 1000)
+	for i := 0; i < 3*1024; i++ {
+		e.Logger.Info(line)
+	}
+	require.NoError(t, out.Close()) // wait compression of rotated files
+
+	backups, err := out.(*logfile.Writer).Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	for _, backup := range backups {
+		require.True(t, strings.HasSuffix(backup, ".gz"), backup)
+	}
+
+	// reopen by SIGHUP after logrotate moved the file
+	moved := cfg.LogFile + ".1"
+	require.NoError(t, os.Rename(cfg.LogFile, moved))
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(cfg.LogFile)
+		return err == nil && strings.Contains(string(data), "config reloaded")
+	}, time.Second, 10*time.Millisecond)
+}
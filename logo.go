@@ -0,0 +1,17 @@
+package qrcodeapi
+
+import (
+	"fmt"
+	"net/url"
+)
+
+const maxLogoBytes = 1 << 20
+
+// validateImageURL returns error if image url is not of allowed schemes, see fetchRemote
+func validateImageURL(imageURL string) error {
+	u, err := url.Parse(imageURL)
+	if err != nil || validateRemoteURL(u) != nil {
+		return fmt.Errorf("invalid image url: %s", imageURL)
+	}
+	return nil
+}
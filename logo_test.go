@@ -0,0 +1,146 @@
+package qrcodeapi
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+	"qrcodeapi/pkg/qrcode"
+)
+
+func newLogoServer(ctx context.Context) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/logo.png" {
+			http.NotFound(w, r)
+			return
+		}
+
+		logo := image.NewRGBA(image.Rect(0, 0, 32, 32))
+		draw.Draw(logo, logo.Bounds(), image.NewUniform(color.RGBA{0xff, 0x00, 0x00, 0xff}), image.Point{}, draw.Src)
+		w.Header().Set("Content-Type", "image/png")
+		png.Encode(w, logo)
+	}))
+	go func() {
+		<-ctx.Done()
+		ts.Close()
+	}()
+	return ts
+}
+
+func TestLogo(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	allowLoopback(t)
+
+	logoServer := newLogoServer(ctx)
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	// svg embeds the logo as base64 image
+	resp, err := request.Get("%s/qrcode", ts.URL).
+		Query("content", "https://github.com/whitekid/qrcodeapi").
+		Query("t", "svg").
+		Query("logo", logoServer.URL+"/logo.png").
+		Do(ctx)
+	require.NoError(t, err)
+	require.Truef(t, resp.Success(), "failed with %d", resp.StatusCode)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), `<image `)
+	require.Contains(t, string(body), `href="data:image/png;base64,`)
+
+	// raster with the logo is still scannable
+	resp, err = request.Get("%s/qrcode", ts.URL).
+		Query("content", "https://github.com/whitekid/qrcodeapi").
+		Query("logo", logoServer.URL+"/logo.png").
+		Do(ctx)
+	require.NoError(t, err)
+	require.Truef(t, resp.Success(), "failed with %d", resp.StatusCode)
+	defer resp.Body.Close()
+	img, _, err := image.Decode(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, color.RGBA{0xff, 0x00, 0x00, 0xff}, color.RGBAModel.Convert(img.At(100, 100)))
+	got, err := qrcode.Decode(img)
+	require.NoError(t, err)
+	require.Equal(t, "https://github.com/whitekid/qrcodeapi", got)
+
+	tests := [...]struct {
+		name        string
+		logo        string
+		wantMessage string
+	}{
+		{"not found", logoServer.URL + "/unknown.png", "failed to fetch logo: status 404"},
+		{"scheme", "file:///etc/passwd", "invalid logo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/qrcode", ts.URL).
+				Query("content", "hello").
+				Query("logo", tt.logo).
+				Do(ctx)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.True(t, strings.Contains(string(body), tt.wantMessage), string(body))
+		})
+	}
+}
+
+func TestLogoAuto(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	allowLoopback(t)
+
+	logoServer := newLogoServer(ctx)
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name         string
+		query        map[string]string
+		wantStatus   int
+		wantKnockout string
+	}{
+		{"ec H", map[string]string{"ec": "H", "logoAuto": "true"}, http.StatusOK, "11x11"},
+		{"ec L", map[string]string{"logoAuto": "true"}, http.StatusOK, "3x3"},
+		{"logo forces ec H", map[string]string{"logo": logoServer.URL + "/logo.png", "logoAuto": "true"}, http.StatusOK, "11x11"},
+		{"disabled", map[string]string{"ec": "H", "logoAuto": "false"}, http.StatusOK, ""},
+		{"invalid", map[string]string{"logoAuto": "maybe"}, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/qrcode", ts.URL).
+				Query("content", "https://github.com/whitekid/qrcodeapi").
+				Queries(tt.query).
+				Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			require.Equal(t, tt.wantKnockout, resp.Header.Get("X-QR-Knockout"))
+			if !resp.Success() {
+				return
+			}
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, "https://github.com/whitekid/qrcodeapi", got)
+		})
+	}
+}
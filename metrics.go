@@ -0,0 +1,118 @@
+package qrcodeapi
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/whitekid/goxp/fx"
+)
+
+const (
+	metricsPath = "/metrics"
+
+	// context keys filled by handlers for metrics labels
+	ctxKeyPayloadKind       = "qrcodeapi.payloadKind"
+	ctxKeyFormat            = "qrcodeapi.format"
+	ctxKeyEncodeDuration    = "qrcodeapi.encodeDuration"
+	ctxKeySerializeDuration = "qrcodeapi.serializeDuration"
+
+	labelOther = "other"
+)
+
+const (
+	kindText    = "text"
+	kindURL     = "url"
+	kindWifi    = "wifi"
+	kindContact = "contact"
+	kindVCard   = "vcard"
+	kindVEvent  = "vevent"
+	kindEmail   = "email"
+	kindTel     = "tel"
+	kindToken   = "token"
+)
+
+var payloadKinds = []string{kindText, kindURL, kindWifi, kindContact, kindVCard, kindVEvent, kindEmail, kindTel, kindToken}
+
+type metrics struct {
+	registry          *prometheus.Registry
+	requests          *prometheus.CounterVec
+	encodeDuration    *prometheus.HistogramVec
+	serializeDuration *prometheus.HistogramVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "qrcodeapi_requests_total",
+			Help: "number of requests by payload kind, image format and status code",
+		}, []string{"kind", "format", "code"}),
+		encodeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "qrcodeapi_encode_duration_seconds",
+			Help:    "qrcode encoding time by payload kind",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 4, 8),
+		}, []string{"kind"}),
+		serializeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "qrcodeapi_serialize_duration_seconds",
+			Help:    "image serializing time by image format",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 4, 8),
+		}, []string{"format"}),
+	}
+
+	m.registry.MustRegister(m.requests, m.encodeDuration, m.serializeDuration)
+	return m
+}
+
+func (m *metrics) handler() echo.HandlerFunc {
+	return echo.WrapHandler(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+}
+
+func (m *metrics) middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Path() == metricsPath {
+				return next(c)
+			}
+
+			err := next(c)
+
+			code := c.Response().Status
+			if err != nil {
+				code = http.StatusInternalServerError
+				if he, ok := err.(*echo.HTTPError); ok {
+					code = he.Code
+				}
+			}
+
+			kind := labelValue(c.Get(ctxKeyPayloadKind), payloadKinds)
+			format := labelValue(c.Get(ctxKeyFormat), formatNames())
+			m.requests.WithLabelValues(kind, format, strconv.Itoa(code)).Inc()
+
+			if d, ok := c.Get(ctxKeyEncodeDuration).(time.Duration); ok {
+				m.encodeDuration.WithLabelValues(kind).Observe(d.Seconds())
+			}
+
+			if d, ok := c.Get(ctxKeySerializeDuration).(time.Duration); ok {
+				m.serializeDuration.WithLabelValues(format).Observe(d.Seconds())
+			}
+
+			return err
+		}
+	}
+}
+
+// labelValue returns value if it is allowed, "other" if not to keep cardinality bounded
+func labelValue(value interface{}, allowed []string) string {
+	if s, ok := value.(string); ok && fx.Contains(allowed, s) {
+		return s
+	}
+	return labelOther
+}
+
+func formatNames() []string {
+	return fx.Map(imageFormats, func(f *imageFormat) string { return f.name })
+}
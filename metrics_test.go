@@ -0,0 +1,98 @@
+package qrcodeapi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+)
+
+func TestMetrics(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	requests := []*request.Request{
+		request.Get("%s/qrcode", ts.URL).Query("content", "hello"),
+		request.Get("%s/qrcode", ts.URL).Query("content", "hello").Query("t", "gif"),
+		request.Get("%s/qrcode", ts.URL).Query("url", "github.com").Query("t", "jpg"),
+		request.Get("%s/qrcode", ts.URL).Query("url", "github.com").Query("t", "jpg"),
+		request.Get("%s/qrcode", ts.URL).Query("ssid", "myssid").Query("auth", "WPA"),
+		request.Get("%s/qrcode", ts.URL).Query("ssid", "myssid"), // missing auth
+		request.Get("%s/contact", ts.URL).Query("name[first]", "first"),
+		request.Get("%s/unknown", ts.URL),
+	}
+	for _, req := range requests {
+		_, err := req.Do(ctx)
+		require.NoError(t, err)
+	}
+
+	resp, err := request.Get("%s%s", ts.URL, metricsPath).Do(ctx)
+	require.NoError(t, err)
+	require.True(t, resp.Success())
+	defer resp.Body.Close()
+
+	families, err := (&expfmt.TextParser{}).TextToMetricFamilies(resp.Body)
+	require.NoError(t, err)
+
+	counter := func(kind, format, code string) float64 {
+		for _, m := range families["qrcodeapi_requests_total"].GetMetric() {
+			labels := labelsMap(m)
+			if labels["kind"] == kind && labels["format"] == format && labels["code"] == code {
+				return m.GetCounter().GetValue()
+			}
+		}
+		return 0
+	}
+
+	tests := [...]struct {
+		kind, format, code string
+		want               float64
+	}{
+		{"text", "png", "200", 1},
+		{"text", "gif", "200", 1},
+		{"url", "jpeg", "200", 2},
+		{"wifi", "png", "200", 1},
+		{"wifi", "other", "400", 1},
+		{"contact", "png", "200", 1},
+		{"other", "other", "404", 1},
+	}
+	for _, tt := range tests {
+		require.Equalf(t, tt.want, counter(tt.kind, tt.format, tt.code), "kind=%s, format=%s, code=%s", tt.kind, tt.format, tt.code)
+	}
+
+	histogramCount := func(name, label, value string) uint64 {
+		for _, m := range families[name].GetMetric() {
+			if labelsMap(m)[label] == value {
+				return m.GetHistogram().GetSampleCount()
+			}
+		}
+		return 0
+	}
+	require.Equal(t, uint64(2), histogramCount("qrcodeapi_encode_duration_seconds", "kind", "text"))
+	require.Equal(t, uint64(2), histogramCount("qrcodeapi_encode_duration_seconds", "kind", "url"))
+	require.Equal(t, uint64(2), histogramCount("qrcodeapi_serialize_duration_seconds", "format", "jpeg"))
+	require.Equal(t, uint64(3), histogramCount("qrcodeapi_serialize_duration_seconds", "format", "png"))
+}
+
+func labelsMap(m *dto.Metric) map[string]string {
+	labels := map[string]string{}
+	for _, l := range m.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	return labels
+}
+
+func TestLabelValue(t *testing.T) {
+	require.Equal(t, "wifi", labelValue("wifi", payloadKinds))
+	require.Equal(t, labelOther, labelValue("unknown", payloadKinds))
+	require.Equal(t, labelOther, labelValue(nil, payloadKinds))
+}
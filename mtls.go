@@ -0,0 +1,98 @@
+package qrcodeapi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"qrcodeapi/config"
+)
+
+// mtlsListener returns tls listener of mtls_bind_addr which requires client certificate verified by mtls_client_ca;
+// connections without valid client certificate fail the handshake.
+func mtlsListener(cfg *config.Config) (net.Listener, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.MTLSCertFile, cfg.MTLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle, err := os.ReadFile(cfg.MTLSClientCA)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, errors.New("no certificate in " + cfg.MTLSClientCA)
+	}
+
+	ln, err := net.Listen("tcp", cfg.MTLSBindAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	return tls.NewListener(ln, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+		MinVersion:   tls.VersionTLS12,
+	}), nil
+}
+
+// certPrincipalPrefix prefix of principals authenticated by client certificate
+const certPrincipalPrefix = "cert:"
+
+// certPrincipal set principal of verified client certificate, used for rate limit and audit log as api keys.
+// principal is mapped by mtls_principals with CN or SAN of the certificate, `cert:<CN>` if not mapped.
+func certPrincipal(cfg *config.Config) echo.MiddlewareFunc {
+	principals := map[string]string{}
+	for name, p := range cfg.MTLSPrincipals {
+		// keys of config are case insensitive
+		principals[strings.ToLower(name)] = p
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			state := c.Request().TLS
+			if state == nil || len(state.VerifiedChains) == 0 {
+				return next(c)
+			}
+
+			cert := state.VerifiedChains[0][0]
+			principal := cert.Subject.CommonName
+			for _, name := range certNames(cert) {
+				if p, ok := principals[strings.ToLower(name)]; ok {
+					principal = p
+					break
+				}
+			}
+
+			c.Set(ctxKeyPrincipal, certPrincipalPrefix+principal)
+			return next(c)
+		}
+	}
+}
+
+// certNames returns CN and SANs of the certificate
+func certNames(cert *x509.Certificate) []string {
+	names := []string{cert.Subject.CommonName}
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	return names
+}
+
+// certAuthenticated returns true if the request is authenticated by client certificate
+func certAuthenticated(c echo.Context) bool {
+	return strings.HasPrefix(principal(c), certPrincipalPrefix)
+}
@@ -0,0 +1,186 @@
+package qrcodeapi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/links"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+// newTestCert create certificate signed by the parent, self-signed if parent is nil
+func newTestCert(t *testing.T, parent *testCert, template *x509.Certificate) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template.SerialNumber = serial
+	if template.NotBefore.IsZero() {
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().Add(time.Hour)
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCert{cert: cert, key: key}
+}
+
+func newTestCA(t *testing.T, name string) *testCert {
+	return newTestCert(t, nil, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+}
+
+func newClientCert(t *testing.T, ca *testCert, cn string, dnsNames []string, notAfter time.Time) *testCert {
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: cn},
+		DNSNames:    dnsNames,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+	}
+	if !notAfter.IsZero() {
+		template.NotBefore, template.NotAfter = notAfter.Add(-time.Hour), notAfter
+	}
+	return newTestCert(t, ca, template)
+}
+
+func writePEM(t *testing.T, file, typ string, der []byte) {
+	require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600))
+}
+
+func TestMTLS(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	ca := newTestCA(t, "test ca")
+	server := newTestCert(t, ca, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "qrcodeapi"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+	})
+	serverKey, err := x509.MarshalECPrivateKey(server.key)
+	require.NoError(t, err)
+
+	cfg := config.Current()
+	cfg.MTLSBindAddr = "127.0.0.1:0"
+	cfg.MTLSCertFile = filepath.Join(dir, "server.pem")
+	cfg.MTLSKeyFile = filepath.Join(dir, "server.key")
+	cfg.MTLSClientCA = filepath.Join(dir, "ca.pem")
+	cfg.MTLSPrincipals = map[string]string{"dashboard.internal": "dashboard"}
+	writePEM(t, cfg.MTLSCertFile, "CERTIFICATE", server.cert.Raw)
+	writePEM(t, cfg.MTLSKeyFile, "EC PRIVATE KEY", serverKey)
+	writePEM(t, cfg.MTLSClientCA, "CERTIFICATE", ca.cert.Raw)
+
+	e := newEcho(cfg, os.Stdout)
+	newLinksAPI(ctx, config.Current(), links.NewMemoryStore()).Route(e, "")
+	e.GET("/whoami", func(c echo.Context) error { return c.String(http.StatusOK, principal(c)) })
+
+	ln, err := mtlsListener(cfg)
+	require.NoError(t, err)
+	go http.Serve(ln, e)
+	defer ln.Close()
+
+	// plain listener is not affected
+	plain := httptest.NewServer(e)
+	defer plain.Close()
+
+	get := func(baseURL, path string, cert *testCert) (int, string, error) {
+		transport := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: x509.NewCertPool()}}
+		transport.TLSClientConfig.RootCAs.AddCert(ca.cert)
+		if cert != nil {
+			transport.TLSClientConfig.Certificates = []tls.Certificate{cert.tlsCertificate()}
+		}
+		defer transport.CloseIdleConnections()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+path, nil)
+		require.NoError(t, err)
+		resp, err := (&http.Client{Transport: transport}).Do(req)
+		if err != nil {
+			return 0, "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body), nil
+	}
+
+	mtlsURL := "https://" + ln.Addr().String()
+	tests := [...]struct {
+		name          string
+		cert          *testCert
+		wantErr       bool
+		wantPrincipal string
+	}{
+		{"mapped by san", newClientCert(t, ca, "service-a", []string{"dashboard.internal"}, time.Time{}), false, "cert:dashboard"},
+		{"common name", newClientCert(t, ca, "service-b", nil, time.Time{}), false, "cert:service-b"},
+		{"wrong ca", newClientCert(t, newTestCA(t, "other ca"), "service-a", nil, time.Time{}), true, ""},
+		{"expired", newClientCert(t, ca, "service-a", nil, time.Now().Add(-time.Minute)), true, ""},
+		{"no certificate", nil, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body, err := get(mtlsURL, "/whoami", tt.cert)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, status)
+			require.Equal(t, tt.wantPrincipal, body)
+
+			// client certificate is accepted instead of api key
+			status, _, err = get(mtlsURL, "/links", tt.cert)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, status)
+		})
+	}
+
+	status, body, err := get(plain.URL, "/whoami", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "anonymous", body)
+
+	status, _, err = get(plain.URL, "/links", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, status)
+}
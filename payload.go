@@ -0,0 +1,16 @@
+package qrcodeapi
+
+import (
+	"qrcodeapi/pkg/qrcode"
+)
+
+// wifiPayload build wifi join payload; shared by `GET /wifi`, legacy `GET /qrcode?ssid=` and json document
+func wifiPayload(wifi *WifiDocument) (*qrcode.QR, error) {
+	return qrcode.WIFI(wifi.SSID,
+		qrcode.StrToWifiAuth(wifi.Auth), wifi.Pass, wifi.Hidden,
+		qrcode.WPA2Options{
+			EAPMethod:         wifi.EAP,
+			AnonymousIdentity: wifi.AnonID,
+			Identity:          wifi.Ident,
+			Phase2Method:      wifi.PH2})
+}
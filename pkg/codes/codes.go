@@ -0,0 +1,84 @@
+// Package codes provides persisted qrcodes; canonical request parameters are stored to render code again by ID.
+package codes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+var ErrNotFound = errors.New("code not found")
+
+// idLength length of code id in hex digits, 64 bits of sha-256
+const idLength = 16
+
+// Code persisted qrcode
+type Code struct {
+	ID        string            `json:"id"`
+	Kind      string            `json:"kind"`    // payload kind
+	Content   string            `json:"content"` // payload encoded into qrcode
+	Params    map[string]string `json:"params"`  // canonical render parameters
+	CreatedAt time.Time         `json:"created_at"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// New create new code with content-addressed id which expires after ttl
+func New(kind, content string, params map[string]string, ttl time.Duration) *Code {
+	now := time.Now().UTC()
+	return &Code{
+		ID:        ID(content, params),
+		Kind:      kind,
+		Content:   content,
+		Params:    params,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+}
+
+// Canonical returns canonical form of the code; content and params are form-urlencoded and sorted by key
+// as url.Values.Encode() does, ex) "content=hello+world&h=200&t=png&w=200".
+// params should be normalized by the caller, including default values, so that equal requests have equal form.
+func Canonical(content string, params map[string]string) string {
+	values := url.Values{}
+	for k, v := range params {
+		values.Set(k, v)
+	}
+	values.Set("content", content)
+
+	return values.Encode()
+}
+
+// ID returns content-addressed id, the first 16 hex digits of sha-256 of the canonical form
+func ID(content string, params map[string]string) string {
+	sum := sha256.Sum256([]byte(Canonical(content, params)))
+	return hex.EncodeToString(sum[:])[:idLength]
+}
+
+// Expired returns true if the code is expired
+func (c *Code) Expired() bool { return time.Now().After(c.ExpiresAt) }
+
+// Store code storage
+type Store interface {
+	Put(ctx context.Context, code *Code) error // create or replace the code
+	Get(ctx context.Context, id string) (*Code, error)
+
+	// Ping check the store can be read and written, for readiness check
+	Ping(ctx context.Context) error
+}
+
+// NewStore create code store
+// typ: memory, file
+func NewStore(typ string, path string) (Store, error) {
+	switch typ {
+	case "memory", "":
+		return NewMemoryStore(), nil
+	case "file":
+		return NewFileStore(path)
+	}
+
+	return nil, fmt.Errorf("unsupported code store: %s", typ)
+}
@@ -0,0 +1,57 @@
+package codes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+
+	fileStore, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"file":   fileStore,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			code := New("text", "hello world", map[string]string{"w": "200"}, time.Hour)
+			require.NoError(t, store.Put(ctx, code))
+			require.NoError(t, store.Put(ctx, code))
+
+			got, err := store.Get(ctx, code.ID)
+			require.NoError(t, err)
+			require.Equal(t, code.Content, got.Content)
+			require.Equal(t, code.Params, got.Params)
+			require.False(t, got.Expired())
+
+			_, err = store.Get(ctx, "unknown")
+			require.ErrorIs(t, err, ErrNotFound)
+
+			_, err = store.Get(ctx, "../unknown")
+			require.ErrorIs(t, err, ErrNotFound)
+
+			require.NoError(t, store.Ping(ctx))
+		})
+	}
+}
+
+func TestExpired(t *testing.T) {
+	require.True(t, New("text", "hello", nil, -time.Second).Expired())
+	require.False(t, New("text", "hello", nil, time.Second).Expired())
+}
+
+func TestID(t *testing.T) {
+	params := map[string]string{"w": "200", "h": "200", "t": "png"}
+
+	require.Equal(t, "content=hello+world&h=200&t=png&w=200", Canonical("hello world", params))
+	require.Len(t, ID("hello world", params), 16)
+	require.Equal(t, ID("hello world", params), ID("hello world", map[string]string{"t": "png", "h": "200", "w": "200"}))
+	require.NotEqual(t, ID("hello world", params), ID("hello world", map[string]string{"w": "100", "h": "200", "t": "png"}))
+	require.NotEqual(t, ID("hello world", params), ID("hello", params))
+}
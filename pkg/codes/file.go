@@ -0,0 +1,75 @@
+package codes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileStore stores each code as json file in a directory
+type fileStore struct {
+	dir string
+}
+
+var _ Store = (*fileStore)(nil)
+
+// NewFileStore create code store persisted in the directory
+func NewFileStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &fileStore{dir: dir}, nil
+}
+
+func (s *fileStore) filename(id string) string { return filepath.Join(s.dir, id+".json") }
+
+func (s *fileStore) Put(ctx context.Context, code *Code) error {
+	data, err := json.Marshal(code)
+	if err != nil {
+		return err
+	}
+
+	// write to temporary file and rename so that readers never see partial file
+	tmp := s.filename(code.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.filename(code.ID))
+}
+
+func (s *fileStore) Get(ctx context.Context, id string) (*Code, error) {
+	// id comes from the request path
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return nil, ErrNotFound
+	}
+
+	data, err := os.ReadFile(s.filename(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	code := &Code{}
+	if err := json.Unmarshal(data, code); err != nil {
+		return nil, err
+	}
+
+	return code, nil
+}
+
+// Ping create and remove a temporary file in the directory
+func (s *fileStore) Ping(ctx context.Context) error {
+	f, err := os.CreateTemp(s.dir, ".ping-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
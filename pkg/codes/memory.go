@@ -0,0 +1,42 @@
+package codes
+
+import (
+	"context"
+	"sync"
+)
+
+type memoryStore struct {
+	mu    sync.RWMutex
+	codes map[string]*Code
+}
+
+var _ Store = (*memoryStore)(nil)
+
+// NewMemoryStore create in-memory code store
+func NewMemoryStore() Store {
+	return &memoryStore{codes: map[string]*Code{}}
+}
+
+func (s *memoryStore) Put(ctx context.Context, code *Code) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v := *code
+	s.codes[code.ID] = &v
+	return nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, id string) (*Code, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	code, ok := s.codes[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	v := *code
+	return &v, nil
+}
+
+func (s *memoryStore) Ping(ctx context.Context) error { return nil }
@@ -0,0 +1,43 @@
+// Package formats names of output formats, shared by config validation and the renderer
+package formats
+
+import (
+	"strings"
+
+	"github.com/whitekid/goxp/fx"
+)
+
+// format names
+const (
+	PNG      = "png"
+	JPEG     = "jpeg"
+	GIF      = "gif"
+	SVG      = "svg"
+	TXT      = "txt"
+	Markdown = "markdown"
+	ImgTag   = "imgtag"
+	PDF      = "pdf"
+	ICO      = "ico"
+)
+
+var (
+	// Names of output formats, in the order of listing
+	Names = []string{PNG, JPEG, GIF, SVG, TXT, Markdown, ImgTag, PDF, ICO}
+
+	// Aliases format names of the aliases
+	Aliases = map[string]string{
+		"jpg": JPEG,
+	}
+)
+
+// Normalize returns lower-cased format name of the name or the alias
+func Normalize(name string) string {
+	name = strings.ToLower(name)
+	if alias, ok := Aliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// Valid returns true if the name is a format name or an alias
+func Valid(name string) bool { return fx.Contains(Names, Normalize(name)) }
@@ -0,0 +1,29 @@
+package formats
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := [...]struct {
+		name      string
+		wantName  string
+		wantValid bool
+	}{
+		{"png", "png", true},
+		{"PNG", "png", true},
+		{"jpg", "jpeg", true},
+		{"JPG", "jpeg", true},
+		{"imgtag", "imgtag", true},
+		{"bmp", "bmp", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.wantName, Normalize(tt.name))
+			require.Equal(t, tt.wantValid, Valid(tt.name))
+		})
+	}
+}
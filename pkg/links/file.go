@@ -0,0 +1,191 @@
+package links
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// fileStore stores each link as json file in a directory, stats are stored in <id>.stats file
+type fileStore struct {
+	mu  sync.RWMutex
+	dir string
+}
+
+var _ Store = (*fileStore)(nil)
+
+// NewFileStore create link store persisted in the directory
+func NewFileStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &fileStore{dir: dir}, nil
+}
+
+func (s *fileStore) filename(id string) string      { return filepath.Join(s.dir, id+".json") }
+func (s *fileStore) statsFilename(id string) string { return filepath.Join(s.dir, id+".stats") }
+
+func (s *fileStore) Create(ctx context.Context, link *Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.filename(link.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return ErrExists
+		}
+		return err
+	}
+	defer f.Close()
+
+	return json.NewEncoder(f).Encode(link)
+}
+
+func (s *fileStore) Update(ctx context.Context, link *Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.read(link.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := nextVersion(stored, link); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(s.filename(link.ID), data)
+}
+
+// writeFile write to temp file and rename to keep the file consistent
+func writeFile(name string, data []byte) error {
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, name)
+}
+
+func (s *fileStore) Get(ctx context.Context, id string) (*Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.read(id)
+}
+
+func (s *fileStore) read(id string) (*Link, error) {
+	// id comes from the request path
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return nil, ErrNotFound
+	}
+
+	data, err := os.ReadFile(s.filename(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	link := &Link{}
+	if err := json.Unmarshal(data, link); err != nil {
+		return nil, err
+	}
+
+	return link, nil
+}
+
+func (s *fileStore) List(ctx context.Context) ([]*Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	links := []*Link{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		link, err := s.read(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	sortLinks(links)
+
+	return links, nil
+}
+
+func (s *fileStore) AddStats(ctx context.Context, id string, delta *Stats) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, err := s.readStats(id)
+	if err != nil {
+		return err
+	}
+	stats.Merge(delta)
+
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(s.statsFilename(id), data)
+}
+
+func (s *fileStore) GetStats(ctx context.Context, id string) (*Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.readStats(id)
+}
+
+func (s *fileStore) readStats(id string) (*Stats, error) {
+	if _, err := s.read(id); err != nil {
+		return nil, err
+	}
+
+	stats := NewStats()
+	data, err := os.ReadFile(s.statsFilename(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return stats, nil
+		}
+		return nil, err
+	}
+
+	v := NewStats()
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	stats.Merge(v)
+
+	return stats, nil
+}
+
+// Ping create and remove a temporary file in the directory
+func (s *fileStore) Ping(ctx context.Context) error {
+	f, err := os.CreateTemp(s.dir, ".ping-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,115 @@
+// Package links provides dynamic qrcode links; qrcode encodes redirect url and target can be changed later.
+package links
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("link not found")
+	ErrExists   = errors.New("link already exists")
+	ErrConflict = errors.New("link version conflict")
+)
+
+const idLetters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// Link redirect link
+type Link struct {
+	ID            string     `json:"id"`
+	Target        string     `json:"target"`
+	Version       int        `json:"version"` // increased on every update, for optimistic concurrency
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+}
+
+// Active returns true if link is not deactivated
+func (l *Link) Active() bool { return l.DeactivatedAt == nil }
+
+// Deactivate mark link deactivated
+func (l *Link) Deactivate() {
+	now := time.Now().UTC()
+	l.DeactivatedAt = &now
+}
+
+// Store link storage
+type Store interface {
+	Create(ctx context.Context, link *Link) error
+	Get(ctx context.Context, id string) (*Link, error)
+	List(ctx context.Context) ([]*Link, error)
+
+	// Update replace the link if version of the link equals to stored one, or returns ErrConflict.
+	// version of the link is increased on success.
+	Update(ctx context.Context, link *Link) error
+
+	AddStats(ctx context.Context, id string, delta *Stats) error
+	GetStats(ctx context.Context, id string) (*Stats, error)
+
+	// Ping check the store can be read and written, for readiness check
+	Ping(ctx context.Context) error
+}
+
+// New create new link with random id
+func New(target string) *Link {
+	now := time.Now().UTC()
+	return &Link{
+		ID:        randomID(8),
+		Target:    target,
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// randomID returns id of n letters of crypto/rand, as the id of the link is not to be guessed
+func randomID(n int) string {
+	max := big.NewInt(int64(len(idLetters)))
+	id := make([]byte, n)
+	for i := range id {
+		c, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err) // the system random source is not available
+		}
+		id[i] = idLetters[c.Int64()]
+	}
+	return string(id)
+}
+
+// Shortened create link of shortened url; id is derived from the target so that the same url is shortened to the same link
+func Shortened(target string) *Link {
+	link := New(target)
+	sum := sha256.Sum256([]byte(target))
+	link.ID = "s" + hex.EncodeToString(sum[:])[:11]
+	return link
+}
+
+// nextVersion check version and increase it for update
+func nextVersion(stored, link *Link) error {
+	if stored.Version != link.Version {
+		return ErrConflict
+	}
+
+	link.Version++
+	link.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+// NewStore create link store
+// typ: memory, file
+func NewStore(typ string, path string) (Store, error) {
+	switch typ {
+	case "memory", "":
+		return NewMemoryStore(), nil
+	case "file":
+		return NewFileStore(path)
+	}
+
+	return nil, fmt.Errorf("unsupported link store: %s", typ)
+}
@@ -0,0 +1,129 @@
+package links
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStores(t *testing.T) map[string]Store {
+	fileStore, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+
+	return map[string]Store{
+		"memory": NewMemoryStore(),
+		"file":   fileStore,
+	}
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			link := New("https://example.com/menu")
+			require.Len(t, link.ID, 8)
+			require.NoError(t, store.Create(ctx, link))
+			require.ErrorIs(t, store.Create(ctx, link), ErrExists)
+
+			got, err := store.Get(ctx, link.ID)
+			require.NoError(t, err)
+			require.Equal(t, link.Target, got.Target)
+			require.True(t, link.CreatedAt.Equal(got.CreatedAt))
+
+			_, err = store.Get(ctx, "unknown")
+			require.ErrorIs(t, err, ErrNotFound)
+
+			_, err = store.Get(ctx, "../unknown")
+			require.ErrorIs(t, err, ErrNotFound)
+
+			link2 := New("https://example.com/menu2")
+			require.NoError(t, store.Create(ctx, link2))
+
+			links, err := store.List(ctx)
+			require.NoError(t, err)
+			require.Len(t, links, 2)
+			require.Equal(t, link.ID, links[0].ID)
+			require.Equal(t, link2.ID, links[1].ID)
+		})
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	_, err := NewStore("memory", "")
+	require.NoError(t, err)
+
+	_, err = NewStore("file", t.TempDir())
+	require.NoError(t, err)
+
+	_, err = NewStore("redis", "")
+	require.Error(t, err)
+}
+
+func TestStoreUpdate(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			link := New("https://example.com/menu")
+			require.NoError(t, store.Create(ctx, link))
+
+			editor1, err := store.Get(ctx, link.ID)
+			require.NoError(t, err)
+			editor2, err := store.Get(ctx, link.ID)
+			require.NoError(t, err)
+
+			editor1.Target = "https://example.com/menu1"
+			require.NoError(t, store.Update(ctx, editor1))
+			require.Equal(t, 2, editor1.Version)
+
+			editor2.Target = "https://example.com/menu2"
+			require.ErrorIs(t, store.Update(ctx, editor2), ErrConflict)
+
+			got, err := store.Get(ctx, link.ID)
+			require.NoError(t, err)
+			require.Equal(t, "https://example.com/menu1", got.Target)
+			require.True(t, got.Active())
+
+			got.Deactivate()
+			require.NoError(t, store.Update(ctx, got))
+			got, err = store.Get(ctx, link.ID)
+			require.NoError(t, err)
+			require.False(t, got.Active())
+			require.Equal(t, 3, got.Version)
+
+			require.ErrorIs(t, store.Update(ctx, New("https://example.com")), ErrNotFound)
+		})
+	}
+}
+
+func TestStorePing(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, store.Ping(ctx))
+		})
+	}
+
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+	require.NoError(t, os.RemoveAll(dir))
+	require.Error(t, store.Ping(ctx))
+}
+
+func TestRandomID(t *testing.T) {
+	ids := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		id := New("https://example.com").ID
+		require.Len(t, id, 8)
+		for _, c := range id {
+			require.Contains(t, idLetters, string(c))
+		}
+		require.False(t, ids[id], "duplicated id: %s", id)
+		ids[id] = true
+	}
+}
@@ -0,0 +1,123 @@
+package links
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+type memoryStore struct {
+	mu    sync.RWMutex
+	links map[string]*Link
+	stats map[string]*Stats
+}
+
+var _ Store = (*memoryStore)(nil)
+
+// NewMemoryStore create in-memory link store
+func NewMemoryStore() Store {
+	return &memoryStore{links: map[string]*Link{}, stats: map[string]*Stats{}}
+}
+
+func (s *memoryStore) Create(ctx context.Context, link *Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.links[link.ID]; ok {
+		return ErrExists
+	}
+
+	v := *link
+	s.links[link.ID] = &v
+	return nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, id string) (*Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	link, ok := s.links[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	v := *link
+	return &v, nil
+}
+
+func (s *memoryStore) Update(ctx context.Context, link *Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.links[link.ID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if err := nextVersion(stored, link); err != nil {
+		return err
+	}
+
+	v := *link
+	s.links[link.ID] = &v
+	return nil
+}
+
+func (s *memoryStore) List(ctx context.Context) ([]*Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	links := make([]*Link, 0, len(s.links))
+	for _, link := range s.links {
+		v := *link
+		links = append(links, &v)
+	}
+	sortLinks(links)
+
+	return links, nil
+}
+
+func sortLinks(links []*Link) {
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].CreatedAt.Equal(links[j].CreatedAt) {
+			return links[i].ID < links[j].ID
+		}
+		return links[i].CreatedAt.Before(links[j].CreatedAt)
+	})
+}
+
+func (s *memoryStore) AddStats(ctx context.Context, id string, delta *Stats) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.links[id]; !ok {
+		return ErrNotFound
+	}
+
+	stats, ok := s.stats[id]
+	if !ok {
+		stats = NewStats()
+		s.stats[id] = stats
+	}
+	stats.Merge(delta)
+
+	return nil
+}
+
+func (s *memoryStore) GetStats(ctx context.Context, id string) (*Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.links[id]; !ok {
+		return nil, ErrNotFound
+	}
+
+	stats := NewStats()
+	if v, ok := s.stats[id]; ok {
+		stats.Merge(v)
+	}
+
+	return stats, nil
+}
+
+func (s *memoryStore) Ping(ctx context.Context) error { return nil }
@@ -0,0 +1,111 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/whitekid/goxp/log"
+)
+
+// Recorder aggregates scans in background and writes them to the store periodically,
+// so that slow or failing store never blocks redirects.
+type Recorder struct {
+	store         Store
+	flushInterval time.Duration
+	scans         chan *Scan
+
+	mu      sync.Mutex
+	pending map[string]*Stats // aggregated but not written yet
+}
+
+// NewRecorder create scan recorder; call Run() to start background aggregation.
+func NewRecorder(store Store, flushInterval time.Duration) *Recorder {
+	return &Recorder{
+		store:         store,
+		flushInterval: flushInterval,
+		scans:         make(chan *Scan, 1024),
+		pending:       map[string]*Stats{},
+	}
+}
+
+// Record queue the scan; scan is dropped if the queue is full.
+func (r *Recorder) Record(scan *Scan) bool {
+	select {
+	case r.scans <- scan:
+		return true
+	default:
+		log.Warnf("scan queue full, scan dropped: link=%s", scan.LinkID)
+		return false
+	}
+}
+
+// Run aggregate scans until context is done
+func (r *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.drain()
+			r.Flush(context.Background())
+			return
+
+		case scan := <-r.scans:
+			r.aggregate(scan)
+
+		case <-ticker.C:
+			r.Flush(ctx)
+		}
+	}
+}
+
+func (r *Recorder) drain() {
+	for {
+		select {
+		case scan := <-r.scans:
+			r.aggregate(scan)
+		default:
+			return
+		}
+	}
+}
+
+func (r *Recorder) aggregate(scan *Scan) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats, ok := r.pending[scan.LinkID]
+	if !ok {
+		stats = NewStats()
+		r.pending[scan.LinkID] = stats
+	}
+	stats.add(scan)
+}
+
+// Flush write aggregated stats to the store; failed ones are kept for next flush.
+func (r *Recorder) Flush(ctx context.Context) {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = map[string]*Stats{}
+	r.mu.Unlock()
+
+	for id, stats := range pending {
+		if err := r.store.AddStats(ctx, id, stats); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+
+			log.Errorf("fail to write stats: link=%s, %+v", id, err)
+
+			r.mu.Lock()
+			if s, ok := r.pending[id]; ok {
+				stats.Merge(s)
+			}
+			r.pending[id] = stats
+			r.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package links
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	DeviceMobile  = "mobile"
+	DeviceDesktop = "desktop"
+	DeviceOther   = "other"
+)
+
+// Stats scan statistics of a link
+type Stats struct {
+	Scans     int64            `json:"scans"`
+	Daily     map[string]int64 `json:"daily"`   // scans by day, YYYY-MM-DD in UTC
+	Devices   map[string]int64 `json:"devices"` // scans by device class
+	Countries map[string]int64 `json:"countries,omitempty"`
+}
+
+func NewStats() *Stats {
+	return &Stats{
+		Daily:     map[string]int64{},
+		Devices:   map[string]int64{},
+		Countries: map[string]int64{},
+	}
+}
+
+// Scan a scan of the link; ip address is not kept for privacy
+type Scan struct {
+	LinkID  string
+	Time    time.Time
+	Device  string
+	Country string
+}
+
+func (s *Stats) add(scan *Scan) {
+	s.Scans++
+	s.Daily[scan.Time.UTC().Format("2006-01-02")]++
+	s.Devices[scan.Device]++
+	if scan.Country != "" {
+		s.Countries[scan.Country]++
+	}
+}
+
+// Merge add counts of other stats
+func (s *Stats) Merge(o *Stats) {
+	s.Scans += o.Scans
+	mergeCounts(s.Daily, o.Daily)
+	mergeCounts(s.Devices, o.Devices)
+	mergeCounts(s.Countries, o.Countries)
+}
+
+func mergeCounts(dst, src map[string]int64) {
+	for k, v := range src {
+		dst[k] += v
+	}
+}
+
+// DeviceClass classify user agent coarsely
+func DeviceClass(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case ua == "" || strings.Contains(ua, "bot") || strings.Contains(ua, "spider") || strings.Contains(ua, "crawl"):
+		return DeviceOther
+	case strings.Contains(ua, "mobi") || strings.Contains(ua, "android") || strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
+		return DeviceMobile
+	default:
+		return DeviceDesktop
+	}
+}
@@ -0,0 +1,100 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			link := New("https://example.com")
+			require.NoError(t, store.Create(ctx, link))
+
+			stats, err := store.GetStats(ctx, link.ID)
+			require.NoError(t, err)
+			require.Equal(t, int64(0), stats.Scans)
+
+			delta := NewStats()
+			delta.add(&Scan{LinkID: link.ID, Time: time.Date(2022, 11, 1, 23, 0, 0, 0, time.UTC), Device: DeviceMobile, Country: "KR"})
+			delta.add(&Scan{LinkID: link.ID, Time: time.Date(2022, 11, 2, 1, 0, 0, 0, time.UTC), Device: DeviceDesktop})
+			require.NoError(t, store.AddStats(ctx, link.ID, delta))
+			require.NoError(t, store.AddStats(ctx, link.ID, delta))
+
+			stats, err = store.GetStats(ctx, link.ID)
+			require.NoError(t, err)
+			require.Equal(t, int64(4), stats.Scans)
+			require.Equal(t, map[string]int64{"2022-11-01": 2, "2022-11-02": 2}, stats.Daily)
+			require.Equal(t, map[string]int64{DeviceMobile: 2, DeviceDesktop: 2}, stats.Devices)
+			require.Equal(t, map[string]int64{"KR": 2}, stats.Countries)
+
+			require.ErrorIs(t, store.AddStats(ctx, "unknown", delta), ErrNotFound)
+			_, err = store.GetStats(ctx, "unknown")
+			require.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+// failingStore fails AddStats while fail is set
+type failingStore struct {
+	Store
+	fail bool
+}
+
+func (s *failingStore) AddStats(ctx context.Context, id string, delta *Stats) error {
+	if s.fail {
+		return errors.New("store unavailable")
+	}
+	return s.Store.AddStats(ctx, id, delta)
+}
+
+func TestRecorder(t *testing.T) {
+	ctx := context.Background()
+
+	store := &failingStore{Store: NewMemoryStore(), fail: true}
+	link := New("https://example.com")
+	require.NoError(t, store.Create(ctx, link))
+
+	r := NewRecorder(store, time.Hour)
+	for i := 0; i < 3; i++ {
+		require.True(t, r.Record(&Scan{LinkID: link.ID, Time: time.Now(), Device: DeviceMobile}))
+	}
+	r.Record(&Scan{LinkID: "unknown", Time: time.Now(), Device: DeviceMobile})
+	r.drain()
+
+	// failed stats are kept until store recovered
+	r.Flush(ctx)
+	stats, err := store.GetStats(ctx, link.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), stats.Scans)
+
+	store.fail = false
+	r.Flush(ctx)
+	stats, err = store.GetStats(ctx, link.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), stats.Scans)
+	require.Empty(t, r.pending)
+}
+
+func TestDeviceClass(t *testing.T) {
+	tests := [...]struct {
+		userAgent string
+		want      string
+	}{
+		{"", DeviceOther},
+		{"Googlebot/2.1 (+http://www.google.com/bot.html)", DeviceOther},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 16_0 like Mac OS X) Mobile/15E148", DeviceMobile},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) Safari/605.1.15", DeviceDesktop},
+	}
+	for _, tt := range tests {
+		t.Run(tt.userAgent, func(t *testing.T) {
+			require.Equal(t, tt.want, DeviceClass(tt.userAgent))
+		})
+	}
+}
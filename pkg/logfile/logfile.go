@@ -0,0 +1,244 @@
+// Package logfile writes logs to a file rotated by size
+package logfile
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat timestamp of rotated file name; sortable and distinct for rotations in a row
+const backupTimeFormat = "2006-01-02T15-04-05.000000000"
+
+// Options rotation options
+type Options struct {
+	MaxSize    int64         // rotate when the file exceeds MaxSize bytes, no rotation if 0
+	MaxBackups int           // number of rotated files to keep, all if 0
+	MaxAge     time.Duration // remove rotated files older than MaxAge, kept if 0
+	Compress   bool          // gzip rotated files
+}
+
+// Writer log file writer which rotates the file by size.
+// rotated files are named as `<name>-<timestamp><ext>`, ex) `access-2006-01-02T15-04-05.000000000.log`.
+type Writer struct {
+	filename string
+	opts     Options
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+
+	millMu sync.Mutex // serialize compression and removal of rotated files
+	wg     sync.WaitGroup
+}
+
+var _ io.WriteCloser = (*Writer)(nil)
+
+// New create writer of the file; directory is created if not exists
+func New(filename string, opts Options) (*Writer, error) {
+	w := &Writer{filename: filename, opts: opts}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.filename), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(w.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	w.file, w.size = f, info.Size()
+	return nil
+}
+
+// Write write to the file, rotates the file before if it exceeds max size
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if w.opts.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.opts.MaxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Reopen close and open the file again, for the file moved by external tools such as logrotate
+func (w *Writer) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.close(); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+// Close close the file and wait rotated files are processed
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	err := w.close()
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	return err
+}
+
+func (w *Writer) close() error {
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// rotate rename current file to backup and open new one; backups are compressed and removed in background
+func (w *Writer) rotate() error {
+	if err := w.close(); err != nil {
+		return err
+	}
+
+	backup := w.backupName(time.Now())
+	if err := os.Rename(w.filename, backup); err != nil {
+		return err
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		w.millMu.Lock()
+		defer w.millMu.Unlock()
+
+		if w.opts.Compress {
+			compress(backup)
+		}
+		w.removeBackups()
+	}()
+
+	return nil
+}
+
+func (w *Writer) backupName(t time.Time) string {
+	ext := filepath.Ext(w.filename)
+	return strings.TrimSuffix(w.filename, ext) + "-" + t.Format(backupTimeFormat) + ext
+}
+
+// Backups returns rotated files, newest first
+func (w *Writer) Backups() ([]string, error) {
+	ext := filepath.Ext(w.filename)
+	prefix := strings.TrimSuffix(filepath.Base(w.filename), ext) + "-"
+
+	entries, err := os.ReadDir(filepath.Dir(w.filename))
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []string{}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".gz")
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+
+		ts := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		if _, err := time.Parse(backupTimeFormat, ts); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(filepath.Dir(w.filename), entry.Name()))
+	}
+
+	// timestamp is sortable and has the same length
+	sort.Slice(backups, func(i, j int) bool {
+		return strings.TrimSuffix(backups[i], ".gz") > strings.TrimSuffix(backups[j], ".gz")
+	})
+	return backups, nil
+}
+
+// removeBackups remove backups over max backups or older than max age
+func (w *Writer) removeBackups() {
+	backups, err := w.Backups()
+	if err != nil {
+		return
+	}
+
+	for i, backup := range backups {
+		if w.opts.MaxBackups > 0 && i >= w.opts.MaxBackups {
+			os.Remove(backup)
+			continue
+		}
+
+		if w.opts.MaxAge > 0 {
+			if info, err := os.Stat(backup); err == nil && time.Since(info.ModTime()) > w.opts.MaxAge {
+				os.Remove(backup)
+			}
+		}
+	}
+}
+
+// compress gzip the file and remove original one; original is kept if failed
+func compress(filename string) error {
+	src, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(filename+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		os.Remove(filename + ".gz")
+		return err
+	}
+
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(filename + ".gz")
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		os.Remove(filename + ".gz")
+		return err
+	}
+
+	return os.Remove(filename)
+}
@@ -0,0 +1,102 @@
+package logfile
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeLines write n lines of 100 bytes
+func writeLines(t *testing.T, w io.Writer, n int) {
+	line := strings.Repeat("x", 99) + "\n"
+	for i := 0; i < n; i++ {
+		_, err := w.Write([]byte(line))
+		require.NoError(t, err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	tests := [...]struct {
+		name        string
+		opts        Options
+		lines       int
+		wantBackups int
+		wantGzip    bool
+		wantSize    int64 // size of current file
+	}{
+		{"no rotation", Options{}, 100, 0, false, 10000},
+		{"rotate", Options{MaxSize: 1000}, 55, 5, false, 500},
+		{"max backups", Options{MaxSize: 1000, MaxBackups: 3}, 55, 3, false, 500},
+		{"compress", Options{MaxSize: 1000, MaxBackups: 2, Compress: true}, 55, 2, true, 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "logs", "app.log")
+			w, err := New(filename, tt.opts)
+			require.NoError(t, err)
+
+			writeLines(t, w, tt.lines)
+			require.NoError(t, w.Close())
+
+			backups, err := w.Backups()
+			require.NoError(t, err)
+			require.Len(t, backups, tt.wantBackups)
+
+			for _, backup := range backups {
+				require.Equal(t, tt.wantGzip, strings.HasSuffix(backup, ".gz"))
+
+				var r io.Reader
+				f, err := os.Open(backup)
+				require.NoError(t, err)
+				defer f.Close()
+				r = f
+				if tt.wantGzip {
+					r, err = gzip.NewReader(f)
+					require.NoError(t, err)
+				}
+
+				data, err := io.ReadAll(r)
+				require.NoError(t, err)
+				require.Len(t, data, 1000)
+			}
+
+			info, err := os.Stat(filename)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantSize, info.Size())
+		})
+	}
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.log")
+	w, err := New(filename, Options{})
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("before\n"))
+	require.NoError(t, err)
+
+	// moved by logrotate
+	moved := filepath.Join(dir, "app.log.1")
+	require.NoError(t, os.Rename(filename, moved))
+	_, err = w.Write([]byte("still old\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, w.Reopen())
+	_, err = w.Write([]byte("after\n"))
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(moved)
+	require.NoError(t, err)
+	require.Equal(t, "before\nstill old\n", string(data))
+
+	data, err = os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, "after\n", string(data))
+}
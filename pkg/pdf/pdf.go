@@ -0,0 +1,203 @@
+// Package pdf writes minimal pdf documents of raster images placed in physical dimensions
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strconv"
+)
+
+// page sizes in points
+var (
+	A4     = Size{595.28, 841.89}
+	A5     = Size{419.53, 595.28}
+	Letter = Size{612, 792}
+	Legal  = Size{612, 1008}
+)
+
+// units in points
+const (
+	Point      = 1.0
+	Inch       = 72.0
+	Millimeter = Inch / 25.4
+	Centimeter = Millimeter * 10
+)
+
+// Size width and height in points
+type Size struct {
+	Width, Height float64
+}
+
+// Rect rectangle in points; X, Y is the top-left corner from the top-left of the page
+type Rect struct {
+	X, Y, Width, Height float64
+}
+
+// Document pdf document; images are compressed when they are drawn and written by WriteTo()
+type Document struct {
+	pages []*Page
+}
+
+// Page page of the document
+type Page struct {
+	Size
+	images []*xobject
+}
+
+// xobject image xobject of 8 bits DeviceRGB, flate encoded
+type xobject struct {
+	width, height int
+	data          []byte
+	rect          Rect
+}
+
+// New create empty document
+func New() *Document { return &Document{} }
+
+// AddPage append new page of the size
+func (d *Document) AddPage(size Size) *Page {
+	p := &Page{Size: size}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Pages returns number of pages
+func (d *Document) Pages() int { return len(d.pages) }
+
+// DrawImage draw the image scaled to the rect; transparent pixels are composed on white
+func (p *Page) DrawImage(img image.Image, rect Rect) error {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+
+	bounds := img.Bounds()
+	row := make([]byte, 0, bounds.Dx()*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row = row[:0]
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			// colors are alpha-premultiplied, add white of the transparent part
+			row = append(row, byte((r+0xffff-a)>>8), byte((g+0xffff-a)>>8), byte((b+0xffff-a)>>8))
+		}
+		if _, err := zw.Write(row); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	p.images = append(p.images, &xobject{width: bounds.Dx(), height: bounds.Dy(), data: buf.Bytes(), rect: rect})
+	return nil
+}
+
+// WriteTo write the document
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	pw := &writer{w: bufio.NewWriter(w)}
+
+	// object numbers: 1 catalog, 2 page tree, then page, contents and images of each page
+	pageIDs := make([]int, len(d.pages))
+	next := 3
+	for i, p := range d.pages {
+		pageIDs[i] = next
+		next += 2 + len(p.images)
+	}
+
+	pw.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	pw.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+
+	kids := &bytes.Buffer{}
+	for i, id := range pageIDs {
+		if i > 0 {
+			kids.WriteByte(' ')
+		}
+		fmt.Fprintf(kids, "%d 0 R", id)
+	}
+	pw.object(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, len(d.pages)))
+
+	for i, p := range d.pages {
+		id := pageIDs[i]
+
+		resources := &bytes.Buffer{}
+		contents := &bytes.Buffer{}
+		for j, im := range p.images {
+			fmt.Fprintf(resources, "/Im%d %d 0 R ", j, id+2+j)
+			// pdf origin is the bottom-left of the page
+			fmt.Fprintf(contents, "q %s 0 0 %s %s %s cm /Im%d Do Q\n",
+				num(im.rect.Width), num(im.rect.Height), num(im.rect.X), num(p.Height-im.rect.Y-im.rect.Height), j)
+		}
+
+		pw.object(id, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /XObject << %s>> >> /Contents %d 0 R >>",
+			num(p.Width), num(p.Height), resources, id+1))
+		pw.stream(id+1, "", contents.Bytes())
+		for j, im := range p.images {
+			pw.stream(id+2+j, fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode ",
+				im.width, im.height), im.data)
+		}
+	}
+
+	xref := pw.n
+	pw.printf("xref\n0 %d\n0000000000 65535 f \n", next)
+	for _, offset := range pw.offsets[1:] {
+		pw.printf("%010d 00000 n \n", offset)
+	}
+	pw.printf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", next, xref)
+
+	if pw.err != nil {
+		return pw.n, pw.err
+	}
+	return pw.n, pw.w.Flush()
+}
+
+// writer keeps offsets of objects for cross reference table; the first error is kept and later writes are ignored
+type writer struct {
+	w       *bufio.Writer
+	n       int64
+	offsets []int64 // offset of object by its number
+	err     error
+}
+
+func (w *writer) printf(format string, args ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(w.w, format, args...)
+	w.n += int64(n)
+	w.err = err
+}
+
+func (w *writer) write(b []byte) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+	w.err = err
+}
+
+func (w *writer) begin(id int) {
+	for len(w.offsets) <= id {
+		w.offsets = append(w.offsets, 0)
+	}
+	w.offsets[id] = w.n
+	w.printf("%d 0 obj\n", id)
+}
+
+func (w *writer) object(id int, dict string) {
+	w.begin(id)
+	w.printf("%s\nendobj\n", dict)
+}
+
+func (w *writer) stream(id int, dict string, data []byte) {
+	w.begin(id)
+	w.printf("<< %s/Length %d >>\nstream\n", dict, len(data))
+	w.write(data)
+	w.printf("\nendstream\nendobj\n")
+}
+
+// num format number in points, rounded to 1/100 point
+func num(v float64) string { return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64) }
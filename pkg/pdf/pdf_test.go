@@ -0,0 +1,81 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"image"
+	"image/color"
+	"io"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocument(t *testing.T) {
+	doc := New()
+
+	red := image.NewRGBA(image.Rect(0, 0, 2, 3))
+	for i := 0; i < len(red.Pix); i += 4 {
+		copy(red.Pix[i:], []byte{0xff, 0, 0, 0xff})
+	}
+	transparent := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+
+	page := doc.AddPage(A4)
+	require.NoError(t, page.DrawImage(red, Rect{10 * Millimeter, 20 * Millimeter, 2 * Inch, 3 * Inch}))
+	require.NoError(t, page.DrawImage(transparent, Rect{0, 0, 10, 10}))
+	doc.AddPage(Letter)
+	require.Equal(t, 2, doc.Pages())
+
+	var buf bytes.Buffer
+	n, err := doc.WriteTo(&buf)
+	require.NoError(t, err)
+	require.Equal(t, int64(buf.Len()), n)
+	data := buf.Bytes()
+
+	require.Contains(t, string(data), "/Count 2")
+	require.Contains(t, string(data), "/MediaBox [0 0 595.28 841.89]")
+	require.Contains(t, string(data), "/MediaBox [0 0 612 792]")
+	// placed from the bottom-left: 841.89 - 20mm - 3in = 569.2
+	require.Contains(t, string(data), "q 144 0 0 216 28.35 569.2 cm /Im0 Do Q")
+
+	// objects are at the offsets of cross reference table
+	xref := regexp.MustCompile(`(?m)^(\d{10}) 00000 n $`).FindAllSubmatch(data, -1)
+	require.Len(t, xref, 8)
+	for i, m := range xref {
+		offset, _ := strconv.Atoi(string(m[1]))
+		require.True(t, bytes.HasPrefix(data[offset:], []byte(strconv.Itoa(i+1)+" 0 obj\n")), "object %d", i+1)
+	}
+
+	images := decodeImages(t, data)
+	require.Len(t, images, 2)
+	require.Equal(t, image.Rect(0, 0, 2, 3), images[0].Bounds())
+	require.Equal(t, color.RGBA{0xff, 0, 0, 0xff}, images[0].At(1, 2))
+	require.Equal(t, color.RGBA{0xff, 0xff, 0xff, 0xff}, images[1].At(0, 0))
+}
+
+var imagePattern = regexp.MustCompile(`/Width (\d+) /Height (\d+) .*?/Length (\d+) >>\nstream\n`)
+
+// decodeImages returns images of the document in order
+func decodeImages(t *testing.T, data []byte) []image.Image {
+	var images []image.Image
+	for _, m := range imagePattern.FindAllSubmatchIndex(data, -1) {
+		width, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		height, _ := strconv.Atoi(string(data[m[4]:m[5]]))
+		length, _ := strconv.Atoi(string(data[m[6]:m[7]]))
+
+		zr, err := zlib.NewReader(bytes.NewReader(data[m[1] : m[1]+length]))
+		require.NoError(t, err)
+		pix, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		require.Len(t, pix, width*height*3)
+
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		for i := 0; i < width*height; i++ {
+			copy(img.Pix[i*4:], []byte{pix[i*3], pix[i*3+1], pix[i*3+2], 0xff})
+		}
+		images = append(images, img)
+	}
+	return images
+}
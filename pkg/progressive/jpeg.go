@@ -0,0 +1,406 @@
+package progressive
+
+import (
+	"bufio"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math"
+)
+
+const blockSize = 64 // 8x8 coefficients of a block
+
+// zigzag maps zig-zag order to the natural order of the block
+var zigzag = [blockSize]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// quantization tables of section K.1 of the spec in zig-zag order; luminance, chrominance
+var baseQuant = [2][blockSize]byte{
+	{
+		16, 11, 12, 14, 12, 10, 16, 14,
+		13, 14, 18, 17, 16, 19, 24, 40,
+		26, 24, 22, 22, 24, 49, 35, 37,
+		29, 40, 58, 51, 61, 60, 57, 51,
+		56, 55, 64, 72, 92, 78, 64, 68,
+		87, 69, 55, 56, 80, 109, 81, 87,
+		95, 98, 103, 104, 103, 62, 77, 113,
+		121, 112, 100, 120, 92, 101, 103, 99,
+	},
+	{
+		17, 18, 18, 24, 21, 24, 47, 26,
+		26, 47, 99, 66, 56, 66, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+	},
+}
+
+// huffmanSpec huffman table of section K.3 of the spec; counts of code length 1~16 and values
+type huffmanSpec struct {
+	class, id byte // 0 for DC, 1 for AC
+	counts    [16]byte
+	values    []byte
+}
+
+var huffmanSpecs = [4]huffmanSpec{
+	// luminance DC
+	{0, 0, [16]byte{0, 1, 5, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0}, []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+	// luminance AC
+	{1, 0, [16]byte{0, 2, 1, 3, 3, 2, 4, 3, 5, 5, 4, 4, 0, 0, 1, 125}, []byte{
+		0x01, 0x02, 0x03, 0x00, 0x04, 0x11, 0x05, 0x12, 0x21, 0x31, 0x41, 0x06, 0x13, 0x51, 0x61, 0x07,
+		0x22, 0x71, 0x14, 0x32, 0x81, 0x91, 0xa1, 0x08, 0x23, 0x42, 0xb1, 0xc1, 0x15, 0x52, 0xd1, 0xf0,
+		0x24, 0x33, 0x62, 0x72, 0x82, 0x09, 0x0a, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x25, 0x26, 0x27, 0x28,
+		0x29, 0x2a, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39, 0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49,
+		0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59, 0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69,
+		0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79, 0x7a, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89,
+		0x8a, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98, 0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7,
+		0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3, 0xc4, 0xc5,
+		0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2, 0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda, 0xe1, 0xe2,
+		0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea, 0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+		0xf9, 0xfa,
+	}},
+	// chrominance DC
+	{0, 1, [16]byte{0, 3, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0}, []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+	// chrominance AC
+	{1, 1, [16]byte{0, 2, 1, 2, 4, 4, 3, 4, 7, 5, 4, 4, 0, 1, 2, 119}, []byte{
+		0x00, 0x01, 0x02, 0x03, 0x11, 0x04, 0x05, 0x21, 0x31, 0x06, 0x12, 0x41, 0x51, 0x07, 0x61, 0x71,
+		0x13, 0x22, 0x32, 0x81, 0x08, 0x14, 0x42, 0x91, 0xa1, 0xb1, 0xc1, 0x09, 0x23, 0x33, 0x52, 0xf0,
+		0x15, 0x62, 0x72, 0xd1, 0x0a, 0x16, 0x24, 0x34, 0xe1, 0x25, 0xf1, 0x17, 0x18, 0x19, 0x1a, 0x26,
+		0x27, 0x28, 0x29, 0x2a, 0x35, 0x36, 0x37, 0x38, 0x39, 0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48,
+		0x49, 0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59, 0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68,
+		0x69, 0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79, 0x7a, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
+		0x88, 0x89, 0x8a, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98, 0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5,
+		0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3,
+		0xc4, 0xc5, 0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2, 0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda,
+		0xe2, 0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+		0xf9, 0xfa,
+	}},
+}
+
+// huffmanCode code and its length in bits by value
+type huffmanCode struct {
+	code uint32
+	bits uint8
+}
+
+func (s *huffmanSpec) codes() [256]huffmanCode {
+	var codes [256]huffmanCode
+	code, k := uint32(0), 0
+	for i, n := range s.counts {
+		for j := 0; j < int(n); j++ {
+			codes[s.values[k]] = huffmanCode{code: code, bits: uint8(i + 1)}
+			code++
+			k++
+		}
+		code <<= 1
+	}
+	return codes
+}
+
+// scan of the progressive jpeg; spectral selection only, so successive approximation is not used
+type scan struct {
+	components []int // component index
+	start, end int   // spectral selection in zig-zag order
+}
+
+// scans DC coefficients of all components first, then low frequencies of luminance come before the rest
+var scans = []scan{
+	{[]int{0, 1, 2}, 0, 0},
+	{[]int{0}, 1, 5},
+	{[]int{1}, 1, 63},
+	{[]int{2}, 1, 63},
+	{[]int{0}, 6, 63},
+}
+
+// EncodeJPEG write the image as progressive jpeg of 4:4:4 YCbCr; quality is 1~100 as image/jpeg
+func EncodeJPEG(w io.Writer, img image.Image, o *jpeg.Options) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 || width >= 1<<16 || height >= 1<<16 {
+		return jpeg.UnsupportedError("image size: " + bounds.String())
+	}
+
+	quality := jpeg.DefaultQuality
+	if o != nil {
+		quality = o.Quality
+	}
+	quant := scaleQuant(quality)
+	blocks := transform(img, quant)
+
+	e := &encoder{w: bufio.NewWriter(w)}
+	e.marker(0xd8, nil) // SOI
+
+	// DQT
+	dqt := []byte{}
+	for i := range quant {
+		dqt = append(dqt, byte(i))
+		dqt = append(dqt, quant[i][:]...)
+	}
+	e.marker(0xdb, dqt)
+
+	// SOF2, progressive DCT; 3 components of 1x1 sampling, luminance uses table 0 and chrominance uses table 1
+	e.marker(0xc2, []byte{8, byte(height >> 8), byte(height), byte(width >> 8), byte(width), 3,
+		1, 0x11, 0, 2, 0x11, 1, 3, 0x11, 1})
+
+	// DHT
+	dht := []byte{}
+	var codes [2][2][256]huffmanCode // class, id
+	for _, s := range huffmanSpecs {
+		dht = append(dht, s.class<<4|s.id)
+		dht = append(dht, s.counts[:]...)
+		dht = append(dht, s.values...)
+		codes[s.class][s.id] = s.codes()
+	}
+	e.marker(0xc4, dht)
+
+	for _, sc := range scans {
+		// SOS
+		sos := []byte{byte(len(sc.components))}
+		for _, c := range sc.components {
+			table := byte(tableIndex(c))
+			sos = append(sos, byte(c+1), table<<4|table)
+		}
+		e.marker(0xda, append(sos, byte(sc.start), byte(sc.end), 0))
+
+		if sc.start == 0 {
+			// interleaved DC, blocks of the same index of the components make a MCU
+			var pred [3]int32
+			for i := range blocks[0] {
+				for _, c := range sc.components {
+					dc := blocks[c][i][0]
+					e.emitValue(codes[0][tableIndex(c)], 0, dc-pred[c])
+					pred[c] = dc
+				}
+			}
+		} else {
+			for _, block := range blocks[sc.components[0]] {
+				e.emitAC(codes[1][tableIndex(sc.components[0])], block[sc.start:sc.end+1])
+			}
+		}
+		e.flushBits()
+	}
+
+	e.marker(0xd9, nil) // EOI
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+// tableIndex returns table index of the component; 0 for luminance, 1 for chrominance
+func tableIndex(component int) int {
+	if component == 0 {
+		return 0
+	}
+	return 1
+}
+
+// scaleQuant returns quantization tables of the quality as libjpeg does
+func scaleQuant(quality int) [2][blockSize]byte {
+	switch {
+	case quality < 1:
+		quality = 1
+	case quality > 100:
+		quality = 100
+	}
+
+	scale := 200 - quality*2
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+
+	var quant [2][blockSize]byte
+	for i := range baseQuant {
+		for j, q := range baseQuant[i] {
+			v := (int(q)*scale + 50) / 100
+			switch {
+			case v < 1:
+				v = 1
+			case v > 255:
+				v = 255
+			}
+			quant[i][j] = byte(v)
+		}
+	}
+	return quant
+}
+
+// cosines of forward dct, cosines[x][u] = cos((2x+1)uπ/16)
+var cosines = func() (c [8][8]float64) {
+	for x := range c {
+		for u := range c[x] {
+			c[x][u] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / 16)
+		}
+	}
+	return
+}()
+
+// transform returns quantized dct coefficients in zig-zag order of Y, Cb, Cr blocks in raster order;
+// edge pixels are repeated to fill the partial blocks
+func transform(img image.Image, quant [2][blockSize]byte) [3][][blockSize]int32 {
+	bounds := img.Bounds()
+	cols, rows := (bounds.Dx()+7)/8, (bounds.Dy()+7)/8
+
+	var blocks [3][][blockSize]int32
+	for c := range blocks {
+		blocks[c] = make([][blockSize]int32, cols*rows)
+	}
+
+	var samples [3][blockSize]float64
+	for by := 0; by < rows; by++ {
+		for bx := 0; bx < cols; bx++ {
+			for i := 0; i < blockSize; i++ {
+				x := clamp(bounds.Min.X+bx*8+i%8, bounds.Min.X, bounds.Max.X-1)
+				y := clamp(bounds.Min.Y+by*8+i/8, bounds.Min.Y, bounds.Max.Y-1)
+				r, g, b, _ := img.At(x, y).RGBA()
+				yy, cb, cr := color.RGBToYCbCr(uint8(r>>8), uint8(g>>8), uint8(b>>8))
+				samples[0][i], samples[1][i], samples[2][i] = float64(yy)-128, float64(cb)-128, float64(cr)-128
+			}
+
+			for c := range samples {
+				fdct(&samples[c], &blocks[c][by*cols+bx], &quant[tableIndex(c)])
+			}
+		}
+	}
+	return blocks
+}
+
+// fdct dct of the samples, quantized and stored in zig-zag order
+func fdct(samples *[blockSize]float64, out *[blockSize]int32, quant *[blockSize]byte) {
+	var rows [blockSize]float64
+	for y := 0; y < 8; y++ {
+		for u := 0; u < 8; u++ {
+			sum := 0.0
+			for x := 0; x < 8; x++ {
+				sum += samples[y*8+x] * cosines[x][u]
+			}
+			rows[y*8+u] = sum
+		}
+	}
+
+	for k, natural := range zigzag {
+		u, v := natural%8, natural/8
+		sum := 0.0
+		for y := 0; y < 8; y++ {
+			sum += rows[y*8+u] * cosines[y][v]
+		}
+		cu, cv := 1.0, 1.0
+		if u == 0 {
+			cu = math.Sqrt2 / 2
+		}
+		if v == 0 {
+			cv = math.Sqrt2 / 2
+		}
+		out[k] = int32(math.Round(sum * cu * cv / 4 / float64(quant[k])))
+	}
+}
+
+func clamp(v, lo, hi int) int {
+	switch {
+	case v < lo:
+		return lo
+	case v > hi:
+		return hi
+	}
+	return v
+}
+
+// encoder writes markers and huffman coded bits; the first error is kept and later writes are ignored
+type encoder struct {
+	w     *bufio.Writer
+	bits  uint32
+	nBits uint8
+	err   error
+}
+
+func (e *encoder) writeByte(b byte) {
+	if e.err == nil {
+		e.err = e.w.WriteByte(b)
+	}
+}
+
+func (e *encoder) marker(m byte, data []byte) {
+	e.writeByte(0xff)
+	e.writeByte(m)
+	if data == nil {
+		return
+	}
+
+	n := len(data) + 2
+	e.writeByte(byte(n >> 8))
+	e.writeByte(byte(n))
+	for _, b := range data {
+		e.writeByte(b)
+	}
+}
+
+// emit write the lowest n bits of the code; 0xff of coded data is followed by 0x00
+func (e *encoder) emit(code uint32, n uint8) {
+	for i := int(n) - 1; i >= 0; i-- {
+		e.bits = e.bits<<1 | (code>>uint(i))&1
+		e.nBits++
+		if e.nBits == 8 {
+			b := byte(e.bits)
+			e.writeByte(b)
+			if b == 0xff {
+				e.writeByte(0)
+			}
+			e.bits, e.nBits = 0, 0
+		}
+	}
+}
+
+// flushBits pad the last byte of the scan with 1 bits
+func (e *encoder) flushBits() {
+	if e.nBits > 0 {
+		e.emit(0xff, 8-e.nBits)
+	}
+}
+
+// emitValue write symbol of the run and size of the value, then its additional bits
+func (e *encoder) emitValue(codes [256]huffmanCode, run int, v int32) {
+	size, bits := uint8(0), v
+	if v < 0 {
+		v, bits = -v, v-1
+	}
+	for ; v > 0; v >>= 1 {
+		size++
+	}
+
+	symbol := codes[byte(run)<<4|size]
+	e.emit(symbol.code, symbol.bits)
+	e.emit(uint32(bits), size)
+}
+
+// emitAC write AC coefficients of the band of a block; trailing zeros are EOB of one block
+func (e *encoder) emitAC(codes [256]huffmanCode, band []int32) {
+	run := 0
+	for _, v := range band {
+		if v == 0 {
+			run++
+			continue
+		}
+
+		for ; run > 15; run -= 16 {
+			e.emit(codes[0xf0].code, codes[0xf0].bits) // ZRL
+		}
+		e.emitValue(codes, run, v)
+		run = 0
+	}
+
+	if run > 0 {
+		e.emit(codes[0x00].code, codes[0x00].bits) // EOB0
+	}
+}
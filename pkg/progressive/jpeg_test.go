@@ -0,0 +1,100 @@
+package progressive
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// frameMarkers returns start of frame markers of the jpeg
+func frameMarkers(t *testing.T, data []byte) []byte {
+	var markers []byte
+	for i := 2; i+4 <= len(data); {
+		require.Equal(t, byte(0xff), data[i], "marker at %d", i)
+		marker := data[i+1]
+		if marker == 0xd9 {
+			break
+		}
+		if marker >= 0xc0 && marker <= 0xcf && marker != 0xc4 && marker != 0xc8 && marker != 0xcc {
+			markers = append(markers, marker)
+		}
+
+		i += 2 + (int(data[i+2])<<8 | int(data[i+3]))
+		if marker != 0xda {
+			continue
+		}
+		// skip entropy coded data of the scan; 0xff is followed by 0x00
+		for i+1 < len(data) && (data[i] != 0xff || data[i+1] == 0) {
+			i++
+		}
+	}
+	return markers
+}
+
+// maxDiff returns the largest difference of color channels of the decoded jpeg from the image
+func maxDiff(t *testing.T, img image.Image, data []byte) int {
+	got, err := jpeg.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	bounds := img.Bounds()
+	require.Equal(t, bounds.Size(), got.Bounds().Size())
+
+	diff := 0
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			r1, g1, b1, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			r2, g2, b2, _ := got.At(x, y).RGBA()
+			for _, d := range []int{int(r1>>8) - int(r2>>8), int(g1>>8) - int(g2>>8), int(b1>>8) - int(b2>>8)} {
+				if d < 0 {
+					d = -d
+				}
+				if d > diff {
+					diff = d
+				}
+			}
+		}
+	}
+	return diff
+}
+
+func TestEncodeJPEG(t *testing.T) {
+	checker := image.NewGray(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			if (x/8+y/8)%2 == 0 {
+				checker.SetGray(x, y, color.Gray{0xff})
+			}
+		}
+	}
+
+	tests := [...]struct {
+		name    string
+		img     image.Image
+		options *jpeg.Options
+	}{
+		{"checker", checker, nil},
+		{"partial blocks", checker.SubImage(image.Rect(3, 5, 60, 40)), nil},
+		{"color", testImage(37, 29, 0xff), nil},
+		{"best quality", testImage(37, 29, 0xff), &jpeg.Options{Quality: 100}},
+		{"low quality", testImage(37, 29, 0xff), &jpeg.Options{Quality: 10}},
+		{"one pixel", testImage(1, 1, 0xff), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, EncodeJPEG(&buf, tt.img, tt.options))
+			data := buf.Bytes()
+			require.Equal(t, []byte{0xff, 0xd8}, data[:2])
+			require.Equal(t, []byte{0xc2}, frameMarkers(t, data), "progressive DCT only")
+
+			// as close as baseline of image/jpeg in the same quality
+			var baseline bytes.Buffer
+			require.NoError(t, jpeg.Encode(&baseline, tt.img, tt.options))
+			require.Equal(t, []byte{0xc0}, frameMarkers(t, baseline.Bytes()))
+			require.LessOrEqual(t, maxDiff(t, tt.img, data), maxDiff(t, tt.img, baseline.Bytes())+2)
+		})
+	}
+}
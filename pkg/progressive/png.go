@@ -0,0 +1,216 @@
+// Package progressive encodes images which are displayed progressively while loading;
+// Adam7 interlaced png and progressive jpeg which are not supported by image/png and image/jpeg.
+package progressive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// adam7 passes of interlaced png; offset and step of x, y
+var adam7 = [...]struct{ x, y, dx, dy int }{
+	{0, 0, 8, 8},
+	{4, 0, 8, 8},
+	{0, 4, 4, 8},
+	{2, 0, 4, 4},
+	{0, 2, 2, 4},
+	{1, 0, 2, 2},
+	{0, 1, 1, 2},
+}
+
+// png row filters
+const (
+	filterNone = iota
+	filterSub
+	filterUp
+	filterAverage
+	filterPaeth
+)
+
+// EncodePNG write the image as 8 bits truecolor Adam7 interlaced png;
+// alpha channel is written only if the image has transparent pixels.
+func EncodePNG(w io.Writer, img image.Image, level png.CompressionLevel) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 {
+		return png.FormatError("invalid image size: " + bounds.String())
+	}
+
+	bpp := 3 // bytes per pixel
+	if !opaque(img) {
+		bpp = 4
+	}
+
+	var data bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&data, zlibLevel(level))
+	if err != nil {
+		return err
+	}
+
+	for _, pass := range adam7 {
+		passWidth := (width - pass.x + pass.dx - 1) / pass.dx
+		passHeight := (height - pass.y + pass.dy - 1) / pass.dy
+		if passWidth <= 0 || passHeight <= 0 {
+			continue
+		}
+
+		// previous row starts with zeros in each pass
+		prev := make([]byte, passWidth*bpp)
+		cur := make([]byte, passWidth*bpp)
+		for y := pass.y; y < height; y += pass.dy {
+			for i, x := 0, pass.x; x < width; i, x = i+bpp, x+pass.dx {
+				c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+				cur[i], cur[i+1], cur[i+2] = c.R, c.G, c.B
+				if bpp == 4 {
+					cur[i+3] = c.A
+				}
+			}
+
+			if _, err := zw.Write(filterRow(cur, prev, bpp)); err != nil {
+				return err
+			}
+			prev, cur = cur, prev
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	colorType := byte(2) // truecolor
+	if bpp == 4 {
+		colorType = 6 // truecolor with alpha
+	}
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(width))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(height))
+	ihdr[8], ihdr[9], ihdr[12] = 8, colorType, 1 // bit depth, color type, interlace method
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(pngSignature); err != nil {
+		return err
+	}
+	if err := writeChunk(bw, "IHDR", ihdr); err != nil {
+		return err
+	}
+	if err := writeChunk(bw, "IDAT", data.Bytes()); err != nil {
+		return err
+	}
+	if err := writeChunk(bw, "IEND", nil); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func writeChunk(w io.Writer, name string, data []byte) error {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], name)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+
+	for _, b := range [][]byte{header, data, binary.BigEndian.AppendUint32(nil, crc.Sum32())} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterRow returns the filtered row prefixed by its filter type; the filter of the smallest sum of absolute differences is selected
+func filterRow(cur, prev []byte, bpp int) []byte {
+	best, bestSum := []byte(nil), -1
+	for filter := filterNone; filter <= filterPaeth; filter++ {
+		out := make([]byte, len(cur)+1)
+		out[0] = byte(filter)
+		sum := 0
+		for i := range cur {
+			var a, c byte
+			if i >= bpp {
+				a, c = cur[i-bpp], prev[i-bpp]
+			}
+			b := prev[i]
+
+			var predict byte
+			switch filter {
+			case filterSub:
+				predict = a
+			case filterUp:
+				predict = b
+			case filterAverage:
+				predict = byte((int(a) + int(b)) / 2)
+			case filterPaeth:
+				predict = paeth(a, b, c)
+			}
+
+			out[i+1] = cur[i] - predict
+			if d := int(int8(out[i+1])); d < 0 {
+				sum -= d
+			} else {
+				sum += d
+			}
+		}
+
+		if bestSum < 0 || sum < bestSum {
+			best, bestSum = out, sum
+		}
+	}
+	return best
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func opaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func zlibLevel(level png.CompressionLevel) int {
+	switch level {
+	case png.NoCompression:
+		return zlib.NoCompression
+	case png.BestSpeed:
+		return zlib.BestSpeed
+	case png.BestCompression:
+		return zlib.BestCompression
+	}
+	return zlib.DefaultCompression
+}
@@ -0,0 +1,73 @@
+package progressive
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testImage returns image of pattern which is different in every pass of adam7
+func testImage(width, height int, alpha uint8) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 7), uint8(y * 13), uint8(x ^ y), alpha})
+		}
+	}
+	return img
+}
+
+func TestEncodePNG(t *testing.T) {
+	tests := [...]struct {
+		name          string
+		img           image.Image
+		wantColorType byte
+	}{
+		{"opaque", testImage(37, 29, 0xff), 2},
+		{"transparent", testImage(37, 29, 0x80), 6},
+		{"smaller than a pass", testImage(3, 1, 0xff), 2},
+		{"one pixel", testImage(1, 1, 0xff), 2},
+		{"offset bounds", testImage(40, 40, 0xff).SubImage(image.Rect(5, 7, 30, 21)), 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, EncodePNG(&buf, tt.img, png.DefaultCompression))
+
+			data := buf.Bytes()
+			require.Equal(t, pngSignature, data[:8])
+			require.Equal(t, "IHDR", string(data[12:16]))
+			ihdr := data[16:29]
+			require.Equal(t, byte(8), ihdr[8], "bit depth")
+			require.Equal(t, tt.wantColorType, ihdr[9], "color type")
+			require.Equal(t, byte(1), ihdr[12], "interlace method")
+
+			got, err := png.Decode(bytes.NewReader(data))
+			require.NoError(t, err)
+			bounds := tt.img.Bounds()
+			require.Equal(t, bounds.Size(), got.Bounds().Size())
+			for y := 0; y < bounds.Dy(); y++ {
+				for x := 0; x < bounds.Dx(); x++ {
+					require.Equal(t, color.NRGBAModel.Convert(tt.img.At(bounds.Min.X+x, bounds.Min.Y+y)),
+						color.NRGBAModel.Convert(got.At(x, y)), "pixel at %d,%d", x, y)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodePNGCompression(t *testing.T) {
+	img := testImage(100, 100, 0xff)
+
+	size := func(level png.CompressionLevel) int {
+		var buf bytes.Buffer
+		require.NoError(t, EncodePNG(&buf, img, level))
+		return buf.Len()
+	}
+	require.Greater(t, size(png.NoCompression), size(png.BestCompression))
+	require.Error(t, EncodePNG(&bytes.Buffer{}, image.NewRGBA(image.Rectangle{}), png.DefaultCompression))
+}
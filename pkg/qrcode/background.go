@@ -0,0 +1,46 @@
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+var errBackgroundRaster = errors.New("background image is supported for raster image only")
+
+// BackgroundError the code does not fit in the background image
+type BackgroundError struct {
+	Code       image.Rectangle // position of the code in the background
+	Background image.Point     // size of the background
+}
+
+func (e *BackgroundError) Error() string {
+	return fmt.Sprintf("code of %dx%d at %d,%d does not fit in background of %dx%d",
+		e.Code.Dx(), e.Code.Dy(), e.Code.Min.X, e.Code.Min.Y, e.Background.X, e.Background.Y)
+}
+
+// drawOnBackground draw the code image onto the copy of background image over white box for contrast;
+// the code is centered if the position is not given.
+func drawOnBackground(code image.Image, opts *RenderOptions) (image.Image, error) {
+	bg := opts.BackgroundImage.Bounds()
+	size := code.Bounds().Size()
+
+	pos := image.Pt((bg.Dx()-size.X)/2, (bg.Dy()-size.Y)/2)
+	if opts.BackgroundPosition != nil {
+		pos = *opts.BackgroundPosition
+	}
+
+	rect := image.Rectangle{Min: pos, Max: pos.Add(size)}
+	if !rect.In(image.Rectangle{Max: bg.Size()}) {
+		return nil, &BackgroundError{Code: rect, Background: bg.Size()}
+	}
+
+	img := image.NewRGBA(image.Rectangle{Max: bg.Size()})
+	draw.Draw(img, img.Bounds(), opts.BackgroundImage, bg.Min, draw.Src)
+	draw.Draw(img, rect, image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(img, rect, code, code.Bounds().Min, draw.Over)
+
+	return img, nil
+}
@@ -0,0 +1,55 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderBackground(t *testing.T) {
+	qr, _ := Text("https://github.com/whitekid/qrcodeapi")
+
+	bg := image.NewRGBA(image.Rect(0, 0, 600, 400))
+	draw.Draw(bg, bg.Bounds(), image.NewUniform(color.RGBA{R: 0x20, G: 0x40, B: 0x80, A: 0xff}), image.Point{}, draw.Src)
+
+	tests := [...]struct {
+		name     string
+		position *image.Point
+		wantCode image.Rectangle
+		wantErr  bool
+	}{
+		{"centered", nil, image.Rect(200, 100, 400, 300), false},
+		{"position", &image.Point{X: 380, Y: 180}, image.Rect(380, 180, 580, 380), false},
+		{"out of background", &image.Point{X: 500, Y: 0}, image.Rectangle{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := qr.RenderWithOptions(&RenderOptions{Width: 200, Height: 200, BackgroundImage: bg, BackgroundPosition: tt.position})
+			if tt.wantErr {
+				var bgErr *BackgroundError
+				require.True(t, errors.As(err, &bgErr))
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, bg.Bounds().Size(), img.Bounds().Size())
+
+			// background is kept outside of the code
+			require.Equal(t, bg.At(0, 0), img.At(0, 0))
+			require.Equal(t, color.RGBA{R: 255, G: 255, B: 255, A: 255}, color.RGBAModel.Convert(img.At(tt.wantCode.Min.X, tt.wantCode.Min.Y)))
+
+			got, err := Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, "https://github.com/whitekid/qrcodeapi", got)
+		})
+	}
+
+	var buf bytes.Buffer
+	opts := &RenderOptions{Width: 200, Height: 200, BackgroundImage: bg}
+	require.Error(t, qr.RenderSVG(&buf, opts))
+	require.Error(t, qr.RenderText(&buf, opts))
+}
@@ -0,0 +1,81 @@
+package qrcode
+
+import (
+	"github.com/makiuchi-d/gozxing/qrcode/decoder"
+)
+
+// LastVersion the largest symbol version
+const LastVersion = 40
+
+// Capacity the largest number of characters of each mode that fits in the version and error correction level,
+// as a single segment
+type Capacity struct {
+	Version         int    `json:"version"`
+	ErrorCorrection string `json:"ec"`
+	Modules         int    `json:"modules"` // modules per side without quiet zone
+	DataCodewords   int    `json:"data_codewords"`
+	Numeric         int    `json:"numeric"`
+	Alphanumeric    int    `json:"alphanumeric"`
+	Byte            int    `json:"byte"`
+	Kanji           int    `json:"kanji"`
+}
+
+// Capacities returns capacities of every version and error correction level, computed from the encoder tables
+func Capacities() []Capacity {
+	capacities := make([]Capacity, 0, LastVersion*len(ErrorCorrections))
+	for version := 1; version <= LastVersion; version++ {
+		for _, ec := range ErrorCorrections {
+			capacities = append(capacities, capacity(version, ec))
+		}
+	}
+	return capacities
+}
+
+// ByteCapacity returns the largest bytes of the version and error correction level; 0 if invalid
+func ByteCapacity(version int, ec string) int {
+	if version < 1 || version > LastVersion {
+		return 0
+	}
+	return capacity(version, ec).Byte
+}
+
+func capacity(number int, ec string) Capacity {
+	version, _ := decoder.Version_GetVersionForNumber(number)
+	level, _ := errorCorrectionLevel(ec)
+	data := version.GetTotalCodewords() - version.GetECBlocksForLevel(level).GetTotalECCodewords()
+
+	// data bits after mode indicator and character count
+	bits := func(mode *decoder.Mode) int { return data*8 - 4 - mode.GetCharacterCountBits(version) }
+
+	return Capacity{
+		Version:         number,
+		ErrorCorrection: level.String(),
+		Modules:         version.GetDimensionForVersion(),
+		DataCodewords:   data,
+		Numeric:         numericCapacity(bits(decoder.Mode_NUMERIC)),
+		Alphanumeric:    alphanumericCapacity(bits(decoder.Mode_ALPHANUMERIC)),
+		Byte:            bits(decoder.Mode_BYTE) / 8,
+		Kanji:           bits(decoder.Mode_KANJI) / 13,
+	}
+}
+
+// numericCapacity 10 bits per 3 digits, 7 bits for 2 and 4 bits for 1 remaining digits
+func numericCapacity(bits int) int {
+	n := bits / 10 * 3
+	switch rest := bits % 10; {
+	case rest >= 7:
+		n += 2
+	case rest >= 4:
+		n++
+	}
+	return n
+}
+
+// alphanumericCapacity 11 bits per 2 characters, 6 bits for 1 remaining character
+func alphanumericCapacity(bits int) int {
+	n := bits / 11 * 2
+	if bits%11 >= 6 {
+		n++
+	}
+	return n
+}
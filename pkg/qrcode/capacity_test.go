@@ -0,0 +1,53 @@
+package qrcode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapacities(t *testing.T) {
+	capacities := Capacities()
+	require.Len(t, capacities, LastVersion*len(ErrorCorrections))
+
+	find := func(version int, ec string) Capacity {
+		for _, c := range capacities {
+			if c.Version == version && c.ErrorCorrection == ec {
+				return c
+			}
+		}
+		require.FailNow(t, "not found")
+		return Capacity{}
+	}
+
+	// ISO/IEC 18004 table 7
+	tests := [...]struct {
+		version int
+		ec      string
+		want    Capacity
+	}{
+		{1, "L", Capacity{Version: 1, ErrorCorrection: "L", Modules: 21, DataCodewords: 19, Numeric: 41, Alphanumeric: 25, Byte: 17, Kanji: 10}},
+		{1, "H", Capacity{Version: 1, ErrorCorrection: "H", Modules: 21, DataCodewords: 9, Numeric: 17, Alphanumeric: 10, Byte: 7, Kanji: 4}},
+		{10, "M", Capacity{Version: 10, ErrorCorrection: "M", Modules: 57, DataCodewords: 216, Numeric: 513, Alphanumeric: 311, Byte: 213, Kanji: 131}},
+		{40, "L", Capacity{Version: 40, ErrorCorrection: "L", Modules: 177, DataCodewords: 2956, Numeric: 7089, Alphanumeric: 4296, Byte: 2953, Kanji: 1817}},
+		{40, "H", Capacity{Version: 40, ErrorCorrection: "H", Modules: 177, DataCodewords: 1276, Numeric: 3057, Alphanumeric: 1852, Byte: 1273, Kanji: 784}},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, find(tt.version, tt.ec))
+	}
+
+	// the byte capacity fits, and one more does not
+	for _, version := range []int{1, 5} {
+		c := find(version, "M")
+		qr, _ := Text(strings.Repeat("a", c.Byte))
+		code, err := qr.encode(&RenderOptions{ErrorCorrection: "M"})
+		require.NoError(t, err)
+		require.Equal(t, version, code.GetVersion().GetVersionNumber())
+
+		qr, _ = Text(strings.Repeat("a", c.Byte+1))
+		code, err = qr.encode(&RenderOptions{ErrorCorrection: "M"})
+		require.NoError(t, err)
+		require.Equal(t, version+1, code.GetVersion().GetVersionNumber())
+	}
+}
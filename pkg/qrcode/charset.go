@@ -0,0 +1,93 @@
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/common"
+)
+
+// CharsetUTF8 default charset of byte mode content
+const CharsetUTF8 = "utf-8"
+
+// Charsets supported charsets of byte mode content, for legacy scanners which do not read utf-8
+var Charsets = []string{CharsetUTF8, "shift_jis", "euc-kr", "iso-8859-1"}
+
+// charsetECINames names of charsets for the encoder
+var charsetECINames = map[string]string{
+	CharsetUTF8:  "UTF-8",
+	"shift_jis":  "Shift_JIS",
+	"euc-kr":     "EUC-KR",
+	"iso-8859-1": "ISO-8859-1",
+}
+
+// ParseCharset returns normalized charset, utf-8 if empty
+func ParseCharset(s string) (string, error) {
+	s = strings.ToLower(s)
+	if s == "" {
+		return CharsetUTF8, nil
+	}
+	if _, ok := charsetECINames[s]; ok {
+		return s, nil
+	}
+	return "", errors.New("invalid charset: " + s)
+}
+
+// CharsetError content has a character which can not be represented in the charset
+type CharsetError struct {
+	Charset string
+	Char    rune
+	Offset  int // byte offset in the content
+}
+
+func (e *CharsetError) Error() string {
+	return fmt.Sprintf("%q at %d can not be encoded in %s", e.Char, e.Offset, e.Charset)
+}
+
+// checkCharset returns CharsetError of the first character of the content which is not representable in the charset
+func checkCharset(content string, charset string) error {
+	if charset == "" || charset == CharsetUTF8 {
+		return nil
+	}
+
+	eci, ok := common.GetCharacterSetECIByName(charsetECINames[charset])
+	if !ok {
+		return errors.New("invalid charset: " + charset)
+	}
+
+	enc := eci.GetCharset().NewEncoder()
+	for i, r := range content {
+		if _, err := enc.String(string(r)); err != nil {
+			return &CharsetError{Charset: charset, Char: r, Offset: i}
+		}
+	}
+	return nil
+}
+
+// CharsetBytes returns bytes of the content in the charset, as encoded in byte mode
+func CharsetBytes(content string, charset string) ([]byte, error) {
+	if err := checkCharset(content, charset); err != nil {
+		return nil, err
+	}
+	if charset == "" || charset == CharsetUTF8 {
+		return []byte(content), nil
+	}
+
+	eci, _ := common.GetCharacterSetECIByName(charsetECINames[charset])
+	return eci.GetCharset().NewEncoder().Bytes([]byte(content))
+}
+
+// encodeHints returns encoder hints of the charset of the options; content of other charsets than utf-8 is transcoded
+// and always designated by ECI, utf-8 only if ECI is set.
+func encodeHints(opts *RenderOptions) map[gozxing.EncodeHintType]interface{} {
+	hints := map[gozxing.EncodeHintType]interface{}{}
+	switch {
+	case opts.Charset != "" && opts.Charset != CharsetUTF8:
+		hints[gozxing.EncodeHintType_CHARACTER_SET] = charsetECINames[opts.Charset]
+	case opts.ECI:
+		hints[gozxing.EncodeHintType_CHARACTER_SET] = charsetECINames[CharsetUTF8]
+	}
+	return hints
+}
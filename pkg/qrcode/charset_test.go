@@ -0,0 +1,76 @@
+package qrcode
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestCharset(t *testing.T) {
+	tests := [...]struct {
+		name     string
+		content  string
+		charset  string
+		eci      bool
+		encoding encoding.Encoding
+		wantErr  *CharsetError
+	}{
+		{"shift_jis", "QRコード 123", "shift_jis", false, japanese.ShiftJIS, nil},
+		{"euc-kr", "안녕하세요 QR", "euc-kr", false, korean.EUCKR, nil},
+		{"iso-8859-1", "Grüße", "iso-8859-1", false, charmap.ISO8859_1, nil},
+		{"utf-8", "QRコード 123", "", false, unicode.UTF8, nil},
+		{"utf-8 eci", "QRコード 123", "utf-8", true, unicode.UTF8, nil},
+		{"unrepresentable", "QR 한글", "shift_jis", false, nil, &CharsetError{Charset: "shift_jis", Char: '한', Offset: 3}},
+		{"unrepresentable latin", "€5", "iso-8859-1", false, nil, &CharsetError{Charset: "iso-8859-1", Char: '€', Offset: 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qr, _ := Text(tt.content)
+			img, err := qr.RenderWithOptions(&RenderOptions{Width: 200, Height: 200, Charset: tt.charset, ECI: tt.eci})
+			if tt.wantErr != nil {
+				require.Equal(t, tt.wantErr, err)
+				return
+			}
+			require.NoError(t, err)
+
+			bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+			require.NoError(t, err)
+			result, err := qrcode.NewQRCodeReader().Decode(bmp, nil)
+			require.NoError(t, err)
+
+			// raw bytes of the byte segment are of the charset
+			segments, _ := result.GetResultMetadata()[gozxing.ResultMetadataType_BYTE_SEGMENTS].([][]byte)
+			require.Len(t, segments, 1)
+			raw := bytes.Join(segments, nil)
+			payload, err := CharsetBytes(tt.content, tt.charset)
+			require.NoError(t, err)
+			require.Equal(t, payload, raw)
+
+			decoded, err := tt.encoding.NewDecoder().Bytes(raw)
+			require.NoError(t, err)
+			require.Equal(t, tt.content, string(decoded))
+
+			// ECI aware scanners read the content as is
+			require.Equal(t, tt.content, result.GetText())
+		})
+	}
+}
+
+func TestParseCharset(t *testing.T) {
+	for arg, want := range map[string]string{"": CharsetUTF8, "UTF-8": CharsetUTF8, "Shift_JIS": "shift_jis", "EUC-KR": "euc-kr"} {
+		got, err := ParseCharset(arg)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+
+	_, err := ParseCharset("utf-16")
+	require.Error(t, err)
+}
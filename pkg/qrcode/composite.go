@@ -0,0 +1,94 @@
+package qrcode
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"strings"
+
+	"github.com/whitekid/goxp/fx"
+)
+
+// composite layouts
+const (
+	CompositeHorizontal = "horizontal" // second code on the right
+	CompositeVertical   = "vertical"   // second code below
+)
+
+// CompositeLayouts supported composite layouts
+var CompositeLayouts = []string{CompositeHorizontal, CompositeVertical}
+
+// CompositeOptions second code of the same content drawn next to the code, ex) small code of high error correction level
+type CompositeOptions struct {
+	Layout string
+
+	// ErrorCorrection error correction level of the second code, L if empty
+	ErrorCorrection string
+
+	// Size width and height of the second code
+	Size int
+}
+
+// ParseCompositeLayout returns normalized composite layout
+func ParseCompositeLayout(s string) (string, error) {
+	s = strings.ToLower(s)
+	if !fx.Contains(CompositeLayouts, s) {
+		return "", errors.New("invalid composite layout: " + s)
+	}
+	return s, nil
+}
+
+var errCompositeRaster = errors.New("composite is supported for raster image only")
+
+// renderComposite render the code and the second code of composite options side by side.
+// codes are centered across the layout and corners are rounded for the whole image.
+func (q *QR) renderComposite(opts *RenderOptions) (image.Image, error) {
+	primary := *opts
+	primary.Composite, primary.CornerRadius = nil, 0
+
+	secondary := primary
+	secondary.ErrorCorrection = opts.Composite.ErrorCorrection
+	secondary.Width, secondary.Height = opts.Composite.Size, opts.Composite.Size
+	secondary.Label = ""
+	secondary.Halftone = nil // small code is plain to stay scannable
+
+	var images [2]*image.RGBA
+	padding := -1 // the smallest padding to the code area
+	for i, o := range []*RenderOptions{&primary, &secondary} {
+		code, err := q.encode(o)
+		if err != nil {
+			return nil, err
+		}
+
+		l := newLayout(code.GetMatrix(), o)
+		if err := l.checkHalftone(o); err != nil {
+			return nil, err
+		}
+		if p := l.padding(); padding < 0 || p < padding {
+			padding = p
+		}
+		images[i] = render(code.GetMatrix(), o)
+	}
+
+	a, b := images[0].Bounds().Size(), images[1].Bounds().Size()
+	var size, offsetA, offsetB image.Point
+	if opts.Composite.Layout == CompositeVertical {
+		size = image.Pt(fx.Max([]int{a.X, b.X}), a.Y+b.Y)
+		offsetA, offsetB = image.Pt((size.X-a.X)/2, 0), image.Pt((size.X-b.X)/2, a.Y)
+	} else {
+		size = image.Pt(a.X+b.X, fx.Max([]int{a.Y, b.Y}))
+		offsetA, offsetB = image.Pt(0, (size.Y-a.Y)/2), image.Pt(a.X, (size.Y-b.Y)/2)
+	}
+
+	img := image.NewRGBA(image.Rectangle{Max: size})
+	draw.Draw(img, img.Bounds(), image.NewUniform(opts.padColor()), image.Point{}, draw.Src)
+	draw.Draw(img, images[0].Bounds().Add(offsetA), images[0], image.Point{}, draw.Src)
+	draw.Draw(img, images[1].Bounds().Add(offsetB), images[1], image.Point{}, draw.Src)
+
+	if opts.CornerRadius > 0 {
+		radius := fx.Min([]int{opts.CornerRadius, maxCornerRadius(padding), size.X / 2, size.Y / 2})
+		roundCorners(img, radius, opts.CornerBackground)
+	}
+
+	return img, nil
+}
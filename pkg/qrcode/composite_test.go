@@ -0,0 +1,67 @@
+package qrcode
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderComposite(t *testing.T) {
+	qr, _ := Text("https://github.com/whitekid/qrcodeapi")
+
+	tests := [...]struct {
+		layout     string
+		wantSize   image.Point
+		wantBounds [2]image.Rectangle // bounds of the sub codes
+	}{
+		{CompositeHorizontal, image.Pt(300, 200), [2]image.Rectangle{image.Rect(0, 0, 200, 200), image.Rect(200, 50, 300, 150)}},
+		{CompositeVertical, image.Pt(200, 300), [2]image.Rectangle{image.Rect(0, 0, 200, 200), image.Rect(50, 200, 150, 300)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.layout, func(t *testing.T) {
+			img, err := qr.RenderWithOptions(&RenderOptions{
+				Width: 200, Height: 200, ErrorCorrection: "L", CornerRadius: 10,
+				Composite: &CompositeOptions{Layout: tt.layout, ErrorCorrection: "H", Size: 100},
+			})
+			require.NoError(t, err)
+			require.Equal(t, tt.wantSize, img.Bounds().Size())
+
+			for _, bounds := range tt.wantBounds {
+				got, err := Decode(img.(*image.RGBA).SubImage(bounds))
+				require.NoError(t, err)
+				require.Equal(t, "https://github.com/whitekid/qrcodeapi", got)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	opts := &RenderOptions{Width: 200, Height: 200, Composite: &CompositeOptions{Layout: CompositeVertical, Size: 100}}
+	require.Error(t, qr.RenderSVG(&buf, opts))
+	require.Error(t, qr.RenderText(&buf, opts))
+}
+
+func TestParseCompositeLayout(t *testing.T) {
+	tests := [...]struct {
+		layout  string
+		want    string
+		wantErr bool
+	}{
+		{"horizontal", CompositeHorizontal, false},
+		{"Vertical", CompositeVertical, false},
+		{"", "", true},
+		{"diagonal", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.layout, func(t *testing.T) {
+			got, err := ParseCompositeLayout(tt.layout)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -5,11 +5,15 @@ import (
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
+	"math"
 
 	"github.com/makiuchi-d/gozxing"
+	multiqrcode "github.com/makiuchi-d/gozxing/multi/qrcode"
 	"github.com/makiuchi-d/gozxing/qrcode"
 )
 
+// Decode decode qrcode in the image; upright code with quiet zone, as rendered by this package,
+// is decoded without the detector if the detector fails.
 func Decode(img image.Image) (string, error) {
 	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
 	if err != nil {
@@ -19,8 +23,74 @@ func Decode(img image.Image) (string, error) {
 	r := qrcode.NewQRCodeReader()
 	result, err := r.Decode(bmp, nil)
 	if err != nil {
-		return "", err
+		// detector misreads some valid codes, ex) some version 2 codes of mask 2
+		pure, pureErr := r.Decode(bmp, map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_PURE_BARCODE: true})
+		if pureErr != nil {
+			return "", err
+		}
+		result = pure
 	}
 
 	return result.String(), nil
 }
+
+// DecodedCode code found in the image by DecodeAll
+type DecodedCode struct {
+	Content string
+	Points  []image.Point // centers of finder patterns, bottom-left, top-left and top-right, and alignment pattern if found
+	Bounds  image.Rectangle
+}
+
+// DecodeAll decode every code in the image; empty if no code is found. Bounds of the code is the quadrangle of
+// the finder patterns, extended by the half of the patterns to the outer edges of the symbol.
+func DecodeAll(img image.Image) ([]*DecodedCode, error) {
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := multiqrcode.NewQRCodeMultiReader().DecodeMultipleWithoutHint(bmp)
+	if _, ok := err.(gozxing.NotFoundException); ok {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	origin := img.Bounds().Min
+	codes := make([]*DecodedCode, len(results))
+	for i, result := range results {
+		code := &DecodedCode{Content: result.GetText()}
+		for _, p := range result.GetResultPoints() {
+			code.Points = append(code.Points, origin.Add(image.Pt(int(math.Round(p.GetX())), int(math.Round(p.GetY())))))
+		}
+		code.Bounds = symbolBounds(result.GetResultPoints()).Add(origin)
+		codes[i] = code
+	}
+	return codes, nil
+}
+
+// symbolBounds returns bounds of the finder patterns; bottom-right corner is of the parallelogram of the patterns,
+// and the finder pattern is 7 modules so that its edges are 3.5 modules from the center.
+func symbolBounds(points []gozxing.ResultPoint) image.Rectangle {
+	bottomLeft, topLeft, topRight := points[0], points[1], points[2]
+	corners := [][2]float64{
+		{bottomLeft.GetX(), bottomLeft.GetY()},
+		{topLeft.GetX(), topLeft.GetY()},
+		{topRight.GetX(), topRight.GetY()},
+		{topRight.GetX() + bottomLeft.GetX() - topLeft.GetX(), topRight.GetY() + bottomLeft.GetY() - topLeft.GetY()},
+	}
+
+	moduleSize := 0.0
+	if p, ok := topLeft.(interface{ GetEstimatedModuleSize() float64 }); ok {
+		moduleSize = p.GetEstimatedModuleSize()
+	}
+	margin := 3.5 * moduleSize
+
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, c := range corners {
+		minX, maxX = math.Min(minX, c[0]), math.Max(maxX, c[0])
+		minY, maxY = math.Min(minY, c[1]), math.Max(maxY, c[1])
+	}
+	return image.Rect(int(math.Floor(minX-margin)), int(math.Floor(minY-margin)), int(math.Ceil(maxX+margin)), int(math.Ceil(maxY+margin)))
+}
@@ -0,0 +1,71 @@
+package qrcode
+
+import (
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// payload types detected by Detect()
+const (
+	TypeText  = "text"
+	TypeURL   = "url"
+	TypeEmail = "email"
+	TypeTel   = "tel"
+	TypeWifi  = "wifi"
+	TypeVCard = "vcard"
+)
+
+var (
+	// already formatted payloads
+	payloadPrefixes = []struct {
+		prefix string
+		typ    string
+	}{
+		{"WIFI:", TypeWifi},
+		{"BEGIN:VCARD", TypeVCard},
+		{"URLTO:", TypeURL},
+		{"mailto:", TypeEmail},
+		{"MATMSG:", TypeEmail},
+		{"tel:", TypeTel},
+	}
+
+	rePhone = regexp.MustCompile(`^\+?[0-9][0-9 ().-]*[0-9]$`)
+)
+
+// Detect inspects the content and returns payload type and qrcode of the content formatted for the type;
+// content is encoded as text if no type is detected.
+func Detect(content string) (string, *QR) {
+	content = strings.TrimSpace(content)
+
+	for _, p := range payloadPrefixes {
+		if len(content) >= len(p.prefix) && strings.EqualFold(content[:len(p.prefix)], p.prefix) {
+			return p.typ, &QR{Content: content}
+		}
+	}
+
+	if u, err := url.Parse(content); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && !strings.ContainsAny(content, " \t\n") {
+		return TypeURL, &QR{Content: "URLTO:" + content}
+	}
+
+	if addr, err := mail.ParseAddress(content); err == nil && addr.Address == content {
+		return TypeEmail, &QR{Content: "mailto:" + content}
+	}
+
+	if rePhone.MatchString(content) {
+		digits := strings.Map(func(r rune) rune {
+			if r == '+' || (r >= '0' && r <= '9') {
+				return r
+			}
+			return -1
+		}, content)
+
+		// E.164 allows up to 15 digits
+		if n := len(strings.TrimPrefix(digits, "+")); n >= 7 && n <= 15 {
+			return TypeTel, &QR{Content: "tel:" + digits}
+		}
+	}
+
+	return TypeText, &QR{Content: content}
+}
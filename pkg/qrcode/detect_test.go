@@ -0,0 +1,33 @@
+package qrcode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	tests := [...]struct {
+		name        string
+		content     string
+		wantType    string
+		wantContent string
+	}{
+		{"url", "https://example.com/menu?table=1", TypeURL, "URLTO:https://example.com/menu?table=1"},
+		{"email", "me@example.com", TypeEmail, "mailto:me@example.com"},
+		{"phone", "+82 (10) 1234-5678", TypeTel, "tel:+821012345678"},
+		{"wifi", "WIFI:S:myssid;T:WPA;P:secret;;", TypeWifi, "WIFI:S:myssid;T:WPA;P:secret;;"},
+		{"tel uri", "tel:+123456789", TypeTel, "tel:+123456789"},
+		{"text", "hello world", TypeText, "hello world"},
+		{"short number", "1234", TypeText, "1234"},
+		{"url like text", "see https://example.com", TypeText, "see https://example.com"},
+		{"named address", "Me <me@example.com>", TypeText, "Me <me@example.com>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ, qr := Detect(tt.content)
+			require.Equal(t, tt.wantType, typ)
+			require.Equal(t, tt.wantContent, qr.Content)
+		})
+	}
+}
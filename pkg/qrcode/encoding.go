@@ -0,0 +1,68 @@
+package qrcode
+
+import (
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode/decoder"
+)
+
+// Encoding encoding decisions and codewords of the symbol before rendering, for debugging
+type Encoding struct {
+	Version         int
+	ErrorCorrection string
+	Mode            string // mode of the single segment, ex) BYTE
+	Mask            int
+	// DataCodewords mode indicator, character count, data, terminator and padding, before error correction
+	DataCodewords []byte
+	// Codewords data and error correction codewords interleaved in the order they are placed in the matrix
+	Codewords []byte
+	Matrix    [][]bool // dark modules without quiet zone, see Matrix
+}
+
+// Encoding returns encoding of the code; codewords are read back from the encoded matrix, as scanners do,
+// since the encoder does not expose its bit stream.
+func (q *QR) Encoding(opts *RenderOptions) (*Encoding, error) {
+	code, err := q.encode(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	m := code.GetMatrix()
+	bits, _ := gozxing.NewBitMatrix(m.GetWidth(), m.GetHeight())
+	matrix := make([][]bool, m.GetHeight())
+	for y := range matrix {
+		matrix[y] = make([]bool, m.GetWidth())
+		for x := range matrix[y] {
+			if matrix[y][x] = m.Get(x, y) == 1; matrix[y][x] {
+				bits.Set(x, y)
+			}
+		}
+	}
+
+	parser, err := decoder.NewBitMatrixParser(bits)
+	if err != nil {
+		return nil, err
+	}
+	codewords, err := parser.ReadCodewords()
+	if err != nil {
+		return nil, err
+	}
+
+	blocks, err := decoder.DataBlock_GetDataBlocks(codewords, code.GetVersion(), code.GetECLevel())
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	for _, block := range blocks {
+		data = append(data, block.GetCodewords()[:block.GetNumDataCodewords()]...)
+	}
+
+	return &Encoding{
+		Version:         code.GetVersion().GetVersionNumber(),
+		ErrorCorrection: code.GetECLevel().String(),
+		Mode:            code.GetMode().String(),
+		Mask:            code.GetMaskPattern(),
+		DataCodewords:   data,
+		Codewords:       codewords,
+		Matrix:          matrix,
+	}, nil
+}
@@ -0,0 +1,30 @@
+package qrcode
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncoding(t *testing.T) {
+	qr, _ := Text("hello world")
+
+	got, err := qr.Encoding(&RenderOptions{})
+	require.NoError(t, err)
+	require.Equal(t, 1, got.Version)
+	require.Equal(t, "L", got.ErrorCorrection)
+	require.Equal(t, "BYTE", got.Mode)
+
+	// 0100 byte mode, 00001011 11 characters, "hello world", 0000 terminator, then 0xec 0x11 padding to 19 codewords
+	require.Equal(t, "40b68656c6c6f20776f726c640ec11ec11ec11", hex.EncodeToString(got.DataCodewords))
+	// single block: data followed by 7 error correction codewords
+	require.Len(t, got.Codewords, 26)
+	require.Equal(t, got.DataCodewords, got.Codewords[:19])
+
+	require.Equal(t, strings.TrimPrefix(goldenHelloWorld, "\n"), matrixString(got.Matrix))
+
+	_, err = qr.Encoding(&RenderOptions{ErrorCorrection: "X"})
+	require.Error(t, err)
+}
@@ -0,0 +1,140 @@
+package qrcode
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	ICalTime = "20060102T150405Z" // UTC date-time of iCalendar, RFC 5545 3.3.5
+	ICalDate = "20060102"         // date of iCalendar, RFC 5545 3.3.4
+)
+
+// Event calendar event of VEVENT; End is optional.
+// Start and End of all-day event are dates, and End is exclusive, the day after the last day, by the spec.
+type Event struct {
+	Summary     string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+	Location    string
+	Description string
+	URL         string
+	Geo         *Geo
+	Alarms      []string // TRIGGER durations of VALARMs, ex) -PT15M
+}
+
+// Geo latitude and longitude of GEO in degrees, RFC 5545 3.8.1.6
+type Geo struct {
+	Lat float64
+	Lon float64
+}
+
+func (g *Geo) validate() error {
+	if g.Lat < -90 || g.Lat > 90 {
+		return errors.New("latitude must be between -90 and 90")
+	}
+	if g.Lon < -180 || g.Lon > 180 {
+		return errors.New("longitude must be between -180 and 180")
+	}
+	return nil
+}
+
+func (g *Geo) String() string {
+	return strconv.FormatFloat(g.Lat, 'f', -1, 64) + ";" + strconv.FormatFloat(g.Lon, 'f', -1, 64)
+}
+
+// icalEscaper escape text value of iCalendar, RFC 5545 3.3.11
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// icalDuration duration of iCalendar, RFC 5545 3.3.6; `T` without time is rejected separately
+var icalDuration = regexp.MustCompile(`^[+-]?P(\d+W|\d+D(T(\d+H)?(\d+M)?(\d+S)?)?|T(\d+H)?(\d+M)?(\d+S)?)$`)
+
+// ValidDuration returns true if the duration is of iCalendar, ex) -PT15M, P1D
+func ValidDuration(s string) bool {
+	return icalDuration.MatchString(s) && !strings.HasSuffix(s, "T")
+}
+
+// icalLineOctets max octets of content line of iCalendar, without the line ending, RFC 5545 3.1
+const icalLineOctets = 75
+
+// icalFold fold the content line longer than icalLineOctets with CRLF and a space, without splitting utf-8 characters
+func icalFold(line string) string {
+	var b strings.Builder
+	for limit := icalLineOctets; len(line) > limit; limit = icalLineOctets - 1 {
+		i := limit
+		for i > 0 && !utf8.RuneStart(line[i]) {
+			i--
+		}
+		b.WriteString(line[:i])
+		b.WriteString("\r\n ")
+		line = line[i:]
+	}
+	b.WriteString(line)
+	return b.String()
+}
+
+// VEvent generate VEVENT of the event with CRLF line endings; times are formatted in UTC, and long lines are folded
+func VEvent(event *Event) (*QR, error) {
+	if event.Summary == "" {
+		return nil, errors.New("summary is required")
+	}
+	if event.Start.IsZero() {
+		return nil, errors.New("start is required")
+	}
+	if !event.End.IsZero() && event.End.Before(event.Start) {
+		return nil, errors.New("end is before start")
+	}
+	if event.AllDay && !event.End.IsZero() && !event.End.After(event.Start) {
+		return nil, errors.New("end is not after start")
+	}
+	if event.Geo != nil {
+		if err := event.Geo.validate(); err != nil {
+			return nil, err
+		}
+	}
+	for _, trigger := range event.Alarms {
+		if !ValidDuration(trigger) {
+			return nil, errors.New("invalid alarm duration: " + trigger)
+		}
+	}
+
+	// date-time in UTC, or date of all-day event as is
+	format := func(name string, t time.Time) string {
+		if event.AllDay {
+			return name + ";VALUE=DATE:" + t.Format(ICalDate)
+		}
+		return name + ":" + t.UTC().Format(ICalTime)
+	}
+
+	lines := []string{"BEGIN:VEVENT", "SUMMARY:" + icalEscaper.Replace(event.Summary), format("DTSTART", event.Start)}
+	if !event.End.IsZero() {
+		lines = append(lines, format("DTEND", event.End))
+	}
+	if event.Location != "" {
+		lines = append(lines, "LOCATION:"+icalEscaper.Replace(event.Location))
+	}
+	if event.Description != "" {
+		lines = append(lines, "DESCRIPTION:"+icalEscaper.Replace(event.Description))
+	}
+	if event.URL != "" {
+		lines = append(lines, "URL:"+event.URL)
+	}
+	if event.Geo != nil {
+		lines = append(lines, "GEO:"+event.Geo.String())
+	}
+	// DESCRIPTION is required for DISPLAY, RFC 5545 3.6.6
+	for _, trigger := range event.Alarms {
+		lines = append(lines, "BEGIN:VALARM", "ACTION:DISPLAY", "TRIGGER:"+trigger, "DESCRIPTION:"+icalEscaper.Replace(event.Summary), "END:VALARM")
+	}
+	lines = append(lines, "END:VEVENT")
+
+	for i, line := range lines {
+		lines[i] = icalFold(line)
+	}
+	return Text(strings.Join(lines, "\r\n"))
+}
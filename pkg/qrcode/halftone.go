@@ -0,0 +1,151 @@
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/makiuchi-d/gozxing/qrcode/decoder"
+	"github.com/makiuchi-d/gozxing/qrcode/encoder"
+)
+
+// halftoneSubModules sub-modules per side of a module; the center one keeps the module color for scanners
+// which sample the center of modules, and the others follow the image.
+const halftoneSubModules = 3
+
+var errHalftoneRaster = errors.New("halftone is supported for raster image only")
+
+// HalftoneError the code is too small for sub-module dots
+type HalftoneError struct {
+	Multiple int // pixels per module
+}
+
+func (e *HalftoneError) Error() string {
+	return fmt.Sprintf("halftone requires at least %d pixels per module but %d, increase the size or shorten the content",
+		halftoneSubModules, e.Multiple)
+}
+
+func (l *layout) checkHalftone(opts *RenderOptions) error {
+	if opts.Halftone != nil && l.multiple < halftoneSubModules {
+		return &HalftoneError{Multiple: l.multiple}
+	}
+	return nil
+}
+
+// drawHalftone draw modules as 3x3 sub-module dots of the halftone image;
+// function patterns are drawn as whole modules to keep them dominant.
+func drawHalftone(img *image.RGBA, matrix *encoder.ByteMatrix, l *layout, opts *RenderOptions) {
+	size := matrix.GetWidth()
+	n := size * halftoneSubModules
+	dots := halftoneDots(opts.Halftone, n)
+	isFunction := functionPatterns(size)
+	fg := image.NewUniform(opts.foreground())
+
+	// pixel offsets of sub-modules in a module; the center gets the remainder of the division
+	edge := l.multiple / halftoneSubModules
+	edges := [halftoneSubModules + 1]int{0, edge, l.multiple - edge, l.multiple}
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dark := matrix.Get(x, y) == 1
+			rect := image.Rect(0, 0, l.multiple, l.multiple).Add(image.Pt(l.left+x*l.multiple, l.top+y*l.multiple))
+			if isFunction(x, y) {
+				if dark {
+					draw.Draw(img, rect, image.NewUniform(opts.moduleColor(x, y, size)), image.Point{}, draw.Src)
+				}
+				continue
+			}
+
+			for j := 0; j < halftoneSubModules; j++ {
+				for i := 0; i < halftoneSubModules; i++ {
+					on := dots[(y*halftoneSubModules+j)*n+x*halftoneSubModules+i]
+					if i == halftoneSubModules/2 && j == halftoneSubModules/2 {
+						on = dark
+					}
+					if !on {
+						continue
+					}
+
+					sub := image.Rect(edges[i], edges[j], edges[i+1], edges[j+1]).Add(rect.Min)
+					draw.Draw(img, sub, fg, image.Point{}, draw.Src)
+				}
+			}
+		}
+	}
+}
+
+// halftoneDots returns dark dots of n x n grid over the image by Floyd-Steinberg error diffusion of the luminance,
+// so that density of dots follows the brightness.
+func halftoneDots(img image.Image, n int) []bool {
+	b := img.Bounds()
+	levels := make([]float64, n*n)
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			levels[y*n+x] = luminance(img.At(b.Min.X+(2*x+1)*b.Dx()/(2*n), b.Min.Y+(2*y+1)*b.Dy()/(2*n)))
+		}
+	}
+
+	dots := make([]bool, n*n)
+	diffuse := func(x, y int, e float64) {
+		if x >= 0 && x < n && y < n {
+			levels[y*n+x] += e
+		}
+	}
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			v := levels[y*n+x]
+			quantized := 1.0
+			if v < 0.5 {
+				dots[y*n+x], quantized = true, 0
+			}
+
+			e := v - quantized
+			diffuse(x+1, y, e*7/16)
+			diffuse(x-1, y+1, e*3/16)
+			diffuse(x, y+1, e*5/16)
+			diffuse(x+1, y+1, e*1/16)
+		}
+	}
+	return dots
+}
+
+// luminance returns luminance 0..1 of the color over white
+func luminance(c color.Color) float64 {
+	r, g, b, a := c.RGBA()
+	y := (19595*r + 38470*g + 7471*b + 1<<15) >> 16
+	return float64(y+0xffff-a) / 0xffff
+}
+
+// functionPatterns returns checker of modules which are drawn whole in halftone; finder patterns with separators,
+// timing patterns, alignment patterns, format and version information.
+func functionPatterns(size int) func(x, y int) bool {
+	var centers []int
+	version, err := decoder.Version_GetVersionForNumber((size - 17) / 4)
+	if err == nil {
+		centers = version.GetAlignmentPatternCenters()
+	}
+
+	near := func(a, b int) bool { return a-b >= -2 && a-b <= 2 }
+	return func(x, y int) bool {
+		const separator = finderSize + 1
+		switch {
+		case x <= separator && y <= separator, x >= size-separator && y <= separator, x <= separator && y >= size-separator:
+			return true // finder patterns, separators and format information
+		case x == 6 || y == 6:
+			return true // timing patterns
+		case size >= 45 && ((x >= size-11 && x < size-8 && y < 6) || (y >= size-11 && y < size-8 && x < 6)):
+			return true // version information from version 7
+		}
+
+		for _, cy := range centers {
+			for _, cx := range centers {
+				if near(x, cx) && near(y, cy) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,103 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testGradient returns horizontal gradient from black to white
+func testGradient(width, height int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetGray(x, y, color.Gray{Y: uint8(x * 255 / (width - 1))})
+		}
+	}
+	return img
+}
+
+// testDisc returns dark disc on white
+func testDisc(size int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	r := size / 3
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			c := uint8(0xff)
+			if dx, dy := x-size/2, y-size/2; dx*dx+dy*dy < r*r {
+				c = 0x30
+			}
+			img.SetGray(x, y, color.Gray{Y: c})
+		}
+	}
+	return img
+}
+
+func TestRenderHalftone(t *testing.T) {
+	tests := [...]struct {
+		name     string
+		content  string
+		halftone image.Image
+		ec       string
+		size     int
+	}{
+		{"gradient", "https://github.com/whitekid/qrcodeapi", testGradient(300, 100), "H", 200},
+		{"disc", "https://github.com/whitekid/qrcodeapi", testDisc(64), "H", 200},
+		{"low level", "hello world", testDisc(64), "L", 200},
+		{"large", "https://github.com/whitekid/qrcodeapi", testGradient(64, 64), "Q", 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qr, _ := Text(tt.content)
+			opts := &RenderOptions{Width: tt.size, Height: tt.size, ErrorCorrection: tt.ec, Halftone: tt.halftone}
+			img, err := qr.RenderWithOptions(opts)
+			require.NoError(t, err)
+
+			got, err := Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, tt.content, got)
+
+			// image shows through: the halftone differs from the plain code
+			plain, err := qr.RenderWithOptions(&RenderOptions{Width: tt.size, Height: tt.size, ErrorCorrection: tt.ec})
+			require.NoError(t, err)
+			require.NotEqual(t, plain.(*image.RGBA).Pix, img.(*image.RGBA).Pix)
+		})
+	}
+}
+
+func TestHalftoneDots(t *testing.T) {
+	// density of dots follows the luminance
+	dots := halftoneDots(testGradient(300, 100), 30)
+	dark := func(x0, x1 int) int {
+		n := 0
+		for y := 0; y < 30; y++ {
+			for x := x0; x < x1; x++ {
+				if dots[y*30+x] {
+					n++
+				}
+			}
+		}
+		return n
+	}
+	require.Greater(t, dark(0, 10), dark(10, 20))
+	require.Greater(t, dark(10, 20), dark(20, 30))
+}
+
+func TestHalftoneError(t *testing.T) {
+	qr, _ := Text("https://github.com/whitekid/qrcodeapi")
+
+	// 2 pixels per module
+	_, err := qr.RenderWithOptions(&RenderOptions{Width: 80, Height: 80, Halftone: testDisc(64)})
+	var halftoneErr *HalftoneError
+	require.True(t, errors.As(err, &halftoneErr))
+	require.Equal(t, 2, halftoneErr.Multiple)
+
+	var buf bytes.Buffer
+	opts := &RenderOptions{Width: 200, Height: 200, Halftone: testDisc(64)}
+	require.Error(t, qr.RenderSVG(&buf, opts))
+	require.Error(t, qr.RenderText(&buf, opts))
+}
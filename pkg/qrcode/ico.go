@@ -0,0 +1,70 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"io"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// IconSizes sizes of images of ico, in pixels
+var IconSizes = []int{16, 32, 64}
+
+// RenderICO render qrcode as ico of IconSizes for site icons; each image is rendered from the matrix in the size
+// as png, the format of Windows Vista or later, and scaled down if the code with the quiet zone does not fit in it.
+func (q *QR) RenderICO(w io.Writer, opts *RenderOptions) error {
+	images := make([][]byte, len(IconSizes))
+	for i, size := range IconSizes {
+		iconOpts := *opts
+		iconOpts.Width, iconOpts.Height = size, size
+		img, err := q.RenderWithOptions(&iconOpts)
+		if err != nil {
+			return err
+		}
+
+		if bounds := img.Bounds(); bounds.Dx() != size || bounds.Dy() != size {
+			scaled := image.NewRGBA(image.Rect(0, 0, size, size))
+			xdraw.BiLinear.Scale(scaled, scaled.Bounds(), img, bounds, xdraw.Src, nil)
+			img = scaled
+		}
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return err
+		}
+		images[i] = buf.Bytes()
+	}
+
+	return writeICO(w, IconSizes, images)
+}
+
+// writeICO write ico of the png images; ICONDIR header, ICONDIRENTRY of each image and the images
+func writeICO(w io.Writer, sizes []int, images [][]byte) error {
+	const headerSize, entrySize = 6, 16
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, [3]uint16{0, 1, uint16(len(images))}) // reserved, type of icon, count
+
+	offset := headerSize + entrySize*len(images)
+	for i, data := range images {
+		// width and height of 256 are 0
+		size := uint8(sizes[i] % 256)
+		entry := struct {
+			Width, Height, Colors, Reserved uint8
+			Planes, BitCount                uint16
+			Size, Offset                    uint32
+		}{size, size, 0, 0, 1, 32, uint32(len(data)), uint32(offset)}
+		binary.Write(&buf, binary.LittleEndian, entry)
+		offset += len(data)
+	}
+
+	for _, data := range images {
+		buf.Write(data)
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,52 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// icoEntry ICONDIRENTRY
+type icoEntry struct {
+	Width, Height, Colors, Reserved uint8
+	Planes, BitCount                uint16
+	Size, Offset                    uint32
+}
+
+func TestRenderICO(t *testing.T) {
+	qr, _ := Text("hello")
+	var buf bytes.Buffer
+	require.NoError(t, qr.RenderICO(&buf, &RenderOptions{Width: 200, Height: 200}))
+	data := buf.Bytes()
+
+	var header [3]uint16
+	require.NoError(t, binary.Read(bytes.NewReader(data), binary.LittleEndian, &header))
+	require.Equal(t, [3]uint16{0, 1, 3}, header)
+
+	entries := make([]icoEntry, header[2])
+	require.NoError(t, binary.Read(bytes.NewReader(data[6:]), binary.LittleEndian, entries))
+
+	sizes := []int{}
+	for _, entry := range entries {
+		require.Equal(t, entry.Width, entry.Height)
+		require.Equal(t, uint16(32), entry.BitCount)
+		sizes = append(sizes, int(entry.Width))
+
+		img, err := png.Decode(bytes.NewReader(data[entry.Offset : entry.Offset+entry.Size]))
+		require.NoError(t, err)
+		require.Equal(t, int(entry.Width), img.Bounds().Dx())
+		require.Equal(t, int(entry.Height), img.Bounds().Dy())
+
+		// code of the size is rendered from the matrix
+		if entry.Width == 64 {
+			got, err := Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, "hello", got)
+		}
+	}
+	require.Equal(t, IconSizes, sizes)
+	require.Equal(t, uint32(len(data)), entries[2].Offset+entries[2].Size)
+}
@@ -0,0 +1,143 @@
+package qrcode
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const labelPadding = 4
+
+// label size limits; pixels, or ratio of the image width if less than 1
+const (
+	DefaultLabelSize = 0.065 // 13 pixels for 200 pixels width
+	minLabelPixels   = 6
+	maxLabelPixels   = 64
+	minLabelRatio    = 0.02
+)
+
+var labelFont = func() *opentype.Font {
+	f, err := opentype.Parse(gomono.TTF)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}()
+
+// ParseLabelSize parse label font size, pixels(`14`) or ratio of the image width(`0.1`); DefaultLabelSize if empty
+func ParseLabelSize(s string) (float64, error) {
+	if s == "" {
+		return DefaultLabelSize, nil
+	}
+
+	size, err := strconv.ParseFloat(s, 64)
+	switch {
+	case err != nil:
+		return 0, err
+	case size >= 1 && (size < minLabelPixels || size > maxLabelPixels):
+		return 0, errors.New("label size should be between 6 and 64 pixels: " + s)
+	case size < 1 && size < minLabelRatio:
+		return 0, errors.New("label size ratio should be between 0.02 and 1: " + s)
+	}
+	return size, nil
+}
+
+// labelLayout label wrapped to the image width
+type labelLayout struct {
+	face       font.Face
+	size       int // font size in pixels
+	lines      []string
+	lineHeight int
+}
+
+// newLabelLayout wrap the label by words to fit the width; size is pixels or ratio of the width
+func newLabelLayout(label string, width int, size float64) *labelLayout {
+	if size == 0 {
+		size = DefaultLabelSize
+	}
+	if size < 1 {
+		size *= float64(width)
+	}
+	pixels := int(size + 0.5)
+	if pixels < minLabelPixels {
+		pixels = minLabelPixels
+	}
+
+	face, err := opentype.NewFace(labelFont, &opentype.FaceOptions{Size: float64(pixels), DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		panic(err) // only for invalid options
+	}
+
+	l := &labelLayout{face: face, size: pixels, lineHeight: face.Metrics().Height.Ceil()}
+	l.lines = wrapLabel(face, label, fixed.I(width-labelPadding*2))
+	return l
+}
+
+// height returns height of label strip under the code
+func (l *labelLayout) height() int { return l.lineHeight*len(l.lines) + labelPadding*2 }
+
+// LabelHeight returns height of the label strip in the image of the width; size is pixels or ratio of the width
+func LabelHeight(label string, width int, size float64) int {
+	if label == "" {
+		return 0
+	}
+	return newLabelLayout(label, width, size).height()
+}
+
+// baseline returns baseline of the line in the label strip starts at top
+func (l *labelLayout) baseline(top, line int) int {
+	return top + labelPadding + l.face.Metrics().Ascent.Ceil() + l.lineHeight*line
+}
+
+// wrapLabel break label into lines by words; words longer than the width are broken by characters
+func wrapLabel(face font.Face, label string, width fixed.Int26_6) []string {
+	lines := []string{}
+	line := ""
+	for _, word := range strings.Fields(label) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+
+		if font.MeasureString(face, candidate) <= width {
+			line = candidate
+			continue
+		}
+
+		if line != "" {
+			lines = append(lines, line)
+		}
+
+		// break long word
+		line = ""
+		for _, r := range word {
+			if line != "" && font.MeasureString(face, line+string(r)) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			line += string(r)
+		}
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// drawLabel draw label lines centered horizontally in the strip starts at top.
+// glyphs are anti-aliased masks of the font, independent of the modules which are always drawn hard edged.
+func drawLabel(img draw.Image, l *labelLayout, top int, src image.Image) {
+	d := &font.Drawer{Dst: img, Src: src, Face: l.face}
+	for i, line := range l.lines {
+		width := d.MeasureString(line).Ceil()
+		d.Dot = fixed.P((img.Bounds().Dx()-width)/2, l.baseline(top, i))
+		d.DrawString(line)
+	}
+}
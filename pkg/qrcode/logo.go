@@ -0,0 +1,88 @@
+package qrcode
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/whitekid/goxp/fx"
+	xdraw "golang.org/x/image/draw"
+)
+
+// logoRatio logo size relative to the code area, covers about 5% of the code
+const logoRatio = 0.22
+
+// logoRect returns logo rect fit in the center of the code area keeping aspect ratio
+// and background box of the logo aligned to modules.
+func logoRect(logo image.Rectangle, code image.Rectangle, multiple int) (image.Rectangle, image.Rectangle) {
+	size := int(float64(code.Dx()) * logoRatio)
+	width, height := size, size
+	if logo.Dx() > logo.Dy() {
+		height = size * logo.Dy() / logo.Dx()
+	} else {
+		width = size * logo.Dx() / logo.Dy()
+	}
+
+	center := image.Pt(code.Min.X+code.Dx()/2, code.Min.Y+code.Dy()/2)
+	rect := image.Rect(center.X-width/2, center.Y-height/2, center.X-width/2+width, center.Y-height/2+height)
+
+	box := rect.Inset(-multiple)
+	return rect, box
+}
+
+// error tolerance of error correction levels, ratio of recoverable codewords
+var errorTolerance = map[string]float64{"L": 0.07, "M": 0.15, "Q": 0.25, "H": 0.30}
+
+// knockoutSafety ratio of the tolerance used by knockout; a square area damages more codewords than its modules
+// because codewords are laid out in zigzag columns, and modules around it can be misread.
+const knockoutSafety = 0.3
+
+// knockoutModules returns the largest odd side in modules of central knockout which the error correction level can recover
+func knockoutModules(size int, level string) int {
+	n := int(math.Sqrt(errorTolerance[level] * knockoutSafety * float64(size*size)))
+	if n%2 == 0 {
+		n--
+	}
+	return fx.Max([]int{n, 0})
+}
+
+// KnockoutModules returns side in modules of the central knockout of `LogoAuto`, 0 if not enabled
+func (q *QR) KnockoutModules(opts *RenderOptions) (int, error) {
+	if !opts.LogoAuto {
+		return 0, nil
+	}
+
+	code, err := q.encode(opts)
+	if err != nil {
+		return 0, err
+	}
+	return knockoutModules(code.GetMatrix().GetWidth(), code.GetECLevel().String()), nil
+}
+
+// knockoutRect returns central knockout of n modules and the logo fit in it keeping aspect ratio
+func knockoutRect(logo image.Rectangle, code image.Rectangle, multiple, size, n int) (image.Rectangle, image.Rectangle) {
+	offset := (size - n) / 2 * multiple
+	box := image.Rect(code.Min.X+offset, code.Min.Y+offset, code.Min.X+offset+n*multiple, code.Min.Y+offset+n*multiple)
+	if logo.Empty() {
+		return image.Rectangle{}, box
+	}
+
+	rect := box.Inset(multiple)
+	width, height := rect.Dx(), rect.Dy()
+	if logo.Dx() > logo.Dy() {
+		height = width * logo.Dy() / logo.Dx()
+	} else {
+		width = height * logo.Dx() / logo.Dy()
+	}
+	center := image.Pt(rect.Min.X+rect.Dx()/2, rect.Min.Y+rect.Dy()/2)
+	return image.Rect(center.X-width/2, center.Y-height/2, center.X-width/2+width, center.Y-height/2+height), box
+}
+
+// drawLogo draw logo scaled to the rect over background box
+func drawLogo(img draw.Image, logo image.Image, rect, box image.Rectangle, background color.Color) {
+	draw.Draw(img, box, image.NewUniform(background), image.Point{}, draw.Src)
+	if logo != nil {
+		xdraw.CatmullRom.Scale(img, rect, logo, logo.Bounds(), draw.Over, nil)
+	}
+}
@@ -0,0 +1,110 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testLogo() image.Image {
+	logo := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	draw.Draw(logo, logo.Bounds(), image.NewUniform(color.RGBA{0xff, 0x00, 0x00, 0xff}), image.Point{}, draw.Src)
+	return logo
+}
+
+func TestRenderLogo(t *testing.T) {
+	qr, _ := Text("https://github.com/whitekid/qrcodeapi")
+
+	opts := &RenderOptions{Width: 200, Height: 200, ErrorCorrection: "H", Logo: testLogo()}
+	img, err := qr.RenderWithOptions(opts)
+	require.NoError(t, err)
+	require.Equal(t, color.RGBA{0xff, 0x00, 0x00, 0xff}, img.At(100, 100))
+
+	got, err := Decode(img)
+	require.NoError(t, err)
+	require.Equal(t, "https://github.com/whitekid/qrcodeapi", got)
+}
+
+func TestRenderSVGLogo(t *testing.T) {
+	qr, _ := Text("https://github.com/whitekid/qrcodeapi")
+
+	var buf bytes.Buffer
+	require.NoError(t, qr.RenderSVG(&buf, &RenderOptions{Width: 200, Height: 200, ErrorCorrection: "H", Logo: testLogo()}))
+
+	var svg struct {
+		Image struct {
+			Width  int    `xml:"width,attr"`
+			Height int    `xml:"height,attr"`
+			Href   string `xml:"href,attr"`
+		} `xml:"image"`
+	}
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &svg))
+	require.True(t, strings.HasPrefix(svg.Image.Href, "data:image/png;base64,"))
+	require.Equal(t, 4*svg.Image.Height/3, svg.Image.Width, "aspect ratio is kept")
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(svg.Image.Href, "data:image/png;base64,"))
+	require.NoError(t, err)
+	logo, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, image.Pt(64, 48), logo.Bounds().Size())
+}
+
+func TestKnockoutModules(t *testing.T) {
+	tests := [...]struct {
+		size  int
+		level string
+		want  int
+	}{
+		{21, "L", 3},
+		{21, "H", 5},
+		{57, "M", 11},
+		{57, "H", 17},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, knockoutModules(tt.size, tt.level), "size=%d, level=%s", tt.size, tt.level)
+	}
+}
+
+func TestRenderLogoAuto(t *testing.T) {
+	content := "https://github.com/whitekid/qrcodeapi"
+	qr, _ := Text(content)
+
+	tests := [...]struct {
+		name string
+		logo image.Image
+	}{
+		{"logo", testLogo()},
+		{"knockout only", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &RenderOptions{Width: 200, Height: 200, ErrorCorrection: "H", Logo: tt.logo, LogoAuto: true}
+			img, err := qr.RenderWithOptions(opts)
+			require.NoError(t, err)
+
+			n, err := qr.KnockoutModules(opts)
+			require.NoError(t, err)
+			require.Equal(t, 11, n)
+
+			// knockout is larger than the fixed logo box
+			code, err := qr.encode(opts)
+			require.NoError(t, err)
+			l := newLayout(code.GetMatrix(), opts)
+			fixed := newLayout(code.GetMatrix(), &RenderOptions{Width: 200, Height: 200, ErrorCorrection: "H", Logo: testLogo()})
+			require.Equal(t, n*l.multiple, l.logoBox.Dx())
+			require.Greater(t, l.logoBox.Dx(), fixed.logoBox.Dx())
+
+			got, err := Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, content, got)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package qrcode
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode/decoder"
+	"github.com/makiuchi-d/gozxing/qrcode/encoder"
+)
+
+// mask selection strategies; a mask pattern 0..7 can be given instead
+const (
+	MaskAuto       = "auto"        // the lowest penalty score of the standard
+	MaskAutoCenter = "auto-center" // the least dark modules in the central logo region
+)
+
+// Masks supported mask selection strategies
+var Masks = []string{MaskAuto, MaskAutoCenter}
+
+// ParseMask returns normalized mask strategy or pattern, auto if empty
+func ParseMask(s string) (string, error) {
+	s = strings.ToLower(s)
+	switch s {
+	case "":
+		return MaskAuto, nil
+	case MaskAuto, MaskAutoCenter:
+		return s, nil
+	}
+
+	if pattern, err := strconv.Atoi(s); err == nil && encoder.QRCode_IsValidMaskPattern(pattern) {
+		return strconv.Itoa(pattern), nil
+	}
+	return "", errors.New("invalid mask: " + s)
+}
+
+// encodeMask encode the content with the mask of the options
+func (q *QR) encodeMask(level decoder.ErrorCorrectionLevel, opts *RenderOptions) (*encoder.QRCode, error) {
+	switch opts.Mask {
+	case "", MaskAuto:
+		code, err := encoder.Encoder_encode(q.Content, level, encodeHints(opts))
+		if err != nil {
+			return nil, err
+		}
+		return code, nil
+
+	case MaskAutoCenter:
+		return q.encodeCenterMask(level, opts)
+	}
+
+	pattern, err := strconv.Atoi(opts.Mask)
+	if err != nil || !encoder.QRCode_IsValidMaskPattern(pattern) {
+		return nil, errors.New("invalid mask: " + opts.Mask)
+	}
+	return encodeWithMask(q.Content, level, pattern, opts)
+}
+
+// encodeCenterMask returns the code of the mask which has the least dark modules in the center,
+// so that the logo box hides less of the code; the standard choice is kept on ties.
+func (q *QR) encodeCenterMask(level decoder.ErrorCorrectionLevel, opts *RenderOptions) (*encoder.QRCode, error) {
+	auto, err := encoder.Encoder_encode(q.Content, level, encodeHints(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	best := auto
+	bestDark := darkModules(auto, centerModules(auto, opts))
+	for pattern := 0; pattern < encoder.QRCode_NUM_MASK_PATERNS; pattern++ {
+		if pattern == auto.GetMaskPattern() {
+			continue
+		}
+
+		code, err := encodeWithMask(q.Content, level, pattern, opts)
+		if err != nil {
+			return nil, err
+		}
+		if dark := darkModules(code, centerModules(code, opts)); dark < bestDark {
+			best, bestDark = code, dark
+		}
+	}
+	return best, nil
+}
+
+func encodeWithMask(content string, level decoder.ErrorCorrectionLevel, pattern int, opts *RenderOptions) (*encoder.QRCode, error) {
+	hints := encodeHints(opts)
+	hints[gozxing.EncodeHintType_QR_MASK_PATTERN] = pattern
+	code, err := encoder.Encoder_encode(content, level, hints)
+	if err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// centerModules returns odd side in modules of the central region covered by the logo box
+func centerModules(code *encoder.QRCode, opts *RenderOptions) int {
+	size := code.GetMatrix().GetWidth()
+	if opts.LogoAuto {
+		return knockoutModules(size, code.GetECLevel().String())
+	}
+
+	// logo and a module of the box padding on each side
+	n := int(float64(size)*logoRatio) + 2
+	if n%2 == 0 {
+		n++
+	}
+	return n
+}
+
+// darkModules returns number of dark modules in the central n x n region
+func darkModules(code *encoder.QRCode, n int) int {
+	m := code.GetMatrix()
+	offset := (m.GetWidth() - n) / 2
+
+	dark := 0
+	for y := offset; y < offset+n; y++ {
+		for x := offset; x < offset+n; x++ {
+			if m.Get(x, y) == 1 {
+				dark++
+			}
+		}
+	}
+	return dark
+}
@@ -0,0 +1,95 @@
+package qrcode
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMask(t *testing.T) {
+	tests := [...]struct {
+		name    string
+		arg     string
+		want    string
+		wantErr bool
+	}{
+		{"default", "", MaskAuto, false},
+		{"auto", "auto", MaskAuto, false},
+		{"auto center", "Auto-Center", MaskAutoCenter, false},
+		{"pattern", "7", "7", false},
+		{"invalid pattern", "8", "", true},
+		{"invalid", "center", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMask(tt.arg)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMaskAutoCenter(t *testing.T) {
+	tests := [...]struct {
+		name    string
+		content string
+		opts    RenderOptions
+	}{
+		{"logo", "https://github.com/whitekid/qrcodeapi", RenderOptions{Width: 200, Height: 200, ErrorCorrection: "H", Logo: testLogo()}},
+		{"knockout", "https://github.com/whitekid/qrcodeapi", RenderOptions{Width: 200, Height: 200, ErrorCorrection: "H", LogoAuto: true}},
+		{"text", "hello world", RenderOptions{Width: 200, Height: 200, ErrorCorrection: "Q", Logo: testLogo()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qr, _ := Text(tt.content)
+
+			density := func(mask string) int {
+				opts := tt.opts
+				opts.Mask = mask
+				code, err := qr.encode(&opts)
+				require.NoError(t, err)
+				return darkModules(code, centerModules(code, &opts))
+			}
+
+			// auto-center is the least dense of all patterns, and no denser than the standard choice
+			center := density(MaskAutoCenter)
+			require.LessOrEqual(t, center, density(MaskAuto))
+			for pattern := 0; pattern < 8; pattern++ {
+				require.LessOrEqual(t, center, density(strconv.Itoa(pattern)))
+			}
+
+			opts := tt.opts
+			opts.Mask = MaskAutoCenter
+			img, err := qr.RenderWithOptions(&opts)
+			require.NoError(t, err)
+
+			got, err := Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, tt.content, got)
+		})
+	}
+}
+
+func TestMaskPattern(t *testing.T) {
+	qr, _ := Text("hello world")
+
+	for pattern := 0; pattern < 8; pattern++ {
+		t.Run(strconv.Itoa(pattern), func(t *testing.T) {
+			opts := &RenderOptions{Width: 200, Height: 200, Mask: strconv.Itoa(pattern)}
+			code, err := qr.encode(opts)
+			require.NoError(t, err)
+			require.Equal(t, pattern, code.GetMaskPattern())
+
+			img, err := qr.RenderWithOptions(opts)
+			require.NoError(t, err)
+			got, err := Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, "hello world", got)
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package qrcode
+
+// Matrix returns module matrix of the code without quiet zone, matrix[y][x] is true for dark module.
+// It is intended to pin exact module placement in tests.
+func (q *QR) Matrix(opts *RenderOptions) ([][]bool, error) {
+	code, err := q.encode(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	m := code.GetMatrix()
+	matrix := make([][]bool, m.GetHeight())
+	for y := range matrix {
+		matrix[y] = make([]bool, m.GetWidth())
+		for x := range matrix[y] {
+			matrix[y][x] = m.Get(x, y) == 1
+		}
+	}
+	return matrix, nil
+}
@@ -0,0 +1,89 @@
+package qrcode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// golden matrix of "hello world" with error correction level L, version 1
+const goldenHelloWorld = `
+#######..#.##.#######
+#.....#.##.#..#.....#
+#.###.#.##..#.#.###.#
+#.###.#..#.#..#.###.#
+#.###.#.#...#.#.###.#
+#.....#.#..##.#.....#
+#######.#.#.#.#######
+........#####........
+##.#..##.##...###.##.
+...#.#.####...###..##
+#.#..##..##.#..#.##.#
+.##.##.#####..#.##.##
+###.#.##..#.##.##....
+........#.##......#.#
+#######.#.....######.
+#.....#..####..#....#
+#.###.#....#.#.....#.
+#.###.#.###....######
+#.###.#..#..#.#.#.#.#
+#.....#.#..#.#.......
+#######.##..#.##.#.#.
+`
+
+func matrixString(matrix [][]bool) string {
+	var b strings.Builder
+	for _, row := range matrix {
+		for _, dark := range row {
+			if dark {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func TestMatrix(t *testing.T) {
+	qr, _ := Text("hello world")
+
+	matrix, err := qr.Matrix(&RenderOptions{})
+	require.NoError(t, err)
+	require.Equal(t, strings.TrimPrefix(goldenHelloWorld, "\n"), matrixString(matrix))
+}
+
+func TestMatrixAlignmentPattern(t *testing.T) {
+	type args struct {
+		content string
+		ec      string
+	}
+	tests := [...]struct {
+		name       string
+		args       args
+		wantSize   int
+		wantCenter int
+	}{
+		{"version 3", args{"hello world, hello world, hello", "M"}, 29, 22},
+		{"version 4", args{"https://github.com/whitekid/qrcodeapi/blob/main/README.md?q=1", "M"}, 33, 26},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qr, _ := Text(tt.args.content)
+			matrix, err := qr.Matrix(&RenderOptions{ErrorCorrection: tt.args.ec})
+			require.NoError(t, err)
+			require.Len(t, matrix, tt.wantSize)
+
+			// 5x5 alignment pattern: dark ring, light ring and dark center
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					ring := dx*dx > 1 || dy*dy > 1
+					center := dx == 0 && dy == 0
+					require.Equalf(t, ring || center, matrix[tt.wantCenter+dy][tt.wantCenter+dx], "module (%d, %d)", tt.wantCenter+dx, tt.wantCenter+dy)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package qrcode
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// ContentChunkKey keyword of png text chunk of the encoded content
+const ContentChunkKey = "qr-content"
+
+var (
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+	errNotPNG       = errors.New("not a png image")
+	errMalformedPNG = errors.New("malformed png chunk")
+)
+
+// EmbedContent returns the png with the content in iTXt chunk of ContentChunkKey after IHDR, so that the content
+// is recovered without decoding the code; image/png does not write ancillary chunks.
+func EmbedContent(png []byte, content string) ([]byte, error) {
+	if !bytes.HasPrefix(png, pngSignature) {
+		return nil, errNotPNG
+	}
+
+	// IHDR is always the first chunk
+	ihdrEnd := len(pngSignature) + 8 + 13 + 4
+	if len(png) < ihdrEnd || string(png[len(pngSignature)+4:len(pngSignature)+8]) != "IHDR" {
+		return nil, errMalformedPNG
+	}
+
+	// keyword, null, compression flag and method, empty language tag and translated keyword, text
+	var data bytes.Buffer
+	data.WriteString(ContentChunkKey)
+	data.Write([]byte{0, 0, 0, 0, 0})
+	data.WriteString(content)
+
+	out := bytes.NewBuffer(make([]byte, 0, len(png)+data.Len()+12))
+	out.Write(png[:ihdrEnd])
+	writePNGChunk(out, "iTXt", data.Bytes())
+	out.Write(png[ihdrEnd:])
+	return out.Bytes(), nil
+}
+
+// EmbeddedContent returns the content of tEXt, zTXt or iTXt chunk of ContentChunkKey of the png, false if it is not embedded
+func EmbeddedContent(png []byte) (string, bool, error) {
+	if !bytes.HasPrefix(png, pngSignature) {
+		return "", false, errNotPNG
+	}
+
+	for rest := png[len(pngSignature):]; len(rest) > 0; {
+		if len(rest) < 12 {
+			return "", false, errMalformedPNG
+		}
+		length := binary.BigEndian.Uint32(rest)
+		if uint64(len(rest)) < 12+uint64(length) {
+			return "", false, errMalformedPNG
+		}
+		name, data := string(rest[4:8]), rest[8:8+length]
+		rest = rest[12+length:]
+
+		switch name {
+		case "IEND":
+			return "", false, nil
+		case "tEXt", "zTXt", "iTXt":
+			keyword, text, ok := bytes.Cut(data, []byte{0})
+			if !ok || string(keyword) != ContentChunkKey {
+				continue
+			}
+			content, err := chunkText(name, text)
+			return content, err == nil, err
+		}
+	}
+	return "", false, errMalformedPNG
+}
+
+// chunkText returns text of the text chunk after the keyword; tEXt and zTXt are latin-1, iTXt is utf-8
+func chunkText(name string, data []byte) (string, error) {
+	switch name {
+	case "tEXt":
+		return charmap.ISO8859_1.NewDecoder().String(string(data))
+
+	case "zTXt":
+		if len(data) < 1 {
+			return "", errMalformedPNG
+		}
+		text, err := inflate(data[1:])
+		if err != nil {
+			return "", err
+		}
+		return charmap.ISO8859_1.NewDecoder().String(string(text))
+
+	default:
+		if len(data) < 2 {
+			return "", errMalformedPNG
+		}
+		compressed := data[0] == 1
+		// language tag and translated keyword
+		parts := bytes.SplitN(data[2:], []byte{0}, 3)
+		if len(parts) != 3 {
+			return "", errMalformedPNG
+		}
+		text := parts[2]
+		if compressed {
+			var err error
+			if text, err = inflate(text); err != nil {
+				return "", err
+			}
+		}
+		return string(text), nil
+	}
+}
+
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func writePNGChunk(w *bytes.Buffer, name string, data []byte) {
+	binary.Write(w, binary.BigEndian, uint32(len(data)))
+	crc := crc32.NewIEEE()
+	io.MultiWriter(w, crc).Write(append([]byte(name), data...))
+	binary.Write(w, binary.BigEndian, crc.Sum32())
+}
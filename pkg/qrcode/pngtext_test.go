@@ -0,0 +1,79 @@
+package qrcode
+
+import (
+	"bytes"
+	"compress/zlib"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbedContent(t *testing.T) {
+	qr, _ := Text("https://example.com/?q=한글")
+	img, err := qr.Render(100, 100)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	_, ok, err := EmbeddedContent(buf.Bytes())
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	got, err := EmbedContent(buf.Bytes(), qr.Content)
+	require.NoError(t, err)
+
+	// image is not changed
+	decoded, err := png.Decode(bytes.NewReader(got))
+	require.NoError(t, err)
+	require.Equal(t, img.Bounds(), decoded.Bounds())
+
+	content, ok, err := EmbeddedContent(got)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, qr.Content, content)
+
+	_, err = EmbedContent([]byte("GIF89a"), "hello")
+	require.Error(t, err)
+}
+
+func TestEmbeddedContent(t *testing.T) {
+	qr, _ := Text("hello")
+	img, _ := qr.Render(100, 100)
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	ihdrEnd := len(pngSignature) + 25
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte("caf\xe9"))
+	zw.Close()
+
+	tests := [...]struct {
+		name  string
+		chunk string
+		data  []byte
+		want  string
+	}{
+		{"tEXt", "tEXt", []byte("qr-content\x00caf\xe9"), "café"},
+		{"zTXt", "zTXt", append([]byte("qr-content\x00\x00"), compressed.Bytes()...), "café"},
+		{"iTXt", "iTXt", []byte("qr-content\x00\x00\x00ko\x00\x00café"), "café"},
+		{"other keyword", "tEXt", []byte("Comment\x00hello"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			out.Write(buf.Bytes()[:ihdrEnd])
+			writePNGChunk(&out, tt.chunk, tt.data)
+			out.Write(buf.Bytes()[ihdrEnd:])
+
+			got, ok, err := EmbeddedContent(out.Bytes())
+			require.NoError(t, err)
+			require.Equal(t, tt.want != "", ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	_, _, err := EmbeddedContent(buf.Bytes()[:ihdrEnd+4])
+	require.Error(t, err)
+}
@@ -3,12 +3,9 @@ package qrcode
 import (
 	"bytes"
 	"fmt"
-	"image"
 	"strings"
 
 	"github.com/emersion/go-vcard"
-	"github.com/makiuchi-d/gozxing"
-	"github.com/makiuchi-d/gozxing/qrcode"
 	"github.com/whitekid/goxp/fx"
 	"github.com/whitekid/goxp/types"
 )
@@ -17,12 +14,6 @@ type QR struct {
 	Content string
 }
 
-func (q *QR) Render(width, height int) (image.Image, error) {
-	return qrcode.NewQRCodeWriter().
-		Encode(q.Content, gozxing.BarcodeFormat_QR_CODE,
-			width, height, nil)
-}
-
 func Text(content string) (*QR, error) { return &QR{Content: content}, nil }
 
 type WiFiAuth int
@@ -84,9 +75,10 @@ func WIFI(ssid string, auth WiFiAuth, password string, hidden *bool,
 			return true
 		}
 
-		spec := `\;,":`
-		for _, x := range spec {
-			v = strings.ReplaceAll(v, string(x), `\`+string(x))
+		v = wifiEscaper.Replace(v)
+		// ascii name which could be interpreted as hex is quoted
+		if (k == "S" || k == "P") && isHex(v) {
+			v = `"` + v + `"`
 		}
 
 		values2 = append(values2, k+":"+v)
@@ -96,7 +88,22 @@ func WIFI(ssid string, auth WiFiAuth, password string, hidden *bool,
 	return Text("WIFI:" + strings.Join(values2, ";") + ";;")
 }
 
+// wifiEscaper escape special characters of wifi payload value
+var wifiEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, `"`, `\"`, ":", `\:`)
+
+// isHex returns true if s consists of hex digits only
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
 type Card struct {
+	Version string // vCard version, VCardVersion4 if empty
+
 	LastName      string
 	FirstName     string
 	MiddleName    string
@@ -132,6 +139,9 @@ type Card struct {
 
 	Note string
 
+	Birthday    *Date
+	Anniversary *Date
+
 	SocialProfiles []SocialProfile
 }
 
@@ -166,22 +176,31 @@ func setFieldIf(vc vcard.Card, key, value string) {
 }
 
 func Contact(card *Card) (*QR, error) {
+	version := fx.Ternary(card.Version == "", VCardVersion4, card.Version)
 	vc := vcard.Card{
-		"VERSION": []*vcard.Field{{Value: "4.0"}},
+		"VERSION": []*vcard.Field{{Value: version}},
 		"N":       []*vcard.Field{{Value: fmt.Sprintf("%s;%s;%s;%s;%s", card.LastName, card.FirstName, card.MiddleName, card.PrefixName, card.SuffixName)}}, // Name
 	}
 
 	setFieldIf(vc, "EMAIL;type=INTERNET;type=HOME;type=pref", card.HomeEmail)
 	setFieldIf(vc, "EMAIL;type=INTERNET;type=WORK", card.WorkEmail)
-	setFieldIf(vc, "TEL;type=CELL;type=VOICE;type=pref", card.Mobile)
-	setFieldIf(vc, "TEL;type=HOME;type=VOICE", card.HomeTel)
-	setFieldIf(vc, "TEL;type=WORK;type=VOICE", card.WorkTel)
-	setFieldIf(vc, "TEL;type=MAIN", card.Tel)
-
-	setFieldIf(vc, "TEL;type=HOME;type=FAX", card.HomeFax)
-	setFieldIf(vc, "TEL;type=WORK;type=FAX", card.WorkFax)
-
-	setFieldIf(vc, "TEL;type=PAGER", card.Pager)
+	for _, tel := range []struct {
+		number string
+		types  []string
+	}{
+		{card.Mobile, []string{"cell"}},
+		{card.HomeTel, []string{"home"}},
+		{card.WorkTel, []string{"work"}},
+		{card.Tel, nil},
+		{card.HomeFax, []string{"home", "fax"}},
+		{card.WorkFax, []string{"work", "fax"}},
+		{card.Pager, []string{"pager"}},
+	} {
+		if tel.number != "" {
+			key, value := TelProperty(version, tel.number, tel.types...)
+			setField(vc, key, value)
+		}
+	}
 	setFieldIf(vc, "ADR;type=HOME;type=pref", card.HomeAddr.String())
 	setFieldIf(vc, "ADR;type=WORK", card.WorkAddr.String())
 
@@ -213,8 +232,15 @@ func Contact(card *Card) (*QR, error) {
 
 	setFieldIf(vc, "NOTE", card.Note)
 
+	if card.Birthday != nil {
+		setField(vc, "BDAY", card.Birthday.Format(version))
+	}
+	if card.Anniversary != nil {
+		setField(vc, AnniversaryField(version), card.Anniversary.Format(version))
+	}
+
 	var buf bytes.Buffer
-	if err := vcard.NewEncoder(&buf).Encode(vc); err != nil {
+	if err := encodeVCard(&buf, vc); err != nil {
 		return nil, err
 	}
 
@@ -224,7 +250,7 @@ func Contact(card *Card) (*QR, error) {
 func VCard(card vcard.Card) (*QR, error) {
 	var buf bytes.Buffer
 
-	if err := vcard.NewEncoder(&buf).Encode(card); err != nil {
+	if err := encodeVCard(&buf, card); err != nil {
 		return nil, err
 	}
 
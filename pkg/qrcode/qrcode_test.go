@@ -87,3 +87,81 @@ func TestQR(t *testing.T) {
 func TestWifiAuth(t *testing.T) {
 	require.Equal(t, AuthNone, StrToWifiAuth("xx"))
 }
+
+func TestWIFIEscape(t *testing.T) {
+	tests := [...]struct {
+		name     string
+		ssid     string
+		password string
+		want     string
+	}{
+		{"plain", "office", "secret", `WIFI:S:office;T:WPA;P:secret;;`},
+		{"semicolon", "my;net", "pa;ss", `WIFI:S:my\;net;T:WPA;P:pa\;ss;;`},
+		{"colon", "my:net", "pa:ss", `WIFI:S:my\:net;T:WPA;P:pa\:ss;;`},
+		{"comma", "my,net", "pa,ss", `WIFI:S:my\,net;T:WPA;P:pa\,ss;;`},
+		{"backslash", `my\net`, `pa\ss`, `WIFI:S:my\\net;T:WPA;P:pa\\ss;;`},
+		{"double quote", `"my"net`, `pa"ss`, `WIFI:S:\"my\"net;T:WPA;P:pa\"ss;;`},
+		{"escape sequence", `\;`, `\\`, `WIFI:S:\\\;;T:WPA;P:\\\\;;`},
+		{"hex ssid", "ABCDEF", "secret", `WIFI:S:"ABCDEF";T:WPA;P:secret;;`},
+		{"hex password", "office", "0123abcd", `WIFI:S:office;T:WPA;P:"0123abcd";;`},
+		{"not hex", "ABCDEFG", "12 34", `WIFI:S:ABCDEFG;T:WPA;P:12 34;;`},
+		{"unicode", "카페;2층", "비밀:번호", `WIFI:S:카페\;2층;T:WPA;P:비밀\:번호;;`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qr, err := WIFI(tt.ssid, AuthWPA, tt.password, nil, WPA2Options{})
+			require.NoError(t, err)
+			require.Equal(t, tt.want, qr.Content)
+
+			img, err := qr.Render(300, 300)
+			require.NoError(t, err)
+			decoded, err := Decode(img)
+			require.NoError(t, err)
+
+			fields := parseWIFI(t, decoded)
+			require.Equal(t, tt.ssid, fields["S"])
+			require.Equal(t, tt.password, fields["P"])
+			require.Equal(t, "WPA", fields["T"])
+		})
+	}
+}
+
+// parseWIFI parse fields of wifi payload as scanners do; escaped characters are unescaped and quotes are removed
+func parseWIFI(t *testing.T, payload string) map[string]string {
+	require.True(t, strings.HasPrefix(payload, "WIFI:"))
+
+	fields := map[string]string{}
+	var field strings.Builder
+	quoted := false
+	flush := func() {
+		k, v, ok := strings.Cut(field.String(), ":")
+		field.Reset()
+		if !ok {
+			return
+		}
+		if quoted && strings.HasPrefix(v, `"`) && strings.HasSuffix(v, `"`) {
+			v = v[1 : len(v)-1]
+		}
+		fields[k] = v
+		quoted = false
+	}
+
+	escaped := false
+	for _, r := range strings.TrimPrefix(payload, "WIFI:") {
+		switch {
+		case escaped:
+			field.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ';':
+			flush()
+		case r == '"':
+			quoted = true
+			field.WriteRune(r)
+		default:
+			field.WriteRune(r)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,539 @@
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode/decoder"
+	"github.com/makiuchi-d/gozxing/qrcode/encoder"
+	"github.com/whitekid/goxp/fx"
+)
+
+// QuietZone quiet zone size in modules
+const QuietZone = 4
+
+// module shapes
+const (
+	ShapeSquare = "square"
+	ShapeDot    = "dot" // round dots; finder patterns are kept square for scanners
+)
+
+// Shapes supported module shapes
+var Shapes = []string{ShapeSquare, ShapeDot}
+
+// ErrorCorrections supported error correction levels, from the lowest
+var ErrorCorrections = []string{"L", "M", "Q", "H"}
+
+const finderSize = 7
+
+// RenderOptions options for rendering qrcode image
+type RenderOptions struct {
+	Width  int
+	Height int
+
+	// CornerRadius round corners of whole canvas in pixels.
+	// radius is limited so that rounded area does not cover the code area.
+	CornerRadius int
+
+	// CornerBackground fill color outside of rounded corners, transparent if nil
+	CornerBackground color.Color
+
+	// Alt text alternative for vector output, content if empty
+	Alt string
+
+	// ErrorCorrection error correction level: L, M, Q, H; L if empty
+	ErrorCorrection string
+
+	// Charset charset of byte mode content, see Charsets; utf-8 if empty.
+	// content is transcoded and designated by ECI, CharsetError if a character can not be represented.
+	Charset string
+
+	// ECI designate utf-8 content by ECI; scanners assume utf-8 or guess without it
+	ECI bool
+
+	// Foreground, Background module and background colors, black and white if nil
+	Foreground color.Color
+	Background color.Color
+
+	// PadColor letterbox color outside of the quiet zone, filled when the image is larger than the code; Background if nil
+	PadColor color.Color
+
+	// EyeOuter, EyeInner colors of finder pattern outer ring and inner 3x3 dot, foreground if nil
+	EyeOuter color.Color
+	EyeInner color.Color
+
+	// Shape module shape, square if empty
+	Shape string
+
+	// Margin quiet zone in modules, QuietZone if nil
+	Margin *int
+
+	// MarginTop, MarginRight, MarginBottom, MarginLeft quiet zone of the side in modules, Margin if nil;
+	// scanners need about 4 modules of quiet zone, so small one is for layouts which keep the space around the image.
+	MarginTop    *int
+	MarginRight  *int
+	MarginBottom *int
+	MarginLeft   *int
+
+	// Label caption drawn under the code, wrapped by words to the width; image height grows by the label
+	Label string
+
+	// LabelSize font size of the label in pixels, or ratio of the width if less than 1; DefaultLabelSize if 0
+	LabelSize float64
+
+	// Mask mask selection strategy or pattern 0..7, MaskAuto if empty
+	Mask string
+
+	// MaxVersion the largest symbol version allowed, no limit if 0
+	MaxVersion int
+
+	// Logo image drawn at the center over background box; high error correction level is recommended
+	Logo image.Image
+
+	// LogoAuto knock out the center as large as the error correction level can recover, instead of fixed logo size;
+	// the center is cleared even if there is no logo.
+	LogoAuto bool
+
+	// Composite draw second code of the same content next to the code; raster output only
+	Composite *CompositeOptions
+
+	// BackgroundImage image onto which the code is drawn over white box, ex) photo for mockups; raster output only.
+	// size of the output is the size of the background image.
+	BackgroundImage image.Image
+
+	// BackgroundPosition top-left of the code in the background image, centered if nil
+	BackgroundPosition *image.Point
+
+	// ExactSize output is exactly Width x Height, including the label; the code is scaled by the largest integer multiple
+	// and centered. SizeError if the code does not fit in one pixel per module.
+	ExactSize bool
+
+	// Halftone image shown through the modules by dots of 3x3 sub-modules; raster output only.
+	// the center of each module keeps its color and function patterns are drawn whole, high error correction level is recommended.
+	Halftone image.Image
+}
+
+var errExactSizeComposite = errors.New("exact size can not be used with composite")
+
+// ErrContentTooLong content does not fit in the largest version of the error correction level
+var ErrContentTooLong = errors.New("content does not fit in the largest version")
+
+// VersionError content requires denser code than allowed
+type VersionError struct {
+	Version    int
+	MaxVersion int
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("content requires version %d, exceeds max version %d", e.Version, e.MaxVersion)
+}
+
+// SizeError the code does not fit in the exact size
+type SizeError struct {
+	Modules image.Point // modules with quiet zone
+	Size    image.Point // size for the code, without the label
+}
+
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("code of %dx%d modules does not fit in %dx%d pixels", e.Modules.X, e.Modules.Y, e.Size.X, e.Size.Y)
+}
+
+func (opts *RenderOptions) margin() int {
+	if opts.Margin == nil {
+		return QuietZone
+	}
+	return *opts.Margin
+}
+
+// margins quiet zone of each side in modules
+type margins struct {
+	top, right, bottom, left int
+}
+
+func (opts *RenderOptions) margins() margins {
+	side := func(v *int) int {
+		if v == nil {
+			return opts.margin()
+		}
+		return *v
+	}
+	return margins{top: side(opts.MarginTop), right: side(opts.MarginRight), bottom: side(opts.MarginBottom), left: side(opts.MarginLeft)}
+}
+
+func (opts *RenderOptions) foreground() color.Color {
+	if opts.Foreground == nil {
+		return color.Black
+	}
+	return opts.Foreground
+}
+
+func (opts *RenderOptions) background() color.Color {
+	if opts.Background == nil {
+		return color.White
+	}
+	return opts.Background
+}
+
+func (opts *RenderOptions) padColor() color.Color {
+	if opts.PadColor == nil {
+		return opts.background()
+	}
+	return opts.PadColor
+}
+
+// moduleColor returns color of the dark module at x, y; finder patterns can have own colors
+func (opts *RenderOptions) moduleColor(x, y, size int) color.Color {
+	if !isFinder(x, y, size) {
+		return opts.foreground()
+	}
+
+	// position in the finder pattern
+	if x >= size-finderSize {
+		x -= size - finderSize
+	}
+	if y >= size-finderSize {
+		y -= size - finderSize
+	}
+
+	if x >= 2 && x <= 4 && y >= 2 && y <= 4 {
+		if opts.EyeInner != nil {
+			return opts.EyeInner
+		}
+	} else if opts.EyeOuter != nil {
+		return opts.EyeOuter
+	}
+	return opts.foreground()
+}
+
+// ParseShape returns normalized module shape, square if empty
+func ParseShape(s string) (string, error) {
+	s = strings.ToLower(s)
+	if s == "" {
+		return ShapeSquare, nil
+	}
+
+	if !fx.Contains(Shapes, s) {
+		return "", errors.New("invalid shape: " + s)
+	}
+	return s, nil
+}
+
+// isFinder returns true if the module is a part of finder patterns
+func isFinder(x, y, size int) bool {
+	return (x < finderSize && y < finderSize) ||
+		(x >= size-finderSize && y < finderSize) ||
+		(x < finderSize && y >= size-finderSize)
+}
+
+// ParseErrorCorrection returns normalized error correction level, L if empty
+func ParseErrorCorrection(s string) (string, error) {
+	level, err := errorCorrectionLevel(s)
+	if err != nil {
+		return "", err
+	}
+	return level.String(), nil
+}
+
+func errorCorrectionLevel(s string) (decoder.ErrorCorrectionLevel, error) {
+	if s == "" {
+		return decoder.ErrorCorrectionLevel_L, nil
+	}
+	return decoder.ErrorCorrectionLevel_ValueOf(strings.ToUpper(s))
+}
+
+func (q *QR) encode(opts *RenderOptions) (*encoder.QRCode, error) {
+	if q.Content == "" {
+		return nil, errors.New("empty content")
+	}
+
+	level, err := errorCorrectionLevel(opts.ErrorCorrection)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkCharset(q.Content, opts.Charset); err != nil {
+		return nil, err
+	}
+
+	code, err := q.encodeMask(level, opts)
+	if err != nil {
+		// encoder tells capacity overflow only by the message
+		if _, ok := err.(gozxing.WriterException); ok && strings.Contains(err.Error(), "Data too big") {
+			return nil, ErrContentTooLong
+		}
+		return nil, err
+	}
+
+	if version := code.GetVersion().GetVersionNumber(); opts.MaxVersion > 0 && version > opts.MaxVersion {
+		return nil, &VersionError{Version: version, MaxVersion: opts.MaxVersion}
+	}
+
+	return code, nil
+}
+
+// Symbol version and size of the encoded symbol
+type Symbol struct {
+	Version         int
+	ErrorCorrection string
+	Modules         int // modules per side without quiet zone
+}
+
+// Symbol returns the symbol of the code encoded with the options
+func (q *QR) Symbol(opts *RenderOptions) (*Symbol, error) {
+	code, err := q.encode(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Symbol{
+		Version:         code.GetVersion().GetVersionNumber(),
+		ErrorCorrection: code.GetECLevel().String(),
+		Modules:         code.GetMatrix().GetWidth(),
+	}, nil
+}
+
+// Modules returns number of modules per side without quiet zone
+func (q *QR) Modules(opts *RenderOptions) (int, error) {
+	code, err := q.encode(opts)
+	if err != nil {
+		return 0, err
+	}
+	return code.GetMatrix().GetWidth(), nil
+}
+
+// Render render qrcode image with given size
+func (q *QR) Render(width, height int) (image.Image, error) {
+	return q.RenderWithOptions(&RenderOptions{Width: width, Height: height})
+}
+
+// RenderWithOptions render qrcode image with options
+func (q *QR) RenderWithOptions(opts *RenderOptions) (image.Image, error) {
+	var img image.Image
+	if opts.Composite != nil {
+		if opts.ExactSize {
+			return nil, errExactSizeComposite
+		}
+
+		composite, err := q.renderComposite(opts)
+		if err != nil {
+			return nil, err
+		}
+		img = composite
+	} else {
+		code, err := q.encode(opts)
+		if err != nil {
+			return nil, err
+		}
+		l := newLayout(code.GetMatrix(), opts)
+		if err := l.checkExactSize(code.GetMatrix(), opts); err != nil {
+			return nil, err
+		}
+		if err := l.checkHalftone(opts); err != nil {
+			return nil, err
+		}
+		img = render(code.GetMatrix(), opts)
+	}
+
+	if opts.BackgroundImage != nil {
+		return drawOnBackground(img, opts)
+	}
+	return img, nil
+}
+
+// layout geometry of rendered image
+type layout struct {
+	width, height int
+	multiple      int // pixels per module
+	left, top     int // padding to the code area
+	right, bottom int
+	quietZone     image.Rectangle // code area with the quiet zone; outside of it is letterbox
+	radius        int             // corner radius
+	label         *labelLayout
+	labelTop      int // top of the label strip
+	logo          image.Rectangle
+	logoBox       image.Rectangle // background of the logo
+}
+
+// newLayout scale modules with the largest integer multiple that fits and center it with the quiet zone
+// as gozxing QRCodeWriter does.
+func newLayout(matrix *encoder.ByteMatrix, opts *RenderOptions) *layout {
+	inputWidth, inputHeight := matrix.GetWidth(), matrix.GetHeight()
+	m := opts.margins()
+	qrWidth, qrHeight := inputWidth+m.left+m.right, inputHeight+m.top+m.bottom
+	outputWidth, outputHeight := fx.Max([]int{qrWidth, opts.Width}), fx.Max([]int{qrHeight, opts.Height})
+
+	var label *labelLayout
+	if opts.ExactSize {
+		// label strip is inside of the height; the multiple is 0 if the code does not fit, see checkExactSize
+		outputWidth, outputHeight = opts.Width, opts.Height
+		if opts.Label != "" {
+			label = newLabelLayout(opts.Label, outputWidth, opts.LabelSize)
+			outputHeight -= label.height()
+		}
+	}
+
+	l := &layout{width: outputWidth, height: outputHeight}
+	l.multiple = minInt(outputWidth/qrWidth, outputHeight/qrHeight)
+	l.left = (outputWidth-qrWidth*l.multiple)/2 + m.left*l.multiple
+	l.top = (outputHeight-qrHeight*l.multiple)/2 + m.top*l.multiple
+	l.right = outputWidth - l.left - inputWidth*l.multiple
+	l.bottom = outputHeight - l.top - inputHeight*l.multiple
+
+	code := image.Rect(l.left, l.top, l.left+inputWidth*l.multiple, l.top+inputHeight*l.multiple)
+	l.quietZone = image.Rect(code.Min.X-m.left*l.multiple, code.Min.Y-m.top*l.multiple, code.Max.X+m.right*l.multiple, code.Max.Y+m.bottom*l.multiple)
+	switch {
+	case opts.LogoAuto:
+		level, _ := ParseErrorCorrection(opts.ErrorCorrection)
+		var logo image.Rectangle
+		if opts.Logo != nil {
+			logo = opts.Logo.Bounds()
+		}
+		l.logo, l.logoBox = knockoutRect(logo, code, l.multiple, inputWidth, knockoutModules(inputWidth, level))
+	case opts.Logo != nil:
+		l.logo, l.logoBox = logoRect(opts.Logo.Bounds(), code, l.multiple)
+	}
+
+	if opts.Label != "" {
+		if l.label = label; l.label == nil {
+			l.label = newLabelLayout(opts.Label, outputWidth, opts.LabelSize)
+		}
+		l.labelTop = outputHeight
+		l.height += l.label.height()
+	}
+
+	if opts.CornerRadius > 0 {
+		l.radius = fx.Min([]int{opts.CornerRadius, maxCornerRadius(l.padding()), l.width / 2, l.height / 2})
+	}
+
+	return l
+}
+
+// checkExactSize returns SizeError if the code does not fit in the exact size
+func (l *layout) checkExactSize(matrix *encoder.ByteMatrix, opts *RenderOptions) error {
+	if !opts.ExactSize || l.multiple > 0 {
+		return nil
+	}
+
+	height := l.height
+	if l.label != nil {
+		height = l.labelTop
+	}
+
+	m := opts.margins()
+	return &SizeError{
+		Modules: image.Pt(matrix.GetWidth()+m.left+m.right, matrix.GetHeight()+m.top+m.bottom),
+		Size:    image.Pt(l.width, height),
+	}
+}
+
+// padding returns the smallest padding to the code area
+func (l *layout) padding() int {
+	return minInt(minInt(l.left, l.right), minInt(l.top, l.bottom))
+}
+
+// render renders module matrix to image
+func render(matrix *encoder.ByteMatrix, opts *RenderOptions) *image.RGBA {
+	l := newLayout(matrix, opts)
+
+	img := image.NewRGBA(image.Rect(0, 0, l.width, l.height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(opts.padColor()), image.Point{}, draw.Src)
+	if opts.PadColor != nil {
+		draw.Draw(img, l.quietZone, image.NewUniform(opts.background()), image.Point{}, draw.Src)
+	}
+
+	fg := image.NewUniform(opts.foreground())
+	if opts.Halftone != nil {
+		drawHalftone(img, matrix, l, opts)
+	} else {
+		drawModules(img, matrix, l, opts)
+	}
+
+	if !l.logoBox.Empty() {
+		drawLogo(img, opts.Logo, l.logo, l.logoBox, opts.background())
+	}
+
+	if opts.Label != "" {
+		drawLabel(img, l.label, l.labelTop, fg)
+	}
+
+	if l.radius > 0 {
+		roundCorners(img, l.radius, opts.CornerBackground)
+	}
+
+	return img
+}
+
+// drawModules draw dark modules in the shape
+func drawModules(img *image.RGBA, matrix *encoder.ByteMatrix, l *layout, opts *RenderOptions) {
+	fg := opts.foreground()
+	for y := 0; y < matrix.GetHeight(); y++ {
+		for x := 0; x < matrix.GetWidth(); x++ {
+			if matrix.Get(x, y) != 1 {
+				continue
+			}
+
+			rect := image.Rect(0, 0, l.multiple, l.multiple).Add(image.Pt(l.left+x*l.multiple, l.top+y*l.multiple))
+			if opts.Shape == ShapeDot && !isFinder(x, y, matrix.GetWidth()) {
+				drawDot(img, rect, fg)
+				continue
+			}
+			draw.Draw(img, rect, image.NewUniform(opts.moduleColor(x, y, matrix.GetWidth())), image.Point{}, draw.Src)
+		}
+	}
+}
+
+// maxCornerRadius returns the largest corner radius which keeps code area intact.
+// corner arc with radius r cuts r*(1-1/sqrt(2)) pixels diagonally from the corner.
+func maxCornerRadius(quietZone int) int {
+	return int(float64(quietZone) / (1 - math.Sqrt2/2))
+}
+
+// drawDot draw circle inscribed in the rect
+func drawDot(img *image.RGBA, rect image.Rectangle, c color.Color) {
+	r := float64(rect.Dx()) / 2
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			dx, dy := float64(x-rect.Min.X)+0.5-r, float64(y-rect.Min.Y)+0.5-r
+			if dx*dx+dy*dy <= r*r {
+				img.Set(x, y, c)
+			}
+		}
+	}
+}
+
+// minInt returns smaller one; fx.Min() returns the last one for two elements
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func roundCorners(img *image.RGBA, radius int, background color.Color) {
+	if background == nil {
+		background = color.Transparent
+	}
+
+	bounds := img.Bounds()
+	r := float64(radius)
+	for y := 0; y < radius; y++ {
+		for x := 0; x < radius; x++ {
+			// distance from the arc center to the pixel center
+			dx, dy := r-float64(x)-0.5, r-float64(y)-0.5
+			if dx*dx+dy*dy <= r*r {
+				continue
+			}
+
+			img.Set(bounds.Min.X+x, bounds.Min.Y+y, background)
+			img.Set(bounds.Max.X-1-x, bounds.Min.Y+y, background)
+			img.Set(bounds.Min.X+x, bounds.Max.Y-1-y, background)
+			img.Set(bounds.Max.X-1-x, bounds.Max.Y-1-y, background)
+		}
+	}
+}
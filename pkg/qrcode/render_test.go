@@ -0,0 +1,455 @@
+package qrcode
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/makiuchi-d/gozxing/qrcode/encoder"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font"
+)
+
+func TestRender(t *testing.T) {
+	qr, _ := Text("hello world")
+
+	type args struct {
+		width, height int
+	}
+	tests := [...]struct {
+		name     string
+		args     args
+		wantSize image.Point
+	}{
+		{"default", args{200, 200}, image.Pt(200, 200)},
+		{"non-square", args{200, 100}, image.Pt(200, 100)},
+		{"too small", args{10, 10}, image.Pt(29, 29)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := qr.Render(tt.args.width, tt.args.height)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantSize, img.Bounds().Size())
+
+			got, err := Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, "hello world", got)
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	// the detector fails to read this code of mask 2
+	content := "selftest-80ec95a3f6b4fbd7"
+	qr, _ := Text(content)
+
+	code, err := qr.encode(&RenderOptions{})
+	require.NoError(t, err)
+	require.Equal(t, 2, code.GetMaskPattern())
+
+	img, err := qr.Render(200, 200)
+	require.NoError(t, err)
+	got, err := Decode(img)
+	require.NoError(t, err)
+	require.Equal(t, content, got)
+}
+
+func TestRenderCornerRadius(t *testing.T) {
+	qr, _ := Text("hello world")
+
+	type args struct {
+		radius     int
+		background color.Color
+	}
+	tests := [...]struct {
+		name       string
+		args       args
+		wantCorner color.Color
+	}{
+		{"no radius", args{0, nil}, color.RGBA{0xff, 0xff, 0xff, 0xff}},
+		{"transparent", args{20, nil}, color.RGBA{}},
+		{"background", args{20, color.White}, color.RGBA{0xff, 0xff, 0xff, 0xff}},
+		{"too large radius", args{1000, nil}, color.RGBA{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := qr.RenderWithOptions(&RenderOptions{Width: 200, Height: 200, CornerRadius: tt.args.radius, CornerBackground: tt.args.background})
+			require.NoError(t, err)
+
+			bounds := img.Bounds()
+			for _, pt := range []image.Point{{0, 0}, {bounds.Max.X - 1, 0}, {0, bounds.Max.Y - 1}, {bounds.Max.X - 1, bounds.Max.Y - 1}} {
+				require.Equal(t, tt.wantCorner, img.At(pt.X, pt.Y), "corner %v", pt)
+			}
+			require.Equal(t, color.RGBA{0xff, 0xff, 0xff, 0xff}, img.At(bounds.Dx()/2, 2), "edge center should not be rounded")
+
+			got, err := Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, "hello world", got)
+		})
+	}
+}
+
+func TestMaxCornerRadius(t *testing.T) {
+	require.Equal(t, 0, maxCornerRadius(0))
+	require.Equal(t, 34, maxCornerRadius(10))
+}
+
+func TestErrorCorrection(t *testing.T) {
+	qr, _ := Text("hello world")
+
+	tests := [...]struct {
+		name    string
+		level   string
+		want    string
+		wantErr bool
+	}{
+		{"default", "", "L", false},
+		{"lower", "h", "H", false},
+		{"quartile", "Q", "Q", false},
+		{"invalid", "X", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseErrorCorrection(tt.level)
+			require.Truef(t, (err != nil) == tt.wantErr, "error = %v, wantErr = %v", err, tt.wantErr)
+			if tt.wantErr {
+				return
+			}
+			require.Equal(t, tt.want, got)
+
+			img, err := qr.RenderWithOptions(&RenderOptions{Width: 200, Height: 200, ErrorCorrection: tt.level})
+			require.NoError(t, err)
+			decoded, err := Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, "hello world", decoded)
+		})
+	}
+}
+
+func TestRenderStyle(t *testing.T) {
+	qr, _ := Text("hello world")
+
+	fg, bg := color.RGBA{0x11, 0x22, 0x33, 0xff}, color.RGBA{0xee, 0xdd, 0xcc, 0xff}
+	for _, shape := range []string{ShapeSquare, ShapeDot} {
+		t.Run(shape, func(t *testing.T) {
+			img, err := qr.RenderWithOptions(&RenderOptions{Width: 200, Height: 200, Foreground: fg, Background: bg, Shape: shape})
+			require.NoError(t, err)
+
+			require.Equal(t, bg, img.At(0, 0))
+			// top left module of the finder pattern
+			l := newLayout(mustMatrix(t, qr), &RenderOptions{Width: 200, Height: 200})
+			require.Equal(t, fg, img.At(l.left, l.top))
+
+			got, err := Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, "hello world", got)
+		})
+	}
+}
+
+func TestRenderEyeColor(t *testing.T) {
+	qr, _ := Text("hello world")
+
+	fg, outer, inner := color.RGBA{0x11, 0x22, 0x33, 0xff}, color.RGBA{0xcc, 0x00, 0x00, 0xff}, color.RGBA{0x00, 0x66, 0x00, 0xff}
+	opts := &RenderOptions{Width: 200, Height: 200, Foreground: fg, EyeOuter: outer, EyeInner: inner}
+	img, err := qr.RenderWithOptions(opts)
+	require.NoError(t, err)
+
+	matrix := mustMatrix(t, qr)
+	size := matrix.GetWidth()
+	l := newLayout(matrix, opts)
+	at := func(x, y int) color.Color {
+		return img.At(l.left+x*l.multiple+l.multiple/2, l.top+y*l.multiple+l.multiple/2)
+	}
+
+	// every finder pattern
+	for _, origin := range []image.Point{{0, 0}, {size - finderSize, 0}, {0, size - finderSize}} {
+		require.Equal(t, outer, at(origin.X, origin.Y))
+		require.Equal(t, outer, at(origin.X+6, origin.Y+6))
+		require.Equal(t, color.RGBA{0xff, 0xff, 0xff, 0xff}, at(origin.X+1, origin.Y+1))
+		require.Equal(t, inner, at(origin.X+2, origin.Y+2))
+		require.Equal(t, inner, at(origin.X+4, origin.Y+4))
+	}
+
+	got, err := Decode(img)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", got)
+}
+
+func TestLabelSize(t *testing.T) {
+	qr, _ := Text("hello world")
+	caption := "scan this code to join the conference wifi network"
+
+	tests := [...]struct {
+		name      string
+		width     int
+		size      float64
+		wantPx    int
+		wantLines int
+	}{
+		{"default", 200, 0, 13, 3},
+		{"scaled", 100, 0, 7, 3},
+		{"pixels", 200, 20, 20, 4},
+		{"ratio", 200, 0.05, 10, 2},
+		{"minimum", 200, 6, 6, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLabelLayout(caption, tt.width, tt.size)
+			require.Equal(t, tt.wantPx, l.size)
+			require.Len(t, l.lines, tt.wantLines)
+			require.Equal(t, caption, strings.Join(l.lines, " "))
+			for _, line := range l.lines {
+				require.LessOrEqual(t, font.MeasureString(l.face, line).Ceil(), tt.width-labelPadding*2)
+			}
+
+			img, err := qr.RenderWithOptions(&RenderOptions{Width: tt.width, Height: tt.width, Label: caption, LabelSize: tt.size})
+			require.NoError(t, err)
+			require.Equal(t, tt.width+l.height(), img.Bounds().Dy())
+
+			// every line is drawn
+			for i := range l.lines {
+				dark := 0
+				for y := l.baseline(tt.width, i) - l.lineHeight/2; y < l.baseline(tt.width, i); y++ {
+					for x := 0; x < img.Bounds().Dx(); x++ {
+						if color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y < 0x80 {
+							dark++
+						}
+					}
+				}
+				require.NotZerof(t, dark, "line %d is not drawn", i)
+			}
+		})
+	}
+}
+
+func TestParseLabelSize(t *testing.T) {
+	tests := [...]struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{"", DefaultLabelSize, false},
+		{"14", 14, false},
+		{"0.1", 0.1, false},
+		{"5", 0, true},
+		{"65", 0, true},
+		{"0.01", 0, true},
+		{"-1", 0, true},
+		{"big", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseLabelSize(tt.value)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func mustMatrix(t *testing.T, qr *QR) *encoder.ByteMatrix {
+	code, err := qr.encode(&RenderOptions{})
+	require.NoError(t, err)
+	return code.GetMatrix()
+}
+
+func TestRenderMarginLabel(t *testing.T) {
+	qr, _ := Text("hello world")
+	zero, wide := 0, 8
+
+	type args struct {
+		margin *int
+		label  string
+	}
+	tests := [...]struct {
+		name     string
+		args     args
+		wantSize image.Point
+		wantLeft int
+	}{
+		{"default", args{nil, ""}, image.Pt(200, 200), 37},
+		{"no margin", args{&zero, ""}, image.Pt(200, 200), 5},
+		{"wide margin", args{&wide, ""}, image.Pt(200, 200), 47},
+		{"label", args{nil, "hello"}, image.Pt(200, 200+newLabelLayout("hello", 200, 0).height()), 37},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &RenderOptions{Width: 200, Height: 200, Margin: tt.args.margin, Label: tt.args.label}
+			img, err := qr.RenderWithOptions(opts)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantSize, img.Bounds().Size())
+			require.Equal(t, tt.wantLeft, newLayout(mustMatrix(t, qr), opts).left)
+
+			if tt.args.label != "" {
+				dark := 0
+				for y := 200; y < img.Bounds().Dy(); y++ {
+					for x := 0; x < img.Bounds().Dx(); x++ {
+						if img.At(x, y) == (color.RGBA{0, 0, 0, 0xff}) {
+							dark++
+						}
+					}
+				}
+				require.NotZero(t, dark, "label is not drawn")
+			}
+
+			if tt.args.margin == nil || *tt.args.margin > 0 {
+				got, err := Decode(img)
+				require.NoError(t, err)
+				require.Equal(t, "hello world", got)
+			}
+		})
+	}
+}
+
+func TestRenderMargins(t *testing.T) {
+	qr, _ := Text("hello world") // 21 modules
+	top, right, bottom, left := 1, 10, 2, 4
+
+	tests := [...]struct {
+		name        string
+		size        int
+		wantSize    image.Point
+		wantPadding [4]int // top, right, bottom, left in pixels
+	}{
+		{"natural size", 0, image.Pt(21+right+left, 21+top+bottom), [4]int{1, 10, 2, 4}},
+		// 5 pixels per module, the code with quiet zone of 175x120 is centered
+		{"fixed size", 200, image.Pt(200, 200), [4]int{45, 63, 50, 32}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &RenderOptions{Width: tt.size, Height: tt.size, MarginTop: &top, MarginRight: &right, MarginBottom: &bottom, MarginLeft: &left}
+			img, err := qr.RenderWithOptions(opts)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantSize, img.Bounds().Size())
+
+			l := newLayout(mustMatrix(t, qr), opts)
+			require.Equal(t, tt.wantPadding, [4]int{l.top, l.right, l.bottom, l.left})
+			require.Equal(t, color.RGBA{0, 0, 0, 0xff}, img.At(l.left, l.top), "top-left of finder pattern")
+			require.Equal(t, color.RGBA{0xff, 0xff, 0xff, 0xff}, img.At(l.left-1, l.top))
+		})
+	}
+
+	// uniform margin is used for sides not given
+	opts := &RenderOptions{Width: 200, Height: 200, MarginRight: &right}
+	l := newLayout(mustMatrix(t, qr), opts)
+	require.Equal(t, [4]int{47, 63, 48, 32}, [4]int{l.top, l.right, l.bottom, l.left})
+}
+
+func TestMaxVersion(t *testing.T) {
+	qr, _ := Text("https://github.com/whitekid/qrcodeapi/blob/main/README.md?q=1") // version 4 with ec M
+
+	tests := [...]struct {
+		name       string
+		maxVersion int
+		wantErr    bool
+	}{
+		{"no limit", 0, false},
+		{"allowed", 4, false},
+		{"exceeded", 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := qr.RenderWithOptions(&RenderOptions{Width: 200, Height: 200, ErrorCorrection: "M", MaxVersion: tt.maxVersion})
+			require.Truef(t, (err != nil) == tt.wantErr, "error = %v, wantErr = %v", err, tt.wantErr)
+			if tt.wantErr {
+				var versionErr *VersionError
+				require.ErrorAs(t, err, &versionErr)
+				require.Equal(t, 4, versionErr.Version)
+			}
+		})
+	}
+}
+
+func TestContentTooLong(t *testing.T) {
+	qr, _ := Text(strings.Repeat("\xff", 2954)) // byte capacity of version 40 with ec L is 2953
+
+	_, err := qr.RenderWithOptions(&RenderOptions{Width: 200, Height: 200})
+	require.ErrorIs(t, err, ErrContentTooLong)
+
+	_, err = qr.RenderWithOptions(&RenderOptions{Width: 200, Height: 200, Mask: "3"})
+	require.ErrorIs(t, err, ErrContentTooLong)
+}
+
+func TestRenderAntialias(t *testing.T) {
+	qr, _ := Text("hello world")
+
+	img, err := qr.RenderWithOptions(&RenderOptions{Width: 200, Height: 200, Label: "scan me", Shape: ShapeSquare})
+	require.NoError(t, err)
+
+	levels := func(rect image.Rectangle) map[color.Color]bool {
+		levels := map[color.Color]bool{}
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				levels[color.RGBAModel.Convert(img.At(x, y))] = true
+			}
+		}
+		return levels
+	}
+
+	// modules are hard edged, only foreground and background
+	code := levels(image.Rect(0, 0, 200, 200))
+	require.Equal(t, map[color.Color]bool{color.RGBA{0, 0, 0, 0xff}: true, color.RGBA{0xff, 0xff, 0xff, 0xff}: true}, code)
+
+	// caption is anti-aliased, edges are blended
+	label := levels(image.Rect(0, 200, 200, img.Bounds().Dy()))
+	require.Greater(t, len(label), 2)
+}
+
+func TestRenderExactSize(t *testing.T) {
+	tests := [...]struct {
+		name          string
+		content       string
+		width, height int
+		label         string
+		wantErr       bool
+	}{
+		{"256", "https://github.com/whitekid/qrcodeapi", 256, 256, "", false},
+		{"not square", "hello world", 300, 256, "", false},
+		{"label inside", "hello world", 256, 256, "asset 1", false},
+		{"smaller than modules", "https://github.com/whitekid/qrcodeapi", 30, 30, "", true},
+		{"label does not fit", "hello world", 40, 40, "label which wraps to lines", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qr, _ := Text(tt.content)
+			opts := &RenderOptions{Width: tt.width, Height: tt.height, Label: tt.label, ExactSize: true}
+			img, err := qr.RenderWithOptions(opts)
+			if tt.wantErr {
+				var sizeErr *SizeError
+				require.ErrorAs(t, err, &sizeErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, image.Pt(tt.width, tt.height), img.Bounds().Size())
+
+			got, err := Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, tt.content, got)
+
+			// modules are crisp, scaled by an integer multiple without blending
+			code, err := qr.encode(opts)
+			require.NoError(t, err)
+			l := newLayout(code.GetMatrix(), opts)
+			for y := l.top; y < l.top+code.GetMatrix().GetHeight()*l.multiple; y++ {
+				for x := l.left; x < l.left+code.GetMatrix().GetWidth()*l.multiple; x++ {
+					want := color.Gray{Y: 0xff}
+					if code.GetMatrix().Get((x-l.left)/l.multiple, (y-l.top)/l.multiple) == 1 {
+						want = color.Gray{}
+					}
+					require.Equal(t, want, color.GrayModel.Convert(img.At(x, y)))
+				}
+			}
+		})
+	}
+
+	// without exact size, label grows the height
+	qr, _ := Text("hello world")
+	img, err := qr.RenderWithOptions(&RenderOptions{Width: 256, Height: 256, Label: "asset 1"})
+	require.NoError(t, err)
+	require.Greater(t, img.Bounds().Dy(), 256)
+}
@@ -0,0 +1,141 @@
+package qrcode
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"image/png"
+	"io"
+
+	"github.com/makiuchi-d/gozxing/qrcode/encoder"
+)
+
+// RenderSVG render qrcode as svg image.
+// svg is announced by screen readers with <title> as alt text and <desc> as content.
+func (q *QR) RenderSVG(w io.Writer, opts *RenderOptions) error {
+	if opts.Composite != nil {
+		return errCompositeRaster
+	}
+	if opts.BackgroundImage != nil {
+		return errBackgroundRaster
+	}
+	if opts.Halftone != nil {
+		return errHalftoneRaster
+	}
+
+	code, err := q.encode(opts)
+	if err != nil {
+		return err
+	}
+
+	alt := opts.Alt
+	if alt == "" {
+		alt = q.Content
+	}
+
+	return renderSVG(w, code.GetMatrix(), opts, alt, q.Content)
+}
+
+func renderSVG(w io.Writer, matrix *encoder.ByteMatrix, opts *RenderOptions, title, desc string) error {
+	l := newLayout(matrix, opts)
+	if err := l.checkExactSize(matrix, opts); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" role="img" aria-labelledby="qr-title qr-desc" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n",
+		l.width, l.height, l.width, l.height)
+
+	fmt.Fprint(bw, `<title id="qr-title">`)
+	if err := xml.EscapeText(bw, []byte(title)); err != nil {
+		return err
+	}
+	fmt.Fprint(bw, "</title>\n")
+
+	fmt.Fprint(bw, `<desc id="qr-desc">`)
+	if err := xml.EscapeText(bw, []byte(desc)); err != nil {
+		return err
+	}
+	fmt.Fprint(bw, "</desc>\n")
+
+	// outside of rounded corners is left transparent
+	fmt.Fprintf(bw, `<rect width="%d" height="%d" rx="%d" %s/>`+"\n", l.width, l.height, l.radius, svgFill(opts.padColor()))
+	if opts.PadColor != nil {
+		fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="%d" %s/>`+"\n",
+			l.quietZone.Min.X, l.quietZone.Min.Y, l.quietZone.Dx(), l.quietZone.Dy(), svgFill(opts.background()))
+	}
+
+	// modules of the same color are drawn in one path; dots are drawn as arc pairs
+	fills := []string{svgFill(opts.foreground())}
+	paths := map[string]*bytes.Buffer{fills[0]: {}}
+	for y := 0; y < matrix.GetHeight(); y++ {
+		for x := 0; x < matrix.GetWidth(); x++ {
+			if matrix.Get(x, y) != 1 {
+				continue
+			}
+
+			fill := svgFill(opts.moduleColor(x, y, matrix.GetWidth()))
+			path, ok := paths[fill]
+			if !ok {
+				path = &bytes.Buffer{}
+				paths[fill] = path
+				fills = append(fills, fill)
+			}
+
+			left, top := l.left+x*l.multiple, l.top+y*l.multiple
+			if opts.Shape == ShapeDot && !isFinder(x, y, matrix.GetWidth()) {
+				r := float64(l.multiple) / 2
+				fmt.Fprintf(path, "M%d %ga%g %g 0 1 0 %d 0a%g %g 0 1 0 -%d 0z", left, float64(top)+r, r, r, l.multiple, r, r, l.multiple)
+				continue
+			}
+			fmt.Fprintf(path, "M%d %dh%dv%dh-%dz", left, top, l.multiple, l.multiple, l.multiple)
+		}
+	}
+	for _, fill := range fills {
+		fmt.Fprintf(bw, `<path %s d="%s"/>`+"\n", fill, paths[fill].Bytes())
+	}
+
+	if !l.logoBox.Empty() {
+		fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="%d" %s/>`+"\n",
+			l.logoBox.Min.X, l.logoBox.Min.Y, l.logoBox.Dx(), l.logoBox.Dy(), svgFill(opts.background()))
+	}
+
+	if opts.Logo != nil {
+		var logo bytes.Buffer
+		if err := png.Encode(&logo, opts.Logo); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(bw, `<image x="%d" y="%d" width="%d" height="%d" preserveAspectRatio="xMidYMid meet" href="data:image/png;base64,%s"/>`+"\n",
+			l.logo.Min.X, l.logo.Min.Y, l.logo.Dx(), l.logo.Dy(), base64.StdEncoding.EncodeToString(logo.Bytes()))
+	}
+
+	if opts.Label != "" {
+		for i, line := range l.label.lines {
+			fmt.Fprintf(bw, `<text x="%d" y="%d" text-anchor="middle" font-family="monospace" font-size="%d" %s>`,
+				l.width/2, l.label.baseline(l.labelTop, i), l.label.size, svgFill(opts.foreground()))
+			if err := xml.EscapeText(bw, []byte(line)); err != nil {
+				return err
+			}
+			fmt.Fprint(bw, "</text>\n")
+		}
+	}
+
+	fmt.Fprint(bw, "</svg>\n")
+
+	return bw.Flush()
+}
+
+// svgFill returns fill attributes of the color
+func svgFill(c color.Color) string {
+	rgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	fill := fmt.Sprintf(`fill="#%02x%02x%02x"`, rgba.R, rgba.G, rgba.B)
+	if rgba.A != 0xff {
+		fill += fmt.Sprintf(` fill-opacity="%.3g"`, float64(rgba.A)/0xff)
+	}
+	return fill
+}
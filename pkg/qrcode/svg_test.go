@@ -0,0 +1,138 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderSVG(t *testing.T) {
+	qr, _ := Text("hello <world>")
+
+	type args struct {
+		alt string
+	}
+	tests := [...]struct {
+		name      string
+		args      args
+		wantTitle string
+	}{
+		{"content", args{""}, "hello <world>"},
+		{"alt", args{"greeting"}, "greeting"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, qr.RenderSVG(&buf, &RenderOptions{Width: 200, Height: 200, Alt: tt.args.alt}))
+
+			var svg struct {
+				XMLName xml.Name `xml:"svg"`
+				Role    string   `xml:"role,attr"`
+				Width   int      `xml:"width,attr"`
+				Title   string   `xml:"title"`
+				Desc    string   `xml:"desc"`
+			}
+			require.NoError(t, xml.Unmarshal(buf.Bytes(), &svg))
+			require.Equal(t, "img", svg.Role)
+			require.Equal(t, 200, svg.Width)
+			require.Equal(t, tt.wantTitle, svg.Title)
+			require.Equal(t, "hello <world>", svg.Desc)
+		})
+	}
+}
+
+func TestRenderSVGEyeColor(t *testing.T) {
+	qr, _ := Text("hello world")
+
+	tests := [...]struct {
+		name      string
+		opts      *RenderOptions
+		wantFills []string
+	}{
+		{"default", &RenderOptions{}, []string{"#000000"}},
+		{"eyes", &RenderOptions{EyeOuter: color.RGBA{0xcc, 0x00, 0x00, 0xff}, EyeInner: color.RGBA{0x00, 0x66, 0x00, 0xff}}, []string{"#000000", "#cc0000", "#006600"}},
+		{"same as fg", &RenderOptions{EyeOuter: color.Black, EyeInner: color.Black}, []string{"#000000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, qr.RenderSVG(&buf, tt.opts))
+
+			var svg struct {
+				Paths []struct {
+					Fill string `xml:"fill,attr"`
+				} `xml:"path"`
+			}
+			require.NoError(t, xml.Unmarshal(buf.Bytes(), &svg))
+
+			fills := []string{}
+			for _, path := range svg.Paths {
+				fills = append(fills, path.Fill)
+			}
+			require.Equal(t, tt.wantFills, fills)
+		})
+	}
+}
+
+// TestRenderSVGLayout svg is of the same size, quiet zone and module positions as the raster image,
+// so that printed svg and png of the same content line up module for module
+func TestRenderSVGLayout(t *testing.T) {
+	margin0, margin2 := 0, 2
+	tests := [...]struct {
+		name string
+		opts RenderOptions
+	}{
+		{"default", RenderOptions{Width: 200, Height: 200}},
+		{"not square", RenderOptions{Width: 200, Height: 150}},
+		{"margin", RenderOptions{Width: 100, Height: 100, Margin: &margin2}},
+		{"no margin", RenderOptions{Width: 100, Height: 100, Margin: &margin0}},
+		{"small", RenderOptions{Width: 10, Height: 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qr, _ := Text("https://example.com/")
+			img, err := qr.RenderWithOptions(&tt.opts)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			require.NoError(t, qr.RenderSVG(&buf, &tt.opts))
+			var svg struct {
+				Width  int `xml:"width,attr"`
+				Height int `xml:"height,attr"`
+				Paths  []struct {
+					D string `xml:"d,attr"`
+				} `xml:"path"`
+			}
+			require.NoError(t, xml.Unmarshal(buf.Bytes(), &svg))
+			require.Equal(t, img.Bounds().Dx(), svg.Width)
+			require.Equal(t, img.Bounds().Dy(), svg.Height)
+			require.Len(t, svg.Paths, 1)
+
+			// every module of the svg is dark in the image, and every dark pixel is in a module
+			inModule := map[[2]int]bool{}
+			for _, module := range strings.Split(strings.TrimSuffix(svg.Paths[0].D, "z"), "z") {
+				var x, y, size int
+				_, err := fmt.Sscanf(module, "M%d %dh%d", &x, &y, &size)
+				require.NoError(t, err)
+				for py := y; py < y+size; py++ {
+					for px := x; px < x+size; px++ {
+						require.Equal(t, color.Gray{}, color.GrayModel.Convert(img.At(px, py)), "(%d, %d)", px, py)
+						inModule[[2]int{px, py}] = true
+					}
+				}
+			}
+			for py := 0; py < svg.Height; py++ {
+				for px := 0; px < svg.Width; px++ {
+					if color.GrayModel.Convert(img.At(px, py)) == (color.Gray{}) {
+						require.True(t, inModule[[2]int{px, py}], "(%d, %d)", px, py)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package qrcode
+
+import (
+	"bufio"
+	"io"
+)
+
+// RenderText render qrcode as text with unicode half blocks, two module rows per line.
+// dark modules are drawn as blocks, so it is for light background.
+func (q *QR) RenderText(w io.Writer, opts *RenderOptions) error {
+	if opts.Composite != nil {
+		return errCompositeRaster
+	}
+	if opts.BackgroundImage != nil {
+		return errBackgroundRaster
+	}
+	if opts.Halftone != nil {
+		return errHalftoneRaster
+	}
+
+	code, err := q.encode(opts)
+	if err != nil {
+		return err
+	}
+
+	matrix := code.GetMatrix()
+	m := opts.margins()
+	width, height := matrix.GetWidth()+m.left+m.right, matrix.GetHeight()+m.top+m.bottom
+	dark := func(x, y int) bool {
+		x, y = x-m.left, y-m.top
+		return x >= 0 && y >= 0 && x < matrix.GetWidth() && y < matrix.GetHeight() && matrix.Get(x, y) == 1
+	}
+
+	bw := bufio.NewWriter(w)
+	for y := 0; y < height; y += 2 {
+		for x := 0; x < width; x++ {
+			switch upper, lower := dark(x, y), dark(x, y+1); {
+			case upper && lower:
+				bw.WriteString("█")
+			case upper:
+				bw.WriteString("▀")
+			case lower:
+				bw.WriteString("▄")
+			default:
+				bw.WriteString(" ")
+			}
+		}
+		bw.WriteString("\n")
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,40 @@
+package qrcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderText(t *testing.T) {
+	qr, _ := Text("hello world")
+	zero := 0
+
+	var buf bytes.Buffer
+	require.NoError(t, qr.RenderText(&buf, &RenderOptions{Margin: &zero}))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 11) // 21 rows
+	for _, line := range lines {
+		require.Len(t, []rune(line), 21)
+	}
+
+	// top rows of finder patterns
+	require.Equal(t, "█▀▀▀▀▀█", string([]rune(lines[0])[:7]))
+	require.Equal(t, "█▀▀▀▀▀█", string([]rune(lines[0])[14:]))
+
+	buf.Reset()
+	require.NoError(t, qr.RenderText(&buf, &RenderOptions{}))
+	require.Len(t, strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"), 15) // 29 rows
+
+	// quiet zone of the sides
+	buf.Reset()
+	two := 2
+	require.NoError(t, qr.RenderText(&buf, &RenderOptions{Margin: &zero, MarginLeft: &two, MarginBottom: &two}))
+	lines = strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 12) // 23 rows
+	require.Equal(t, "  █▀▀▀▀▀█", string([]rune(lines[0])[:9]))
+	require.Len(t, []rune(lines[0]), 23)
+}
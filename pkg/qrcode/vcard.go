@@ -0,0 +1,124 @@
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/whitekid/goxp/fx"
+)
+
+// vCard versions of generated contacts
+const (
+	VCardVersion21 = "2.1" // legacy parsers of old feature phones and car systems
+	VCardVersion3  = "3.0"
+	VCardVersion4  = "4.0"
+)
+
+// VCardVersions supported vCard versions of generated contacts
+var VCardVersions = []string{VCardVersion21, VCardVersion3, VCardVersion4}
+
+// ParseVCardVersion returns normalized vCard version, 4.0 if empty
+func ParseVCardVersion(s string) (string, error) {
+	switch s {
+	case "":
+		return VCardVersion4, nil
+	case "3", "4":
+		s += ".0"
+	}
+
+	if !fx.Contains(VCardVersions, s) {
+		return "", errors.New("invalid vcard version: " + s)
+	}
+	return s, nil
+}
+
+// Date date of the contact such as birthday; Year is 0 if the year is unknown
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// ParseDate parse `YYYY-MM-DD`, or `--MM-DD` of unknown year as RFC 6350
+func ParseDate(s string) (*Date, error) {
+	value, unknownYear := s, strings.HasPrefix(s, "--")
+	if unknownYear {
+		// leap year so that --02-29 is valid
+		value = "2000" + s[1:]
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date: %s", s)
+	}
+
+	d := &Date{Year: t.Year(), Month: t.Month(), Day: t.Day()}
+	if unknownYear {
+		d.Year = 0
+	}
+	return d, nil
+}
+
+// Format returns the date value of the vCard version; 4.0 is basic format as `19960415` or `--0415`,
+// 3.0 and 2.1 are extended format as `1996-04-15` or truncated `--04-15` of ISO 8601.
+func (d *Date) Format(version string) string {
+	if version != VCardVersion4 {
+		if d.Year == 0 {
+			return fmt.Sprintf("--%02d-%02d", d.Month, d.Day)
+		}
+		return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+	}
+
+	if d.Year == 0 {
+		return fmt.Sprintf("--%02d%02d", d.Month, d.Day)
+	}
+	return fmt.Sprintf("%04d%02d%02d", d.Year, d.Month, d.Day)
+}
+
+// AnniversaryField returns property of anniversary; ANNIVERSARY is added by 4.0, X-ANNIVERSARY is used for 3.0 and 2.1
+func AnniversaryField(version string) string {
+	if version != VCardVersion4 {
+		return "X-ANNIVERSARY"
+	}
+	return "ANNIVERSARY"
+}
+
+// phoneNumberPattern digits with optional leading `+`, separated by space, hyphen, dot or parentheses
+var phoneNumberPattern = regexp.MustCompile(`^\+?\(?[0-9][0-9 ().-]{0,30}$`)
+
+// ValidPhoneNumber returns true if the number is digits with optional leading `+` and visual separators,
+// ex) `+82-10-1234-5678`, `(02) 123.4567`
+func ValidPhoneNumber(number string) bool {
+	return phoneNumberPattern.MatchString(number)
+}
+
+// TelProperty returns property with parameters and value of the typed telephone number of the vCard version;
+// types are such as cell, home, work, fax and pager. 4.0 is tel uri with lower-cased types as RFC 6350,
+// ex) `TEL;VALUE=uri;TYPE=cell` and `tel:+82-10-1234-5678`; 3.0 is text with upper-cased types as RFC 2426,
+// ex) `TEL;TYPE=CELL` and `+82-10-1234-5678`; 2.1 has bare types, ex) `TEL;CELL`.
+// parameters are in the property so that the order is kept.
+func TelProperty(version string, number string, types ...string) (string, string) {
+	if version == VCardVersion21 {
+		if len(types) == 0 {
+			return "TEL", number
+		}
+		return "TEL;" + strings.ToUpper(strings.Join(types, ";")), number
+	}
+
+	if version == VCardVersion3 {
+		if len(types) == 0 {
+			return "TEL", number
+		}
+		return "TEL;TYPE=" + strings.ToUpper(strings.Join(types, ",")), number
+	}
+
+	// space is not a visual separator of tel uri
+	uri := "tel:" + strings.ReplaceAll(number, " ", "-")
+	if len(types) == 0 {
+		return "TEL;VALUE=uri", uri
+	}
+	return "TEL;VALUE=uri;TYPE=" + strings.ToLower(strings.Join(types, ",")), uri
+}
@@ -0,0 +1,121 @@
+package qrcode
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/emersion/go-vcard"
+)
+
+// encodeVCard encode the card by its version; 2.1 is of the legacy syntax which go-vcard does not write
+func encodeVCard(w io.Writer, card vcard.Card) error {
+	if card.Value(vcard.FieldVersion) == VCardVersion21 {
+		return encodeVCard21(w, card)
+	}
+	return vcard.NewEncoder(w).Encode(card)
+}
+
+// encodeVCard21 encode the card as vCard 2.1 for legacy parsers such as old feature phones and car systems;
+// types are bare parameters as `TEL;HOME;FAX`, values of non-ASCII or line breaks are quoted-printable of utf-8,
+// and lines are neither folded nor soft broken as many 2.1 parsers reject them.
+func encodeVCard21(w io.Writer, card vcard.Card) error {
+	lines := []string{"BEGIN:VCARD", "VERSION:" + VCardVersion21}
+
+	keys := make([]string, 0, len(card))
+	for k := range card {
+		if !strings.EqualFold(k, vcard.FieldVersion) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, field := range card[k] {
+			lines = append(lines, formatLine21(k, field))
+		}
+	}
+	lines = append(lines, "END:VCARD")
+
+	_, err := io.WriteString(w, strings.Join(lines, "\r\n")+"\r\n")
+	return err
+}
+
+// formatLine21 returns line of the property; key may have parameters as `EMAIL;type=INTERNET;type=HOME`
+func formatLine21(key string, field *vcard.Field) string {
+	parts := strings.Split(key, ";")
+	name, params := parts[0], []string{}
+	for _, param := range parts[1:] {
+		k, v, _ := strings.Cut(param, "=")
+		params = append(params, formatParam21(k, strings.Split(v, ","))...)
+	}
+
+	paramKeys := make([]string, 0, len(field.Params))
+	for k := range field.Params {
+		paramKeys = append(paramKeys, k)
+	}
+	sort.Strings(paramKeys)
+	for _, k := range paramKeys {
+		params = append(params, formatParam21(k, field.Params[k])...)
+	}
+
+	value := field.Value
+	if needQuotedPrintable(value) && field.Params.Get("ENCODING") == "" {
+		if field.Params.Get("CHARSET") == "" {
+			params = append(params, "CHARSET=UTF-8")
+		}
+		params = append(params, "ENCODING=QUOTED-PRINTABLE")
+		value = quotedPrintable(value)
+	}
+
+	line := name
+	if field.Group != "" {
+		line = field.Group + "." + line
+	}
+	for _, param := range params {
+		line += ";" + param
+	}
+	return line + ":" + value
+}
+
+// formatParam21 returns parameters of the values; types are bare and upper-cased, values of others are repeated
+func formatParam21(k string, values []string) []string {
+	params := make([]string, 0, len(values))
+	for _, v := range values {
+		switch {
+		case v == "":
+			params = append(params, strings.ToUpper(k))
+		case strings.EqualFold(k, vcard.ParamType):
+			params = append(params, strings.ToUpper(v))
+		default:
+			params = append(params, strings.ToUpper(k)+"="+v)
+		}
+	}
+	return params
+}
+
+func needQuotedPrintable(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x7f || s[i] < 0x20 {
+			return true
+		}
+	}
+	return false
+}
+
+// quotedPrintable returns quoted-printable of the value without soft line breaks; line breaks are CRLF as `=0D=0A`
+func quotedPrintable(s string) string {
+	s = strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		trailingSpace := (c == ' ' || c == '\t') && i == len(s)-1
+		if c == '=' || c < 0x20 || c >= 0x7f || trailingSpace {
+			fmt.Fprintf(&b, "=%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package qrcode
+
+import (
+	"io"
+	"mime/quotedprintable"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVCard21(t *testing.T) {
+	card := &Card{
+		LastName:  "홍",
+		FirstName: "길동 " + strings.Repeat("가", 40), // longer than 75 octets of line by quoted-printable
+		Mobile:    "+82-10-1234-5678",
+		WorkFax:   "02-123-4568",
+		HomeEmail: "gildong@example.com",
+		Note:      "first line\nsecond = line",
+		Birthday:  &Date{Year: 1996, Month: 4, Day: 15},
+	}
+
+	card.Version = VCardVersion21
+	qr, err := Contact(card)
+	require.NoError(t, err)
+	got := qr.Content
+
+	card.Version = VCardVersion3
+	qr, err = Contact(card)
+	require.NoError(t, err)
+	v3 := qr.Content
+
+	lines := strings.Split(strings.TrimSuffix(got, "\r\n"), "\r\n")
+	require.Equal(t, "BEGIN:VCARD", lines[0])
+	require.Equal(t, "VERSION:2.1", lines[1])
+	require.Equal(t, "END:VCARD", lines[len(lines)-1])
+	require.Contains(t, lines, "TEL;CELL:+82-10-1234-5678")
+	require.Contains(t, lines, "TEL;WORK;FAX:02-123-4568")
+	require.Contains(t, lines, "EMAIL;INTERNET;HOME;PREF:gildong@example.com")
+	require.Contains(t, lines, "BDAY:1996-04-15")
+	require.NotContains(t, got, "TYPE=")
+	require.Contains(t, v3, "TEL;TYPE=CELL:+82-10-1234-5678\r\n")
+	require.Contains(t, v3, "VERSION:3.0\r\n")
+
+	// quoted-printable of non-ASCII and line breaks, without soft line breaks
+	values := map[string]string{}
+	for _, line := range lines {
+		require.False(t, strings.HasSuffix(line, "="), "soft line break: %s", line)
+		property, value, _ := strings.Cut(line, ":")
+		values[property] = value
+	}
+	for property, want := range map[string]string{
+		"N;CHARSET=UTF-8;ENCODING=QUOTED-PRINTABLE":    "홍;길동 " + strings.Repeat("가", 40) + ";;;",
+		"NOTE;CHARSET=UTF-8;ENCODING=QUOTED-PRINTABLE": "first line\r\nsecond = line",
+	} {
+		value, ok := values[property]
+		require.Truef(t, ok, "%s not found in %s", property, got)
+		require.Greater(t, len(property+":"+value), 75)
+
+		decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(value)))
+		require.NoError(t, err)
+		require.Equal(t, want, string(decoded))
+	}
+	require.Contains(t, v3, "N:홍;길동")
+}
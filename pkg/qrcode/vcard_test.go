@@ -0,0 +1,64 @@
+package qrcode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := [...]struct {
+		date    string
+		wantV4  string
+		wantV3  string
+		wantErr bool
+	}{
+		{"1996-04-15", "19960415", "1996-04-15", false},
+		{"--04-15", "--0415", "--04-15", false},
+		{"--02-29", "--0229", "--02-29", false},
+		{"2024-02-29", "20240229", "2024-02-29", false},
+		{"2023-02-29", "", "", true},
+		{"2024-02-30", "", "", true},
+		{"--02-30", "", "", true},
+		{"1996-4-15", "", "", true},
+		{"19960415", "", "", true},
+		{"", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.date, func(t *testing.T) {
+			got, err := ParseDate(tt.date)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantV4, got.Format(VCardVersion4))
+			require.Equal(t, tt.wantV3, got.Format(VCardVersion3))
+		})
+	}
+}
+
+func TestParseVCardVersion(t *testing.T) {
+	tests := [...]struct {
+		version string
+		want    string
+		wantErr bool
+	}{
+		{"", VCardVersion4, false},
+		{"4.0", VCardVersion4, false},
+		{"3", VCardVersion3, false},
+		{"2.1", VCardVersion21, false},
+		{"5.0", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := ParseVCardVersion(tt.version)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
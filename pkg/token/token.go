@@ -0,0 +1,98 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	ErrInvalid = errors.New("invalid token")
+	ErrExpired = errors.New("token is expired")
+)
+
+// Signer signs claims as compact jwt and verifies them
+type Signer struct {
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// NewSigner create signer of the algorithm;
+// key is the secret for HS256 and base64 encoded ed25519 seed or private key for EdDSA.
+func NewSigner(alg string, key string) (*Signer, error) {
+	if key == "" {
+		return nil, errors.New("signing key required")
+	}
+
+	switch alg {
+	case AlgHS256:
+		return &Signer{method: jwt.SigningMethodHS256, signKey: []byte(key), verifyKey: []byte(key)}, nil
+
+	case AlgEdDSA:
+		b, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ed25519 key: %w", err)
+		}
+
+		var privateKey ed25519.PrivateKey
+		switch len(b) {
+		case ed25519.SeedSize:
+			privateKey = ed25519.NewKeyFromSeed(b)
+		case ed25519.PrivateKeySize:
+			privateKey = ed25519.PrivateKey(b)
+		default:
+			return nil, fmt.Errorf("invalid ed25519 key size: %d", len(b))
+		}
+		return &Signer{method: jwt.SigningMethodEdDSA, signKey: privateKey, verifyKey: privateKey.Public()}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported algorithm: %s", alg)
+}
+
+// Sign returns compact jwt of the claims; `exp` and `iat` are set by the signer
+func (s *Signer) Sign(claims map[string]interface{}, expiresAt time.Time) (string, error) {
+	mc := jwt.MapClaims{}
+	for k, v := range claims {
+		mc[k] = v
+	}
+	mc["iat"] = time.Now().Unix()
+	mc["exp"] = expiresAt.Unix()
+
+	return jwt.NewWithClaims(s.method, mc).SignedString(s.signKey)
+}
+
+// Verify validate signature and expiry of the token and returns its claims
+func (s *Signer) Verify(token string) (map[string]interface{}, error) {
+	parsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		// reject tokens signed by other algorithm, such as `none`
+		if t.Method.Alg() != s.method.Alg() {
+			return nil, fmt.Errorf("unexpected algorithm: %s", t.Method.Alg())
+		}
+		return s.verifyKey, nil
+	})
+	if err != nil {
+		var ve *jwt.ValidationError
+		if errors.As(err, &ve) && ve.Errors&jwt.ValidationErrorExpired != 0 {
+			return nil, ErrExpired
+		}
+		return nil, fmt.Errorf("%w: %s", ErrInvalid, err)
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, fmt.Errorf("%w: expiry required", ErrInvalid)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,83 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner(t *testing.T) {
+	seed := base64.StdEncoding.EncodeToString(make([]byte, ed25519.SeedSize))
+
+	tests := [...]struct {
+		alg string
+		key string
+	}{
+		{AlgHS256, "secret"},
+		{AlgEdDSA, seed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			signer, err := NewSigner(tt.alg, tt.key)
+			require.NoError(t, err)
+
+			token, err := signer.Sign(map[string]interface{}{"event": "conf", "seat": "A-12", "exp": 1}, time.Now().Add(time.Hour))
+			require.NoError(t, err)
+
+			claims, err := signer.Verify(token)
+			require.NoError(t, err)
+			require.Equal(t, "conf", claims["event"])
+			require.Equal(t, "A-12", claims["seat"])
+
+			// expired
+			expired, err := signer.Sign(map[string]interface{}{"event": "conf"}, time.Now().Add(-time.Minute))
+			require.NoError(t, err)
+			_, err = signer.Verify(expired)
+			require.ErrorIs(t, err, ErrExpired)
+
+			// tampered payload
+			parts := strings.Split(token, ".")
+			other, err := signer.Sign(map[string]interface{}{"event": "conf", "seat": "VIP"}, time.Now().Add(time.Hour))
+			require.NoError(t, err)
+			_, err = signer.Verify(parts[0] + "." + strings.Split(other, ".")[1] + "." + parts[2])
+			require.ErrorIs(t, err, ErrInvalid)
+
+			// signed by other key
+			otherSigner, err := NewSigner(AlgHS256, "other")
+			require.NoError(t, err)
+			forged, err := otherSigner.Sign(map[string]interface{}{"event": "conf"}, time.Now().Add(time.Hour))
+			require.NoError(t, err)
+			_, err = signer.Verify(forged)
+			require.ErrorIs(t, err, ErrInvalid)
+
+			// unsigned
+			_, err = signer.Verify("eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0.eyJldmVudCI6ImNvbmYifQ.")
+			require.ErrorIs(t, err, ErrInvalid)
+		})
+	}
+}
+
+func TestNewSigner(t *testing.T) {
+	tests := [...]struct {
+		name    string
+		alg     string
+		key     string
+		wantErr bool
+	}{
+		{"hs256", AlgHS256, "secret", false},
+		{"empty key", AlgHS256, "", true},
+		{"unsupported", "RS256", "secret", true},
+		{"ed25519 not base64", AlgEdDSA, "!!", true},
+		{"ed25519 size", AlgEdDSA, base64.StdEncoding.EncodeToString([]byte("short")), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSigner(tt.alg, tt.key)
+			require.Equal(t, tt.wantErr, err != nil, "error = %v", err)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package qrcodeapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// readyzPath is exempt from rate limiting as probes of orchestrators call it frequently
+const readyzPath = "/readyz"
+
+const defaultCheckTimeout = 2 * time.Second
+
+// readiness check results
+const (
+	checkOK      = "ok"
+	checkWarning = "warning" // soft check failed, the server is degraded
+	checkFailed  = "failed"  // hard check failed, the server is unavailable
+)
+
+// readinessCheck dependency check of configured feature; failure of hard check makes the server not ready
+type readinessCheck struct {
+	name    string
+	hard    bool
+	timeout time.Duration // defaultCheckTimeout if 0
+	check   func(ctx context.Context) error
+}
+
+// readinessAPI runs registered dependency checks
+type readinessAPI struct {
+	checks []readinessCheck
+}
+
+var _ router = (*readinessAPI)(nil)
+
+func newReadinessAPI() *readinessAPI { return &readinessAPI{} }
+
+// register add check of the dependency; features register their checks when they are configured
+func (api *readinessAPI) register(check readinessCheck) { api.checks = append(api.checks, check) }
+
+func (api *readinessAPI) Route(e *echo.Echo, path string) {
+	e.GET(path+readyzPath, api.handleReadyz)
+}
+
+type ReadinessResponse struct {
+	Status string            `json:"status"` // ok, degraded or unavailable
+	Checks []ReadinessResult `json:"checks"`
+}
+
+type ReadinessResult struct {
+	Name     string  `json:"name"`
+	Hard     bool    `json:"hard"`
+	Status   string  `json:"status"`
+	Duration float64 `json:"duration_ms"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// handleReadyz run checks concurrently; returns 503 if any hard check failed, 200 with warning if soft check failed
+func (api *readinessAPI) handleReadyz(c echo.Context) error {
+	resp := &ReadinessResponse{Status: "ok", Checks: make([]ReadinessResult, len(api.checks))}
+
+	var wg sync.WaitGroup
+	for i, check := range api.checks {
+		wg.Add(1)
+		go func(i int, check readinessCheck) {
+			defer wg.Done()
+			resp.Checks[i] = runCheck(c.Request().Context(), check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	for _, result := range resp.Checks {
+		switch result.Status {
+		case checkFailed:
+			resp.Status, status = "unavailable", http.StatusServiceUnavailable
+		case checkWarning:
+			if status == http.StatusOK {
+				resp.Status = "degraded"
+			}
+		}
+		if result.Error != "" {
+			c.Logger().Warnf("readiness check %s: %s", result.Name, result.Error)
+		}
+	}
+
+	return c.JSON(status, resp)
+}
+
+// runCheck run the check with timeout; the check is abandoned on timeout even if it ignores the context
+func runCheck(ctx context.Context, check readinessCheck) ReadinessResult {
+	timeout := check.timeout
+	if timeout == 0 {
+		timeout = defaultCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() { errCh <- check.check(ctx) }()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = fmt.Errorf("timeout after %s", timeout)
+	}
+
+	result := ReadinessResult{Name: check.name, Hard: check.hard, Status: checkOK, Duration: milliseconds(time.Since(start))}
+	if err != nil {
+		result.Status, result.Error = checkWarning, err.Error()
+		if check.hard {
+			result.Status = checkFailed
+		}
+	}
+	return result
+}
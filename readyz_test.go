@@ -0,0 +1,95 @@
+package qrcodeapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/pkg/links"
+)
+
+func TestReadiness(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+	// slow ignores the context so that the timeout of the check is tested
+	slow := func(ctx context.Context) error { time.Sleep(500 * time.Millisecond); return nil }
+
+	tests := [...]struct {
+		name         string
+		checks       []readinessCheck
+		wantStatus   int
+		wantReady    string
+		wantStatuses []string
+		wantErrors   []string
+	}{
+		{"no checks", nil, http.StatusOK, "ok", nil, nil},
+		{"ok", []readinessCheck{{name: "a", hard: true, check: ok}, {name: "b", check: ok}},
+			http.StatusOK, "ok", []string{checkOK, checkOK}, []string{"", ""}},
+		{"soft failure", []readinessCheck{{name: "a", hard: true, check: ok}, {name: "b", check: failing}},
+			http.StatusOK, "degraded", []string{checkOK, checkWarning}, []string{"", "connection refused"}},
+		{"hard failure", []readinessCheck{{name: "a", hard: true, check: failing}, {name: "b", check: failing}},
+			http.StatusServiceUnavailable, "unavailable", []string{checkFailed, checkWarning}, []string{"connection refused", "connection refused"}},
+		{"slow", []readinessCheck{{name: "a", hard: true, timeout: 50 * time.Millisecond, check: slow}, {name: "b", check: ok}},
+			http.StatusServiceUnavailable, "unavailable", []string{checkFailed, checkOK}, []string{"timeout after 50ms", ""}},
+		{"slow in time", []readinessCheck{{name: "a", hard: true, timeout: time.Second, check: slow}},
+			http.StatusOK, "ok", []string{checkOK}, []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(ctx, &readinessAPI{checks: tt.checks})
+
+			start := time.Now()
+			resp, err := request.Get("%s/readyz", ts.URL).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			// timed out check does not hold the response
+			require.Less(t, time.Since(start), time.Second+200*time.Millisecond)
+
+			got := &ReadinessResponse{}
+			require.NoError(t, resp.JSON(got))
+			require.Equal(t, tt.wantReady, got.Status)
+			require.Len(t, got.Checks, len(tt.checks))
+			for i, c := range got.Checks {
+				require.Equal(t, tt.checks[i].name, c.Name)
+				require.Equal(t, tt.checks[i].hard, c.Hard)
+				require.Equal(t, tt.wantStatuses[i], c.Status)
+				require.Equal(t, tt.wantErrors[i], c.Error)
+				require.GreaterOrEqual(t, c.Duration, 0.0)
+			}
+		})
+	}
+}
+
+func TestReadinessLinkStore(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	store, err := links.NewFileStore(dir)
+	require.NoError(t, err)
+
+	api := newReadinessAPI()
+	api.register(readinessCheck{name: "link_store", hard: true, check: store.Ping})
+	ts := newTestServer(ctx, api)
+
+	get := func() int {
+		resp, err := request.Get("%s/readyz", ts.URL).Do(ctx)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusOK, get())
+	require.NoError(t, os.RemoveAll(dir))
+	require.Equal(t, http.StatusServiceUnavailable, get())
+}
@@ -0,0 +1,134 @@
+package qrcodeapi
+
+import (
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// redacted placeholder of sensitive values in logs and error messages
+const redacted = "[REDACTED]"
+
+// sensitiveParams lower cased names of request parameters whose values are never logged or echoed,
+// collected from `sensitive:"true"` fields of binding structs, see markSensitive
+var sensitiveParams = map[string]bool{}
+
+func init() {
+	markSensitive(WIFIRequest{}, WifiDocument{}, IssueTokenRequest{}, VerifyTokenRequest{})
+}
+
+// markSensitive add query, form and json names of `sensitive:"true"` fields of the binding structs;
+// fields of nested structs are also added.
+func markSensitive(bindings ...interface{}) {
+	for _, b := range bindings {
+		markSensitiveType(reflect.TypeOf(b))
+	}
+}
+
+func markSensitiveType(t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Tag.Get("sensitive") != "true" {
+			markSensitiveType(f.Type)
+			continue
+		}
+
+		for _, key := range []string{"query", "form", "json"} {
+			if name, _, _ := strings.Cut(f.Tag.Get(key), ","); name != "" && name != "-" {
+				sensitiveParams[strings.ToLower(name)] = true
+			}
+		}
+	}
+}
+
+var (
+	// otpSecretPattern shared secret of otpauth uris, ex) `otpauth://totp/a?secret=JBSWY3DP`
+	otpSecretPattern = regexp.MustCompile(`(?i)([?&]secret=)[^&#]*`)
+	// wifiPassPattern password field of wifi payloads, ex) `WIFI:T:WPA;S:home;P:pass;;`
+	wifiPassPattern = regexp.MustCompile(`(?i)(^WIFI:|;)(P:)(?:\\.|[^;\\])*`)
+)
+
+// redactPayload returns the value with secrets embedded in payloads, given as content or url, replaced with the placeholder
+func redactPayload(value string) string {
+	value = otpSecretPattern.ReplaceAllString(value, "${1}"+redacted)
+	if len(value) >= 5 && strings.EqualFold(value[:5], "WIFI:") {
+		value = wifiPassPattern.ReplaceAllString(value, "${1}${2}"+redacted)
+	}
+	return value
+}
+
+// sensitivePayload returns true if the payload is of secrets, such as wifi networks and otp, which are never exposed
+// other than in the code itself
+func sensitivePayload(kind string, content string) bool {
+	for _, scheme := range []string{"WIFI:", "otpauth:"} {
+		if len(content) >= len(scheme) && strings.EqualFold(content[:len(scheme)], scheme) {
+			return true
+		}
+	}
+	return kind == kindWifi || redactPayload(content) != content
+}
+
+// redactParam returns placeholder if the parameter is sensitive, the value with secrets of payloads redacted otherwise
+func redactParam(name, value string) string {
+	if sensitiveParams[strings.ToLower(name)] {
+		return redacted
+	}
+	return redactPayload(value)
+}
+
+// redactQuery returns the raw query with sensitive values redacted; order and encoding of other parameters are kept
+func redactQuery(rawQuery string) string {
+	pairs := strings.Split(rawQuery, "&")
+	for i, pair := range pairs {
+		rawName, rawValue, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+
+		name, err := url.QueryUnescape(rawName)
+		if err != nil {
+			name = rawName
+		}
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			// the value can not be checked
+			pairs[i] = rawName + "=" + url.QueryEscape(redacted)
+			continue
+		}
+
+		if r := redactParam(name, value); r != value {
+			pairs[i] = rawName + "=" + url.QueryEscape(r)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// redactURI returns request uri with sensitive query parameters redacted
+func redactURI(uri string) string {
+	path, query, ok := strings.Cut(uri, "?")
+	if !ok {
+		return uri
+	}
+	return path + "?" + redactQuery(query)
+}
+
+// redactRequestURI redact request uri of the access log; handlers read parameters of the url, not the request uri.
+func redactRequestURI() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			req.RequestURI = redactURI(req.RequestURI)
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package qrcodeapi
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+	"qrcodeapi/pkg/token"
+)
+
+func TestRedactQuery(t *testing.T) {
+	tests := [...]struct {
+		query string
+		want  string
+	}{
+		{"ssid=home&pass=hunter2", "ssid=home&pass=%5BREDACTED%5D"},
+		{"PASS=hunter2&w=100", "PASS=%5BREDACTED%5D&w=100"},
+		{"content=otpauth%3A%2F%2Ftotp%2Fa%3Fsecret%3DJBSWY3DP%26issuer%3Dex", "content=otpauth%3A%2F%2Ftotp%2Fa%3Fsecret%3D%5BREDACTED%5D%26issuer%3Dex"},
+		{"content=WIFI:T:WPA;S:home;P:hunter2;;", "content=WIFI%3AT%3AWPA%3BS%3Ahome%3BP%3A%5BREDACTED%5D%3B%3B"},
+		{"content=WIFI:P:hunter2\\;x;S:home;;", "content=WIFI%3AP%3A%5BREDACTED%5D%3BS%3Ahome%3B%3B"},
+		{"content=hello&ec=H", "content=hello&ec=H"},
+		{"pass=%zz", "pass=%5BREDACTED%5D"},
+		{"token=eyJhbGciOiJIUzI1NiJ9.e30.sig", "token=%5BREDACTED%5D"},
+		{"claims=%7B%22seat%22%3A%22A-12%22%7D", "claims=%5BREDACTED%5D"},
+		{"flag", "flag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			require.Equal(t, tt.want, redactQuery(tt.query))
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	const pass, secret = "hunter2-wifi-pass", "JBSWY3DPEHPK3PXP"
+
+	signer, err := token.NewSigner(token.AlgHS256, "signing-key")
+	require.NoError(t, err)
+	signed, err := signer.Sign(map[string]interface{}{"seat": "A-12"}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	e := newEcho(config.Current(), &out)
+	newAPIv1(config.Current(), codes.NewMemoryStore(), nil).Route(e, "")
+	newTokenAPI(config.Current(), signer).Route(e, "")
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	tests := [...]struct {
+		name       string
+		path       string
+		params     map[string]string
+		wantStatus int
+	}{
+		{"wifi", "/wifi", map[string]string{"ssid": "home", "auth": "WPA", "pass": pass}, http.StatusOK},
+		{"wifi invalid", "/wifi", map[string]string{"auth": "WPA", "pass": pass}, http.StatusBadRequest},
+		{"wifi invalid image", "/wifi", map[string]string{"ssid": "home", "auth": "WPA", "pass": pass, "ec": "bogus", "errorMode": "image"}, http.StatusBadRequest},
+		{"wifi content", "/qrcode", map[string]string{"content": "WIFI:T:WPA;S:home;P:" + pass + ";;", "ec": "bogus"}, http.StatusBadRequest},
+		{"otp", "/qrcode", map[string]string{"content": "otpauth://totp/ex:kim?secret=" + secret + "&issuer=ex"}, http.StatusOK},
+		{"otp invalid", "/qrcode", map[string]string{"content": "otpauth://totp/ex:kim?secret=" + secret, "fg": "bogus"}, http.StatusBadRequest},
+		{"otp url", "/url", map[string]string{"target": "https://a.example/otp?secret=" + secret, "shorten": "bogus"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request.Get("%s%s", ts.URL, tt.path)
+			for k, v := range tt.params {
+				req = req.Query(k, v)
+			}
+			resp, err := req.Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.NotContains(t, string(body), pass)
+			require.NotContains(t, string(body), secret)
+		})
+	}
+
+	// token given as query parameter, and token of the body failed to verify
+	for _, body := range []map[string]string{{"token": signed}, {"token": signed + "x"}} {
+		resp, err := request.Post("%s/token/verify", ts.URL).Query("token", signed).JSON(body).Do(ctx)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NotContains(t, string(body), signed)
+	}
+
+	// echoed values of sensitive parameters
+	require.Equal(t, "invalid pass: [REDACTED]", invalidParam("pass", pass).(interface{ Unwrap() error }).Unwrap().Error())
+	e.Logger.Errorf("%+v", invalidParam("pass", pass))
+
+	log := out.String()
+	require.NotContains(t, log, pass)
+	require.NotContains(t, log, secret)
+	require.NotContains(t, log, signed)
+	require.Contains(t, log, "pass=%5BREDACTED%5D")
+	require.Contains(t, log, "token=%5BREDACTED%5D")
+	require.Contains(t, log, "ssid=home")
+}
@@ -0,0 +1,86 @@
+package qrcodeapi
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"golang.org/x/time/rate"
+
+	"qrcodeapi/config"
+)
+
+// reloadConfig reload config file and apply it to the server of the config; in-flight requests are not affected
+func reloadConfig(e *echo.Echo, cfg *config.Config) ([]string, error) {
+	warnings, err := config.Reload(cfg)
+	if err != nil {
+		e.Logger.Errorf("config is not reloaded: %s", err)
+		return nil, err
+	}
+
+	e.Logger.SetLevel(logLevels[cfg.Snapshot().LogLevel])
+	for _, w := range warnings {
+		e.Logger.Warnf("config reloaded: %s", w)
+	}
+	e.Logger.Infof("config reloaded")
+
+	return warnings, nil
+}
+
+// adminAPI server management api
+type adminAPI struct {
+	cfg *config.Config
+}
+
+var _ router = (*adminAPI)(nil)
+
+func newAdminAPI(cfg *config.Config) router { return &adminAPI{cfg: cfg} }
+
+func (api *adminAPI) Route(e *echo.Echo, path string) {
+	e.POST(path+"/admin/reload", func(c echo.Context) error { return api.handleReload(c, e) }, requireAPIKey(api.cfg))
+	e.POST(path+"/admin/cache/purge", api.handlePurgeCache, requireAuthConfigured(api.cfg), requireAPIKey(api.cfg))
+}
+
+type ReloadResponse struct {
+	Warnings []string `json:"warnings"` // changed configs which require restart
+}
+
+// handleReload reload config as SIGHUP does; returns 400 and keeps current config if new config is not valid
+func (api *adminAPI) handleReload(c echo.Context, e *echo.Echo) error {
+	warnings, err := reloadConfig(e, api.cfg)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "config is not reloaded: "+err.Error())
+	}
+
+	if warnings == nil {
+		warnings = []string{}
+	}
+
+	return c.JSON(http.StatusOK, &ReloadResponse{Warnings: warnings})
+}
+
+// rateLimiterStore rate limiter store which follows rate_limit config;
+// counters are reset when the limit is changed.
+type rateLimiterStore struct {
+	cfg   *config.Config
+	mu    sync.Mutex
+	limit int
+	store middleware.RateLimiterStore
+}
+
+var _ middleware.RateLimiterStore = (*rateLimiterStore)(nil)
+
+func (s *rateLimiterStore) Allow(identifier string) (bool, error) {
+	limit := s.cfg.Snapshot().RateLimit
+
+	s.mu.Lock()
+	if s.store == nil || s.limit != limit {
+		s.limit = limit
+		s.store = middleware.NewRateLimiterMemoryStore(rate.Limit(limit))
+	}
+	store := s.store
+	s.mu.Unlock()
+
+	return store.Allow(identifier)
+}
@@ -0,0 +1,127 @@
+package qrcodeapi
+
+import (
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+)
+
+func TestReload(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	allowLoopback(t)
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig := func(content string) {
+		require.NoError(t, os.WriteFile(configFile, []byte(content), 0o644))
+	}
+	writeConfig("api_keys: [secret]\npresets: {brand: {fg: \"#ff0000\"}}\n")
+
+	viper.Set("config_file", configFile)
+	viper.Set("api_keys", []string{"secret"})
+	defer func() {
+		viper.Set("config_file", "")
+		viper.Set("api_keys", []string{})
+		viper.Set("presets", map[string]interface{}{})
+		viper.Set("rate_limit", 20)
+		viper.Set("log_level", "info")
+	}()
+
+	// rate limit of the server follows the reloaded config
+	cfg := config.Current()
+	e := newEcho(cfg, os.Stdout)
+	newAPIv1(cfg, codes.NewMemoryStore(), nil).Route(e, "")
+	newAdminAPI(cfg).Route(e, "")
+	ts := httptest.NewServer(e)
+	defer ts.Close()
+
+	reload := func() (int, *ReloadResponse) {
+		resp, err := request.Post("%s/admin/reload", ts.URL).
+			Header(echo.HeaderAuthorization, "Bearer secret").
+			Do(ctx)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		got := &ReloadResponse{}
+		if resp.Success() {
+			require.NoError(t, resp.JSON(got))
+		}
+		return resp.StatusCode, got
+	}
+
+	preset := func(name string) int {
+		resp, err := request.Get("%s/qrcode", ts.URL).Query("content", "hello").Query("preset", name).Do(ctx)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// api key is required
+	resp, err := request.Post("%s/admin/reload", ts.URL).Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	status, _ := reload()
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, http.StatusOK, preset("brand"))
+
+	// swap preset; listener address is not changed
+	writeConfig("api_keys: [secret]\npresets: {other: {fg: \"#00ff00\"}}\nbind_addr: 127.0.0.1:9000\n")
+	status, got := reload()
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, []string{"bind_addr is changed, restart required"}, got.Warnings)
+	require.Equal(t, "127.0.0.1:8000", cfg.BindAddr)
+	require.Equal(t, http.StatusBadRequest, preset("brand"))
+	require.Equal(t, http.StatusOK, preset("other"))
+
+	// invalid config is rejected and current config is kept
+	writeConfig("api_keys: [secret]\nrate_limit: 0\n")
+	status, _ = reload()
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, 20, config.Current().RateLimit)
+	require.Equal(t, 20, cfg.RateLimit)
+	require.Equal(t, http.StatusOK, preset("other"))
+
+	// in-flight request is served while reloading
+	logoServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		png.Encode(w, image.NewGray(image.Rect(0, 0, 16, 16)))
+	}))
+	defer logoServer.Close()
+
+	inflight := make(chan int)
+	go func() {
+		resp, err := request.Get("%s/qrcode", ts.URL).Query("content", "in flight").Query("logo", logoServer.URL+"/logo.png").Do(ctx)
+		if err != nil {
+			inflight <- 0
+			return
+		}
+		resp.Body.Close()
+		inflight <- resp.StatusCode
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// swap rate limit; burst follows the limit
+	writeConfig("api_keys: [secret]\npresets: {other: {fg: \"#00ff00\"}}\nrate_limit: 2\n")
+	status, _ = reload()
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, http.StatusOK, <-inflight)
+
+	require.Equal(t, http.StatusOK, preset("other"))
+	require.Equal(t, http.StatusOK, preset("other"))
+	require.Equal(t, http.StatusTooManyRequests, preset("other"))
+}
@@ -0,0 +1,910 @@
+package qrcodeapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/whitekid/goxp/fx"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+	"qrcodeapi/pkg/qrcode"
+)
+
+// image size limits
+const (
+	defaultSize = 200
+	minSize     = 21
+	maxSize     = 200 // default of max_size config
+
+	maxDPR = 4
+)
+
+type RenderRequest struct {
+	W            int         `query:"w"`
+	H            int         `query:"h"`
+	T            string      `query:"t"`
+	DPR          float64     `query:"dpr"` // device pixel ratio; w, h are css pixels
+	CornerRadius int         `query:"cornerRadius"`
+	Progressive  bool        `query:"progressive"`
+	Interlace    bool        `query:"interlace"` // png only alias of progressive
+	ExactSize    bool        `query:"exactSize"` // output is exactly w x h, including the label
+	Alt          string      `query:"alt"`
+	EC           string      `query:"ec"`      // error correction level
+	Charset      string      `query:"charset"` // charset of byte mode content, utf-8 if not given
+	ECI          bool        `query:"eci"`     // designate the charset by ECI; always for other charsets than utf-8
+	FG           color.Color `query:"fg"`
+	BG           color.Color `query:"bg"`
+	PadColor     color.Color `query:"padColor"`      // letterbox outside of the quiet zone, bg if not given
+	EyeOuter     color.Color `query:"eyeOuterColor"` // finder pattern outer ring, fg if not given
+	EyeInner     color.Color `query:"eyeInnerColor"` // finder pattern inner dot, fg if not given
+	Shape        string      `query:"shape"`
+	Mask         string      `query:"mask"`      // mask strategy or pattern
+	Margin       int         `query:"margin"`    // quiet zone in modules
+	MarginTop    int         `query:"marginTop"` // quiet zone of each side, margin if not given
+	MarginRight  int         `query:"marginRight"`
+	MarginBottom int         `query:"marginBottom"`
+	MarginLeft   int         `query:"marginLeft"`
+	Label        string      `query:"label"`
+	LabelSize    float64     `query:"captionSize"` // pixels, or ratio of the width if less than 1
+	Compression  string      `query:"compression"` // png compression level
+	Logo         string      `query:"logo"`        // logo image url
+	LogoAuto     bool        `query:"logoAuto"`    // knockout sized to the error correction level
+
+	// image onto which the code is drawn
+	Background    string `query:"background"`    // image url, no background if empty
+	BackgroundPos string `query:"backgroundPos"` // center or x,y pixels of the image
+
+	Halftone string `query:"halftone"` // url of image shown through the modules
+
+	// second code of the same content next to the code
+	Composite     string `query:"composite"` // layout, no composite if empty
+	CompositeEC   string `query:"compositeEc"`
+	CompositeSize int    `query:"compositeSize"`
+
+	maxVersion int // max_version config
+	maxSize    int // max_size config
+}
+
+const (
+	maxMargin      = 16
+	maxLabelLength = 64
+)
+
+// paramError invalid request parameter
+type paramError struct {
+	name   string
+	value  string
+	reason string // why the color is invalid, of invalidColor
+}
+
+// Error returns message of the parameter; values of sensitive parameters are redacted
+func (e *paramError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.name, redactParam(e.name, e.value))
+}
+
+// invalidParam returns bad request error; name of the parameter is kept as internal error
+func invalidParam(name, value string) error {
+	pe := &paramError{name: name, value: value}
+	return echo.NewHTTPError(http.StatusBadRequest, pe.Error()).SetInternal(pe)
+}
+
+// invalidColor returns bad request error with the reason of parseColor
+func invalidColor(name, value string, err error) error {
+	pe := &paramError{name: name, value: value, reason: err.Error()}
+	return echo.NewHTTPError(http.StatusBadRequest, name+": "+pe.reason).SetInternal(pe)
+}
+
+// parseRenderRequest parse render parameters with value getter, such as echo.Context.QueryParam;
+// omitted parameters are of render_defaults config, or the built-in defaults.
+func parseRenderRequest(cfg *config.Config, param func(name string) string) (*RenderRequest, error) {
+	param = withRenderDefaults(param, cfg.RenderDefaults)
+
+	// NOTE c.Bind()는 Post에서 동작하지 않음
+	req := &RenderRequest{
+		maxVersion:   cfg.MaxVersion,
+		maxSize:      cfg.MaxSize,
+		W:            parseIntDef(param("w"), defaultSize, minSize, cfg.MaxSize),
+		H:            parseIntDef(param("h"), defaultSize, minSize, cfg.MaxSize),
+		T:            param("t"),
+		CornerRadius: parseIntDef(param("cornerRadius"), 0, 0, 100),
+		Alt:          param("alt"),
+		Margin:       parseIntDef(param("margin"), qrcode.QuietZone, 0, maxMargin),
+		Label:        param("label"),
+		Logo:         param("logo"),
+	}
+
+	if v := param("logoAuto"); v != "" {
+		logoAuto, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, invalidParam("logoAuto", v)
+		}
+		req.LogoAuto = logoAuto
+	}
+
+	if v := param("progressive"); v != "" {
+		progressive, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, invalidParam("progressive", v)
+		}
+		req.Progressive = progressive
+	}
+
+	if v := param("exactSize"); v != "" {
+		exactSize, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, invalidParam("exactSize", v)
+		}
+		req.ExactSize = exactSize
+	}
+
+	if v := param("interlace"); v != "" {
+		interlace, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, invalidParam("interlace", v)
+		}
+		req.Interlace = interlace
+		req.Progressive = req.Progressive || interlace
+	}
+
+	ec, err := qrcode.ParseErrorCorrection(param("ec"))
+	if err != nil {
+		return nil, invalidParam("ec", param("ec"))
+	}
+	req.EC = ec
+
+	if req.Charset, err = qrcode.ParseCharset(param("charset")); err != nil {
+		return nil, invalidParam("charset", param("charset"))
+	}
+
+	// the encoder designates legacy charsets by ECI, so that scanners do not read the bytes as utf-8
+	req.ECI = req.Charset != qrcode.CharsetUTF8
+	if v := param("eci"); v != "" {
+		eci, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, invalidParam("eci", v)
+		}
+		if !eci && req.ECI {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "eci=false can not be used with charset "+req.Charset+", it is always designated by ECI")
+		}
+		req.ECI = eci
+	}
+
+	if req.Logo != "" {
+		if err := validateImageURL(req.Logo); err != nil {
+			return nil, invalidParam("logo", req.Logo)
+		}
+		// logo covers modules, only the highest level can recover it reliably
+		req.EC = "H"
+	}
+
+	if req.Halftone = param("halftone"); req.Halftone != "" {
+		if err := validateImageURL(req.Halftone); err != nil {
+			return nil, invalidParam("halftone", req.Halftone)
+		}
+		// dots around the module centers are errors for scanners
+		req.EC = "H"
+	}
+
+	for _, side := range []struct {
+		name  string
+		value *int
+	}{
+		{"marginTop", &req.MarginTop},
+		{"marginRight", &req.MarginRight},
+		{"marginBottom", &req.MarginBottom},
+		{"marginLeft", &req.MarginLeft},
+	} {
+		*side.value = req.Margin
+		if v := param(side.name); v != "" {
+			margin, err := strconv.Atoi(v)
+			if err != nil || margin < 0 || margin > maxMargin {
+				return nil, invalidParam(side.name, v)
+			}
+			*side.value = margin
+		}
+	}
+
+	if req.FG, err = parseColorDef(param("fg"), color.Black); err != nil {
+		return nil, invalidColor("fg", param("fg"), err)
+	}
+
+	if req.BG, err = parseColorDef(param("bg"), color.White); err != nil {
+		return nil, invalidColor("bg", param("bg"), err)
+	}
+
+	if req.PadColor, err = parseColorDef(param("padColor"), req.BG); err != nil {
+		return nil, invalidColor("padColor", param("padColor"), err)
+	}
+
+	if req.EyeOuter, err = parseColorDef(param("eyeOuterColor"), req.FG); err != nil {
+		return nil, invalidColor("eyeOuterColor", param("eyeOuterColor"), err)
+	}
+
+	if req.EyeInner, err = parseColorDef(param("eyeInnerColor"), req.FG); err != nil {
+		return nil, invalidColor("eyeInnerColor", param("eyeInnerColor"), err)
+	}
+
+	if req.Shape, err = qrcode.ParseShape(param("shape")); err != nil {
+		return nil, invalidParam("shape", param("shape"))
+	}
+
+	if req.Mask, err = qrcode.ParseMask(param("mask")); err != nil {
+		return nil, invalidParam("mask", param("mask"))
+	}
+
+	if req.DPR, err = parseDPR(param("dpr")); err != nil {
+		return nil, invalidParam("dpr", param("dpr"))
+	}
+
+	if req.Compression, err = parseCompression(param("compression")); err != nil {
+		return nil, invalidParam("compression", param("compression"))
+	}
+
+	if req.LabelSize, err = qrcode.ParseLabelSize(param("captionSize")); err != nil {
+		return nil, invalidParam("captionSize", param("captionSize"))
+	}
+
+	if len([]rune(req.Label)) > maxLabelLength {
+		return nil, invalidParam("label", "longer than "+strconv.Itoa(maxLabelLength))
+	}
+
+	if v := param("composite"); v != "" {
+		if req.Composite, err = qrcode.ParseCompositeLayout(v); err != nil {
+			return nil, invalidParam("composite", v)
+		}
+
+		// small code of the highest level by default
+		req.CompositeEC = "H"
+		if v := param("compositeEc"); v != "" {
+			if req.CompositeEC, err = qrcode.ParseErrorCorrection(v); err != nil {
+				return nil, invalidParam("compositeEc", v)
+			}
+		}
+
+		req.CompositeSize = parseIntDef(param("compositeSize"), fx.Max([]int{req.W / 2, minSize}), minSize, cfg.MaxSize)
+	}
+
+	if req.Background = param("background"); req.Background != "" {
+		if err := validateImageURL(req.Background); err != nil {
+			return nil, invalidParam("background", req.Background)
+		}
+
+		if req.BackgroundPos, err = parseBackgroundPosition(param("backgroundPos")); err != nil {
+			return nil, invalidParam("backgroundPos", param("backgroundPos"))
+		}
+	}
+
+	return req, nil
+}
+
+// params returns normalized render parameters
+func (req *RenderRequest) params() map[string]string {
+	t := ""
+	if f := formatByName(req.T); f != nil {
+		t = f.name
+	}
+
+	return map[string]string{
+		"w":             strconv.Itoa(req.W),
+		"h":             strconv.Itoa(req.H),
+		"t":             t,
+		"dpr":           strconv.FormatFloat(req.DPR, 'g', -1, 64),
+		"cornerRadius":  strconv.Itoa(req.CornerRadius),
+		"progressive":   strconv.FormatBool(req.Progressive),
+		"exactSize":     strconv.FormatBool(req.ExactSize),
+		"alt":           req.Alt,
+		"ec":            req.EC,
+		"charset":       req.Charset,
+		"eci":           strconv.FormatBool(req.ECI),
+		"fg":            formatColor(req.FG),
+		"bg":            formatColor(req.BG),
+		"padColor":      formatColor(req.PadColor),
+		"eyeOuterColor": formatColor(req.EyeOuter),
+		"eyeInnerColor": formatColor(req.EyeInner),
+		"shape":         req.Shape,
+		"mask":          req.Mask,
+		"margin":        strconv.Itoa(req.Margin),
+		"marginTop":     strconv.Itoa(req.MarginTop),
+		"marginRight":   strconv.Itoa(req.MarginRight),
+		"marginBottom":  strconv.Itoa(req.MarginBottom),
+		"marginLeft":    strconv.Itoa(req.MarginLeft),
+		"label":         req.Label,
+		"captionSize":   strconv.FormatFloat(req.LabelSize, 'g', -1, 64),
+		"compression":   req.Compression,
+		"logo":          req.Logo,
+		"logoAuto":      strconv.FormatBool(req.LogoAuto),
+		"composite":     req.Composite,
+		"compositeEc":   req.CompositeEC,
+		"compositeSize": strconv.Itoa(req.CompositeSize),
+		"background":    req.Background,
+		"backgroundPos": req.BackgroundPos,
+		"halftone":      req.Halftone,
+	}
+}
+
+// parseDPR parse device pixel ratio, ex) `2`, `1.5`, `3x`; 1 if empty
+func parseDPR(s string) (float64, error) {
+	if s == "" {
+		return 1, nil
+	}
+
+	dpr, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(s), "x"), 64)
+	if err != nil {
+		return 0, err
+	}
+	if dpr < 1 || dpr > maxDPR {
+		return 0, fmt.Errorf("dpr must be between 1 and %d", maxDPR)
+	}
+	return dpr, nil
+}
+
+// scale returns pixel ratio of the image; dpr is reduced so that the larger side fits in max_size config
+func (req *RenderRequest) scale() float64 {
+	if req.DPR <= 1 {
+		return 1
+	}
+	return math.Min(req.DPR, float64(req.maxSize)/float64(fx.Max([]int{req.W, req.H})))
+}
+
+// pixels returns device pixels of css pixels
+func (req *RenderRequest) pixels(v int) int { return int(math.Round(float64(v) * req.scale())) }
+
+func parseColorDef(s string, defaultValue color.Color) (color.Color, error) {
+	if s == "" {
+		return defaultValue, nil
+	}
+	return parseColor(s)
+}
+
+// renderParams returns render parameter getter of the request;
+// parameters of `preset` from config are used if not given explicitly.
+func renderParams(cfg *config.Config, c echo.Context) (func(name string) string, error) {
+	query := func(name string) string {
+		switch name {
+		case "t":
+			return formatParam(c)
+		case "dpr":
+			return dprParam(c)
+		}
+		return c.QueryParam(name)
+	}
+
+	name := c.QueryParam("preset")
+	if name == "" {
+		return query, nil
+	}
+
+	presets := cfg.RenderPresets()
+	preset, ok := presets[strings.ToLower(name)]
+	if !ok {
+		names := fx.Keys(presets)
+		sort.Strings(names)
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unknown preset: %s, available: %s", name, strings.Join(names, ", ")))
+	}
+
+	return func(name string) string {
+		if v := query(name); v != "" {
+			return v
+		}
+		// config keys are case insensitive
+		return preset[strings.ToLower(name)]
+	}, nil
+}
+
+// withRenderDefaults returns getter of the parameter, or of the defaults if the parameter is not given
+func withRenderDefaults(param func(name string) string, defaults map[string]string) func(name string) string {
+	if len(defaults) == 0 {
+		return param
+	}
+
+	return func(name string) string {
+		if v := param(name); v != "" {
+			return v
+		}
+		// config keys are case insensitive
+		return defaults[strings.ToLower(name)]
+	}
+}
+
+// validateRenderDefaults returns error if a value of render_defaults config is not valid as the request parameter;
+// sizes, which requests clamp to max_size config, and margin are rejected if out of range.
+func validateRenderDefaults(cfg *config.Config) error {
+	defaults := cfg.RenderDefaults
+	invalid := func(reason string) error { return errors.New("invalid render_defaults: " + reason) }
+
+	for _, v := range []struct {
+		name     string
+		min, max int
+	}{{"w", minSize, cfg.MaxSize}, {"h", minSize, cfg.MaxSize}, {"margin", 0, maxMargin}} {
+		s, ok := defaults[v.name]
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(s); err != nil || n < v.min || n > v.max {
+			return invalid(fmt.Sprintf("%s: %s, must be between %d and %d", v.name, s, v.min, v.max))
+		}
+	}
+
+	if _, err := parseRenderRequest(cfg, func(string) string { return "" }); err != nil {
+		return invalid(errorMessage(err))
+	}
+	return nil
+}
+
+// ctxKeyPathDPR device pixel ratio of the path suffix
+const ctxKeyPathDPR = "qrcodeapi.pathDPR"
+
+var scaleSuffixPattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)x(?:\.([a-z]+))?$`)
+
+// withPathScale set device pixel ratio and format of the path suffix, ex) `/qrcode@2x.png`, `/qrcode@1.5x`
+func withPathScale(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		m := scaleSuffixPattern.FindStringSubmatch(c.Param("scale"))
+		if m == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "unknown suffix: @"+c.Param("scale"))
+		}
+
+		if m[2] != "" {
+			f := formatByName(m[2])
+			if f == nil {
+				return echo.NewHTTPError(http.StatusNotFound, "unknown extension: "+m[2])
+			}
+			c.Set(ctxKeyPathFormat, f.name)
+		}
+
+		c.Set(ctxKeyPathDPR, m[1])
+		return next(c)
+	}
+}
+
+// dprParam returns requested device pixel ratio; `dpr` parameter, then path suffix
+func dprParam(c echo.Context) string {
+	if v := c.QueryParam("dpr"); v != "" {
+		return v
+	}
+
+	v, _ := c.Get(ctxKeyPathDPR).(string)
+	return v
+}
+
+// renderQRCode render qrcode with request parameters
+func renderQRCode(cfg *config.Config, c echo.Context, in *qrcode.QR) error {
+	params, err := renderParams(cfg, c)
+	if err != nil {
+		return err
+	}
+
+	req, err := parseRenderRequest(cfg, params)
+	if err != nil {
+		return err
+	}
+
+	return writeQRCode(cfg, c, in, req)
+}
+
+// writeQRCode render qrcode and write it to the response
+func writeQRCode(cfg *config.Config, c echo.Context, in *qrcode.QR, req *RenderRequest) error {
+	if err := checkPayload(cfg, c, in.Content); err != nil {
+		return err
+	}
+
+	dry, err := dryRun(c)
+	if err != nil {
+		return err
+	}
+	if dry {
+		return writeDryRun(c, in, req)
+	}
+
+	// output format is negotiated by Accept; Accept-Encoding is listed for proxies which compress the response
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept+", "+echo.HeaderAcceptEncoding+", "+echo.HeaderCookie)
+	format := negotiateFormat(cfg, req.T, c.Request().Header.Get(echo.HeaderAccept), cookieFormat(c))
+	c.Set(ctxKeyFormat, format.name)
+
+	if err := checkFormat(cfg, format, req); err != nil {
+		return err
+	}
+
+	mimeType, err := contentType(c, format)
+	if err != nil {
+		return err
+	}
+
+	verify := false
+	if v := c.QueryParam("verify"); v != "" {
+		var err error
+		if verify, err = strconv.ParseBool(v); err != nil {
+			return invalidParam("verify", v)
+		}
+	}
+
+	embed, err := parseEmbedOptions(c, format, req)
+	if err != nil {
+		return err
+	}
+
+	embedText, err := parseEmbedText(c, format, in.Content)
+	if err != nil {
+		return err
+	}
+
+	opts := req.options()
+	if !format.transparent {
+		opts.CornerBackground = color.White
+	}
+
+	// identical concurrent requests share one rendering, keyed by the canonical form of persisted codes;
+	// the key has negotiated format so that requests of different Accept are not mixed up
+	key := renderCacheKey(strings.ToLower(c.QueryParam("preset")), format, verify, codes.Canonical(in.Content, req.params()))
+	cacheSize := cfg.Snapshot().RenderCache
+	cacheable := c.Request().Method == http.MethodGet && cacheSize > 0
+	out, shared := (*renderOutput)(nil), false
+	if cacheable {
+		out, shared = renderCache.Get(key)
+	}
+	if out == nil {
+		var err error
+		out, err, shared = renderFlight.Do(key, func() (*renderOutput, error) {
+			// rendering is shared, so it should not be canceled by the request started it
+			return renderImage(context.Background(), cfg, in, format.withEncoding(req), opts, req.remoteImages(), verify)
+		})
+		if err != nil {
+			return err
+		}
+
+		if cacheable {
+			renderCache.Add(key, out, cacheSize)
+		}
+	}
+
+	// durations are observed once for the shared or cached rendering
+	if !shared {
+		if out.encodeDuration > 0 {
+			c.Set(ctxKeyEncodeDuration, out.encodeDuration)
+		}
+		if out.serializeDuration > 0 {
+			c.Set(ctxKeySerializeDuration, out.serializeDuration)
+		}
+	}
+
+	data := out.data
+	if format.embedsPNG() {
+		body, err := embedImage(format, out.data, embed)
+		if err != nil {
+			return err
+		}
+		data = []byte(body)
+	}
+
+	// chunk is added to the rendered png, so that cached and shared renderings are of the same image
+	if embedText {
+		if data, err = qrcode.EmbedContent(data, in.Content); err != nil {
+			return err
+		}
+	}
+
+	if out.knockout > 0 {
+		c.Response().Header().Set("X-QR-Knockout", fmt.Sprintf("%dx%d", out.knockout, out.knockout))
+	}
+
+	if len(out.adjusted) > 0 {
+		c.Response().Header().Set("X-QR-Adjusted", strings.Join(out.adjusted, ", "))
+	}
+
+	if verify {
+		c.Response().Header().Set("X-QR-Content", base64.StdEncoding.EncodeToString([]byte(out.decoded)))
+	}
+
+	// exact payload encoded after normalization and charset, for clients keeping audit chains
+	payload, err := qrcode.CharsetBytes(in.Content, req.Charset)
+	if err != nil {
+		return renderError(err)
+	}
+	sum := sha256.Sum256(payload)
+	c.Response().Header().Set("X-Content-SHA256", hex.EncodeToString(sum[:]))
+	setSymbolHeaders(c, len(payload), out.symbol)
+
+	return c.Blob(http.StatusOK, mimeType, data)
+}
+
+// setSymbolHeaders set version, error correction level and size of the generated symbol, and length in bytes and kind of the payload;
+// the payload itself is never in headers.
+func setSymbolHeaders(c echo.Context, length int, symbol *qrcode.Symbol) {
+	h := c.Response().Header()
+	h.Set("X-QR-Version", strconv.Itoa(symbol.Version))
+	h.Set("X-QR-EC-Level", symbol.ErrorCorrection)
+	h.Set("X-QR-Modules", strconv.Itoa(symbol.Modules))
+	h.Set("X-QR-Content-Length", strconv.Itoa(length))
+	if kind, _ := c.Get(ctxKeyPayloadKind).(string); kind != "" {
+		h.Set("X-QR-Payload-Kind", kind)
+	}
+}
+
+// DryRunResponse payload and planned symbol of `dryrun=true&response=json`
+type DryRunResponse struct {
+	Payload         string `json:"payload"`
+	Version         int    `json:"version"`
+	ErrorCorrection string `json:"ec"`
+	Mode            string `json:"mode"`
+}
+
+// dryRun returns true if `dryrun=true` is given
+func dryRun(c echo.Context) (bool, error) {
+	v := c.QueryParam("dryrun")
+	if v == "" {
+		return false, nil
+	}
+
+	dry, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, invalidParam("dryrun", v)
+	}
+	return dry, nil
+}
+
+// writeDryRun write the exact payload to be encoded, after escaping and line endings, instead of the image;
+// `response=json` adds version and error correction level of the symbol.
+func writeDryRun(c echo.Context, in *qrcode.QR, req *RenderRequest) error {
+	// payload may have secrets such as wifi password
+	c.Response().Header().Set("Cache-Control", "no-store")
+	if c.QueryParam("response") != "json" {
+		return c.String(http.StatusOK, in.Content)
+	}
+
+	enc, err := in.Encoding(req.options())
+	if err != nil {
+		return renderError(err)
+	}
+
+	return c.JSON(http.StatusOK, &DryRunResponse{
+		Payload:         in.Content,
+		Version:         enc.Version,
+		ErrorCorrection: enc.ErrorCorrection,
+		Mode:            enc.Mode,
+	})
+}
+
+// checkFormat returns error if the request can not be rendered in the format
+func checkFormat(cfg *config.Config, format *imageFormat, req *RenderRequest) error {
+	if !formatEnabled(cfg, format) {
+		return formatNotEnabled(cfg, format)
+	}
+
+	if req.Interlace && format != formatPNG && !format.embedsPNG() {
+		return echo.NewHTTPError(http.StatusBadRequest, "interlace is supported for png only, use progressive for jpeg")
+	}
+
+	if req.Progressive && !format.progressive() {
+		return echo.NewHTTPError(http.StatusBadRequest, "progressive is not supported for "+format.name)
+	}
+
+	if req.Composite != "" && req.ExactSize {
+		return echo.NewHTTPError(http.StatusBadRequest, "exactSize can not be used with composite")
+	}
+
+	if req.Composite != "" && format.render != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "composite is not supported for "+format.name)
+	}
+
+	if req.Background != "" && format.render != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "background is not supported for "+format.name)
+	}
+
+	if req.Halftone != "" && format.render != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "halftone is not supported for "+format.name)
+	}
+	return nil
+}
+
+// options returns render options of the request; remote images are not fetched
+func (req *RenderRequest) options() *qrcode.RenderOptions {
+	opts := &qrcode.RenderOptions{
+		Width:           req.pixels(req.W),
+		Height:          req.pixels(req.H),
+		CornerRadius:    req.pixels(req.CornerRadius),
+		Alt:             req.Alt,
+		ErrorCorrection: req.EC,
+		Charset:         req.Charset,
+		ECI:             req.ECI,
+		Foreground:      req.FG,
+		Background:      req.BG,
+		PadColor:        req.PadColor,
+		EyeOuter:        req.EyeOuter,
+		EyeInner:        req.EyeInner,
+		Shape:           req.Shape,
+		Mask:            req.Mask,
+		Margin:          &req.Margin,
+		MarginTop:       &req.MarginTop,
+		MarginRight:     &req.MarginRight,
+		MarginBottom:    &req.MarginBottom,
+		MarginLeft:      &req.MarginLeft,
+		Label:           req.Label,
+		LabelSize:       req.LabelSize,
+		MaxVersion:      req.maxVersion,
+		LogoAuto:        req.LogoAuto,
+		ExactSize:       req.ExactSize,
+	}
+
+	// label size less than 1 is ratio of the width
+	if req.LabelSize >= 1 {
+		opts.LabelSize = req.LabelSize * req.scale()
+	}
+
+	if req.Composite != "" {
+		size := req.pixels(req.CompositeSize)
+		if size > req.maxSize {
+			size = req.maxSize
+		}
+		opts.Composite = &qrcode.CompositeOptions{Layout: req.Composite, ErrorCorrection: req.CompositeEC, Size: size}
+	}
+
+	// position is in pixels of the background image, not scaled by dpr
+	opts.BackgroundPosition = req.backgroundPosition()
+
+	return opts
+}
+
+// renderCacheKey returns key of rendered image, `<preset>:<format>:<verify>:<canonical form>`;
+// preset is empty if not given. canonical form is url-encoded, so it has no colon.
+func renderCacheKey(preset string, format *imageFormat, verify bool, canonical string) string {
+	return preset + ":" + format.name + ":" + strconv.FormatBool(verify) + ":" + canonical
+}
+
+var (
+	renderFlight flightGroup[*renderOutput]
+	renderCache  lruCache[*renderOutput] // rendered images of GET requests
+)
+
+type renderOutput struct {
+	data              []byte
+	decoded           string   // decoded content of the generated code if verified
+	adjusted          []string // styling reduced to make the code scannable, see scanAdjustments
+	knockout          int      // side of the central knockout in modules
+	symbol            *qrcode.Symbol
+	encodeDuration    time.Duration
+	serializeDuration time.Duration
+}
+
+// renderImage render qrcode in the format; remote images are fetched if given.
+// if verify, the code is decoded and rendered again with reduced styling, up to verify_retries times,
+// while it can not be decoded; 422 if none is scannable.
+func renderImage(ctx context.Context, cfg *config.Config, in *qrcode.QR, format *imageFormat, opts *qrcode.RenderOptions, remote remoteImages, verify bool) (*renderOutput, error) {
+	if err := remote.fetch(ctx, opts); err != nil {
+		return nil, err
+	}
+
+	retries := cfg.Snapshot().VerifyRetries
+	var adjusted []string
+	for {
+		out, err := renderAttempt(in, format, opts, verify)
+		var scanErr *scanError
+		if !errors.As(err, &scanErr) {
+			if out != nil {
+				out.adjusted = adjusted
+			}
+			return out, err
+		}
+
+		adjustment := ""
+		if len(adjusted) < retries {
+			adjustment = adjustForScan(opts)
+		}
+		if adjustment == "" {
+			return nil, unscannable(scanErr, adjusted)
+		}
+		adjusted = append(adjusted, adjustment)
+	}
+}
+
+// renderAttempt render qrcode in the format with the options; scanError if verify and the code can not be decoded
+func renderAttempt(in *qrcode.QR, format *imageFormat, opts *qrcode.RenderOptions, verify bool) (*renderOutput, error) {
+	out := &renderOutput{}
+	var buf bytes.Buffer
+
+	// the symbol of composite is the primary code
+	var err error
+	if out.symbol, err = in.Symbol(opts); err != nil {
+		return nil, renderError(err)
+	}
+
+	if opts.LogoAuto {
+		var err error
+		if out.knockout, err = in.KnockoutModules(opts); err != nil {
+			return nil, renderError(err)
+		}
+	}
+
+	if format.render != nil {
+		start := time.Now()
+		if err := format.render(in, &buf, opts); err != nil {
+			return nil, renderError(err)
+		}
+		out.encodeDuration = time.Since(start)
+		out.data = buf.Bytes()
+
+		if verify {
+			img, err := in.RenderWithOptions(opts)
+			if err != nil {
+				return nil, renderError(err)
+			}
+			if out.decoded, err = decodeGenerated(img); err != nil {
+				return nil, err
+			}
+		}
+
+		return out, nil
+	}
+
+	start := time.Now()
+	img, err := in.RenderWithOptions(opts)
+	if err != nil {
+		return nil, renderError(err)
+	}
+	out.encodeDuration = time.Since(start)
+
+	if verify {
+		if out.decoded, err = decodeGenerated(img); err != nil {
+			return nil, err
+		}
+	}
+
+	start = time.Now()
+	if err := format.encode(&buf, img); err != nil {
+		return nil, err
+	}
+	out.serializeDuration = time.Since(start)
+	out.data = buf.Bytes()
+
+	return out, nil
+}
+
+// renderError returns bad request error if the content is not acceptable
+func renderError(err error) error {
+	if errors.Is(err, qrcode.ErrContentTooLong) {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"content is too long: does not fit in the largest version, shorten the content, lower ec or use a url shortener")
+	}
+
+	var versionErr *qrcode.VersionError
+	if errors.As(err, &versionErr) {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("content is too long: requires version %d but up to %d is allowed, shorten the content or use a url shortener",
+				versionErr.Version, versionErr.MaxVersion))
+	}
+
+	var charsetErr *qrcode.CharsetError
+	if errors.As(err, &charsetErr) {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "content can not be encoded: "+charsetErr.Error())
+	}
+
+	var backgroundErr *qrcode.BackgroundError
+	if errors.As(err, &backgroundErr) {
+		return echo.NewHTTPError(http.StatusBadRequest, backgroundErr.Error())
+	}
+
+	var sizeErr *qrcode.SizeError
+	if errors.As(err, &sizeErr) {
+		return echo.NewHTTPError(http.StatusBadRequest, sizeErr.Error()+", increase w, h or remove the label")
+	}
+
+	var halftoneErr *qrcode.HalftoneError
+	if errors.As(err, &halftoneErr) {
+		return echo.NewHTTPError(http.StatusBadRequest, halftoneErr.Error())
+	}
+	return err
+}
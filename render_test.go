@@ -0,0 +1,759 @@
+package qrcodeapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+	"qrcodeapi/pkg/qrcode"
+)
+
+func TestPreset(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+presets:
+  brand-dark:
+    fg: "#ffffff"
+    bg: "#112233"
+    shape: dot
+  brand-light:
+    fg: "#112233"
+    bg: "#eeeeee"
+    cornerRadius: 20
+`), 0o644))
+
+	viper.Set("config_file", configFile)
+	defer viper.Set("config_file", "")
+	_, err := config.Reload(config.Current())
+	require.NoError(t, err)
+	defer viper.Set("presets", map[string]interface{}{})
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	// for "hello", 21 modules are scaled by 6 and padded 37 pixels in 200x200 image
+	tests := [...]struct {
+		name       string
+		query      string
+		wantStatus int
+		wantFG     color.Color
+		wantBG     color.Color
+		wantCorner color.Color
+	}{
+		{"dark", "preset=brand-dark", http.StatusOK, color.RGBA{0xff, 0xff, 0xff, 0xff}, color.RGBA{0x11, 0x22, 0x33, 0xff}, color.RGBA{0x11, 0x22, 0x33, 0xff}},
+		{"light", "preset=brand-light", http.StatusOK, color.RGBA{0x11, 0x22, 0x33, 0xff}, color.RGBA{0xee, 0xee, 0xee, 0xff}, color.RGBA{}},
+		{"case insensitive", "preset=Brand-Dark", http.StatusOK, color.RGBA{0xff, 0xff, 0xff, 0xff}, color.RGBA{0x11, 0x22, 0x33, 0xff}, color.RGBA{0x11, 0x22, 0x33, 0xff}},
+		{"override", "preset=brand-dark&fg=ff0000", http.StatusOK, color.RGBA{0xff, 0x00, 0x00, 0xff}, color.RGBA{0x11, 0x22, 0x33, 0xff}, color.RGBA{0x11, 0x22, 0x33, 0xff}},
+		{"unknown", "preset=unknown", http.StatusBadRequest, nil, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/qrcode?content=hello&%s", ts.URL, tt.query).Do(ctx)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			defer resp.Body.Close()
+			if tt.wantStatus != http.StatusOK {
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				require.Contains(t, string(body), "available: brand-dark, brand-light")
+				return
+			}
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantCorner, color.RGBAModel.Convert(img.At(0, 0)))
+			require.Equal(t, tt.wantBG, color.RGBAModel.Convert(img.At(20, 20)))
+			require.Equal(t, tt.wantFG, color.RGBAModel.Convert(img.At(40, 40)))
+		})
+	}
+}
+
+func TestRenderDefaults(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	viper.Set("render_defaults", map[string]string{"w": "150", "h": "150", "ec": "H", "margin": "2", "bg": "#ffffcc"})
+	defer viper.Set("render_defaults", map[string]string{})
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name     string
+		req      *request.Request
+		wantSize int
+		wantEC   string
+		wantBG   color.Color
+	}{
+		{"omitted", request.Get("%s/qrcode?content=hello", ts.URL), 150, "H", color.RGBA{0xff, 0xff, 0xcc, 0xff}},
+		{"explicit", request.Get("%s/qrcode?content=hello&w=100&h=100&ec=L&bg=white", ts.URL), 100, "L", color.RGBA{0xff, 0xff, 0xff, 0xff}},
+		{"wifi", request.Get("%s/wifi?ssid=MySSID&auth=WPA&pass=secret", ts.URL), 150, "H", color.RGBA{0xff, 0xff, 0xcc, 0xff}},
+		{"document", request.Post("%s/qrcode", ts.URL).JSON(map[string]string{"content": "hello", "ec": "M"}), 150, "M", color.RGBA{0xff, 0xff, 0xcc, 0xff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.req.Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, tt.wantEC, resp.Header.Get("X-QR-EC-Level"))
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, image.Rect(0, 0, tt.wantSize, tt.wantSize), img.Bounds())
+			require.Equal(t, tt.wantBG, color.RGBAModel.Convert(img.At(0, 0)))
+		})
+	}
+}
+
+func TestValidateRenderDefaults(t *testing.T) {
+	tests := [...]struct {
+		name     string
+		maxSize  int
+		defaults map[string]string
+		wantErr  string
+	}{
+		{"empty", 200, nil, ""},
+		{"print", 1200, map[string]string{"w": "600", "h": "600", "ec": "H", "margin": "4", "fg": "navy", "padcolor": "#eeeeee"}, ""},
+		{"too large", 200, map[string]string{"w": "600"}, "invalid render_defaults: w: 600, must be between 21 and 200"},
+		{"larger than max size", 1200, map[string]string{"h": "1600"}, "invalid render_defaults: h: 1600, must be between 21 and 1200"},
+		{"margin", 200, map[string]string{"margin": "wide"}, "invalid render_defaults: margin: wide"},
+		{"ec", 200, map[string]string{"ec": "X"}, "invalid render_defaults: invalid ec: X"},
+		{"color", 200, map[string]string{"eyeoutercolor": "bluish"}, "invalid render_defaults: eyeOuterColor: invalid color: bluish"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Current()
+			cfg.MaxSize = tt.maxSize
+			cfg.RenderDefaults = tt.defaults
+			err := validateRenderDefaults(cfg)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name        string
+		query       string
+		wantStatus  int
+		wantContent string
+	}{
+		{"png", "content=hello+world&verify=true", http.StatusOK, "hello world"},
+		{"svg", "content=hello+world&verify=true&t=svg", http.StatusOK, "hello world"},
+		{"unicode", "content=%ED%95%9C%EA%B8%80&verify=true", http.StatusOK, "한글"},
+		{"url", "url=github.com&verify=true", http.StatusOK, "URLTO:github.com"},
+		{"not requested", "content=hello", http.StatusOK, ""},
+		{"invalid", "content=hello&verify=maybe", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/qrcode?%s", ts.URL, tt.query).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			header := resp.Header.Get("X-QR-Content")
+			if tt.wantContent == "" {
+				require.Empty(t, header)
+				return
+			}
+
+			decoded, err := base64.StdEncoding.DecodeString(header)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantContent, string(decoded))
+		})
+	}
+}
+
+func TestVerifyRetry(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	cfg := config.Current()
+	ts := newTestServer(ctx, newAPIv1(cfg, codes.NewMemoryStore(), nil))
+
+	// white on white is unscannable until the colors are reset
+	tests := [...]struct {
+		name         string
+		query        string
+		retries      int
+		wantStatus   int
+		wantAdjusted string
+	}{
+		{"scannable", "verify=true&shape=dot", 3, http.StatusOK, ""},
+		{"inverted", "verify=true&fg=ffffff&bg=112233", 3, http.StatusOK, ""},
+		{"no contrast", "verify=true&fg=ffffff&shape=dot", 3, http.StatusOK, "ec=H, shape=square, colors=default"},
+		{"ec given", "verify=true&fg=ffffff&ec=H", 3, http.StatusOK, "colors=default"},
+		{"not verified", "fg=ffffff", 3, http.StatusOK, ""},
+		{"retries exhausted", "verify=true&fg=ffffff&shape=dot", 2, http.StatusUnprocessableEntity, "ec=H, shape=square"},
+		{"no retries", "verify=true&fg=ffffff", 0, http.StatusUnprocessableEntity, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.VerifyRetries = tt.retries
+
+			resp, err := request.Get("%s/qrcode?content=hello&%s", ts.URL, tt.query).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			if tt.wantStatus != http.StatusOK {
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				require.Contains(t, string(body), "generated code can not be decoded")
+				if tt.wantAdjusted != "" {
+					require.Contains(t, string(body), "also with "+tt.wantAdjusted)
+				}
+				return
+			}
+			require.Equal(t, tt.wantAdjusted, resp.Header.Get("X-QR-Adjusted"))
+
+			if tt.wantAdjusted != "" {
+				img, _, err := image.Decode(resp.Body)
+				require.NoError(t, err)
+				content, err := qrcode.Decode(img)
+				require.NoError(t, err)
+				require.Equal(t, "hello", content)
+			}
+		})
+	}
+}
+
+func TestCharset(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name        string
+		params      map[string]string
+		wantStatus  int
+		wantMessage string
+	}{
+		{"shift_jis", map[string]string{"content": "QRコード 123", "charset": "Shift_JIS"}, http.StatusOK, ""},
+		{"euc-kr eci", map[string]string{"content": "안녕 QR", "charset": "euc-kr", "eci": "true"}, http.StatusOK, ""},
+		{"utf-8 eci", map[string]string{"content": "QRコード 123", "eci": "true"}, http.StatusOK, ""},
+		{"unrepresentable", map[string]string{"content": "QR 한글", "charset": "shift_jis"}, http.StatusUnprocessableEntity, `content can not be encoded: '한' at 3 can not be encoded in shift_jis`},
+		{"invalid", map[string]string{"content": "hello", "charset": "utf-16"}, http.StatusBadRequest, "invalid charset: utf-16"},
+		{"eci off", map[string]string{"content": "hello", "charset": "shift_jis", "eci": "false"}, http.StatusBadRequest, "eci=false can not be used with charset shift_jis"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request.Get("%s/qrcode", ts.URL)
+			for k, v := range tt.params {
+				req = req.Query(k, v)
+			}
+			resp, err := req.Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			if tt.wantStatus != http.StatusOK {
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				require.Contains(t, string(body), tt.wantMessage)
+				return
+			}
+
+			payload, err := qrcode.CharsetBytes(tt.params["content"], strings.ToLower(tt.params["charset"]))
+			require.NoError(t, err)
+			require.Equal(t, strconv.Itoa(len(payload)), resp.Header.Get("X-QR-Content-Length"))
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			content, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, tt.params["content"], content)
+		})
+	}
+}
+
+func TestContentSHA256(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name        string
+		path        string
+		query       string
+		wantStatus  int
+		wantPayload string
+	}{
+		{"text", "/qrcode", "content=hello+world", http.StatusOK, "hello world"},
+		{"unicode", "/qrcode", "content=%ED%95%9C%EA%B8%80&t=svg", http.StatusOK, "한글"},
+		{"url", "/qrcode", "url=github.com", http.StatusOK, "URLTO:github.com"},
+		{"trimmed", "/auto", "content=+https%3A%2F%2Fexample.com+", http.StatusOK, "URLTO:https://example.com"},
+		{"wifi", "/wifi", "ssid=MySSID&auth=WPA&pass=secret", http.StatusOK, "WIFI:S:MySSID;T:WPA;P:secret;H:false;;"},
+		{"invalid", "/qrcode", "content=hello&ec=X", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s%s?%s", ts.URL, tt.path, tt.query).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			header := resp.Header.Get("X-Content-SHA256")
+			if tt.wantPayload == "" {
+				require.Empty(t, header)
+				return
+			}
+
+			sum := sha256.Sum256([]byte(tt.wantPayload))
+			require.Equal(t, hex.EncodeToString(sum[:]), header)
+		})
+	}
+}
+
+func TestSymbolHeaders(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	large := strings.Repeat("a", 1000)
+	tests := [...]struct {
+		name       string
+		path       string
+		query      string
+		wantStatus int
+		want       map[string]string
+	}{
+		{"small", "/qrcode", "content=hello", http.StatusOK, map[string]string{
+			"X-QR-Version": "1", "X-QR-EC-Level": "L", "X-QR-Modules": "21", "X-QR-Content-Length": "5", "X-QR-Payload-Kind": "text",
+		}},
+		{"large", "/qrcode", "content=" + large + "&ec=M&w=200", http.StatusOK, map[string]string{
+			"X-QR-Version": "26", "X-QR-EC-Level": "M", "X-QR-Modules": "121", "X-QR-Content-Length": "1000", "X-QR-Payload-Kind": "text",
+		}},
+		{"svg", "/qrcode", "content=" + large + "&ec=M&t=svg", http.StatusOK, map[string]string{
+			"X-QR-Version": "26", "X-QR-EC-Level": "M", "X-QR-Modules": "121", "X-QR-Content-Length": "1000", "X-QR-Payload-Kind": "text",
+		}},
+		{"url", "/qrcode", "url=github.com&t=txt", http.StatusOK, map[string]string{
+			"X-QR-Version": "1", "X-QR-EC-Level": "L", "X-QR-Modules": "21", "X-QR-Content-Length": "16", "X-QR-Payload-Kind": "url",
+		}},
+		{"wifi", "/wifi", "ssid=MySSID&auth=WPA&pass=secret&ec=H", http.StatusOK, map[string]string{
+			"X-QR-Version": "5", "X-QR-EC-Level": "H", "X-QR-Modules": "37", "X-QR-Content-Length": "38", "X-QR-Payload-Kind": "wifi",
+		}},
+		{"invalid", "/qrcode", "content=hello&ec=X", http.StatusBadRequest, nil},
+		{"too long", "/qrcode", "content=" + strings.Repeat("a", 3000) + "&ec=H", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s%s?%s", ts.URL, tt.path, tt.query).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			for _, h := range []string{"X-QR-Version", "X-QR-EC-Level", "X-QR-Modules", "X-QR-Content-Length", "X-QR-Payload-Kind"} {
+				require.Equal(t, tt.want[h], resp.Header.Get(h), h)
+			}
+		})
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	vevent := "BEGIN:VEVENT\nSUMMARY:Summer Vacation\nDTSTART:20260701T090000Z\nEND:VEVENT"
+	tests := [...]struct {
+		name  string
+		query string
+		req   func(query string) *request.Request
+	}{
+		{"wifi", "ssid=My%3BSSID&auth=WPA&pass=se%3Acr%5Cet&hidden=true", func(query string) *request.Request {
+			return request.Get("%s/wifi?%s", ts.URL, query)
+		}},
+		{"contact", "name[first]=Gildong&name[last]=Hong&tel=010-1234-5678&email=gildong@example.com", func(query string) *request.Request {
+			return request.Get("%s/contact?%s", ts.URL, query)
+		}},
+		{"event", "", func(query string) *request.Request {
+			return request.Post("%s/vevent?%s", ts.URL, query).ContentType(mimeVEvent).Body(strings.NewReader(vevent))
+		}},
+		{"persist", "content=hello&persist=true", func(query string) *request.Request {
+			return request.Get("%s/qrcode?%s", ts.URL, query)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.req(tt.query + "&dryrun=true").Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, "text/plain; charset=UTF-8", resp.Header.Get(request.HeaderContentType))
+			require.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+			require.Empty(t, resp.Header.Get("X-QR-ID"), "dry run is not persisted")
+			preview, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			resp, err = tt.req(tt.query + "&dryrun=true&response=json").Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			got := &DryRunResponse{}
+			require.NoError(t, resp.JSON(got))
+			require.Equal(t, string(preview), got.Payload)
+			require.Equal(t, "L", got.ErrorCorrection)
+			require.Greater(t, got.Version, 0)
+
+			resp, err = tt.req(tt.query).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			decoded, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, decoded, string(preview))
+		})
+	}
+
+	resp, err := request.Get("%s/qrcode?content=hello&dryrun=maybe", ts.URL).Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestEyeColor(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	// for "hello", 21 modules are scaled by 6 and padded 37 pixels in 200x200 image
+	tests := [...]struct {
+		name       string
+		query      string
+		wantStatus int
+		wantOuter  color.Color
+		wantInner  color.Color
+	}{
+		{"default", "", http.StatusOK, color.RGBA{0x00, 0x00, 0x00, 0xff}, color.RGBA{0x00, 0x00, 0x00, 0xff}},
+		{"fg", "&fg=112233", http.StatusOK, color.RGBA{0x11, 0x22, 0x33, 0xff}, color.RGBA{0x11, 0x22, 0x33, 0xff}},
+		{"eyes", "&eyeOuterColor=cc0000&eyeInnerColor=006600", http.StatusOK, color.RGBA{0xcc, 0x00, 0x00, 0xff}, color.RGBA{0x00, 0x66, 0x00, 0xff}},
+		{"invalid", "&eyeInnerColor=greenish", http.StatusBadRequest, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/qrcode?content=hello%s", ts.URL, tt.query).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if !resp.Success() {
+				return
+			}
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantOuter, color.RGBAModel.Convert(img.At(37+3, 37+3)))
+			require.Equal(t, tt.wantInner, color.RGBAModel.Convert(img.At(37+3*6+3, 37+3*6+3)))
+
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, "hello", got)
+		})
+	}
+}
+
+func TestPadColor(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	// for "hello", 29 modules with the quiet zone are scaled by 3 and centered at 56, 6 in 200x100 image
+	bg, pad := color.RGBA{0xff, 0xff, 0xcc, 0xff}, color.RGBA{0x00, 0x33, 0x66, 0xff}
+	tests := [...]struct {
+		name       string
+		query      string
+		wantStatus int
+		wantQuiet  color.Color
+		wantPad    color.Color
+	}{
+		{"default", "&bg=ffffcc", http.StatusOK, bg, bg},
+		{"pad", "&bg=ffffcc&padColor=003366", http.StatusOK, bg, pad},
+		{"gif", "&padColor=ff0000&t=gif", http.StatusOK, color.RGBA{0xff, 0xff, 0xff, 0xff}, color.RGBA{0xff, 0x00, 0x00, 0xff}}, // colors of the palette
+		{"invalid", "&padColor=bluish", http.StatusBadRequest, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/qrcode?content=hello&w=200&h=100%s", ts.URL, tt.query).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if !resp.Success() {
+				return
+			}
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, image.Rect(0, 0, 200, 100), img.Bounds())
+			for _, p := range []image.Point{{56, 6}, {60, 50}, {142, 92}} {
+				require.Equal(t, tt.wantQuiet, color.RGBAModel.Convert(img.At(p.X, p.Y)), "quiet zone at %v", p)
+			}
+			for _, p := range []image.Point{{0, 0}, {55, 50}, {143, 50}, {199, 99}} {
+				require.Equal(t, tt.wantPad, color.RGBAModel.Convert(img.At(p.X, p.Y)), "letterbox at %v", p)
+			}
+
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, "hello", got)
+		})
+	}
+}
+
+func TestCaptionSize(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	// caption wraps to 3 lines in default size of 200 pixels width
+	caption := "scan this code to join the conference wifi network"
+	tests := [...]struct {
+		name       string
+		query      string
+		wantStatus int
+		wantHeight int
+	}{
+		{"default", "", http.StatusOK, 256},
+		{"pixels", "&captionSize=20", http.StatusOK, 304},
+		{"ratio", "&captionSize=0.05", http.StatusOK, 232},
+		{"too large", "&captionSize=100", http.StatusBadRequest, 0},
+		{"invalid", "&captionSize=large", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/qrcode?content=hello%s", ts.URL, tt.query).
+				Query("label", caption).
+				Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if !resp.Success() {
+				return
+			}
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, 200, img.Bounds().Dx())
+			require.Equal(t, tt.wantHeight, img.Bounds().Dy())
+		})
+	}
+}
+
+func TestComposite(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name       string
+		query      string
+		wantStatus int
+		wantSize   image.Point
+		wantBounds [2]image.Rectangle // bounds of the large and the small code
+	}{
+		{"horizontal", "&composite=horizontal", http.StatusOK, image.Pt(300, 200),
+			[2]image.Rectangle{image.Rect(0, 0, 200, 200), image.Rect(200, 50, 300, 150)}},
+		{"vertical", "&composite=vertical&compositeEc=Q&compositeSize=80", http.StatusOK, image.Pt(200, 280),
+			[2]image.Rectangle{image.Rect(0, 0, 200, 200), image.Rect(60, 200, 140, 280)}},
+		{"invalid layout", "&composite=diagonal", http.StatusBadRequest, image.Point{}, [2]image.Rectangle{}},
+		{"invalid ec", "&composite=vertical&compositeEc=X", http.StatusBadRequest, image.Point{}, [2]image.Rectangle{}},
+		{"svg", "&composite=vertical&t=svg", http.StatusBadRequest, image.Point{}, [2]image.Rectangle{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/qrcode?content=hello%s", ts.URL, tt.query).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if !resp.Success() {
+				return
+			}
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantSize, img.Bounds().Size())
+
+			for _, bounds := range tt.wantBounds {
+				sub := image.NewRGBA(bounds)
+				draw.Draw(sub, bounds, img, bounds.Min, draw.Src)
+				got, err := qrcode.Decode(sub)
+				require.NoError(t, err)
+				require.Equal(t, "hello", got)
+			}
+		})
+	}
+}
+
+func TestDPR(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name       string
+		path       string
+		query      string
+		wantStatus int
+		wantSize   image.Point
+	}{
+		{"default", "/qrcode", "w=80&h=60", http.StatusOK, image.Pt(80, 60)},
+		{"dpr 1", "/qrcode", "w=80&h=60&dpr=1", http.StatusOK, image.Pt(80, 60)},
+		{"dpr 2", "/qrcode", "w=80&h=60&dpr=2", http.StatusOK, image.Pt(160, 120)},
+		{"dpr 1.5", "/qrcode", "w=80&h=60&dpr=1.5", http.StatusOK, image.Pt(120, 90)},
+		{"x suffix", "/qrcode", "w=80&h=60&dpr=2x", http.StatusOK, image.Pt(160, 120)},
+		{"capped", "/qrcode", "w=150&h=120&dpr=2", http.StatusOK, image.Pt(200, 160)},
+		{"capped at max size", "/qrcode", "dpr=3", http.StatusOK, image.Pt(200, 200)},
+		{"path suffix", "/qrcode@2x", "w=80&h=60", http.StatusOK, image.Pt(160, 120)},
+		{"path suffix with extension", "/qrcode@1.5x.png", "w=80&h=60", http.StatusOK, image.Pt(120, 90)},
+		{"parameter over path suffix", "/qrcode@2x", "w=80&h=60&dpr=1", http.StatusOK, image.Pt(80, 60)},
+		{"less than 1", "/qrcode", "dpr=0.5", http.StatusBadRequest, image.Point{}},
+		{"too large", "/qrcode", "dpr=5", http.StatusBadRequest, image.Point{}},
+		{"invalid", "/qrcode", "dpr=retina", http.StatusBadRequest, image.Point{}},
+		{"invalid path suffix", "/qrcode@retina", "", http.StatusNotFound, image.Point{}},
+		{"unknown extension", "/qrcode@2x.bmp", "", http.StatusNotFound, image.Point{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s%s?content=hello&%s", ts.URL, tt.path, tt.query).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if !resp.Success() {
+				return
+			}
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantSize, img.Bounds().Size())
+
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			require.Equal(t, "hello", got)
+		})
+	}
+}
+
+func TestDPRParams(t *testing.T) {
+	params := func(dpr string) map[string]string {
+		req, err := parseRenderRequest(config.Current(), func(name string) string {
+			return map[string]string{"w": "80", "h": "80", "dpr": dpr}[name]
+		})
+		require.NoError(t, err)
+		return req.params()
+	}
+
+	// cache key and code id differ by dpr
+	require.Equal(t, "1", params("")["dpr"])
+	require.Equal(t, params(""), params("1"))
+	require.Equal(t, "1.5", params("1.5")["dpr"])
+	require.NotEqual(t, codes.Canonical("hello", params("1")), codes.Canonical("hello", params("2")))
+}
+
+func TestMargins(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+
+	tests := [...]struct {
+		name       string
+		params     map[string]string
+		wantStatus int
+		wantSize   image.Point
+	}{
+		{"uniform", map[string]string{"margin": "2"}, http.StatusOK, image.Pt(25, 25)},
+		{"sides", map[string]string{"margin": "2", "marginLeft": "10", "marginRight": "0"}, http.StatusOK, image.Pt(31, 25)},
+		{"top bottom", map[string]string{"marginTop": "8", "marginBottom": "1"}, http.StatusOK, image.Pt(29, 30)},
+		{"negative", map[string]string{"marginTop": "-1"}, http.StatusBadRequest, image.Point{}},
+		{"too large", map[string]string{"marginRight": "17"}, http.StatusBadRequest, image.Point{}},
+		{"not number", map[string]string{"marginLeft": "wide"}, http.StatusBadRequest, image.Point{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the smallest size, so that the output grows by the quiet zone
+			req := request.Get("%s/qrcode", ts.URL).Query("content", "hello").Query("w", "21").Query("h", "21")
+			for k, v := range tt.params {
+				req = req.Query(k, v)
+			}
+			resp, err := req.Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantSize, img.Bounds().Size())
+		})
+	}
+}
+
+func TestMask(t *testing.T) {
+	tests := [...]struct {
+		mask    string
+		want    string
+		wantErr bool
+	}{
+		{"", "auto", false},
+		{"auto-center", "auto-center", false},
+		{"3", "3", false},
+		{"9", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mask, func(t *testing.T) {
+			req, err := parseRenderRequest(config.Current(), func(name string) string {
+				return map[string]string{"mask": tt.mask}[name]
+			})
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, req.params()["mask"])
+			require.Equal(t, tt.want, req.options().Mask)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package qrcodeapi
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"qrcodeapi/pkg/qrcode"
+)
+
+// scanAdjustment reduction of styling to make unscannable code scannable; apply returns false if it changes nothing
+type scanAdjustment struct {
+	name  string // reported in X-QR-Adjusted header
+	apply func(opts *qrcode.RenderOptions) bool
+}
+
+// scanAdjustments applied in order, one for each retry, until the code is decoded
+var scanAdjustments = []scanAdjustment{
+	{"ec=H", func(opts *qrcode.RenderOptions) bool {
+		if strings.EqualFold(opts.ErrorCorrection, "H") {
+			return false
+		}
+		opts.ErrorCorrection = "H"
+		return true
+	}},
+	{"halftone=none", func(opts *qrcode.RenderOptions) bool {
+		if opts.Halftone == nil {
+			return false
+		}
+		opts.Halftone = nil
+		return true
+	}},
+	{"shape=square", func(opts *qrcode.RenderOptions) bool {
+		if opts.Shape == "" || opts.Shape == qrcode.ShapeSquare {
+			return false
+		}
+		opts.Shape = qrcode.ShapeSquare
+		return true
+	}},
+	{"colors=default", func(opts *qrcode.RenderOptions) bool {
+		if defaultColors(opts.Foreground, opts.Background, opts.EyeOuter, opts.EyeInner) {
+			return false
+		}
+		opts.Foreground, opts.Background, opts.EyeOuter, opts.EyeInner = color.Black, color.White, nil, nil
+		return true
+	}},
+	{"logo=none", func(opts *qrcode.RenderOptions) bool {
+		if opts.Logo == nil && !opts.LogoAuto {
+			return false
+		}
+		opts.Logo, opts.LogoAuto = nil, false
+		return true
+	}},
+}
+
+// adjustForScan apply the first adjustment which changes the options; adjustments already applied change nothing.
+// returns the name of the adjustment, empty if nothing is left to reduce.
+func adjustForScan(opts *qrcode.RenderOptions) string {
+	for _, adj := range scanAdjustments {
+		if adj.apply(opts) {
+			return adj.name
+		}
+	}
+	return ""
+}
+
+// defaultColors returns true if foreground and background are black and white and eyes are of the foreground
+func defaultColors(fg, bg, eyeOuter, eyeInner color.Color) bool {
+	return sameColor(fg, color.Black) && sameColor(bg, color.White) &&
+		(eyeOuter == nil || sameColor(eyeOuter, fg)) && (eyeInner == nil || sameColor(eyeInner, fg))
+}
+
+func sameColor(a, b color.Color) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
+
+// scanError the generated code can not be decoded
+type scanError struct {
+	err error
+}
+
+func (e *scanError) Error() string { return "generated code can not be decoded: " + e.err.Error() }
+
+// unscannable returns 422 error of the code which can not be decoded after the adjustments
+func unscannable(err *scanError, adjusted []string) error {
+	message := err.Error()
+	if len(adjusted) > 0 {
+		message += ", also with " + strings.Join(adjusted, ", ")
+	}
+	return echo.NewHTTPError(http.StatusUnprocessableEntity, message)
+}
+
+// decodeGenerated decode the generated code on white, as printed, to verify it is scannable;
+// light on dark code of dark themes is decoded inverted as most scanners read it.
+func decodeGenerated(img image.Image) (string, error) {
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	content, err := qrcode.Decode(flat)
+	if err == nil {
+		return content, nil
+	}
+
+	for i := 0; i < len(flat.Pix); i += 4 {
+		flat.Pix[i], flat.Pix[i+1], flat.Pix[i+2] = 0xff-flat.Pix[i], 0xff-flat.Pix[i+1], 0xff-flat.Pix[i+2]
+	}
+	if content, invertedErr := qrcode.Decode(flat); invertedErr == nil {
+		return content, nil
+	}
+	return "", &scanError{err: err}
+}
@@ -0,0 +1,130 @@
+package qrcodeapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/qrcode"
+)
+
+// selftestPath is exempt from rate limiting so that monitoring can probe it frequently
+const selftestPath = "/selftest"
+
+// selftestCase rendering of the self test
+type selftestCase struct {
+	format *imageFormat
+	size   int
+}
+
+// selftestCases are raster formats of small and default size; cheap enough to run every few seconds
+var selftestCases = []selftestCase{
+	{formatPNG, 100},
+	{formatPNG, defaultSize},
+	{formatJPEG, defaultSize},
+	{formatGIF, defaultSize},
+}
+
+// selftestAPI probes encode, serialize and decode pipeline
+type selftestAPI struct {
+	cfg   *config.Config
+	cases []selftestCase
+}
+
+var _ router = (*selftestAPI)(nil)
+
+func newSelftestAPI(cfg *config.Config) router { return &selftestAPI{cfg: cfg, cases: selftestCases} }
+
+func (api *selftestAPI) Route(e *echo.Echo, path string) {
+	e.GET(path+selftestPath, api.handleSelftest, requireAPIKeyIfConfigured(api.cfg))
+}
+
+type SelftestResponse struct {
+	Passed   bool             `json:"passed"`
+	Duration float64          `json:"duration_ms"`
+	Cases    []SelftestResult `json:"cases"`
+}
+
+type SelftestResult struct {
+	Format   string  `json:"format"`
+	Size     int     `json:"size"`
+	Passed   bool    `json:"passed"`
+	Duration float64 `json:"duration_ms"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// handleSelftest render random nonce in each case and decode it; returns 500 if any case is failed
+func (api *selftestAPI) handleSelftest(c echo.Context) error {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	nonce := "selftest-" + hex.EncodeToString(b)
+
+	start := time.Now()
+	resp := &SelftestResponse{Passed: true, Cases: make([]SelftestResult, len(api.cases))}
+	for i, tc := range api.cases {
+		caseStart := time.Now()
+		err := runSelftest(c.Request().Context(), api.cfg, tc, nonce)
+
+		resp.Cases[i] = SelftestResult{
+			Format:   tc.format.name,
+			Size:     tc.size,
+			Passed:   err == nil,
+			Duration: milliseconds(time.Since(caseStart)),
+		}
+		if err != nil {
+			resp.Passed = false
+			resp.Cases[i].Error = err.Error()
+			c.Logger().Errorf("selftest %s %d: %s", tc.format.name, tc.size, err)
+		}
+	}
+	resp.Duration = milliseconds(time.Since(start))
+
+	status := http.StatusOK
+	if !resp.Passed {
+		status = http.StatusInternalServerError
+	}
+	return c.JSON(status, resp)
+}
+
+// runSelftest render the nonce in the case and decode the encoded image
+func runSelftest(ctx context.Context, cfg *config.Config, tc selftestCase, nonce string) error {
+	qr, err := qrcode.Text(nonce)
+	if err != nil {
+		return err
+	}
+
+	out, err := renderImage(ctx, cfg, qr, tc.format, &qrcode.RenderOptions{
+		Width:      tc.size,
+		Height:     tc.size,
+		MaxVersion: cfg.MaxVersion,
+	}, remoteImages{}, false)
+	if err != nil {
+		return err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(out.data))
+	if err != nil {
+		return fmt.Errorf("decode image: %w", err)
+	}
+
+	got, err := qrcode.Decode(img)
+	if err != nil {
+		return fmt.Errorf("decode qrcode: %w", err)
+	}
+	if got != nonce {
+		return fmt.Errorf("decoded content mismatch: %q", got)
+	}
+	return nil
+}
+
+func milliseconds(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
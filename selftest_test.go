@@ -0,0 +1,106 @@
+package qrcodeapi
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+)
+
+func TestSelftest(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// faulty encoders; blank is a valid png without the code
+	blank := &imageFormat{name: "blank", mimeType: "image/png", encode: func(w io.Writer, img image.Image) error {
+		return png.Encode(w, image.NewGray(img.Bounds()))
+	}}
+	broken := &imageFormat{name: "broken", mimeType: "image/png", encode: func(w io.Writer, img image.Image) error {
+		return errors.New("encoder is broken")
+	}}
+
+	tests := [...]struct {
+		name       string
+		cases      []selftestCase
+		wantStatus int
+		wantPassed []bool
+		wantErrors []string
+	}{
+		{"default", selftestCases, http.StatusOK, []bool{true, true, true, true}, []string{"", "", "", ""}},
+		{"no code", []selftestCase{{formatPNG, 100}, {blank, 100}}, http.StatusInternalServerError,
+			[]bool{true, false}, []string{"", "decode qrcode: NotFoundException"}},
+		{"encoder error", []selftestCase{{broken, 100}}, http.StatusInternalServerError,
+			[]bool{false}, []string{"encoder is broken"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(ctx, &selftestAPI{cfg: config.Current(), cases: tt.cases})
+
+			resp, err := request.Get("%s/selftest", ts.URL).Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			got := &SelftestResponse{}
+			require.NoError(t, resp.JSON(got))
+			require.Equal(t, tt.wantStatus == http.StatusOK, got.Passed)
+			require.Greater(t, got.Duration, 0.0)
+			require.Len(t, got.Cases, len(tt.cases))
+			for i, c := range got.Cases {
+				require.Equal(t, tt.cases[i].format.name, c.Format)
+				require.Equal(t, tt.cases[i].size, c.Size)
+				require.Equal(t, tt.wantPassed[i], c.Passed)
+				require.Contains(t, c.Error, tt.wantErrors[i])
+				if tt.wantErrors[i] == "" {
+					require.Empty(t, c.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestSelftestAccess(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	viper.Set("rate_limit", 1)
+	defer viper.Set("rate_limit", 20)
+
+	cfg := config.Current()
+	ts := newTestServer(ctx, newAPIv1(cfg, nil, nil), newSelftestAPI(cfg))
+
+	get := func(path string, key string) int {
+		req := request.Get("%s%s", ts.URL, path).Query("content", "hello")
+		if key != "" {
+			req = req.Header(echo.HeaderAuthorization, "Bearer "+key)
+		}
+		resp, err := req.Do(ctx)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// not rate limited
+	require.Equal(t, http.StatusOK, get("/qrcode", ""))
+	require.Equal(t, http.StatusTooManyRequests, get("/qrcode", ""))
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusOK, get("/selftest", ""))
+	}
+
+	// api key is required if configured
+	cfg.APIKeys = []string{"secret"}
+	require.Equal(t, http.StatusUnauthorized, get("/selftest", ""))
+	require.Equal(t, http.StatusUnauthorized, get("/selftest", "wrong"))
+	require.Equal(t, http.StatusOK, get("/selftest", "secret"))
+}
@@ -0,0 +1,216 @@
+package qrcodeapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/links"
+	"qrcodeapi/pkg/qrcode"
+)
+
+// shorten modes
+const (
+	shortenAuto   = "auto" // shorten the url only if it does not fit to the requested image
+	shortenAlways = "true" // shorten the url for scannability at small print sizes; the url is encoded if shortener fails
+)
+
+// renderURL render qrcode of the url; with `shorten=auto`, url which does not fit is shortened
+// and with `shorten=true` every url is, see shortenURL.
+func (api *APIv1) renderURL(c echo.Context, target string) error {
+	prefix, err := urlPayloadPrefix(c.QueryParam("payload"), api.cfg.URLPayload)
+	if err != nil {
+		return err
+	}
+
+	qr, err := qrcode.Text(prefix + target)
+	if err != nil {
+		return err
+	}
+
+	shorten := c.QueryParam("shorten")
+	if shorten == "" {
+		return api.render(c, qr)
+	}
+
+	if shorten != shortenAuto && shorten != shortenAlways {
+		return invalidParam("shorten", shorten)
+	}
+
+	params, err := renderParams(api.cfg, c)
+	if err != nil {
+		return err
+	}
+
+	req, err := parseRenderRequest(api.cfg, params)
+	if err != nil {
+		return err
+	}
+
+	fit := false
+	if shorten == shortenAuto {
+		if fit, err = fits(qr, req); err != nil {
+			return renderError(err)
+		}
+	}
+
+	if !fit {
+		short, err := api.shortenURL(c, target, shorten)
+		if err != nil {
+			return err
+		}
+
+		// short url is encoded in the payload format of the target
+		if short != target {
+			if qr, err = qrcode.Text(prefix + short); err != nil {
+				return err
+			}
+		}
+	}
+
+	return api.renderRequest(c, qr, req)
+}
+
+// fits returns true if the code is allowed by max_version and every module is at least one pixel in the image;
+// the quiet zone of each side, and the label strip of exactSize which is inside of the height, are laid out as the renderer does.
+func fits(qr *qrcode.QR, req *RenderRequest) (bool, error) {
+	modules, err := qr.Modules(&qrcode.RenderOptions{ErrorCorrection: req.EC, MaxVersion: req.maxVersion})
+	if err != nil {
+		var versionErr *qrcode.VersionError
+		if errors.As(err, &versionErr) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	opts := req.options()
+	width, height := opts.Width, opts.Height
+	if opts.ExactSize {
+		height -= qrcode.LabelHeight(opts.Label, width, opts.LabelSize)
+	}
+
+	return modules+req.MarginLeft+req.MarginRight <= width && modules+req.MarginTop+req.MarginBottom <= height, nil
+}
+
+// shortenURL returns short url of the target by the shortener of config, returned in X-QR-Short-URL header
+// with the target in X-QR-Original-URL. with `shorten=true`, failure of the shortener is returned in X-QR-Warning header
+// and the target is returned to be encoded as is.
+func (api *APIv1) shortenURL(c echo.Context, target string, mode string) (string, error) {
+	// the target is checked before it is hidden behind the short url
+	if err := checkTarget(api.cfg, c, target); err != nil {
+		return "", err
+	}
+
+	var short string
+	var err error
+	switch api.cfg.Shortener {
+	case shortenerHTTP:
+		if short, err = httpShorten(c.Request().Context(), api.cfg, target); err != nil {
+			err = echo.NewHTTPError(http.StatusBadGateway, "shortener failed: "+err.Error())
+		}
+	default:
+		var link *links.Link
+		if link, err = api.shorten(c, target, mode); err == nil {
+			c.Response().Header().Set("X-Link-ID", link.ID)
+			short = redirectURL(api.cfg, c, link.ID)
+		}
+	}
+
+	if err != nil {
+		if mode != shortenAlways {
+			return "", err
+		}
+
+		c.Logger().Warnf("shorten %s: %s", redactPayload(target), err)
+		c.Response().Header().Set("X-QR-Warning", "shortener failed, the url is encoded as is: "+errorMessage(err))
+		return target, nil
+	}
+
+	c.Response().Header().Set("X-QR-Original-URL", target)
+	c.Response().Header().Set("X-QR-Short-URL", short)
+	return short, nil
+}
+
+// errorMessage returns message of http error, or the error
+func errorMessage(err error) string {
+	if he, ok := err.(*echo.HTTPError); ok {
+		return fmt.Sprintf("%v", he.Message)
+	}
+	return err.Error()
+}
+
+// shorten store the url as link; the same url is shortened to the same link
+func (api *APIv1) shorten(c echo.Context, target string, mode string) (*links.Link, error) {
+	if api.links == nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "shorten requires links to be enabled")
+	}
+
+	if err := validateLinkTarget(api.cfg, target); err != nil {
+		return nil, err
+	}
+
+	link := links.Shortened(target)
+	err := api.links.Create(c.Request().Context(), link)
+	switch {
+	case err == nil:
+		audit(c, "create", "link/"+link.ID, "target=%s shorten=%s", link.Target, mode)
+		return link, nil
+
+	case errors.Is(err, links.ErrExists):
+		existing, err := api.links.Get(c.Request().Context(), link.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		// shortened link can be changed with links api
+		if existing.Target != target || !existing.Active() {
+			return nil, echo.NewHTTPError(http.StatusConflict, "shortened link of the url is changed: "+link.ID)
+		}
+		return existing, nil
+	}
+
+	return nil, err
+}
+
+const shortenerHTTP = "http"
+
+// shortenerClient client for the shortener of config; the endpoint is not given by users, so remoteClient is not required
+var shortenerClient = &http.Client{Timeout: 5 * time.Second}
+
+// httpShorten shorten the target with generic POST-JSON shortener; `{"<request field>": "<target>"}` is posted to
+// shortener_url and the short url is read from the response field
+func httpShorten(ctx context.Context, cfg *config.Config, target string) (string, error) {
+	req := request.Post(cfg.ShortenerURL).
+		JSON(map[string]string{cfg.ShortenerRequestField: target}).
+		WithClient(shortenerClient)
+	if token := cfg.ShortenerToken; token != "" {
+		req = req.Header(echo.HeaderAuthorization, "Bearer "+token)
+	}
+
+	resp, err := req.Do(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if !resp.Success() {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	body := map[string]interface{}{}
+	if err := resp.JSON(&body); err != nil {
+		return "", err
+	}
+
+	short, _ := body[cfg.ShortenerResponseField].(string)
+	if err := validateURL(short, []string{"http", "https"}); err != nil {
+		return "", fmt.Errorf("invalid short url: %q", short)
+	}
+	return short, nil
+}
@@ -0,0 +1,243 @@
+package qrcodeapi
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/codes"
+	"qrcodeapi/pkg/links"
+	"qrcodeapi/pkg/qrcode"
+)
+
+func TestShorten(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	store := links.NewMemoryStore()
+	ts := newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), store), newLinksAPI(ctx, config.Current(), store))
+
+	longURL := "https://example.com/" + strings.Repeat("a", 1500)
+	tests := [...]struct {
+		name       string
+		url        string
+		shorten    string
+		w          string
+		margins    map[string]string // quiet zone of sides
+		wantStatus int
+		wantShort  bool
+	}{
+		{"short url", "https://example.com", "auto", "100", nil, http.StatusOK, false},
+		{"long url", longURL, "auto", "100", nil, http.StatusOK, true},
+		{"long url again", longURL, "auto", "100", nil, http.StatusOK, true},
+		{"fits to large image", "https://example.com/" + strings.Repeat("a", 100), "auto", "200", nil, http.StatusOK, false},
+		{"too small image", "https://example.com/" + strings.Repeat("a", 100), "auto", "40", nil, http.StatusOK, true},
+		{"not shortened", longURL, "", "100", nil, http.StatusOK, false},
+		{"invalid mode", longURL, "always", "100", nil, http.StatusBadRequest, false},
+		// 29 modules of the url fit in 40 pixels with the default quiet zone of 4 modules
+		{"fits with margin", "https://example.com/" + strings.Repeat("a", 20), "auto", "40", nil, http.StatusOK, false},
+		{"wide left margin", "https://example.com/" + strings.Repeat("a", 20), "auto", "40", map[string]string{"marginLeft": "16"}, http.StatusOK, true},
+		{"wide top margin", "https://example.com/" + strings.Repeat("a", 20), "auto", "40", map[string]string{"marginTop": "16"}, http.StatusOK, true},
+		{"narrow sides of wide margin", "https://example.com/" + strings.Repeat("a", 20), "auto", "40",
+			map[string]string{"margin": "16", "marginLeft": "2", "marginRight": "2", "marginTop": "2", "marginBottom": "2"}, http.StatusOK, false},
+		{"invalid target", "ftp://example.com/" + strings.Repeat("a", 1500), "auto", "100", nil, http.StatusBadRequest, false},
+	}
+	ids := map[string]string{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := request.Get("%s/qrcode", ts.URL).
+				Query("url", tt.url).
+				Query("shorten", tt.shorten).
+				Query("w", tt.w).Query("h", tt.w).
+				Queries(tt.margins).
+				Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			id := resp.Header.Get("X-Link-ID")
+			if !tt.wantShort {
+				require.Empty(t, id)
+				return
+			}
+
+			// the same url is shortened to the same link
+			if want, ok := ids[tt.url]; ok {
+				require.Equal(t, want, id)
+			}
+			ids[tt.url] = id
+
+			img, _, err := image.Decode(resp.Body)
+			require.NoError(t, err)
+			got, err := qrcode.Decode(img)
+			require.NoError(t, err)
+			// url_payload config is urlto by default
+			require.Equal(t, "URLTO:"+ts.URL+"/r/"+id, got)
+
+			resp, err = request.Get(ts.URL + "/r/" + id).FollowRedirect(false).Do(ctx)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusFound, resp.StatusCode)
+			require.Equal(t, tt.url, resp.Header.Get("Location"))
+		})
+	}
+
+	// links are required to shorten
+	ts = newTestServer(ctx, newAPIv1(config.Current(), codes.NewMemoryStore(), nil))
+	resp, err := request.Get("%s/qrcode", ts.URL).Query("url", longURL).Query("shorten", "auto").Query("w", "100").Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestFits(t *testing.T) {
+	// 29 modules
+	qr, err := qrcode.Text("URLTO:https://example.com/" + strings.Repeat("a", 20))
+	require.NoError(t, err)
+
+	tests := [...]struct {
+		name   string
+		params map[string]string
+		want   bool
+	}{
+		{"default margin", map[string]string{"w": "40", "h": "40"}, true},
+		{"left and right", map[string]string{"w": "40", "h": "40", "marginLeft": "8", "marginRight": "4"}, false},
+		{"top and bottom", map[string]string{"w": "40", "h": "40", "marginTop": "0", "marginBottom": "12"}, false},
+		{"sides of wide margin", map[string]string{"w": "40", "h": "40", "margin": "16", "marginLeft": "0", "marginRight": "0", "marginTop": "0", "marginBottom": "0"}, true},
+		{"dpr", map[string]string{"w": "21", "h": "21", "dpr": "2"}, true},
+		// label strip is under the code, or inside of the height with exactSize
+		{"label", map[string]string{"w": "40", "h": "40", "label": "asset", "captionSize": "12"}, true},
+		{"label of exact size", map[string]string{"w": "40", "h": "40", "label": "asset", "captionSize": "12", "exactSize": "true"}, false},
+		{"exact size", map[string]string{"w": "40", "h": "40", "exactSize": "true"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := parseRenderRequest(config.Current(), func(name string) string { return tt.params[name] })
+			require.NoError(t, err)
+			got, err := fits(qr, req)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestShortenAlways(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var (
+		calls   int32
+		failing int32
+	)
+	shortener := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&failing) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		require.Equal(t, "Bearer shortener-token", r.Header.Get(echo.HeaderAuthorization))
+
+		body := map[string]string{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.NotEmpty(t, body["long_url"])
+		json.NewEncoder(w).Encode(map[string]string{"link": "https://sho.rt/abc"})
+	}))
+	defer shortener.Close()
+
+	store := links.NewMemoryStore()
+	cfg := config.Current()
+	ts := newTestServer(ctx, newAPIv1(cfg, codes.NewMemoryStore(), store), newLinksAPI(ctx, cfg, store))
+
+	decode := func(resp *request.Response) string {
+		img, _, err := image.Decode(resp.Body)
+		require.NoError(t, err)
+		got, err := qrcode.Decode(img)
+		require.NoError(t, err)
+		return got
+	}
+
+	target := "https://example.com/" + strings.Repeat("a", 100)
+
+	// built-in shortener of dynamic links
+	resp, err := request.Get("%s/url", ts.URL).Query("target", target).Query("shorten", "true").Do(ctx)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	id := resp.Header.Get("X-Link-ID")
+	require.NotEmpty(t, id)
+	require.Equal(t, ts.URL+"/r/"+id, resp.Header.Get("X-QR-Short-URL"))
+	require.Equal(t, target, resp.Header.Get("X-QR-Original-URL"))
+	require.Equal(t, ts.URL+"/r/"+id, decode(resp))
+
+	cfg.Shortener = "http"
+	cfg.ShortenerURL = shortener.URL
+	cfg.ShortenerToken = "shortener-token"
+	cfg.ShortenerRequestField = "long_url"
+	cfg.ShortenerResponseField = "link"
+	cfg.URLBlocklist = []string{".phish.example"}
+	cfg.URLPayload = urlPayloadURLTO
+
+	tests := [...]struct {
+		name        string
+		path        string
+		params      map[string]string
+		failing     bool
+		wantStatus  int
+		wantContent string
+		wantShort   string
+		wantWarning bool
+	}{
+		{"url", "/url", map[string]string{"target": target, "shorten": "true"}, false, http.StatusOK, "https://sho.rt/abc", "https://sho.rt/abc", false},
+		{"url urlto", "/url", map[string]string{"target": target, "shorten": "true", "payload": "urlto"}, false, http.StatusOK, "URLTO:https://sho.rt/abc", "https://sho.rt/abc", false},
+		// short url is encoded in the format of url_payload config, or payload parameter, as the target is
+		{"qrcode url", "/qrcode", map[string]string{"url": target, "shorten": "true"}, false, http.StatusOK, "URLTO:https://sho.rt/abc", "https://sho.rt/abc", false},
+		{"qrcode url auto", "/qrcode", map[string]string{"url": target, "shorten": "auto", "w": "30", "h": "30"}, false, http.StatusOK, "URLTO:https://sho.rt/abc", "https://sho.rt/abc", false},
+		{"qrcode url raw", "/qrcode", map[string]string{"url": target, "shorten": "true", "payload": "raw"}, false, http.StatusOK, "https://sho.rt/abc", "https://sho.rt/abc", false},
+		{"not shortened", "/url", map[string]string{"target": target, "shorten": "false"}, false, http.StatusOK, target, "", false},
+		{"fallback", "/url", map[string]string{"target": target, "shorten": "true"}, true, http.StatusOK, target, "", true},
+		{"qrcode url fallback", "/qrcode", map[string]string{"url": target, "shorten": "true"}, true, http.StatusOK, "URLTO:" + target, "", true},
+		{"auto does not fall back", "/qrcode", map[string]string{"url": target, "shorten": "auto", "w": "30", "h": "30"}, true, http.StatusBadGateway, "", "", false},
+		{"blocked before shortened", "/url", map[string]string{"target": "https://phish.example/", "shorten": "true"}, false, http.StatusUnavailableForLegalReasons, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&failing, map[bool]int32{false: 0, true: 1}[tt.failing])
+			before := atomic.LoadInt32(&calls)
+
+			req := request.Get("%s%s", ts.URL, tt.path)
+			for k, v := range tt.params {
+				req = req.Query(k, v)
+			}
+			resp, err := req.Do(ctx)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantStatus == http.StatusUnavailableForLegalReasons {
+				require.Equal(t, before, atomic.LoadInt32(&calls), "blocked url is not sent to the shortener")
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			require.Equal(t, tt.wantContent, decode(resp))
+			require.Equal(t, tt.wantShort, resp.Header.Get("X-QR-Short-URL"))
+			require.Empty(t, resp.Header.Get("X-Link-ID"))
+			if tt.wantShort != "" {
+				require.Equal(t, target, resp.Header.Get("X-QR-Original-URL"))
+			}
+			require.Equal(t, tt.wantWarning, resp.Header.Get("X-QR-Warning") != "")
+		})
+	}
+}
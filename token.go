@@ -0,0 +1,104 @@
+package qrcodeapi
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/qrcode"
+	"qrcodeapi/pkg/token"
+)
+
+// tokenAPI issues qrcode of signed expiring token, such as entry pass of an event
+type tokenAPI struct {
+	cfg    *config.Config
+	signer *token.Signer
+}
+
+var _ router = (*tokenAPI)(nil)
+
+func newTokenAPI(cfg *config.Config, signer *token.Signer) router {
+	return &tokenAPI{cfg: cfg, signer: signer}
+}
+
+func (api *tokenAPI) Route(e *echo.Echo, path string) {
+	e.POST(path+"/token", api.handleIssue, requireAPIKey(api.cfg))
+	e.POST(path+"/token/verify", api.handleVerify)
+}
+
+type IssueTokenRequest struct {
+	Claims    map[string]interface{} `json:"claims" sensitive:"true"`
+	ExpiresAt time.Time              `json:"expires_at"`
+	ExpiresIn string                 `json:"expires_in"` // duration such as `24h`, instead of expires_at
+}
+
+// handleIssue sign the claims and returns qrcode of the token
+func (api *tokenAPI) handleIssue(c echo.Context) error {
+	req := &IssueTokenRequest{}
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	expiresAt := req.ExpiresAt
+	if req.ExpiresIn != "" {
+		if !expiresAt.IsZero() {
+			return echo.NewHTTPError(http.StatusBadRequest, "only one of expires_at or expires_in is allowed")
+		}
+
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			return invalidParam("expires_in", req.ExpiresIn)
+		}
+		expiresAt = time.Now().Add(d)
+	}
+
+	if !expiresAt.After(time.Now()) {
+		return echo.NewHTTPError(http.StatusBadRequest, "expires_at or expires_in is required and must be in the future")
+	}
+
+	signed, err := api.signer.Sign(req.Claims, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	qr, err := qrcode.Text(signed)
+	if err != nil {
+		return err
+	}
+
+	c.Set(ctxKeyPayloadKind, kindToken)
+	return renderQRCode(api.cfg, c, qr)
+}
+
+type VerifyTokenRequest struct {
+	Token string `json:"token" validate:"required" sensitive:"true"` // the token is a credential
+}
+
+type VerifyTokenResponse struct {
+	Claims map[string]interface{} `json:"claims"`
+}
+
+// handleVerify validate signature and expiry of the scanned token; 401 if the token is not valid
+func (api *tokenAPI) handleVerify(c echo.Context) error {
+	req := &VerifyTokenRequest{}
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	claims, err := api.signer.Verify(req.Token)
+	if err != nil {
+		if errors.Is(err, token.ErrExpired) || errors.Is(err, token.ErrInvalid) {
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, &VerifyTokenResponse{Claims: claims})
+}
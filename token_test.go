@@ -0,0 +1,105 @@
+package qrcodeapi
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/qrcode"
+	"qrcodeapi/pkg/token"
+)
+
+func TestToken(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	viper.Set("api_keys", []string{"secret"})
+	defer viper.Set("api_keys", []string{})
+
+	signer, err := token.NewSigner(token.AlgHS256, "signing-key")
+	require.NoError(t, err)
+	ts := newTestServer(ctx, newTokenAPI(config.Current(), signer))
+
+	issue := func(body map[string]interface{}) (*request.Response, error) {
+		return request.Post("%s/token", ts.URL).
+			Header(echo.HeaderAuthorization, "Bearer secret").
+			JSON(body).
+			Do(ctx)
+	}
+	verify := func(signed string) (*request.Response, error) {
+		return request.Post("%s/token/verify", ts.URL).
+			JSON(map[string]string{"token": signed}).
+			Do(ctx)
+	}
+
+	resp, err := issue(map[string]interface{}{
+		"claims":     map[string]string{"event": "conf", "seat": "A-12"},
+		"expires_in": "1h",
+	})
+	require.NoError(t, err)
+	require.Truef(t, resp.Success(), "failed with %d", resp.StatusCode)
+
+	defer resp.Body.Close()
+	img, _, err := image.Decode(resp.Body)
+	require.NoError(t, err)
+	signed, err := qrcode.Decode(img)
+	require.NoError(t, err)
+
+	// verify scanned token
+	resp, err = verify(signed)
+	require.NoError(t, err)
+	require.Truef(t, resp.Success(), "failed with %d", resp.StatusCode)
+	defer resp.Body.Close()
+	got := &VerifyTokenResponse{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(got))
+	require.Equal(t, "conf", got.Claims["event"])
+	require.Equal(t, "A-12", got.Claims["seat"])
+
+	// expired
+	expired, err := signer.Sign(map[string]interface{}{"event": "conf"}, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	resp, err = verify(expired)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// tampered signature
+	i := len(signed) - 10
+	resp, err = verify(signed[:i] + map[bool]string{true: "B", false: "A"}[signed[i] == 'A'] + signed[i+1:])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	tests := [...]struct {
+		name       string
+		body       map[string]interface{}
+		wantStatus int
+	}{
+		{"expires_at", map[string]interface{}{"expires_at": time.Now().Add(time.Hour)}, http.StatusOK},
+		{"no expiry", map[string]interface{}{"claims": map[string]string{"event": "conf"}}, http.StatusBadRequest},
+		{"past", map[string]interface{}{"expires_at": time.Now().Add(-time.Hour)}, http.StatusBadRequest},
+		{"invalid duration", map[string]interface{}{"expires_in": "tomorrow"}, http.StatusBadRequest},
+		{"both", map[string]interface{}{"expires_at": time.Now().Add(time.Hour), "expires_in": "1h"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := issue(tt.body)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+		})
+	}
+
+	// issuing requires api key
+	resp, err = request.Post("%s/token", ts.URL).
+		JSON(map[string]interface{}{"expires_in": "1h"}).
+		Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
@@ -0,0 +1,153 @@
+package qrcodeapi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emersion/go-vcard"
+	"github.com/labstack/echo/v4"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/qrcode"
+)
+
+// decodeVCards returns cards of the body, such as exported address book
+func decodeVCards(body io.Reader) ([]vcard.Card, error) {
+	var cards []vcard.Card
+	dec := vcard.NewDecoder(body)
+	for {
+		card, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		cards = append(cards, card)
+	}
+
+	if len(cards) == 0 {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "no vcard")
+	}
+	return cards, nil
+}
+
+// vcardSelector selects cards of multi-card body; only one of them can be given
+type vcardSelector struct {
+	index *int
+	uid   string
+	all   bool
+}
+
+func parseVCardSelector(c echo.Context) (*vcardSelector, error) {
+	sel := &vcardSelector{uid: c.QueryParam("uid")}
+
+	if v := c.QueryParam("index"); v != "" {
+		index, err := strconv.Atoi(v)
+		if err != nil || index < 0 {
+			return nil, invalidParam("index", v)
+		}
+		sel.index = &index
+	}
+
+	if v := c.QueryParam("all"); v != "" {
+		all, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, invalidParam("all", v)
+		}
+		sel.all = all
+	}
+
+	given := 0
+	for _, ok := range []bool{sel.index != nil, sel.uid != "", sel.all} {
+		if ok {
+			given++
+		}
+	}
+	if given > 1 {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "only one of index, uid or all can be given")
+	}
+
+	return sel, nil
+}
+
+// pick returns the selected card; the only card is selected without selector
+func (sel *vcardSelector) pick(cards []vcard.Card) (vcard.Card, error) {
+	switch {
+	case sel.index != nil:
+		if *sel.index >= len(cards) {
+			return nil, invalidParam("index", fmt.Sprintf("%d, %d cards", *sel.index, len(cards)))
+		}
+		return cards[*sel.index], nil
+
+	case sel.uid != "":
+		for _, card := range cards {
+			if card.Value(vcard.FieldUID) == sel.uid {
+				return card, nil
+			}
+		}
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "uid: not found: "+sel.uid)
+
+	case len(cards) > 1:
+		return nil, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("%d cards, select one with index or uid, or all=true", len(cards)))
+	}
+
+	return cards[0], nil
+}
+
+// renderVCardArchive render code of each card in the format of the request as zip entry named from FN;
+// an invalid card fails the whole request as batch archive does.
+func renderVCardArchive(cfg *config.Config, c echo.Context, cards []vcard.Card) error {
+	if len(cards) > maxBatchItems {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%d cards, up to %d cards", len(cards), maxBatchItems))
+	}
+
+	params, err := renderParams(cfg, c)
+	if err != nil {
+		return err
+	}
+
+	req, err := parseRenderRequest(cfg, params)
+	if err != nil {
+		return err
+	}
+
+	if err := checkPixelTotal(cfg, len(cards)*req.outputPixels()); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	w := newZipArchive(&buf, time.Now())
+	names := map[string]int{}
+	for i, card := range cards {
+		qr, err := qrcode.VCard(card)
+		if err != nil {
+			return batchItemError(i, echo.NewHTTPError(http.StatusBadRequest, err.Error()))
+		}
+
+		format, data, err := renderFile(cfg, c, qr, req, kindVCard)
+		if err != nil {
+			return batchItemError(i, err)
+		}
+
+		if err := w.add(uniqueName(names, contactFileName(card, i), "."+archiveExtension(format)), data); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Blob(http.StatusOK, batchArchives["zip"].mimeType, buf.Bytes())
+}
+
+// contactFileName returns file name of the card from FN without extension, ex) `Kim_Minsu` of `Kim/Minsu`;
+// index of the card is used if FN is empty.
+func contactFileName(card vcard.Card, i int) string {
+	return safeFileName(card.Value(vcard.FieldFormattedName), i)
+}
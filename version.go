@@ -0,0 +1,93 @@
+package qrcodeapi
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/labstack/echo/v4"
+
+	"qrcodeapi/config"
+	"qrcodeapi/pkg/qrcode"
+)
+
+// version build version, set by `-ldflags "-X qrcodeapi.version=<version>"`; module version of build info if empty
+var version string
+
+// encoderModule qrcode encoder library
+const encoderModule = "github.com/makiuchi-d/gozxing"
+
+// versionAPI serves build version and enabled features for support
+type versionAPI struct {
+	cfg *config.Config
+}
+
+var _ router = (*versionAPI)(nil)
+
+func newVersionAPI(cfg *config.Config) router { return &versionAPI{cfg: cfg} }
+
+func (api *versionAPI) Route(e *echo.Echo, path string) {
+	e.GET(path+"/version", api.handleVersion)
+}
+
+type VersionResponse struct {
+	Version   string   `json:"version"`
+	Revision  string   `json:"revision,omitempty"` // vcs revision of the build
+	GoVersion string   `json:"go_version"`
+	Encoder   Module   `json:"encoder"`
+	Features  Features `json:"features"`
+}
+
+type Module struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+}
+
+type Features struct {
+	Symbologies      []string `json:"symbologies"`
+	Formats          []string `json:"formats"`
+	Payloads         []string `json:"payloads"`
+	Shapes           []string `json:"shapes"`
+	ErrorCorrections []string `json:"error_corrections"`
+	Links            bool     `json:"links"`
+	Codes            bool     `json:"codes"`
+	Token            bool     `json:"token"`
+}
+
+func (api *versionAPI) handleVersion(c echo.Context) error {
+	resp := &VersionResponse{
+		Version:   version,
+		GoVersion: runtime.Version(),
+		Encoder:   Module{Path: encoderModule, Version: "unknown"},
+		Features: Features{
+			Symbologies:      []string{"qrcode"},
+			Formats:          enabledFormatNames(api.cfg),
+			Payloads:         payloadKinds,
+			Shapes:           qrcode.Shapes,
+			ErrorCorrections: qrcode.ErrorCorrections,
+			Links:            api.cfg.LinksEnabled,
+			Codes:            api.cfg.CodesEnabled,
+			Token:            api.cfg.TokenKey != "",
+		},
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if resp.Version == "" {
+			resp.Version = info.Main.Version
+		}
+
+		for _, dep := range info.Deps {
+			if dep.Path == encoderModule {
+				resp.Encoder.Version = dep.Version
+			}
+		}
+
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				resp.Revision = setting.Value
+			}
+		}
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
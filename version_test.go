@@ -0,0 +1,44 @@
+package qrcodeapi
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/whitekid/goxp/request"
+
+	"qrcodeapi/config"
+)
+
+func TestVersion(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	cfg := config.Current()
+	cfg.CodesEnabled = false
+	ts := newTestServer(ctx, newVersionAPI(cfg))
+
+	resp, err := request.Get("%s/version", ts.URL).Do(ctx)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	defer resp.Body.Close()
+
+	got := &VersionResponse{}
+	require.NoError(t, resp.JSON(got))
+	require.NotEmpty(t, got.Version)
+	require.Equal(t, runtime.Version(), got.GoVersion)
+	require.Equal(t, encoderModule, got.Encoder.Path)
+	require.Equal(t, "v0.1.1", got.Encoder.Version)
+
+	require.Equal(t, []string{"qrcode"}, got.Features.Symbologies)
+	require.Equal(t, formatNames(), got.Features.Formats)
+	require.Equal(t, payloadKinds, got.Features.Payloads)
+	require.Equal(t, []string{"square", "dot"}, got.Features.Shapes)
+	require.Equal(t, []string{"L", "M", "Q", "H"}, got.Features.ErrorCorrections)
+	require.True(t, got.Features.Links)
+	require.False(t, got.Features.Codes)
+	require.False(t, got.Features.Token)
+}